@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"kbase-catalog/internal/config"
+	"kbase-catalog/internal/processor"
+)
+
+// autoTuner measures the throughput at increasing concurrency, implemented by
+// processor.CatalogProcessor
+type autoTuner interface {
+	AutoTune(ctx context.Context, maxConcurrency int, budget time.Duration) (int, []processor.TuneMeasurement, error)
+}
+
+// runAutoTune calibrates the parallel requests within budget, prints the measurements to out
+// and sets parallel_requests of cfg to the fastest concurrency. The configured value is kept
+// when there were too few images to measure. The concurrency tried is bounded so the parallel
+// catalogs stay within config.MaxInFlightRequests.
+func runAutoTune(ctx context.Context, cfg *config.Config, tuner autoTuner, budget time.Duration, out io.Writer) error {
+	fmt.Fprintln(out, "Auto-tuning parallel requests...")
+	maxConcurrency := min(processor.AutoTuneMaxConcurrency, config.MaxInFlightRequests/cfg.GetParallelCatalogs())
+	concurrency, measurements, err := tuner.AutoTune(ctx, maxConcurrency, budget)
+	for _, m := range measurements {
+		fmt.Fprintf(out, "  %2d parallel requests: %d images in %s, %.2f images/s\n",
+			m.Concurrency, m.Images, m.Duration.Round(time.Millisecond), m.Throughput())
+	}
+	if err != nil {
+		return err
+	}
+
+	if concurrency == 0 {
+		fmt.Fprintf(out, "Too few images to auto-tune, keeping %d parallel requests\n", cfg.ParallelRequests)
+		return nil
+	}
+	cfg.ParallelRequests = concurrency
+	fmt.Fprintf(out, "Using %d parallel requests\n", cfg.ParallelRequests)
+	return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"kbase-catalog/internal/config"
+	"kbase-catalog/internal/processor"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fixedTuner returns the same calibration result on every call
+type fixedTuner struct {
+	measurements []processor.TuneMeasurement
+	err          error
+	// maxConcurrency is the maximum concurrency of the last call
+	maxConcurrency int
+}
+
+// AutoTune returns the fastest of the measurements up to maxConcurrency, like the processor
+func (f *fixedTuner) AutoTune(ctx context.Context, maxConcurrency int, budget time.Duration) (int, []processor.TuneMeasurement, error) {
+	f.maxConcurrency = maxConcurrency
+	var measurements []processor.TuneMeasurement
+	for _, m := range f.measurements {
+		if m.Concurrency <= maxConcurrency {
+			measurements = append(measurements, m)
+		}
+	}
+	return processor.SelectConcurrency(measurements), measurements, f.err
+}
+
+func TestRunAutoTune(t *testing.T) {
+	t.Run("Sets the fastest concurrency", func(t *testing.T) {
+		cfg := &config.Config{ParallelRequests: 3}
+		tuner := &fixedTuner{measurements: []processor.TuneMeasurement{
+			{Concurrency: 1, Images: 2, Duration: 2 * time.Second},
+			{Concurrency: 2, Images: 4, Duration: 2 * time.Second},
+		}}
+
+		var out bytes.Buffer
+		assert.NoError(t, runAutoTune(context.Background(), cfg, tuner, time.Minute, &out))
+		assert.Equal(t, 2, cfg.ParallelRequests)
+		assert.Contains(t, out.String(), " 2 parallel requests: 4 images in 2s, 2.00 images/s")
+		assert.Contains(t, out.String(), "Using 2 parallel requests")
+	})
+
+	t.Run("Stays within the in-flight requests of parallel catalogs", func(t *testing.T) {
+		cfg := &config.Config{ParallelRequests: 2, ParallelCatalogs: 8}
+		tuner := &fixedTuner{measurements: []processor.TuneMeasurement{
+			{Concurrency: 8, Images: 16, Duration: 2 * time.Second},
+			{Concurrency: 16, Images: 32, Duration: 2 * time.Second},
+		}}
+
+		assert.NoError(t, runAutoTune(context.Background(), cfg, tuner, time.Minute, &bytes.Buffer{}))
+		assert.Equal(t, 8, tuner.maxConcurrency)
+		assert.Equal(t, 8, cfg.ParallelRequests)
+		assert.LessOrEqual(t, cfg.GetInFlightRequests(), config.MaxInFlightRequests)
+	})
+
+	t.Run("Keeps the configured concurrency without measurements", func(t *testing.T) {
+		cfg := &config.Config{ParallelRequests: 3}
+
+		var out bytes.Buffer
+		assert.NoError(t, runAutoTune(context.Background(), cfg, &fixedTuner{}, time.Minute, &out))
+		assert.Equal(t, 3, cfg.ParallelRequests)
+		assert.Contains(t, out.String(), "keeping 3 parallel requests")
+	})
+
+	t.Run("Returns the calibration error", func(t *testing.T) {
+		cfg := &config.Config{ParallelRequests: 3}
+		tuner := &fixedTuner{err: errors.New("disk full")}
+
+		assert.Error(t, runAutoTune(context.Background(), cfg, tuner, time.Minute, &bytes.Buffer{}))
+		assert.Equal(t, 3, cfg.ParallelRequests)
+	})
+}
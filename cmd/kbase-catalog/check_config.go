@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"kbase-catalog/internal/config"
+)
+
+// errCheckFailed is returned by runCheckConfig when one of the checks failed
+var errCheckFailed = errors.New("the configuration check failed")
+
+// pingTimeout bounds the reachability check of each API endpoint
+const pingTimeout = 5 * time.Second
+
+// runCheckConfig loads and validates the configuration, checks that the archive directory
+// exists and is writable and, with ping, that the API endpoints answer. It prints a line per
+// check to out and fails when any check did.
+func runCheckConfig(ctx context.Context, configPath, archiveDir string, ping bool, out io.Writer) error {
+	failed := 0
+	report := func(err error, name, detail string) {
+		if err != nil {
+			failed++
+			fmt.Fprintf(out, "FAIL %s: %v\n", name, err)
+			return
+		}
+		fmt.Fprintf(out, "PASS %s: %s\n", name, detail)
+	}
+
+	cfg, err := config.LoadConfig(configPath)
+	report(err, "config", config.ResolveConfigPath(configPath))
+
+	report(checkWritableDir(archiveDir), "archive_dir", archiveDir)
+
+	if ping && cfg != nil {
+		for _, apiURL := range cfg.GetAPIURLs() {
+			report(pingURL(ctx, apiURL), "api_url", apiURL+" is reachable")
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%w: %d problems", errCheckFailed, failed)
+	}
+	return nil
+}
+
+// checkWritableDir checks that dir is an existing directory files can be created in
+func checkWritableDir(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+
+	file, err := os.CreateTemp(dir, ".kbase-check-*")
+	if err != nil {
+		return fmt.Errorf("%s is not writable: %w", dir, err)
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
+
+// pingURL sends a HEAD request to url. Any HTTP answer counts, as the API endpoints usually
+// only accept POST requests.
+func pingURL(ctx context.Context, url string) error {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return err
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	return response.Body.Close()
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTestConfig(t *testing.T, content string) string {
+	t.Helper()
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(configPath, []byte(content), 0644))
+	return configPath
+}
+
+func TestRunCheckConfig(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}))
+	defer server.Close()
+
+	archiveDir := t.TempDir()
+	ctx := context.Background()
+
+	t.Run("Valid config", func(t *testing.T) {
+		configPath := writeTestConfig(t, "api_url: "+server.URL+"\nmodel: test-model\ntimeout: 60\nparallel_requests: 3\n")
+
+		var out bytes.Buffer
+		assert.NoError(t, runCheckConfig(ctx, configPath, archiveDir, true, &out))
+		assert.Contains(t, out.String(), "PASS config: "+configPath)
+		assert.Contains(t, out.String(), "PASS archive_dir: "+archiveDir)
+		assert.Contains(t, out.String(), "PASS api_url: "+server.URL+" is reachable")
+		assert.NotContains(t, out.String(), "FAIL")
+	})
+
+	t.Run("Invalid config", func(t *testing.T) {
+		configPath := writeTestConfig(t, "api_url: "+server.URL+"\nmodel: test-model\ntimeout: 60\nparallel_requests: 3\nmin_description_length: -5\n")
+
+		var out bytes.Buffer
+		err := runCheckConfig(ctx, configPath, archiveDir, true, &out)
+		assert.ErrorIs(t, err, errCheckFailed)
+		assert.Contains(t, out.String(), "FAIL config:")
+		assert.Contains(t, out.String(), "min_description_length")
+	})
+
+	t.Run("Missing archive directory", func(t *testing.T) {
+		configPath := writeTestConfig(t, "api_url: "+server.URL+"\nmodel: test-model\ntimeout: 60\nparallel_requests: 3\n")
+
+		var out bytes.Buffer
+		err := runCheckConfig(ctx, configPath, filepath.Join(archiveDir, "missing"), false, &out)
+		assert.ErrorIs(t, err, errCheckFailed)
+		assert.Contains(t, out.String(), "FAIL archive_dir:")
+	})
+
+	t.Run("Unreachable API", func(t *testing.T) {
+		configPath := writeTestConfig(t, "api_url: http://127.0.0.1:1/v1/chat/completions\nmodel: test-model\ntimeout: 60\nparallel_requests: 3\n")
+
+		var out bytes.Buffer
+		err := runCheckConfig(ctx, configPath, archiveDir, true, &out)
+		assert.ErrorIs(t, err, errCheckFailed)
+		assert.Contains(t, out.String(), "FAIL api_url:")
+	})
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"kbase-catalog/internal/processor"
+)
+
+// runDiff compares two index files and prints the added, removed and changed records to out,
+// as human-readable text or as a single JSON object
+func runDiff(oldPath, newPath string, jsonOutput bool, out io.Writer) error {
+	oldData, err := processor.LoadIndexFile(oldPath)
+	if err != nil {
+		return err
+	}
+	newData, err := processor.LoadIndexFile(newPath)
+	if err != nil {
+		return err
+	}
+
+	diff := processor.DiffIndexes(oldData, newData)
+	if jsonOutput {
+		return writeJSON(out, diff)
+	}
+
+	for _, record := range diff.Added {
+		fmt.Fprintf(out, "+ %s: %s\n", record.Key, record.ShortName)
+	}
+	for _, record := range diff.Removed {
+		fmt.Fprintf(out, "- %s: %s\n", record.Key, record.ShortName)
+	}
+	for _, change := range diff.Changed {
+		fmt.Fprintf(out, "~ %s\n", change.Key)
+		for _, field := range change.Fields {
+			fmt.Fprintf(out, "    %s: %q -> %q\n", field.Field, field.Old, field.New)
+		}
+	}
+	fmt.Fprintf(out, "%d added, %d removed, %d changed, %d unchanged\n",
+		len(diff.Added), len(diff.Removed), len(diff.Changed), diff.Unchanged)
+	return nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunDiff(t *testing.T) {
+	dir := t.TempDir()
+	oldPath := filepath.Join(dir, "old-index.json")
+	newPath := filepath.Join(dir, "new-index.json")
+	assert.NoError(t, os.WriteFile(oldPath, []byte(`{
+		"red.png": {"short_name": "Red", "description": "A red square."},
+		"blue.png": {"short_name": "Blue", "description": "A blue square."},
+		"gone.png": {"short_name": "Gone", "description": "Removed."}
+	}`), 0644))
+	assert.NoError(t, os.WriteFile(newPath, []byte(`{
+		"red.png": {"short_name": "Red", "description": "A red square."},
+		"blue.png": {"short_name": "Blue square", "description": "A blue square on white."},
+		"green.png": {"short_name": "Green", "description": "A green square."}
+	}`), 0644))
+
+	t.Run("Human-readable", func(t *testing.T) {
+		var out bytes.Buffer
+		assert.NoError(t, runDiff(oldPath, newPath, false, &out))
+		assert.Equal(t, `+ green.png: Green
+- gone.png: Gone
+~ blue.png
+    short_name: "Blue" -> "Blue square"
+    description: "A blue square." -> "A blue square on white."
+1 added, 1 removed, 1 changed, 1 unchanged
+`, out.String())
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		var out bytes.Buffer
+		assert.NoError(t, runDiff(oldPath, newPath, true, &out))
+
+		var diff map[string]interface{}
+		assert.NoError(t, json.Unmarshal(out.Bytes(), &diff))
+		assert.Equal(t, []interface{}{map[string]interface{}{"key": "green.png", "short_name": "Green"}}, diff["added"])
+		assert.Equal(t, []interface{}{map[string]interface{}{"key": "gone.png", "short_name": "Gone"}}, diff["removed"])
+		assert.Len(t, diff["changed"], 1)
+		assert.Equal(t, float64(1), diff["unchanged"])
+	})
+
+	t.Run("Missing file", func(t *testing.T) {
+		assert.Error(t, runDiff(oldPath, filepath.Join(dir, "missing.json"), false, &bytes.Buffer{}))
+	})
+}
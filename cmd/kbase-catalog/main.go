@@ -7,10 +7,15 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	"kbase-catalog/internal/config"
+	"kbase-catalog/internal/logging"
 	"kbase-catalog/internal/processor"
+	"kbase-catalog/internal/progress"
 	"kbase-catalog/internal/webserver"
 	"kbase-catalog/web"
 
@@ -18,18 +23,57 @@ import (
 )
 
 var (
-	archiveDirFlag string
-	useFilesystem  bool
+	configFileFlag  string
+	verboseFlag     bool
+	archiveDirFlag  string
+	useFilesystem   bool
+	retryFailedFlag bool
+	outputDirFlag   string
+	timeoutFlag     int
+	// process flags
+	quietFlag             bool
+	progressFlag          bool
+	recursiveCatalogsFlag bool
+	watchFlag             bool
+	recheckFlag           bool
+	minDescriptionFlag    int
+	autoTuneFlag          bool
+	autoTuneBudgetFlag    time.Duration
+	sinceFlag             string
 	// web flags
-	portFlag int
+	portFlag        int
+	hostFlag        string
+	unixSocketFlag  string
+	templateDirFlag string
+	// rebuild index flags
+	fullFlag bool
+	// verify flags
+	fixFlag bool
+	// export and import flags
+	imagesFlag     bool
+	onConflictFlag string
 
 	// Convert images flags
-	qualityFlag   int
-	originDirFlag string
+	qualityFlag           int
+	originDirFlag         string
+	convertOnConflictFlag string
 
 	// Fix names flags
 	fixNamesDirectory string
 
+	// Init config flags
+	forceFlag bool
+
+	// Check config flags
+	pingFlag bool
+
+	// Diff flags
+	diffJSONFlag bool
+
+	// Test flags
+	testJSONFlag      bool
+	testRecursiveFlag bool
+
 	rootCmd = &cobra.Command{
 		Use:   "kbase-catalog",
 		Short: "KBase Image Catalog tool",
@@ -44,18 +88,81 @@ var (
 			ctx, cancel := context.WithCancel(context.Background())
 			defer cancel()
 
+			if watchFlag {
+				// Setup signal handling for graceful shutdown
+				sigChan := make(chan os.Signal, 1)
+				signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+				go func() {
+					<-sigChan
+					fmt.Println("\nReceived interrupt signal, shutting down gracefully...")
+					cancel()
+				}()
+			}
+
 			// Load configuration
-			cfg, err := config.LoadConfig("")
+			cfg, err := config.LoadConfig(configFileFlag)
 			if err != nil {
 				log.Fatalf("Failed to load configuration: %v", err)
 			}
+			applyVerbose(cfg)
+			if err := logging.Setup(cfg.LogLevel, cfg.LogFormat); err != nil {
+				log.Fatalf("Failed to set up logging: %v", err)
+			}
+			cfg.RetryFailed = retryFailedFlag
+			if err := applyTimeout(cmd, cfg); err != nil {
+				log.Fatalf("Invalid timeout: %v", err)
+			}
+			if cmd.Flags().Changed("recursive-catalogs") {
+				cfg.RecursiveCatalogs = recursiveCatalogsFlag
+			}
+			if cmd.Flags().Changed("min-description-length") {
+				cfg.MinDescriptionLength = minDescriptionFlag
+			}
+			cfg.RecheckDescriptions = recheckFlag
+			if sinceFlag != "" {
+				if cfg.Since, err = parseSince(sinceFlag, time.Now()); err != nil {
+					log.Fatalf("Invalid --since: %v", err)
+				}
+			}
+			if recheckFlag && cfg.MinDescriptionLength <= 0 {
+				log.Fatalf("--recheck needs min_description_length in the config or --min-description-length")
+			}
 
 			imagesCatalog := args[0]
 
+			// The calibration tries more parallel requests than the default connection limit allows
+			if autoTuneFlag && cfg.LLMMaxConnsPerHost == 0 {
+				cfg.LLMMaxConnsPerHost = processor.AutoTuneMaxConcurrency
+			}
+
 			// Create processor
 			catalogProcessor := processor.NewCatalogProcessor(cfg, imagesCatalog)
+			if err := catalogProcessor.SetOutputDir(outputDirFlag); err != nil {
+				log.Fatalf("Invalid output directory: %v", err)
+			}
 
 			fmt.Printf("Processing catalog in: %s\n", imagesCatalog)
+			if outputDirFlag != "" {
+				fmt.Printf("Writing index files to: %s\n", outputDirFlag)
+			}
+
+			// Progress goes to stderr so it doesn't mix with piped output
+			var reporter *progress.Reporter
+			if !quietFlag && (progressFlag || progress.IsTerminal(os.Stdout)) {
+				reporter = progress.NewReporter(os.Stderr, progress.DefaultInterval, progress.IsTerminal(os.Stderr))
+				catalogProcessor.SetProgress(reporter)
+				reporter.Start()
+				defer reporter.Stop()
+			}
+
+			latencies := progress.NewLatencies()
+			catalogProcessor.SetLatencies(latencies)
+
+			if autoTuneFlag {
+				if err := runAutoTune(ctx, cfg, catalogProcessor, autoTuneBudgetFlag, os.Stdout); err != nil {
+					log.Fatalf("Failed to auto-tune: %v", err)
+				}
+			}
 
 			err = catalogProcessor.ProcessCatalog(ctx)
 			if err != nil {
@@ -66,6 +173,20 @@ var (
 			if err != nil {
 				log.Fatalf("Failed to rebuild root index: %v", err)
 			}
+
+			if summary := latencies.Summary(); summary.Count > 0 {
+				fmt.Println(summary)
+			}
+
+			if watchFlag {
+				// The progress of the initial pass is complete, the queue reports its own
+				if reporter != nil {
+					reporter.Stop()
+				}
+				if err := runWatch(ctx, cfg, catalogProcessor, imagesCatalog, os.Stdout); err != nil {
+					log.Fatalf("Failed to watch catalog: %v", err)
+				}
+			}
 		},
 	}
 
@@ -77,52 +198,215 @@ var (
 			defer cancel()
 
 			// Load configuration
-			cfg, err := config.LoadConfig("")
+			cfg, err := config.LoadConfig(configFileFlag)
 			if err != nil {
 				log.Fatalf("Failed to load configuration: %v", err)
 			}
+			if err := logging.Setup(cfg.LogLevel, cfg.LogFormat); err != nil {
+				log.Fatalf("Failed to set up logging: %v", err)
+			}
 
 			// Create processor
 			catalogProcessor := processor.NewCatalogProcessor(cfg, archiveDirFlag)
+			if err := catalogProcessor.SetOutputDir(outputDirFlag); err != nil {
+				log.Fatalf("Invalid output directory: %v", err)
+			}
 
-			fmt.Printf("Rebuilding root index in: %s\n", archiveDirFlag)
+			fmt.Printf("Rebuilding root index in: %s\n", catalogProcessor.IndexDir())
 
-			err = catalogProcessor.RebuildRootIndex(ctx)
+			if fullFlag {
+				err = catalogProcessor.RebuildRootIndexFull(ctx)
+			} else {
+				err = catalogProcessor.RebuildRootIndex(ctx)
+			}
 			if err != nil {
 				log.Fatalf("Failed to rebuild root index: %v", err)
 			}
 		},
 	}
 
-	testCmd = &cobra.Command{
-		Use:   "test <image_path>",
-		Short: "Test single image processing",
+	pruneCmd = &cobra.Command{
+		Use:   "prune",
+		Short: "Remove index records of images that no longer exist",
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			// Load configuration
+			cfg, err := config.LoadConfig(configFileFlag)
+			if err != nil {
+				log.Fatalf("Failed to load configuration: %v", err)
+			}
+			if err := logging.Setup(cfg.LogLevel, cfg.LogFormat); err != nil {
+				log.Fatalf("Failed to set up logging: %v", err)
+			}
+
+			// Create processor
+			catalogProcessor := processor.NewCatalogProcessor(cfg, archiveDirFlag)
+			if err := catalogProcessor.SetOutputDir(outputDirFlag); err != nil {
+				log.Fatalf("Invalid output directory: %v", err)
+			}
+
+			fmt.Printf("Pruning catalogs in: %s\n", archiveDirFlag)
+
+			results, err := catalogProcessor.PruneCatalogs(ctx)
+			if err != nil {
+				log.Fatalf("Failed to prune catalogs: %v", err)
+			}
+
+			total := 0
+			for _, result := range results {
+				if result.Removed {
+					fmt.Printf("REMOVED: %s (%d entries, directory no longer exists)\n", result.Catalog, result.Pruned)
+				} else {
+					fmt.Printf("PRUNED: %s (%d entries)\n", result.Catalog, result.Pruned)
+				}
+				total += result.Pruned
+			}
+			fmt.Printf("Pruned %d entries from %d catalogs\n", total, len(results))
+		},
+	}
+
+	verifyCmd = &cobra.Command{
+		Use:   "verify",
+		Short: "Check the catalog indexes and the root index for problems",
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			// Load configuration
+			cfg, err := config.LoadConfig(configFileFlag)
+			if err != nil {
+				log.Fatalf("Failed to load configuration: %v", err)
+			}
+			if err := logging.Setup(cfg.LogLevel, cfg.LogFormat); err != nil {
+				log.Fatalf("Failed to set up logging: %v", err)
+			}
+
+			// Create processor
+			catalogProcessor := processor.NewCatalogProcessor(cfg, archiveDirFlag)
+			if err := catalogProcessor.SetOutputDir(outputDirFlag); err != nil {
+				log.Fatalf("Invalid output directory: %v", err)
+			}
+
+			fmt.Printf("Verifying catalogs in: %s\n", archiveDirFlag)
+
+			if err := runVerify(ctx, catalogProcessor, fixFlag, os.Stdout); err != nil {
+				log.Fatalf("Verification failed: %v", err)
+			}
+		},
+	}
+
+	exportCmd = &cobra.Command{
+		Use:   "export <bundle.zip>",
+		Short: "Export the catalog indexes and metadata to a single zip bundle",
 		Args:  cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
 			ctx, cancel := context.WithCancel(context.Background())
 			defer cancel()
 
 			// Load configuration
-			cfg, err := config.LoadConfig("")
+			cfg, err := config.LoadConfig(configFileFlag)
 			if err != nil {
 				log.Fatalf("Failed to load configuration: %v", err)
 			}
+			if err := logging.Setup(cfg.LogLevel, cfg.LogFormat); err != nil {
+				log.Fatalf("Failed to set up logging: %v", err)
+			}
 
 			// Create processor
 			catalogProcessor := processor.NewCatalogProcessor(cfg, archiveDirFlag)
+			if err := catalogProcessor.SetOutputDir(outputDirFlag); err != nil {
+				log.Fatalf("Invalid output directory: %v", err)
+			}
 
-			imagePath := args[0]
-			fmt.Printf("Testing single image: %s\n", imagePath)
+			bundle, err := os.Create(args[0])
+			if err != nil {
+				log.Fatalf("Failed to create bundle: %v", err)
+			}
+			manifest, err := catalogProcessor.ExportBundle(ctx, bundle, imagesFlag)
+			if closeErr := bundle.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(args[0])
+				log.Fatalf("Export failed: %v", err)
+			}
+
+			records := 0
+			for _, catalog := range manifest.Catalogs {
+				records += catalog.Records
+			}
+			fmt.Printf("Exported %d records from %d catalogs to %s\n", records, len(manifest.Catalogs), args[0])
+		},
+	}
+
+	importCmd = &cobra.Command{
+		Use:   "import <bundle.zip>",
+		Short: "Import the catalogs of a bundle written by export",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
 
-			response, err := catalogProcessor.TestSingleImage(ctx, imagePath)
+			// Load configuration
+			cfg, err := config.LoadConfig(configFileFlag)
 			if err != nil {
-				log.Fatalf("Failed to test image: %v", err)
+				log.Fatalf("Failed to load configuration: %v", err)
+			}
+			if err := logging.Setup(cfg.LogLevel, cfg.LogFormat); err != nil {
+				log.Fatalf("Failed to set up logging: %v", err)
+			}
+
+			// Create processor
+			catalogProcessor := processor.NewCatalogProcessor(cfg, archiveDirFlag)
+			if err := catalogProcessor.SetOutputDir(outputDirFlag); err != nil {
+				log.Fatalf("Invalid output directory: %v", err)
 			}
 
-			if response != nil {
-				fmt.Printf("\n✅ Successfully obtained result:\n")
-				fmt.Printf("Short name: %s\n", response.ShortName)
-				fmt.Printf("Description: %s\n", response.Description)
+			imported, err := catalogProcessor.ImportBundle(ctx, args[0], onConflictFlag)
+			for _, catalog := range imported {
+				fmt.Printf("%s: %s (%d files)\n", strings.ToUpper(catalog.Action), catalog.Name, catalog.Files)
+			}
+			if err != nil {
+				log.Fatalf("Import failed: %v", err)
+			}
+			fmt.Printf("Imported %d catalogs into %s\n", len(imported), archiveDirFlag)
+		},
+	}
+
+	testCmd = &cobra.Command{
+		Use:   "test <image_path|directory>",
+		Short: "Test processing of a single image or of every image in a directory",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			opts := testOptions{JSON: testJSONFlag, Recursive: testRecursiveFlag}
+
+			// Load configuration
+			cfg, err := config.LoadConfig(configFileFlag)
+			if err == nil {
+				applyVerbose(cfg)
+				err = applyTimeout(cmd, cfg)
+			}
+			if err == nil {
+				err = logging.Setup(cfg.LogLevel, cfg.LogFormat)
+			}
+			if err != nil {
+				if opts.JSON {
+					writeJSON(os.Stdout, testResult{Image: args[0], Error: err.Error()})
+					os.Exit(1)
+				}
+				log.Fatalf("Failed to load configuration: %v", err)
+			}
+
+			if err := runTest(ctx, cfg, args[0], opts, os.Stdout); err != nil {
+				if opts.JSON {
+					os.Exit(1)
+				}
+				log.Fatalf("Test failed: %v", err)
 			}
 		},
 	}
@@ -135,13 +419,19 @@ var (
 			defer cancel()
 
 			// Load configuration
-			cfg, err := config.LoadConfig("")
+			cfg, err := config.LoadConfig(configFileFlag)
 			if err != nil {
 				log.Fatalf("Failed to load configuration: %v", err)
 			}
+			if err := logging.Setup(cfg.LogLevel, cfg.LogFormat); err != nil {
+				log.Fatalf("Failed to set up logging: %v", err)
+			}
 
 			// Create converter
 			imageConverter := images.NewImageConverter(cfg)
+			if err := imageConverter.SetOnConflict(convertOnConflictFlag); err != nil {
+				log.Fatalf("Invalid --on-conflict: %v", err)
+			}
 
 			fmt.Printf("Converting images in: %s\n", archiveDirFlag)
 
@@ -169,19 +459,37 @@ var (
 			}()
 
 			// Load configuration
-			cfg, err := config.LoadConfig("")
+			cfg, err := config.LoadConfig(configFileFlag)
 			if err != nil {
 				log.Fatalf("Failed to load configuration: %v", err)
 			}
+			applyVerbose(cfg)
+			if err := logging.Setup(cfg.LogLevel, cfg.LogFormat); err != nil {
+				log.Fatalf("Failed to set up logging: %v", err)
+			}
+			cfg.RetryFailed = retryFailedFlag
 
 			// Create processor
 			catalogProcessor := processor.NewCatalogProcessor(cfg, archiveDirFlag)
+			if err := catalogProcessor.SetOutputDir(outputDirFlag); err != nil {
+				log.Fatalf("Invalid output directory: %v", err)
+			}
 
 			fmt.Println("Starting web interface...")
 
-			web.InitTemplateFS(useFilesystem)
+			if cmd.Flags().Changed("template-dir") {
+				cfg.TemplateDir = templateDirFlag
+			}
+			if cfg.TemplateDir != "" {
+				if info, err := os.Stat(cfg.TemplateDir); err != nil || !info.IsDir() {
+					log.Fatalf("Template directory %s is not a directory", cfg.TemplateDir)
+				}
+			}
+			web.InitTemplateFS(useFilesystem, cfg.TemplateDir)
 
 			server := webserver.NewServer(cfg, catalogProcessor, portFlag, archiveDirFlag)
+			server.SetHost(hostFlag)
+			server.SetUnixSocket(unixSocketFlag)
 
 			err = server.Start()
 			if err != nil {
@@ -206,10 +514,13 @@ var (
 			defer cancel()
 
 			// Load configuration
-			cfg, err := config.LoadConfig("")
+			cfg, err := config.LoadConfig(configFileFlag)
 			if err != nil {
 				log.Fatalf("Failed to load configuration: %v", err)
 			}
+			if err := logging.Setup(cfg.LogLevel, cfg.LogFormat); err != nil {
+				log.Fatalf("Failed to set up logging: %v", err)
+			}
 
 			// Create processor
 			catalogProcessor := processor.NewCatalogProcessor(cfg, archiveDirFlag)
@@ -221,6 +532,46 @@ var (
 		},
 	}
 
+	initConfigCmd = &cobra.Command{
+		Use:          "init-config [path]",
+		Short:        "Write a default configuration file",
+		Args:         cobra.MaximumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			configPath := config.DefaultConfigPath
+			if len(args) > 0 {
+				configPath = args[0]
+			}
+
+			if err := config.InitConfigFile(configPath, forceFlag); err != nil {
+				return err
+			}
+
+			fmt.Printf("Default configuration written to: %s\n", configPath)
+			return nil
+		},
+	}
+
+	diffCmd = &cobra.Command{
+		Use:          "diff <old-index.json> <new-index.json>",
+		Short:        "Show the records added, removed and changed between two index files",
+		Args:         cobra.ExactArgs(2),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDiff(args[0], args[1], diffJSONFlag, os.Stdout)
+		},
+	}
+
+	checkConfigCmd = &cobra.Command{
+		Use:          "check-config",
+		Short:        "Validate the configuration and check the archive directory and API endpoints",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runCheckConfig(cmd.Context(), configFileFlag, archiveDirFlag, pingFlag, os.Stdout)
+		},
+	}
+
 	versionCmd = &cobra.Command{
 		Use:   "version",
 		Short: "Show version information",
@@ -232,32 +583,153 @@ var (
 
 func init() {
 	descriptionArchiveDir := "Directory to use for archive files"
+	descriptionOutputDir := "Directory to keep index files in instead of the catalog directories"
+	descriptionTimeout := "LLM request timeout in seconds, overrides timeout of the config"
+
+	rootCmd.PersistentFlags().StringVarP(&configFileFlag, "config", "f", "",
+		"Path to the configuration file (default: $"+config.ConfigPathEnv+" or "+config.DefaultConfigPath+")")
+	rootCmd.PersistentFlags().BoolVarP(&verboseFlag, "verbose", "v", false,
+		"Log LLM requests and responses at debug level (images elided, API key redacted)")
 
 	// Convert images flags
 	convertImagesCmd.Flags().IntVarP(&qualityFlag, "quality", "q", 85, "WebP compression quality (0-100, default: 85)")
 	convertImagesCmd.Flags().StringVarP(&originDirFlag, "origin-dir", "o", "origin", "Directory to move original files to")
 	convertImagesCmd.Flags().StringVarP(&archiveDirFlag, "archive-dir", "a", "archive", descriptionArchiveDir)
+	convertImagesCmd.Flags().StringVar(&convertOnConflictFlag, "on-conflict", images.OnConflictRename,
+		"What to do when a same-named original already exists in the origin directory: skip, rename or overwrite")
+
+	// process flags
+	descriptionRetryFailed := "Also reprocess images that failed permanently"
+	processCmd.Flags().BoolVar(&retryFailedFlag, "retry-failed", false, descriptionRetryFailed)
+	processCmd.Flags().BoolVar(&quietFlag, "quiet", false, "Don't print progress")
+	processCmd.Flags().BoolVar(&progressFlag, "progress", false, "Print progress even when stdout is not a terminal")
+	processCmd.Flags().StringVar(&outputDirFlag, "output-dir", "", descriptionOutputDir)
+	processCmd.Flags().BoolVar(&recursiveCatalogsFlag, "recursive-catalogs", false,
+		"Index the images of catalog subdirectories too, overrides recursive_catalogs of the config")
+	processCmd.Flags().IntVar(&timeoutFlag, "timeout", 0, descriptionTimeout)
+	processCmd.Flags().BoolVar(&recheckFlag, "recheck", false, "Also describe again the images whose description is shorter than min_description_length")
+	processCmd.Flags().IntVar(&minDescriptionFlag, "min-description-length", 0, "Minimal description length checked by --recheck, overrides min_description_length of the config")
+	processCmd.Flags().BoolVar(&watchFlag, "watch", false, "Keep running after processing and reindex the catalogs whose images change")
+	processCmd.Flags().StringVar(&sinceFlag, "since", "",
+		"Leave described images modified before this date alone: 2006-01-02, an RFC 3339 time or an age like 7d or 36h")
+	processCmd.Flags().BoolVar(&autoTuneFlag, "auto-tune", false,
+		"Measure the throughput of a sample of the images at increasing parallel requests and process the rest with the fastest")
+	processCmd.Flags().DurationVar(&autoTuneBudgetFlag, "auto-tune-budget", processor.DefaultAutoTuneBudget, "Maximal duration of the --auto-tune calibration")
 
 	// web flags
+	webCmd.Flags().BoolVar(&retryFailedFlag, "retry-failed", false, descriptionRetryFailed)
 	webCmd.Flags().IntVarP(&portFlag, "port", "p", 8080, "Port to run the web server on")
+	webCmd.Flags().StringVar(&hostFlag, "host", webserver.DefaultHost, "Address to listen on")
+	webCmd.Flags().StringVar(&hostFlag, "bind", webserver.DefaultHost, "Alias of --host")
+	webCmd.Flags().StringVar(&unixSocketFlag, "unix-socket", "", "Listen on a unix domain socket at this path instead of TCP")
+	webCmd.MarkFlagsMutuallyExclusive("host", "bind")
+	webCmd.MarkFlagsMutuallyExclusive("unix-socket", "host")
+	webCmd.MarkFlagsMutuallyExclusive("unix-socket", "bind")
+	webCmd.MarkFlagsMutuallyExclusive("unix-socket", "port")
 	webCmd.Flags().BoolVarP(&useFilesystem, "use-fs", "l", false, "Use real filesystem for static resources instead of embedded")
+	webCmd.Flags().StringVar(&templateDirFlag, "template-dir", "", "Directory of templates used instead of the built-in ones of the same name, overrides template_dir of the config")
 	webCmd.Flags().StringVarP(&archiveDirFlag, "archive-dir", "a", "archive", descriptionArchiveDir)
+	webCmd.Flags().StringVar(&outputDirFlag, "output-dir", "", descriptionOutputDir)
 
 	// rebuild index flags
 	rebuildIndexCmd.Flags().StringVarP(&archiveDirFlag, "archive-dir", "a", "archive", descriptionArchiveDir)
+	rebuildIndexCmd.Flags().StringVar(&outputDirFlag, "output-dir", "", descriptionOutputDir)
+	rebuildIndexCmd.Flags().BoolVar(&fullFlag, "full", false, "Read the index of every catalog, not only of the ones changed since the last rebuild")
+
+	// prune flags
+	pruneCmd.Flags().StringVarP(&archiveDirFlag, "archive-dir", "a", "archive", descriptionArchiveDir)
+	pruneCmd.Flags().StringVar(&outputDirFlag, "output-dir", "", descriptionOutputDir)
+
+	// verify flags
+	verifyCmd.Flags().StringVarP(&archiveDirFlag, "archive-dir", "a", "archive", descriptionArchiveDir)
+	verifyCmd.Flags().StringVar(&outputDirFlag, "output-dir", "", descriptionOutputDir)
+	verifyCmd.Flags().BoolVar(&fixFlag, "fix", false, "Repair the problems: drop broken records, set unreadable indexes aside and rebuild the root index")
+
+	// export flags
+	exportCmd.Flags().StringVarP(&archiveDirFlag, "archive-dir", "a", "archive", descriptionArchiveDir)
+	exportCmd.Flags().StringVar(&outputDirFlag, "output-dir", "", descriptionOutputDir)
+	exportCmd.Flags().BoolVar(&imagesFlag, "images", false, "Also include the indexed images in the bundle")
+
+	// import flags
+	importCmd.Flags().StringVarP(&archiveDirFlag, "archive-dir", "a", "archive", descriptionArchiveDir)
+	importCmd.Flags().StringVar(&outputDirFlag, "output-dir", "", descriptionOutputDir)
+	importCmd.Flags().StringVar(&onConflictFlag, "on-conflict", processor.ImportSkip,
+		"What to do with catalogs that already have an index: skip, replace or merge")
 
 	// fix names flags
 	fixNamesCmd.Flags().StringVarP(&archiveDirFlag, "archive-dir", "a", "archive", descriptionArchiveDir)
 
+	// test flags
+	testCmd.Flags().BoolVar(&testJSONFlag, "json", false, "Print the result as a single JSON object")
+	testCmd.Flags().BoolVarP(&testRecursiveFlag, "recursive", "r", false, "Also test images in subdirectories when given a directory")
+	testCmd.Flags().IntVar(&timeoutFlag, "timeout", 0, descriptionTimeout)
+
+	// init config flags
+	initConfigCmd.Flags().BoolVar(&forceFlag, "force", false, "Overwrite an existing configuration file")
+
+	// Check config command flags
+	checkConfigCmd.Flags().StringVarP(&archiveDirFlag, "archive-dir", "a", "archive", descriptionArchiveDir)
+	checkConfigCmd.Flags().BoolVar(&pingFlag, "ping", false, "Also check that the API endpoints answer")
+
+	// Diff command flags
+	diffCmd.Flags().BoolVar(&diffJSONFlag, "json", false, "Print the differences as a single JSON object")
+
 	rootCmd.AddCommand(processCmd)
 	rootCmd.AddCommand(rebuildIndexCmd)
+	rootCmd.AddCommand(pruneCmd)
+	rootCmd.AddCommand(verifyCmd)
+	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(importCmd)
 	rootCmd.AddCommand(testCmd)
 	rootCmd.AddCommand(convertImagesCmd)
 	rootCmd.AddCommand(fixNamesCmd)
 	rootCmd.AddCommand(webCmd)
+	rootCmd.AddCommand(initConfigCmd)
+	rootCmd.AddCommand(checkConfigCmd)
+	rootCmd.AddCommand(diffCmd)
 	rootCmd.AddCommand(versionCmd)
 }
 
+// applyTimeout overrides the LLM request timeout of the config with --timeout when given
+func applyTimeout(cmd *cobra.Command, cfg *config.Config) error {
+	if !cmd.Flags().Changed("timeout") {
+		return nil
+	}
+	if timeoutFlag <= 0 {
+		return fmt.Errorf("--timeout must be positive, got %d", timeoutFlag)
+	}
+	cfg.Timeout = timeoutFlag
+	return nil
+}
+
+// parseSince parses the cutoff of --since: a local date, an RFC 3339 time, or an age in days
+// (7d) or as a duration (36h) before now
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if date, err := time.ParseInLocation(time.DateOnly, value, time.Local); err == nil {
+		return date, nil
+	}
+	if timestamp, err := time.Parse(time.RFC3339, value); err == nil {
+		return timestamp, nil
+	}
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if age, err := time.ParseDuration(value); err == nil && age >= 0 {
+		return now.Add(-age), nil
+	}
+	return time.Time{}, fmt.Errorf("%q is not a date, an RFC 3339 time or an age like 7d", value)
+}
+
+// applyVerbose turns on the LLM request logging of --verbose, which is written at debug level
+func applyVerbose(cfg *config.Config) {
+	if verboseFlag {
+		cfg.DebugLLM = true
+		cfg.LogLevel = "debug"
+	}
+}
+
 func main() {
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
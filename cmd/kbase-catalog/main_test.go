@@ -0,0 +1,107 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"kbase-catalog/internal/config"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRootCmd_ConfigFlag(t *testing.T) {
+	tempDir := t.TempDir()
+
+	configPath := filepath.Join(tempDir, "custom.yaml")
+	err := os.WriteFile(configPath, []byte(`
+api_url: "http://localhost:1234/v1/chat/completions"
+model: "test-model"
+timeout: 60
+parallel_requests: 1
+`), 0644)
+	assert.NoError(t, err)
+
+	archiveDir := filepath.Join(tempDir, "archive")
+	assert.NoError(t, os.MkdirAll(archiveDir, 0755))
+
+	// Run from a directory without config.yaml, so only the flag can point to the config
+	t.Chdir(t.TempDir())
+
+	rootCmd.SetArgs([]string{"--config", configPath, "rebuild-index", "--archive-dir", archiveDir})
+	defer rootCmd.SetArgs(nil)
+
+	assert.NoError(t, rootCmd.Execute())
+	assert.Equal(t, configPath, configFileFlag)
+	assert.FileExists(t, filepath.Join(archiveDir, "index.json"))
+}
+
+func TestInitConfigCmd(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	defer rootCmd.SetArgs(nil)
+	defer func() { forceFlag = false }()
+
+	rootCmd.SetArgs([]string{"init-config", configPath})
+	assert.NoError(t, rootCmd.Execute())
+
+	cfg, err := config.LoadConfig(configPath)
+	assert.NoError(t, err)
+	assert.Equal(t, config.GetDefaultConfig(), cfg)
+
+	// An existing file is kept without --force
+	assert.NoError(t, os.WriteFile(configPath, []byte("model: custom\n"), 0644))
+	rootCmd.SetArgs([]string{"init-config", configPath})
+	assert.ErrorIs(t, rootCmd.Execute(), config.ErrConfigExists)
+
+	content, err := os.ReadFile(configPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "model: custom\n", string(content))
+
+	rootCmd.SetArgs([]string{"init-config", configPath, "--force"})
+	assert.NoError(t, rootCmd.Execute())
+
+	_, err = config.LoadConfig(configPath)
+	assert.NoError(t, err)
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC)
+
+	for value, expected := range map[string]time.Time{
+		"2024-05-01":           time.Date(2024, 5, 1, 0, 0, 0, 0, time.Local),
+		"2024-05-01T08:30:00Z": time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC),
+		"7d":                   time.Date(2024, 5, 13, 12, 0, 0, 0, time.UTC),
+		"36h":                  time.Date(2024, 5, 19, 0, 0, 0, 0, time.UTC),
+	} {
+		since, err := parseSince(value, now)
+		assert.NoError(t, err, value)
+		assert.True(t, expected.Equal(since), "%s: %s", value, since)
+	}
+
+	for _, value := range []string{"yesterday", "2024-13-01", "-7d", "-1h", ""} {
+		_, err := parseSince(value, now)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestApplyTimeout(t *testing.T) {
+	defer func() { timeoutFlag = 0 }()
+
+	cmd := &cobra.Command{}
+	cmd.Flags().IntVar(&timeoutFlag, "timeout", 0, "")
+
+	// The config value stays when the flag isn't given
+	cfg := &config.Config{Timeout: 60}
+	assert.NoError(t, applyTimeout(cmd, cfg))
+	assert.Equal(t, 60, cfg.Timeout)
+
+	assert.NoError(t, cmd.Flags().Set("timeout", "5"))
+	assert.NoError(t, applyTimeout(cmd, cfg))
+	assert.Equal(t, 5, cfg.Timeout)
+
+	assert.NoError(t, cmd.Flags().Set("timeout", "0"))
+	assert.Error(t, applyTimeout(cmd, cfg))
+	assert.Equal(t, 5, cfg.Timeout)
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"kbase-catalog/internal/config"
+	"kbase-catalog/internal/llm"
+	"kbase-catalog/internal/processor"
+	"kbase-catalog/internal/progress"
+	"kbase-catalog/internal/utils"
+)
+
+// testOptions controls the output and the scope of the test command
+type testOptions struct {
+	JSON      bool
+	Recursive bool
+}
+
+// testResult is the JSON output of the test command for a single image
+type testResult struct {
+	Image      string           `json:"image"`
+	Response   *llm.LLMResponse `json:"response,omitempty"`
+	Model      string           `json:"model,omitempty"`
+	DurationMs int64            `json:"duration_ms"`
+	Error      string           `json:"error,omitempty"`
+}
+
+// testSummary is the JSON output of the test command for a directory
+type testSummary struct {
+	Directory string       `json:"directory"`
+	Results   []testResult `json:"results"`
+	Succeeded int          `json:"succeeded"`
+	Failed    int          `json:"failed"`
+	Error     string       `json:"error,omitempty"`
+}
+
+// latency summarizes the durations of the successfully tested images
+func (s testSummary) latency() progress.LatencySummary {
+	var durations []time.Duration
+	for _, result := range s.Results {
+		if result.Error == "" {
+			durations = append(durations, time.Duration(result.DurationMs)*time.Millisecond)
+		}
+	}
+	return progress.Summarize(durations)
+}
+
+// writeJSON prints the value as a single line of JSON
+func writeJSON(out io.Writer, value interface{}) error {
+	return json.NewEncoder(out).Encode(value)
+}
+
+// runTest tests a single image, or every image of a directory, against the LLM without
+// writing any index files. The result is printed to out as human-readable text or as a
+// single JSON object. In JSON mode errors are printed as well.
+func runTest(ctx context.Context, cfg *config.Config, path string, opts testOptions, out io.Writer) error {
+	catalogProcessor := processor.NewCatalogProcessor(cfg, archiveDirFlag)
+
+	if utils.IsDirectory(path) {
+		return runTestDirectory(ctx, catalogProcessor, path, opts, out)
+	}
+	return runTestImage(ctx, catalogProcessor, cfg, path, opts, out)
+}
+
+// testImage sends a single image to the LLM and collects the outcome
+func testImage(ctx context.Context, catalogProcessor *processor.CatalogProcessor, imagePath string) (testResult, error) {
+	start := time.Now()
+	response, model, err := catalogProcessor.TestSingleImage(ctx, imagePath)
+
+	result := testResult{
+		Image:      imagePath,
+		Response:   response,
+		Model:      model,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result, err
+}
+
+// runTestImage tests a single image
+func runTestImage(ctx context.Context, catalogProcessor *processor.CatalogProcessor, cfg *config.Config, imagePath string, opts testOptions, out io.Writer) error {
+	if !opts.JSON {
+		fmt.Fprintf(out, "Testing image: %s\n", imagePath)
+		fmt.Fprintf(out, "Directory: %s\n", filepath.Base(filepath.Dir(imagePath)))
+		fmt.Fprintf(out, "Filename: %s\n", filepath.Base(imagePath))
+	}
+
+	result, err := testImage(ctx, catalogProcessor, imagePath)
+
+	if opts.JSON {
+		if writeErr := writeJSON(out, result); writeErr != nil {
+			return writeErr
+		}
+		return err
+	}
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "\n✅ Successfully obtained result:\n")
+	fmt.Fprintf(out, "Short name: %s\n", result.Response.ShortName)
+	if cfg.IsOCRMode() {
+		fmt.Fprintf(out, "Text: %s\n", result.Response.Text)
+	} else {
+		fmt.Fprintf(out, "Description: %s\n", result.Response.Description)
+		if result.Response.LongDescription != "" {
+			fmt.Fprintf(out, "Long description: %s\n", result.Response.LongDescription)
+		}
+	}
+	fmt.Fprintf(out, "Vision model: %s\n", result.Model)
+	fmt.Fprintf(out, "Duration: %s\n", time.Duration(result.DurationMs)*time.Millisecond)
+
+	return nil
+}
+
+// runTestDirectory tests every supported image of a directory and reports a result per file
+// followed by a summary. It stops early when the context is cancelled.
+func runTestDirectory(ctx context.Context, catalogProcessor *processor.CatalogProcessor, dirPath string, opts testOptions, out io.Writer) error {
+	summary := testSummary{Directory: dirPath, Results: []testResult{}}
+
+	images, err := catalogProcessor.FindImages(dirPath, opts.Recursive)
+	if err == nil {
+		sort.Strings(images)
+
+		for _, imagePath := range images {
+			if err = ctx.Err(); err != nil {
+				break
+			}
+
+			result, testErr := testImage(ctx, catalogProcessor, imagePath)
+			if testErr != nil {
+				summary.Failed++
+			} else {
+				summary.Succeeded++
+			}
+			summary.Results = append(summary.Results, result)
+		}
+	}
+	if err == nil && summary.Failed > 0 {
+		err = fmt.Errorf("%d of %d images failed", summary.Failed, len(summary.Results))
+	}
+	if err != nil {
+		summary.Error = err.Error()
+	}
+
+	if opts.JSON {
+		if writeErr := writeJSON(out, summary); writeErr != nil {
+			return writeErr
+		}
+		return err
+	}
+
+	fmt.Fprintf(out, "Testing images in: %s\n\n", dirPath)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tSTATUS\tMODEL\tDURATION\tRESULT")
+	for _, result := range summary.Results {
+		name, _ := filepath.Rel(dirPath, result.Image)
+		duration := time.Duration(result.DurationMs) * time.Millisecond
+		if result.Error != "" {
+			fmt.Fprintf(w, "%s\tFAILED\t-\t%s\t%s\n", name, duration, result.Error)
+		} else {
+			fmt.Fprintf(w, "%s\tOK\t%s\t%s\t%s\n", name, result.Model, duration, result.Response.ShortName)
+		}
+	}
+	w.Flush()
+
+	fmt.Fprintln(out, "==================================================")
+	fmt.Fprintf(out, "Summary:\n")
+	fmt.Fprintf(out, "  Images tested: %d\n", len(summary.Results))
+	fmt.Fprintf(out, "  Succeeded: %d\n", summary.Succeeded)
+	fmt.Fprintf(out, "  Failed: %d\n", summary.Failed)
+	fmt.Fprintf(out, "  %s\n", summary.latency())
+
+	return err
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"kbase-catalog/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestLLMServer returns a mock LLM answering every request with the same image description
+func newTestLLMServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := map[string]interface{}{
+			"model": "test-model",
+			"choices": []interface{}{
+				map[string]interface{}{
+					"message": map[string]interface{}{
+						"content": `{"short_name": "Red Square", "description": "A red square."}`,
+					},
+				},
+			},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}))
+}
+
+// writeTestPNG writes a 10x10 red PNG to path
+func writeTestPNG(t *testing.T, path string) {
+	img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 10; x++ {
+			img.Set(x, y, color.RGBA{255, 0, 0, 255})
+		}
+	}
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, img))
+	assert.NoError(t, os.WriteFile(path, buf.Bytes(), 0644))
+}
+
+func TestRunTest_JSON(t *testing.T) {
+	server := newTestLLMServer()
+	defer server.Close()
+
+	imagePath := filepath.Join(t.TempDir(), "red.png")
+	writeTestPNG(t, imagePath)
+
+	cfg := &config.Config{APIURL: server.URL, Model: "test-model", Timeout: 10, SupportedExtensions: []string{".png"}}
+
+	t.Run("Prints the response as a single JSON object", func(t *testing.T) {
+		var out bytes.Buffer
+		err := runTest(context.Background(), cfg, imagePath, testOptions{JSON: true}, &out)
+		assert.NoError(t, err)
+
+		var result map[string]interface{}
+		assert.NoError(t, json.Unmarshal(out.Bytes(), &result))
+		assert.Equal(t, imagePath, result["image"])
+		assert.Equal(t, "test-model", result["model"])
+		assert.Contains(t, result, "duration_ms")
+		assert.NotContains(t, result, "error")
+
+		response := result["response"].(map[string]interface{})
+		assert.Equal(t, "Red Square", response["short_name"])
+		assert.Equal(t, "A red square.", response["description"])
+	})
+
+	t.Run("Prints a JSON error", func(t *testing.T) {
+		var out bytes.Buffer
+		err := runTest(context.Background(), cfg, filepath.Join(t.TempDir(), "missing.png"), testOptions{JSON: true}, &out)
+		assert.Error(t, err)
+
+		var result map[string]interface{}
+		assert.NoError(t, json.Unmarshal(out.Bytes(), &result))
+		assert.Contains(t, result["error"], "file not found")
+		assert.NotContains(t, result, "response")
+	})
+}
+
+func TestRunTest_Directory(t *testing.T) {
+	server := newTestLLMServer()
+	defer server.Close()
+
+	dirPath := t.TempDir()
+	writeTestPNG(t, filepath.Join(dirPath, "first.png"))
+	writeTestPNG(t, filepath.Join(dirPath, "second.png"))
+	assert.NoError(t, os.MkdirAll(filepath.Join(dirPath, "nested"), 0755))
+	writeTestPNG(t, filepath.Join(dirPath, "nested", "third.png"))
+
+	cfg := &config.Config{APIURL: server.URL, Model: "test-model", Timeout: 10, SupportedExtensions: []string{".png"}}
+
+	t.Run("Reports every image of the directory", func(t *testing.T) {
+		var out bytes.Buffer
+		err := runTest(context.Background(), cfg, dirPath, testOptions{}, &out)
+		assert.NoError(t, err)
+
+		assert.Contains(t, out.String(), "first.png")
+		assert.Contains(t, out.String(), "second.png")
+		assert.NotContains(t, out.String(), "third.png")
+		assert.Contains(t, out.String(), "Images tested: 2")
+		assert.Contains(t, out.String(), "Succeeded: 2")
+		assert.Contains(t, out.String(), "Latency: 2 images")
+
+		// Testing never writes index files
+		assert.NoFileExists(t, filepath.Join(dirPath, "index.json"))
+		assert.NoFileExists(t, filepath.Join(dirPath, "index.md"))
+	})
+
+	t.Run("Descends into subdirectories when recursive", func(t *testing.T) {
+		var out bytes.Buffer
+		err := runTest(context.Background(), cfg, dirPath, testOptions{JSON: true, Recursive: true}, &out)
+		assert.NoError(t, err)
+
+		var summary testSummary
+		assert.NoError(t, json.Unmarshal(out.Bytes(), &summary))
+		assert.Equal(t, 3, summary.Succeeded)
+		assert.Equal(t, 0, summary.Failed)
+		assert.Len(t, summary.Results, 3)
+	})
+
+	t.Run("Stops on cancelled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		var out bytes.Buffer
+		err := runTest(ctx, cfg, dirPath, testOptions{}, &out)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Contains(t, out.String(), "Images tested: 0")
+	})
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"kbase-catalog/internal/processor"
+)
+
+// errVerifyFailed is returned by runVerify when problems are left in the archive
+var errVerifyFailed = errors.New("the archive has index problems")
+
+// runVerify checks the indexes of the archive and prints a report to out, one line per
+// problem. With fix the problems are repaired; it fails when some are left.
+func runVerify(ctx context.Context, catalogProcessor *processor.CatalogProcessor, fix bool, out io.Writer) error {
+	report, err := catalogProcessor.VerifyArchive(ctx, fix)
+	if err != nil {
+		return err
+	}
+
+	for _, issue := range report.Issues {
+		status := "PROBLEM"
+		if issue.Fixed {
+			status = "FIXED"
+		}
+		location := issue.Catalog
+		if location == "" {
+			location = "(root index)"
+		}
+		if issue.Record != "" {
+			location += "/" + issue.Record
+		}
+		fmt.Fprintf(out, "%s: %s %s: %s\n", status, issue.Problem, location, issue.Detail)
+	}
+
+	unfixed := report.Unfixed()
+	fmt.Fprintf(out, "Verified %d records in %d catalogs: %d problems, %d fixed\n",
+		report.Records, report.Catalogs, len(report.Issues), len(report.Issues)-unfixed)
+	if unfixed > 0 && !fix {
+		return fmt.Errorf("%w: %d left, run with --fix to repair them", errVerifyFailed, unfixed)
+	}
+	if unfixed > 0 {
+		return fmt.Errorf("%w: %d could not be repaired and need manual attention", errVerifyFailed, unfixed)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"kbase-catalog/internal/config"
+	"kbase-catalog/internal/processor"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunVerify(t *testing.T) {
+	archiveDir := t.TempDir()
+	catalogDir := filepath.Join(archiveDir, "shapes")
+	assert.NoError(t, os.MkdirAll(catalogDir, 0755))
+	writeTestPNG(t, filepath.Join(catalogDir, "red.png"))
+	writeTestPNG(t, filepath.Join(catalogDir, "blue.png"))
+	index := `{"red.png": {"short_name": "Red"}, "blue.png": {"short_name": "Blue"}}`
+	assert.NoError(t, os.WriteFile(filepath.Join(catalogDir, "index.json"), []byte(index), 0644))
+
+	catalogProcessor := processor.NewCatalogProcessor(config.GetDefaultConfig(), archiveDir)
+	ctx := context.Background()
+	assert.NoError(t, catalogProcessor.RebuildRootIndex(ctx))
+
+	t.Run("Healthy archive", func(t *testing.T) {
+		var out bytes.Buffer
+		assert.NoError(t, runVerify(ctx, catalogProcessor, false, &out))
+		assert.Equal(t, "Verified 2 records in 1 catalogs: 0 problems, 0 fixed\n", out.String())
+	})
+
+	t.Run("Corrupt archive", func(t *testing.T) {
+		assert.NoError(t, os.Remove(filepath.Join(catalogDir, "blue.png")))
+
+		var out bytes.Buffer
+		err := runVerify(ctx, catalogProcessor, false, &out)
+		assert.ErrorIs(t, err, errVerifyFailed)
+		assert.ErrorContains(t, err, "run with --fix")
+		assert.Contains(t, out.String(), "PROBLEM: missing_image shapes/blue.png: image file not found\n")
+		assert.Contains(t, out.String(), "PROBLEM: root_entry_mismatch shapes: image_count is 2, the catalog has 1 images\n")
+
+		out.Reset()
+		assert.NoError(t, runVerify(ctx, catalogProcessor, true, &out))
+		assert.Contains(t, out.String(), "FIXED: missing_image shapes/blue.png")
+		assert.Contains(t, out.String(), "2 problems, 2 fixed")
+
+		out.Reset()
+		assert.NoError(t, runVerify(ctx, catalogProcessor, false, &out))
+	})
+}
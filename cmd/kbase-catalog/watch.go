@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"kbase-catalog/internal/config"
+	"kbase-catalog/internal/webserver/queue"
+	"kbase-catalog/internal/webserver/watch"
+)
+
+// runWatch reindexes the catalogs of archiveDir whose images change, through the task queue and
+// catalog watcher of the web server, until ctx is cancelled. The running task may finish
+// within queue_drain_timeout before the queue stops.
+func runWatch(ctx context.Context, cfg *config.Config, indexer queue.CatalogIndexer, archiveDir string, out io.Writer) error {
+	taskQueue := queue.NewTaskQueue(cfg, indexer, archiveDir)
+	if err := taskQueue.Start(); err != nil {
+		return fmt.Errorf("failed to start task queue: %w", err)
+	}
+	defer taskQueue.Stop()
+
+	watcher, err := watch.NewCatalogWatcher(taskQueue, archiveDir)
+	if err != nil {
+		return fmt.Errorf("failed to create watcher: %w", err)
+	}
+	if ignores, ok := indexer.(watch.IgnoreReloader); ok {
+		watcher.SetIgnoreReloader(ignores)
+	}
+	if err := watcher.Start(); err != nil {
+		watcher.Stop()
+		return fmt.Errorf("failed to start watcher: %w", err)
+	}
+	defer watcher.Stop()
+
+	fmt.Fprintf(out, "Watching %s for changes, press Ctrl+C to stop\n", archiveDir)
+	<-ctx.Done()
+	fmt.Fprintln(out, "Stopping the watcher...")
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"kbase-catalog/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingIndexer reports the catalogs it is asked to reindex
+type recordingIndexer struct {
+	catalogs chan string
+}
+
+func (r *recordingIndexer) ProcessImagesCatalog(ctx context.Context, catalogDir string) error {
+	r.catalogs <- filepath.Base(catalogDir)
+	return nil
+}
+
+// signalWriter closes written on the first write
+type signalWriter struct {
+	written chan struct{}
+}
+
+func (w *signalWriter) Write(p []byte) (int, error) {
+	select {
+	case <-w.written:
+	default:
+		close(w.written)
+	}
+	return len(p), nil
+}
+
+func TestRunWatch(t *testing.T) {
+	archiveDir := t.TempDir()
+	catalogDir := filepath.Join(archiveDir, "shapes")
+	assert.NoError(t, os.MkdirAll(catalogDir, 0755))
+
+	cfg := config.GetDefaultConfig()
+	indexer := &recordingIndexer{catalogs: make(chan string, 10)}
+	out := &signalWriter{written: make(chan struct{})}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- runWatch(ctx, cfg, indexer, archiveDir, out) }()
+
+	select {
+	case <-out.written:
+	case <-time.After(5 * time.Second):
+		t.Fatal("watcher didn't start")
+	}
+
+	// An image added after the initial pass reindexes its catalog
+	writeTestPNG(t, filepath.Join(catalogDir, "red.png"))
+	select {
+	case catalog := <-indexer.catalogs:
+		assert.Equal(t, "shapes", catalog)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reindex task for the new image")
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("watch didn't stop")
+	}
+}
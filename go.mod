@@ -3,7 +3,9 @@ module kbase-catalog
 go 1.24.0
 
 require (
+	github.com/chai2010/webp v1.4.0
 	github.com/fsnotify/fsnotify v1.9.0
+	github.com/jdeng/goheif v0.1.2
 	github.com/moby/patternmatcher v0.6.0
 	github.com/spf13/cobra v1.10.2
 	github.com/stretchr/testify v1.11.1
@@ -12,7 +14,6 @@ require (
 )
 
 require (
-	github.com/chai2010/webp v1.4.0 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
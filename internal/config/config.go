@@ -1,29 +1,165 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
+	"kbase-catalog/internal/logging"
+
+	"github.com/moby/patternmatcher"
 	"gopkg.in/yaml.v2"
 )
 
 type Config struct {
 	APIURL                 string   `yaml:"api_url"`
+	APIURLs                []string `yaml:"api_urls"`
+	APIURLMode             string   `yaml:"api_url_mode"`
+	Provider               string   `yaml:"provider"`
+	APIKey                 string   `yaml:"api_key"`
 	Model                  string   `yaml:"model"`
 	Timeout                int      `yaml:"timeout"`
 	SystemPrompt           string   `yaml:"system_prompt"`
 	SupportedExtensions    []string `yaml:"supported_extensions"`
 	ConvertImageExtensions []string `yaml:"convert_image_extensions"`
+	IncludeFilter          []string `yaml:"include_filter"`
 	ExcludeFilter          []string `yaml:"exclude_filter"`
 	ParallelRequests       int      `yaml:"parallel_requests"`
+	BatchSize              int      `yaml:"batch_size"`
 	MaxRetries             int      `yaml:"max_retries"`
-	RetryDelay             int      `yaml:"retry_delay"`
+	RetryDelay             int      `yaml:"retry_delay"` // Unused, kept so existing configuration files still load
+	TaskMode               string   `yaml:"task_mode"`
+	RequestsPerSecond      float64  `yaml:"requests_per_second"`
+	TaskTimeoutSeconds     int      `yaml:"task_timeout_seconds"`
+	QueueMaxRetries        int      `yaml:"queue_max_retries"`
+	QueueRetryDelay        int      `yaml:"queue_retry_delay"`
+	MetricsEnabled         bool     `yaml:"metrics_enabled"`
+	LogLevel               string   `yaml:"log_level"`
+	LogFormat              string   `yaml:"log_format"`
+	MaxFileSizeMB          int      `yaml:"max_file_size_mb"`
+	RecursiveCatalogs      bool     `yaml:"recursive_catalogs"`
+	DebugLLM               bool     `yaml:"debug_llm"`
+	LLMMaxIdleConns        int      `yaml:"llm_max_idle_conns"`
+	LLMMaxConnsPerHost     int      `yaml:"llm_max_conns_per_host"`
+	WebAuthUser            string   `yaml:"web_auth_user"`
+	WebAuthPassword        string   `yaml:"web_auth_password"`
+	WebAPIToken            string   `yaml:"web_api_token"`
+	// OutputLanguage is the language descriptions are written in, the system prompt decides when empty
+	OutputLanguage string `yaml:"output_language"`
+	// ResponseFieldMap maps keys of the model's JSON answer onto the response fields, e.g. title: short_name
+	ResponseFieldMap map[string]string `yaml:"response_field_map"`
+	// ExportJSONL also writes the indexes as JSON Lines, one record per line, for streaming consumers
+	ExportJSONL bool `yaml:"export_jsonl"`
+	// QueueSize is the number of reindex tasks the web task queue holds before dropping new ones
+	QueueSize int `yaml:"queue_size"`
+	// QueueDrainTimeout is how long stopping the web server waits for the running reindex task
+	QueueDrainTimeout int `yaml:"queue_drain_timeout"`
+	// IndexJSONName and IndexMDName are the file names of the indexes, index.json and index.md when empty
+	IndexJSONName string `yaml:"index_json_name"`
+	IndexMDName   string `yaml:"index_md_name"`
+	// EmbedThumbnails stores a small JPEG preview of every image in its record as a data URL
+	EmbedThumbnails bool `yaml:"embed_thumbnails"`
+	// MinDescriptionLength is the number of characters below which --recheck describes an image again
+	MinDescriptionLength int `yaml:"min_description_length"`
+	// TemplateDir holds web templates used instead of the built-in ones of the same name
+	TemplateDir string `yaml:"template_dir"`
+	// ArchiveBaseURL prefixes the image URLs of the web interface, /archive when empty
+	ArchiveBaseURL string `yaml:"archive_base_url"`
+	// BasePath is the path prefix the web interface is served under, e.g. /kbase behind a reverse proxy
+	BasePath string `yaml:"base_path"`
+	// Temperature and MaxTokens are sent to the LLM API when positive, its defaults apply otherwise
+	Temperature float64 `yaml:"temperature"`
+	MaxTokens   int     `yaml:"max_tokens"`
+	// Seed is sent to the LLM API when not 0, for reproducible descriptions on servers honoring it
+	Seed int `yaml:"seed"`
+	// CheckpointEvery saves a catalog index after every that many processed images, 0 only at the end
+	CheckpointEvery int `yaml:"checkpoint_every"`
+	// ParallelCatalogs is the number of catalogs processed at once, each with ParallelRequests workers
+	ParallelCatalogs int `yaml:"parallel_catalogs"`
+
+	// RetryFailed reprocesses images that failed permanently, set by the --retry-failed flag
+	RetryFailed bool `yaml:"-"`
+	// RecheckDescriptions reprocesses images described in fewer than MinDescriptionLength
+	// characters, set by the --recheck flag
+	RecheckDescriptions bool `yaml:"-"`
+	// Since leaves described images modified before it alone, set by the --since flag
+	Since time.Time `yaml:"-"`
 }
 
-func LoadConfig(configPath string) (*Config, error) {
-	if configPath == "" {
-		configPath = "config.yaml"
+// MaxInFlightRequests caps the LLM requests parallel_catalogs × parallel_requests workers may
+// have in flight at once when several catalogs are processed at once
+const MaxInFlightRequests = 64
+
+// DefaultTaskTimeoutSeconds bounds a single catalog reindex task when no timeout is configured
+const DefaultTaskTimeoutSeconds = 3600
+
+// DefaultQueueRetryDelaySeconds is the pause before a failed reindex task is queued again
+// when no delay is configured, so a failing catalog never retries in a tight loop
+const DefaultQueueRetryDelaySeconds = 30
+
+// DefaultQueueSize is the number of pending reindex tasks when no queue size is configured
+const DefaultQueueSize = 100
+
+// DefaultQueueDrainTimeoutSeconds is how long the web server waits for the running reindex
+// task when it stops
+const DefaultQueueDrainTimeoutSeconds = 30
+
+// Default file names of the indexes
+const (
+	DefaultIndexJSONName = "index.json"
+	DefaultIndexMDName   = "index.md"
+)
+
+// DefaultArchiveBaseURL is the path the web server serves the archive images under
+const DefaultArchiveBaseURL = "/archive"
+
+// Supported values for Config.APIURLMode
+const (
+	APIURLModeFailover   = "failover"
+	APIURLModeRoundRobin = "round_robin"
+)
+
+// ResponseFields are the keys of the JSON answer the response_field_map values may name
+var ResponseFields = []string{"short_name", "description", "long_description", "text", "tags"}
+
+// Supported values for Config.Provider
+const (
+	ProviderOpenAI = "openai"
+	ProviderOllama = "ollama"
+)
+
+// Supported values for Config.TaskMode
+const (
+	TaskModeDescribe = "describe"
+	TaskModeOCR      = "ocr"
+)
+
+// DefaultConfigPath is the configuration file used when no path is given
+const DefaultConfigPath = "config.yaml"
+
+// ConfigPathEnv names the environment variable selecting the configuration file when no
+// path is given explicitly
+const ConfigPathEnv = "KBASE_CONFIG"
+
+// ResolveConfigPath returns the configuration file to load: the given path, the one from
+// the KBASE_CONFIG environment variable or config.yaml in the working directory
+func ResolveConfigPath(configPath string) string {
+	if configPath != "" {
+		return configPath
 	}
+	if envPath := os.Getenv(ConfigPathEnv); envPath != "" {
+		return envPath
+	}
+	return DefaultConfigPath
+}
+
+func LoadConfig(configPath string) (*Config, error) {
+	configPath = ResolveConfigPath(configPath)
 
 	// Check if file exists
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
@@ -33,48 +169,170 @@ func LoadConfig(configPath string) (*Config, error) {
 	// Read YAML file
 	content, err := os.ReadFile(configPath)
 	if err != nil {
-		return nil, fmt.Errorf("error reading configuration file: %w", err)
+		return nil, fmt.Errorf("error reading configuration file %s: %w", configPath, err)
 	}
 
 	var config Config
 	err = yaml.Unmarshal(content, &config)
 	if err != nil {
-		return nil, fmt.Errorf("error parsing configuration file: %w", err)
+		return nil, fmt.Errorf("error parsing configuration file %s: %w", configPath, err)
+	}
+
+	// Environment variables win over the file
+	if err := applyEnvOverrides(&config); err != nil {
+		return nil, fmt.Errorf("invalid environment override: %w", err)
+	}
+
+	if err := normalizeConfig(&config); err != nil {
+		return nil, fmt.Errorf("invalid configuration in %s: %w", configPath, err)
 	}
 
 	// Validate configuration
 	if err := validateConfig(&config); err != nil {
-		return nil, fmt.Errorf("invalid configuration: %w", err)
+		return nil, fmt.Errorf("invalid configuration in %s: %w", configPath, err)
 	}
 
 	return &config, nil
 }
 
+// stringEnvOverrides maps environment variables to the string fields they override
+var stringEnvOverrides = []struct {
+	name  string
+	field func(*Config) *string
+}{
+	{"KBASE_API_URL", func(c *Config) *string { return &c.APIURL }},
+	{"KBASE_API_KEY", func(c *Config) *string { return &c.APIKey }},
+	{"KBASE_MODEL", func(c *Config) *string { return &c.Model }},
+	{"KBASE_PROVIDER", func(c *Config) *string { return &c.Provider }},
+	{"KBASE_TASK_MODE", func(c *Config) *string { return &c.TaskMode }},
+	{"KBASE_OUTPUT_LANGUAGE", func(c *Config) *string { return &c.OutputLanguage }},
+	{"KBASE_LOG_LEVEL", func(c *Config) *string { return &c.LogLevel }},
+	{"KBASE_LOG_FORMAT", func(c *Config) *string { return &c.LogFormat }},
+	{"KBASE_WEB_AUTH_USER", func(c *Config) *string { return &c.WebAuthUser }},
+	{"KBASE_WEB_AUTH_PASSWORD", func(c *Config) *string { return &c.WebAuthPassword }},
+	{"KBASE_WEB_API_TOKEN", func(c *Config) *string { return &c.WebAPIToken }},
+}
+
+// intEnvOverrides maps environment variables to the integer fields they override
+var intEnvOverrides = []struct {
+	name  string
+	field func(*Config) *int
+}{
+	{"KBASE_TIMEOUT", func(c *Config) *int { return &c.Timeout }},
+	{"KBASE_PARALLEL_REQUESTS", func(c *Config) *int { return &c.ParallelRequests }},
+	{"KBASE_MAX_RETRIES", func(c *Config) *int { return &c.MaxRetries }},
+}
+
+// applyEnvOverrides replaces configuration values with the ones set in KBASE_* environment variables
+func applyEnvOverrides(config *Config) error {
+	for _, override := range stringEnvOverrides {
+		if value := os.Getenv(override.name); value != "" {
+			*override.field(config) = value
+		}
+	}
+
+	for _, override := range intEnvOverrides {
+		value := os.Getenv(override.name)
+		if value == "" {
+			continue
+		}
+		number, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("%s must be an integer, got %q", override.name, value)
+		}
+		*override.field(config) = number
+	}
+
+	return nil
+}
+
 func GetDefaultConfig() *Config {
 	return &Config{
-		APIURL:  "http://localhost:1234/v1/chat/completions",
-		Model:   "llava-v1.5-7b",
-		Timeout: 60,
+		APIURL:     "http://localhost:1234/v1/chat/completions",
+		APIURLs:    []string{},
+		APIURLMode: APIURLModeFailover,
+		Provider:   ProviderOpenAI,
+		Model:      "llava-v1.5-7b",
+		Timeout:    60,
 		SystemPrompt: `You are a helpful assistant specialized in image analysis.
 You must respond in valid JSON format ONLY, without any extra text.
-The JSON must contain two keys:
+The JSON must contain four keys:
 1. "short_name": a short, descriptive name for the image.
-2. "description": a detailed description of the image in English.
+2. "description": a one sentence description of the image in English.
+3. "long_description": a detailed paragraph describing the image in English.
+4. "tags": an array of up to 5 short lowercase keywords describing the image.
 
 Example output format:
-{"short_name": "Sunset on the beach", "description": "The image shows a sunset at sea..."}`,
-		SupportedExtensions:    []string{".png", ".jpg", ".jpeg", ".webp", ".gif", ".bmp"},
-		ConvertImageExtensions: []string{".png", ".tiff", ".bmp", ".gif", "jpg", "jpeg"},
+{"short_name": "Sunset on the beach", "description": "A sunset at sea.", "long_description": "The image shows a sunset at sea...", "tags": ["sunset", "beach", "sea"]}`,
+		SupportedExtensions:    []string{".png", ".jpg", ".jpeg", ".webp", ".gif", ".bmp", ".tif", ".tiff"},
+		ConvertImageExtensions: []string{".png", ".tiff", ".bmp", ".gif", ".jpg", ".jpeg", ".heic", ".heif"},
+		IncludeFilter:          []string{},
 		ExcludeFilter:          []string{},
 		ParallelRequests:       3,
 		MaxRetries:             3,
 		RetryDelay:             5,
+		TaskMode:               TaskModeDescribe,
+		RequestsPerSecond:      0,
+		TaskTimeoutSeconds:     DefaultTaskTimeoutSeconds,
+		QueueMaxRetries:        3,
+		QueueRetryDelay:        DefaultQueueRetryDelaySeconds,
+		QueueSize:              DefaultQueueSize,
+		QueueDrainTimeout:      DefaultQueueDrainTimeoutSeconds,
+		IndexJSONName:          DefaultIndexJSONName,
+		IndexMDName:            DefaultIndexMDName,
+		ArchiveBaseURL:         DefaultArchiveBaseURL,
+		MetricsEnabled:         false,
+		LogLevel:               "info",
+		LogFormat:              logging.FormatText,
+		MaxFileSizeMB:          50,
+		ResponseFieldMap:       map[string]string{},
+	}
+}
+
+// normalizeConfig brings loosely written values to the form the rest of the code expects
+func normalizeConfig(config *Config) error {
+	var err error
+	if config.SupportedExtensions, err = normalizeExtensions(config.SupportedExtensions); err != nil {
+		return fmt.Errorf("supported_extensions: %w", err)
+	}
+	if config.ConvertImageExtensions, err = normalizeExtensions(config.ConvertImageExtensions); err != nil {
+		return fmt.Errorf("convert_image_extensions: %w", err)
+	}
+	return nil
+}
+
+// normalizeExtensions lowercases file extensions, prefixes them with a dot and removes
+// duplicates, so "PNG" matches the ".png" returned by filepath.Ext
+func normalizeExtensions(extensions []string) ([]string, error) {
+	if extensions == nil {
+		return nil, nil
 	}
+
+	normalized := make([]string, 0, len(extensions))
+	for _, ext := range extensions {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		ext = strings.TrimPrefix(ext, ".")
+		if ext == "" {
+			return nil, fmt.Errorf("empty extension")
+		}
+
+		ext = "." + ext
+		if !slices.Contains(normalized, ext) {
+			normalized = append(normalized, ext)
+		}
+	}
+	return normalized, nil
 }
 
 func validateConfig(config *Config) error {
-	if config.APIURL == "" {
-		return fmt.Errorf("api_url is required")
+	if len(config.GetAPIURLs()) == 0 {
+		return fmt.Errorf("api_url is required unless api_urls is set")
+	}
+	if slices.Contains(config.APIURLs, "") {
+		return fmt.Errorf("api_urls must not contain empty URLs")
+	}
+	if config.APIURLMode != "" && config.APIURLMode != APIURLModeFailover && config.APIURLMode != APIURLModeRoundRobin {
+		return fmt.Errorf("api_url_mode must be either %q or %q", APIURLModeFailover, APIURLModeRoundRobin)
 	}
 	if config.Model == "" {
 		return fmt.Errorf("model is required")
@@ -85,18 +343,244 @@ func validateConfig(config *Config) error {
 	if config.ParallelRequests <= 0 {
 		return fmt.Errorf("parallel_requests must be positive")
 	}
+	if config.BatchSize < 0 {
+		return fmt.Errorf("batch_size must be non-negative")
+	}
+	if config.ParallelCatalogs < 0 {
+		return fmt.Errorf("parallel_catalogs must be non-negative")
+	}
+	if config.ParallelCatalogs > 1 && config.GetInFlightRequests() > MaxInFlightRequests {
+		return fmt.Errorf("parallel_catalogs × parallel_requests must be at most %d, got %d", MaxInFlightRequests, config.GetInFlightRequests())
+	}
 	if config.MaxRetries < 0 {
 		return fmt.Errorf("max_retries must be non-negative")
 	}
 	if config.RetryDelay < 0 {
 		return fmt.Errorf("retry_delay must be non-negative")
 	}
+	if config.RequestsPerSecond < 0 {
+		return fmt.Errorf("requests_per_second must be non-negative")
+	}
+	if config.TaskTimeoutSeconds < 0 {
+		return fmt.Errorf("task_timeout_seconds must be non-negative")
+	}
+	if config.MaxFileSizeMB < 0 {
+		return fmt.Errorf("max_file_size_mb must be non-negative")
+	}
+	if config.QueueMaxRetries < 0 {
+		return fmt.Errorf("queue_max_retries must be non-negative")
+	}
+	if config.QueueSize < 0 {
+		return fmt.Errorf("queue_size must be positive, or 0 for the default")
+	}
+	if config.QueueDrainTimeout < 0 {
+		return fmt.Errorf("queue_drain_timeout must be non-negative")
+	}
+	for _, field := range [][2]string{{"index_json_name", config.IndexJSONName}, {"index_md_name", config.IndexMDName}} {
+		if name := field[1]; name != "" && (name == "." || name == ".." || strings.ContainsAny(name, `/\`)) {
+			return fmt.Errorf("%s must be a file name without directories, got %q", field[0], name)
+		}
+	}
+	if indexNames := []string{config.GetIndexJSONName(), config.GetIndexMDName(), config.GetIndexJSONLName()}; len(slices.Compact(slices.Sorted(slices.Values(indexNames)))) != len(indexNames) {
+		return fmt.Errorf("index_json_name and index_md_name must name different files")
+	}
+	if base := config.ArchiveBaseURL; base != "" && !strings.HasPrefix(base, "/") && !strings.HasPrefix(base, "http://") && !strings.HasPrefix(base, "https://") {
+		return fmt.Errorf("archive_base_url must be a path starting with / or an http(s) URL, got %q", base)
+	}
+	if config.BasePath != "" && (!strings.HasPrefix(config.BasePath, "/") || strings.ContainsAny(config.BasePath, "?#")) {
+		return fmt.Errorf("base_path must be a path starting with /, got %q", config.BasePath)
+	}
+	if config.Temperature < 0 || config.Temperature > 2 {
+		return fmt.Errorf("temperature must be between 0 and 2")
+	}
+	if config.MaxTokens < 0 {
+		return fmt.Errorf("max_tokens must be non-negative")
+	}
+	if config.CheckpointEvery < 0 {
+		return fmt.Errorf("checkpoint_every must be non-negative")
+	}
+	if config.MinDescriptionLength < 0 {
+		return fmt.Errorf("min_description_length must be non-negative")
+	}
+	if config.LLMMaxIdleConns < 0 {
+		return fmt.Errorf("llm_max_idle_conns must be non-negative")
+	}
+	if config.LLMMaxConnsPerHost < 0 {
+		return fmt.Errorf("llm_max_conns_per_host must be non-negative")
+	}
+	for _, ext := range append(slices.Clone(config.SupportedExtensions), config.ConvertImageExtensions...) {
+		if !strings.HasPrefix(ext, ".") || len(ext) < 2 || ext != strings.ToLower(ext) {
+			return fmt.Errorf("invalid extension %q, expected a lowercase extension like \".png\"", ext)
+		}
+	}
+	if _, err := patternmatcher.New(config.IncludeFilter); err != nil {
+		return fmt.Errorf("invalid include_filter: %w", err)
+	}
+	if _, err := logging.ParseLevel(config.LogLevel); err != nil {
+		return fmt.Errorf("log_level must be one of debug, info, warn or error")
+	}
+	if format := strings.ToLower(config.LogFormat); format != "" && format != logging.FormatText && format != logging.FormatJSON {
+		return fmt.Errorf("log_format must be either %q or %q", logging.FormatText, logging.FormatJSON)
+	}
+	if config.Provider != "" && config.Provider != ProviderOpenAI && config.Provider != ProviderOllama {
+		return fmt.Errorf("provider must be either %q or %q", ProviderOpenAI, ProviderOllama)
+	}
+	if config.TaskMode != "" && config.TaskMode != TaskModeDescribe && config.TaskMode != TaskModeOCR {
+		return fmt.Errorf("task_mode must be either %q or %q", TaskModeDescribe, TaskModeOCR)
+	}
+	for from, to := range config.ResponseFieldMap {
+		if from == "" || !slices.Contains(ResponseFields, to) {
+			return fmt.Errorf("response_field_map: %q must map onto one of %s", from, strings.Join(ResponseFields, ", "))
+		}
+	}
+	if (config.WebAuthUser == "") != (config.WebAuthPassword == "") {
+		return fmt.Errorf("web_auth_user and web_auth_password must be set together")
+	}
 	return nil
 }
 
+// GetAPIURLs returns the LLM API endpoints in the order they are tried. api_urls takes
+// precedence, api_url is the shorthand for a single endpoint.
+func (c *Config) GetAPIURLs() []string {
+	if len(c.APIURLs) > 0 {
+		return c.APIURLs
+	}
+	if c.APIURL == "" {
+		return nil
+	}
+	return []string{c.APIURL}
+}
+
+// IsWebAuthEnabled reports whether the web server requires credentials
+func (c *Config) IsWebAuthEnabled() bool {
+	return c.WebAuthUser != "" || c.WebAPIToken != ""
+}
+
+// IsOCRMode reports whether images should be processed for their visible text
+// instead of a free-form description
+func (c *Config) IsOCRMode() bool {
+	return c.TaskMode == TaskModeOCR
+}
+
+// GetTaskTimeout returns the maximum duration of a single catalog reindex task
+func (c *Config) GetTaskTimeout() time.Duration {
+	if c.TaskTimeoutSeconds <= 0 {
+		return DefaultTaskTimeoutSeconds * time.Second
+	}
+	return time.Duration(c.TaskTimeoutSeconds) * time.Second
+}
+
+// GetBatchSize returns how many images are described with a single LLM request, 1 when
+// batching is off
+func (c *Config) GetBatchSize() int {
+	return max(c.BatchSize, 1)
+}
+
+// GetParallelCatalogs returns how many catalogs are processed at once, 1 when they are
+// processed one after the other
+func (c *Config) GetParallelCatalogs() int {
+	return max(c.ParallelCatalogs, 1)
+}
+
+// GetInFlightRequests returns how many LLM requests the workers of all catalogs processed at
+// once may have in flight
+func (c *Config) GetInFlightRequests() int {
+	return max(c.ParallelRequests, 1) * c.GetParallelCatalogs()
+}
+
+// GetMaxFileSize returns the size limit of images sent to the LLM in bytes, 0 means no limit
+func (c *Config) GetMaxFileSize() int64 {
+	return int64(c.MaxFileSizeMB) * 1024 * 1024
+}
+
+// GetLLMMaxIdleConns returns how many idle connections to the LLM API are kept open for reuse.
+// By default every parallel worker of every catalog keeps its connection.
+func (c *Config) GetLLMMaxIdleConns() int {
+	if c.LLMMaxIdleConns <= 0 {
+		return c.GetInFlightRequests()
+	}
+	return c.LLMMaxIdleConns
+}
+
+// GetLLMMaxConnsPerHost returns the limit of open connections to the LLM API host. By default
+// it leaves room for requests made next to the parallel workers, such as web reprocessing.
+func (c *Config) GetLLMMaxConnsPerHost() int {
+	if c.LLMMaxConnsPerHost <= 0 {
+		return 2 * c.GetInFlightRequests()
+	}
+	return c.LLMMaxConnsPerHost
+}
+
+// GetQueueSize returns the number of pending reindex tasks the web task queue holds
+func (c *Config) GetQueueSize() int {
+	if c.QueueSize <= 0 {
+		return DefaultQueueSize
+	}
+	return c.QueueSize
+}
+
+// GetQueueDrainTimeout returns how long stopping the task queue waits for the running task
+// before cancelling it. Zero cancels it at once.
+func (c *Config) GetQueueDrainTimeout() time.Duration {
+	return time.Duration(c.QueueDrainTimeout) * time.Second
+}
+
+// GetIndexJSONName returns the file name of the JSON indexes. It is safe on a nil config.
+func (c *Config) GetIndexJSONName() string {
+	if c == nil || c.IndexJSONName == "" {
+		return DefaultIndexJSONName
+	}
+	return c.IndexJSONName
+}
+
+// GetIndexMDName returns the file name of the markdown indexes. It is safe on a nil config.
+func (c *Config) GetIndexMDName() string {
+	if c == nil || c.IndexMDName == "" {
+		return DefaultIndexMDName
+	}
+	return c.IndexMDName
+}
+
+// GetIndexJSONLName returns the file name of the JSON Lines indexes, the JSON index name with a
+// .jsonl extension
+func (c *Config) GetIndexJSONLName() string {
+	name := c.GetIndexJSONName()
+	return strings.TrimSuffix(name, filepath.Ext(name)) + ".jsonl"
+}
+
+// GetArchiveBaseURL returns the prefix of the image URLs without a trailing slash. It is safe
+// on a nil config.
+func (c *Config) GetArchiveBaseURL() string {
+	if c == nil || c.ArchiveBaseURL == "" {
+		return DefaultArchiveBaseURL
+	}
+	return strings.TrimSuffix(c.ArchiveBaseURL, "/")
+}
+
+// GetBasePath returns the path prefix of the web interface without a trailing slash, empty when
+// it is served at the root. It is safe on a nil config.
+func (c *Config) GetBasePath() string {
+	if c == nil {
+		return ""
+	}
+	return strings.TrimSuffix(c.BasePath, "/")
+}
+
+// GetQueueRetryDelay returns the pause before a failed reindex task is queued again. A negative
+// delay retries it right away.
+func (c *Config) GetQueueRetryDelay() time.Duration {
+	if c.QueueRetryDelay < 0 {
+		return 0
+	}
+	if c.QueueRetryDelay == 0 {
+		return DefaultQueueRetryDelaySeconds * time.Second
+	}
+	return time.Duration(c.QueueRetryDelay) * time.Second
+}
+
 func (c *Config) WriteToFile(configPath string) error {
 	if configPath == "" {
-		configPath = "config.yaml"
+		configPath = DefaultConfigPath
 	}
 
 	data, err := yaml.Marshal(c)
@@ -106,3 +590,90 @@ func (c *Config) WriteToFile(configPath string) error {
 
 	return os.WriteFile(configPath, data, 0644)
 }
+
+// ErrConfigExists is returned by InitConfigFile when it would overwrite a configuration file
+var ErrConfigExists = errors.New("configuration file already exists")
+
+// fieldComments describe the configuration keys in files written by InitConfigFile
+var fieldComments = map[string]string{
+	"api_url":                  "OpenAI-compatible chat completions endpoint",
+	"api_urls":                 "Several endpoints used instead of api_url, the next one is tried when one fails",
+	"provider":                 "API format: \"openai\" chat completions or \"ollama\" native /api/generate",
+	"api_url_mode":             "\"failover\" tries api_urls in order, \"round_robin\" spreads requests across them",
+	"api_key":                  "Bearer token sent to the API, leave empty for local servers",
+	"model":                    "Vision model used to describe images",
+	"timeout":                  "LLM request timeout in seconds",
+	"system_prompt":            "Instructions sent with every image, the answer must be JSON",
+	"supported_extensions":     "Image extensions processed by the catalog",
+	"convert_image_extensions": "Image extensions converted to WebP by convert-images",
+	"include_filter":           "Patterns of the only files and directories to process, matched like exclude_filter (empty = everything)",
+	"exclude_filter":           "Patterns of files and directories to skip, matched against their path relative to the archive directory",
+	"parallel_requests":        "Number of images processed concurrently",
+	"batch_size":               "Images sent with a single LLM request, for models accepting several images (0 or 1 = off)",
+	"max_retries":              "Runs that try a failing image before it is marked failed, 0 retries it on every run",
+	"retry_delay":              "Unused, kept so existing configuration files still load",
+	"task_mode":                "\"describe\" for descriptions or \"ocr\" to extract visible text",
+	"requests_per_second":      "Max LLM requests per second shared by all workers (0 = unlimited)",
+	"task_timeout_seconds":     "Max duration of a single web reindex task in seconds",
+	"queue_max_retries":        "How many times a failed web reindex task is queued again",
+	"queue_retry_delay":        "Pause before a failed web reindex task is retried in seconds (0 = 30, negative = right away)",
+	"queue_size":               "Pending web reindex tasks held before new ones are rejected",
+	"queue_drain_timeout":      "Seconds the web server waits on shutdown for the running reindex task (0 = cancel it at once)",
+	"index_json_name":          "File name of the JSON indexes, the JSON Lines indexes take its name with a .jsonl extension",
+	"index_md_name":            "File name of the markdown indexes",
+	"embed_thumbnails":         "Store a 64px JPEG thumbnail of every image in its record as a base64 data URL",
+	"min_description_length":   "process --recheck describes again the images whose description is shorter (0 = off)",
+	"template_dir":             "Directory of web templates used instead of the built-in ones of the same name",
+	"archive_base_url":         "Prefix of the image URLs of the web interface, e.g. a CDN serving the archive, paths are under base_path",
+	"base_path":                "Path prefix the web interface is served under, e.g. /kbase behind a reverse proxy",
+	"temperature":              "Sampling temperature of the model, lower values give more reliable JSON (0 = API default)",
+	"max_tokens":               "Maximal length of the answer in tokens (0 = API default)",
+	"seed":                     "Sampling seed for reproducible descriptions, on servers supporting it (0 = not sent)",
+	"checkpoint_every":         "Save the catalog index after every that many processed images, so a crashed run resumes from there (0 = only at the end)",
+	"parallel_catalogs":        "Number of catalogs processed at once, each with parallel_requests workers; above 1 their product is capped at 64 (0 or 1 = one after the other)",
+	"metrics_enabled":          "Expose Prometheus-style metrics at /metrics in web mode",
+	"log_level":                "Minimal log level: debug, info, warn or error",
+	"log_format":               "Log output format: text or json",
+	"max_file_size_mb":         "Larger images are skipped instead of sent to the LLM (0 = no limit)",
+	"recursive_catalogs":       "Index the images of catalog subdirectories too, keyed by their relative path",
+	"debug_llm":                "Log LLM requests and responses at debug level, images elided and the API key redacted",
+	"llm_max_idle_conns":       "Idle LLM API connections kept for reuse (0 = parallel_requests)",
+	"llm_max_conns_per_host":   "Max open connections to the LLM API host (0 = twice parallel_requests)",
+	"web_auth_user":            "HTTP Basic auth user of the web server, leave empty to disable",
+	"web_auth_password":        "HTTP Basic auth password of the web server",
+	"web_api_token":            "Token accepted as \"Authorization: Bearer <token>\" by the web server",
+	"output_language":          "Language of the generated descriptions, e.g. German (empty = as the system prompt asks)",
+	"response_field_map":       "Keys of the model answer renamed to short_name, description, long_description, text or tags, e.g. title: short_name",
+	"export_jsonl":             "Also write index.jsonl next to every index.json, one record per line",
+}
+
+// InitConfigFile writes the default configuration with a comment above each key. An existing
+// file is only replaced when force is set.
+func InitConfigFile(configPath string, force bool) error {
+	if configPath == "" {
+		configPath = DefaultConfigPath
+	}
+
+	if _, err := os.Stat(configPath); err == nil && !force {
+		return fmt.Errorf("%w: %s (use --force to overwrite)", ErrConfigExists, configPath)
+	}
+
+	data, err := yaml.Marshal(GetDefaultConfig())
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+
+	var content strings.Builder
+	content.WriteString("# KBase Image Catalog configuration\n")
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		// Top level keys start at the beginning of the line
+		if key, _, found := strings.Cut(line, ":"); found && !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "-") {
+			if comment, ok := fieldComments[key]; ok {
+				content.WriteString("\n# " + comment + "\n")
+			}
+		}
+		content.WriteString(line)
+	}
+
+	return os.WriteFile(configPath, []byte(content.String()), 0644)
+}
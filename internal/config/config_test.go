@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -49,9 +50,111 @@ retry_delay: 5
 	assert.Equal(t, []string{"*/temp/*", "*/tmp/*", "*.tmp", "*.bak", ".git"}, config.ExcludeFilter)
 }
 
+func TestLoadConfigConvertImageExtensions(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(configPath, []byte(`
+api_url: "http://localhost:1234/v1/chat/completions"
+model: "test-model"
+timeout: 60
+parallel_requests: 1
+convert_image_extensions:
+  - ".png"
+  - "JPG"
+  - "tiff"
+`), 0644)
+	assert.NoError(t, err)
+
+	config, err := LoadConfig(configPath)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{".png", ".jpg", ".tiff"}, config.ConvertImageExtensions)
+}
+
 func TestLoadConfigFileNotFound(t *testing.T) {
 	_, err := LoadConfig("/non/existent/path/config.yaml")
 	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "/non/existent/path/config.yaml")
+}
+
+func TestLoadConfigFromEnv(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "env-config.yaml")
+	err := os.WriteFile(configPath, []byte(`
+api_url: "http://localhost:1234/v1/chat/completions"
+model: "env-model"
+timeout: 60
+parallel_requests: 1
+`), 0644)
+	assert.NoError(t, err)
+
+	t.Setenv(ConfigPathEnv, configPath)
+
+	config, err := LoadConfig("")
+	assert.NoError(t, err)
+	assert.Equal(t, "env-model", config.Model)
+
+	// An explicit path wins over the environment
+	_, err = LoadConfig(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.ErrorContains(t, err, "missing.yaml")
+}
+
+func TestLoadConfigEnvOverrides(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(configPath, []byte(`
+api_url: "http://localhost:1234/v1/chat/completions"
+model: "file-model"
+timeout: 60
+parallel_requests: 3
+`), 0644)
+	assert.NoError(t, err)
+
+	t.Run("Environment wins over the file", func(t *testing.T) {
+		t.Setenv("KBASE_API_URL", "http://llm.internal/v1/chat/completions")
+		t.Setenv("KBASE_MODEL", "env-model")
+		t.Setenv("KBASE_API_KEY", "secret")
+		t.Setenv("KBASE_TIMEOUT", "120")
+		t.Setenv("KBASE_PARALLEL_REQUESTS", " 5 ")
+		t.Setenv("KBASE_PROVIDER", ProviderOllama)
+
+		config, err := LoadConfig(configPath)
+		assert.NoError(t, err)
+		assert.Equal(t, "http://llm.internal/v1/chat/completions", config.APIURL)
+		assert.Equal(t, "env-model", config.Model)
+		assert.Equal(t, "secret", config.APIKey)
+		assert.Equal(t, 120, config.Timeout)
+		assert.Equal(t, 5, config.ParallelRequests)
+		assert.Equal(t, ProviderOllama, config.Provider)
+	})
+
+	t.Run("Unset variables keep file values", func(t *testing.T) {
+		config, err := LoadConfig(configPath)
+		assert.NoError(t, err)
+		assert.Equal(t, "file-model", config.Model)
+		assert.Equal(t, 60, config.Timeout)
+	})
+
+	t.Run("Invalid integer", func(t *testing.T) {
+		t.Setenv("KBASE_TIMEOUT", "soon")
+
+		_, err := LoadConfig(configPath)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), `KBASE_TIMEOUT must be an integer, got "soon"`)
+	})
+
+	t.Run("Overrides are validated", func(t *testing.T) {
+		t.Setenv("KBASE_PARALLEL_REQUESTS", "0")
+
+		_, err := LoadConfig(configPath)
+		assert.ErrorContains(t, err, "parallel_requests must be positive")
+	})
+}
+
+func TestResolveConfigPath(t *testing.T) {
+	t.Setenv(ConfigPathEnv, "")
+	assert.Equal(t, DefaultConfigPath, ResolveConfigPath(""))
+	assert.Equal(t, "/etc/kbase/config.yaml", ResolveConfigPath("/etc/kbase/config.yaml"))
+
+	t.Setenv(ConfigPathEnv, "/srv/kbase.yaml")
+	assert.Equal(t, "/srv/kbase.yaml", ResolveConfigPath(""))
+	assert.Equal(t, "custom.yaml", ResolveConfigPath("custom.yaml"))
 }
 
 func TestValidateConfig(t *testing.T) {
@@ -120,6 +223,312 @@ func TestValidateConfig(t *testing.T) {
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "parallel_requests must be positive")
 	})
+
+	t.Run("Invalid task mode", func(t *testing.T) {
+		config := &Config{
+			APIURL:           "http://localhost:1234/v1/chat/completions",
+			Model:            "test-model",
+			Timeout:          60,
+			ParallelRequests: 3,
+			TaskMode:         "translate",
+		}
+
+		err := validateConfig(config)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "task_mode must be either")
+	})
+
+	t.Run("Extension without dot", func(t *testing.T) {
+		config := &Config{
+			APIURL:              "http://localhost:1234/v1/chat/completions",
+			Model:               "test-model",
+			Timeout:             60,
+			ParallelRequests:    3,
+			SupportedExtensions: []string{"png"},
+		}
+
+		err := validateConfig(config)
+		assert.ErrorContains(t, err, `invalid extension "png"`)
+	})
+
+	t.Run("Invalid include filter", func(t *testing.T) {
+		config := &Config{
+			APIURL:           "http://localhost:1234/v1/chat/completions",
+			Model:            "test-model",
+			Timeout:          60,
+			ParallelRequests: 3,
+			IncludeFilter:    []string{"**/*.png", "photos/[a"},
+		}
+
+		assert.ErrorContains(t, validateConfig(config), "invalid include_filter")
+
+		config.IncludeFilter = []string{"**/*.png"}
+		assert.NoError(t, validateConfig(config))
+	})
+
+	t.Run("Invalid log level", func(t *testing.T) {
+		config := &Config{
+			APIURL:           "http://localhost:1234/v1/chat/completions",
+			Model:            "test-model",
+			Timeout:          60,
+			ParallelRequests: 3,
+			LogLevel:         "verbose",
+		}
+
+		err := validateConfig(config)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "log_level must be one of")
+	})
+
+	t.Run("Invalid log format", func(t *testing.T) {
+		config := &Config{
+			APIURL:           "http://localhost:1234/v1/chat/completions",
+			Model:            "test-model",
+			Timeout:          60,
+			ParallelRequests: 3,
+			LogFormat:        "xml",
+		}
+
+		err := validateConfig(config)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "log_format must be either")
+
+		// The logger ignores the case like it does for log_level
+		config.LogFormat = "JSON"
+		assert.NoError(t, validateConfig(config))
+	})
+
+	t.Run("API URLs without api_url", func(t *testing.T) {
+		config := &Config{
+			APIURLs:          []string{"http://primary/v1/chat/completions", "http://backup/v1/chat/completions"},
+			APIURLMode:       APIURLModeRoundRobin,
+			Model:            "test-model",
+			Timeout:          60,
+			ParallelRequests: 3,
+		}
+
+		assert.NoError(t, validateConfig(config))
+
+		config.APIURLMode = "random"
+		assert.ErrorContains(t, validateConfig(config), "api_url_mode must be either")
+
+		config.APIURLMode = ""
+		config.APIURLs = append(config.APIURLs, "")
+		assert.ErrorContains(t, validateConfig(config), "api_urls must not contain empty URLs")
+	})
+
+	t.Run("Unknown provider", func(t *testing.T) {
+		config := &Config{
+			APIURL:           "http://localhost:1234/v1/chat/completions",
+			Model:            "test-model",
+			Timeout:          60,
+			ParallelRequests: 3,
+			Provider:         "anthropic",
+		}
+
+		assert.ErrorContains(t, validateConfig(config), "provider must be either")
+	})
+
+	t.Run("Negative LLM connection limit", func(t *testing.T) {
+		config := &Config{
+			APIURL:             "http://localhost:1234/v1/chat/completions",
+			Model:              "test-model",
+			Timeout:            60,
+			ParallelRequests:   3,
+			LLMMaxConnsPerHost: -1,
+		}
+
+		err := validateConfig(config)
+		assert.ErrorContains(t, err, "llm_max_conns_per_host must be non-negative")
+	})
+
+	t.Run("Negative queue size", func(t *testing.T) {
+		config := &Config{
+			APIURL:           "http://localhost:1234/v1/chat/completions",
+			Model:            "test-model",
+			Timeout:          60,
+			ParallelRequests: 3,
+			QueueSize:        -1,
+		}
+
+		assert.ErrorContains(t, validateConfig(config), "queue_size must be positive")
+	})
+
+	t.Run("Negative queue drain timeout", func(t *testing.T) {
+		config := &Config{
+			APIURL:            "http://localhost:1234/v1/chat/completions",
+			Model:             "test-model",
+			Timeout:           60,
+			ParallelRequests:  3,
+			QueueDrainTimeout: -1,
+		}
+
+		assert.ErrorContains(t, validateConfig(config), "queue_drain_timeout must be non-negative")
+	})
+
+	t.Run("Index name with a directory", func(t *testing.T) {
+		config := &Config{
+			APIURL:           "http://localhost:1234/v1/chat/completions",
+			Model:            "test-model",
+			Timeout:          60,
+			ParallelRequests: 3,
+			IndexJSONName:    "indexes/index.json",
+		}
+
+		assert.ErrorContains(t, validateConfig(config), "index_json_name must be a file name")
+	})
+
+	t.Run("Index names of the same file", func(t *testing.T) {
+		config := &Config{
+			APIURL:           "http://localhost:1234/v1/chat/completions",
+			Model:            "test-model",
+			Timeout:          60,
+			ParallelRequests: 3,
+			IndexMDName:      "index.json",
+		}
+
+		assert.ErrorContains(t, validateConfig(config), "must name different files")
+	})
+
+	t.Run("Relative archive base URL", func(t *testing.T) {
+		config := &Config{
+			APIURL:           "http://localhost:1234/v1/chat/completions",
+			Model:            "test-model",
+			Timeout:          60,
+			ParallelRequests: 3,
+			ArchiveBaseURL:   "archive",
+		}
+
+		assert.ErrorContains(t, validateConfig(config), "archive_base_url must be a path starting with /")
+	})
+
+	t.Run("Relative base path", func(t *testing.T) {
+		config := &Config{
+			APIURL:           "http://localhost:1234/v1/chat/completions",
+			Model:            "test-model",
+			Timeout:          60,
+			ParallelRequests: 3,
+			BasePath:         "kbase",
+		}
+
+		assert.ErrorContains(t, validateConfig(config), "base_path must be a path starting with /")
+	})
+
+	t.Run("Generation parameters out of range", func(t *testing.T) {
+		config := &Config{
+			APIURL:           "http://localhost:1234/v1/chat/completions",
+			Model:            "test-model",
+			Timeout:          60,
+			ParallelRequests: 3,
+			Temperature:      2.5,
+		}
+		assert.ErrorContains(t, validateConfig(config), "temperature must be between 0 and 2")
+
+		config.Temperature = 0.2
+		config.MaxTokens = -1
+		assert.ErrorContains(t, validateConfig(config), "max_tokens must be non-negative")
+	})
+
+	t.Run("Negative checkpoint interval", func(t *testing.T) {
+		config := &Config{
+			APIURL:           "http://localhost:1234/v1/chat/completions",
+			Model:            "test-model",
+			Timeout:          60,
+			ParallelRequests: 3,
+			CheckpointEvery:  -1,
+		}
+
+		assert.ErrorContains(t, validateConfig(config), "checkpoint_every must be non-negative")
+	})
+
+	t.Run("Parallel catalogs", func(t *testing.T) {
+		config := &Config{
+			APIURL:           "http://localhost:1234/v1/chat/completions",
+			Model:            "test-model",
+			Timeout:          60,
+			ParallelRequests: 3,
+			ParallelCatalogs: -1,
+		}
+		assert.ErrorContains(t, validateConfig(config), "parallel_catalogs must be non-negative")
+
+		config.ParallelCatalogs = 22
+		assert.ErrorContains(t, validateConfig(config), "parallel_catalogs × parallel_requests must be at most 64, got 66")
+
+		config.ParallelCatalogs = 4
+		assert.NoError(t, validateConfig(config))
+
+		// A single catalog at a time keeps the parallel requests of older configs valid
+		config.ParallelRequests = 100
+		for _, parallelCatalogs := range []int{0, 1} {
+			config.ParallelCatalogs = parallelCatalogs
+			assert.NoError(t, validateConfig(config))
+		}
+	})
+
+	t.Run("Negative min description length", func(t *testing.T) {
+		config := &Config{
+			APIURL:               "http://localhost:1234/v1/chat/completions",
+			Model:                "test-model",
+			Timeout:              60,
+			ParallelRequests:     3,
+			MinDescriptionLength: -1,
+		}
+
+		assert.ErrorContains(t, validateConfig(config), "min_description_length must be non-negative")
+	})
+
+	t.Run("Response field mapped onto an unknown field", func(t *testing.T) {
+		config := &Config{
+			APIURL:           "http://localhost:1234/v1/chat/completions",
+			Model:            "test-model",
+			Timeout:          60,
+			ParallelRequests: 3,
+			ResponseFieldMap: map[string]string{"title": "short_name", "caption": "summary"},
+		}
+
+		assert.ErrorContains(t, validateConfig(config), `response_field_map: "caption" must map onto one of short_name, description, long_description, text, tags`)
+	})
+
+	t.Run("Web auth user without password", func(t *testing.T) {
+		config := &Config{
+			APIURL:           "http://localhost:1234/v1/chat/completions",
+			Model:            "test-model",
+			Timeout:          60,
+			ParallelRequests: 3,
+			WebAuthUser:      "admin",
+		}
+
+		err := validateConfig(config)
+		assert.ErrorContains(t, err, "web_auth_user and web_auth_password must be set together")
+	})
+}
+
+func TestNormalizeConfig(t *testing.T) {
+	t.Run("Extensions are lowercased, dotted and deduplicated", func(t *testing.T) {
+		config := &Config{
+			SupportedExtensions:    []string{"PNG", "jpg", ".jpeg", " .Png "},
+			ConvertImageExtensions: []string{"TIFF", ".bmp"},
+		}
+
+		err := normalizeConfig(config)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{".png", ".jpg", ".jpeg"}, config.SupportedExtensions)
+		assert.Equal(t, []string{".tiff", ".bmp"}, config.ConvertImageExtensions)
+	})
+
+	t.Run("Empty extension is rejected", func(t *testing.T) {
+		config := &Config{SupportedExtensions: []string{".png", " "}}
+
+		err := normalizeConfig(config)
+		assert.ErrorContains(t, err, "supported_extensions: empty extension")
+	})
+
+	t.Run("A lone dot is rejected", func(t *testing.T) {
+		config := &Config{ConvertImageExtensions: []string{"."}}
+
+		err := normalizeConfig(config)
+		assert.ErrorContains(t, err, "convert_image_extensions: empty extension")
+	})
 }
 
 func TestGetDefaultConfig(t *testing.T) {
@@ -132,3 +541,105 @@ func TestGetDefaultConfig(t *testing.T) {
 	assert.Equal(t, 3, config.MaxRetries)
 	assert.Equal(t, 5, config.RetryDelay)
 }
+
+func TestGetTaskTimeout(t *testing.T) {
+	assert.Equal(t, time.Hour, (&Config{}).GetTaskTimeout())
+	assert.Equal(t, 90*time.Second, (&Config{TaskTimeoutSeconds: 90}).GetTaskTimeout())
+}
+
+func TestGetQueueRetryDelay(t *testing.T) {
+	assert.Equal(t, 30*time.Second, (&Config{}).GetQueueRetryDelay())
+	assert.Equal(t, 30*time.Second, GetDefaultConfig().GetQueueRetryDelay())
+	assert.Equal(t, time.Duration(0), (&Config{QueueRetryDelay: -1}).GetQueueRetryDelay())
+	assert.Equal(t, 5*time.Second, (&Config{QueueRetryDelay: 5}).GetQueueRetryDelay())
+}
+
+func TestGetQueueSize(t *testing.T) {
+	assert.Equal(t, 100, (&Config{}).GetQueueSize())
+	assert.Equal(t, 5000, (&Config{QueueSize: 5000}).GetQueueSize())
+}
+
+func TestGetQueueDrainTimeout(t *testing.T) {
+	assert.Zero(t, (&Config{}).GetQueueDrainTimeout())
+	assert.Equal(t, 30*time.Second, GetDefaultConfig().GetQueueDrainTimeout())
+}
+
+func TestGetIndexNames(t *testing.T) {
+	assert.Equal(t, "index.json", (&Config{}).GetIndexJSONName())
+	assert.Equal(t, "index.md", (&Config{}).GetIndexMDName())
+	assert.Equal(t, "index.jsonl", (&Config{}).GetIndexJSONLName())
+
+	var nilConfig *Config
+	assert.Equal(t, "index.json", nilConfig.GetIndexJSONName())
+
+	cfg := &Config{IndexJSONName: "catalog.json", IndexMDName: "README.md"}
+	assert.Equal(t, "catalog.json", cfg.GetIndexJSONName())
+	assert.Equal(t, "README.md", cfg.GetIndexMDName())
+	assert.Equal(t, "catalog.jsonl", cfg.GetIndexJSONLName())
+}
+
+func TestGetArchiveBaseURL(t *testing.T) {
+	var nilConfig *Config
+	assert.Equal(t, "/archive", nilConfig.GetArchiveBaseURL())
+	assert.Equal(t, "/archive", (&Config{}).GetArchiveBaseURL())
+	assert.Equal(t, "https://cdn.example.com/images", (&Config{ArchiveBaseURL: "https://cdn.example.com/images/"}).GetArchiveBaseURL())
+}
+
+func TestGetBasePath(t *testing.T) {
+	var nilConfig *Config
+	assert.Equal(t, "", nilConfig.GetBasePath())
+	assert.Equal(t, "", (&Config{BasePath: "/"}).GetBasePath())
+	assert.Equal(t, "/kbase", (&Config{BasePath: "/kbase/"}).GetBasePath())
+}
+
+func TestGetAPIURLs(t *testing.T) {
+	assert.Nil(t, (&Config{}).GetAPIURLs())
+	assert.Equal(t, []string{"http://single"}, (&Config{APIURL: "http://single"}).GetAPIURLs())
+	assert.Equal(t, []string{"http://a", "http://b"}, (&Config{APIURL: "http://single", APIURLs: []string{"http://a", "http://b"}}).GetAPIURLs())
+}
+
+func TestGetLLMConnectionLimits(t *testing.T) {
+	config := &Config{ParallelRequests: 8}
+	assert.Equal(t, 8, config.GetLLMMaxIdleConns())
+	assert.Equal(t, 16, config.GetLLMMaxConnsPerHost())
+
+	config = &Config{ParallelRequests: 8, LLMMaxIdleConns: 4, LLMMaxConnsPerHost: 10}
+	assert.Equal(t, 4, config.GetLLMMaxIdleConns())
+	assert.Equal(t, 10, config.GetLLMMaxConnsPerHost())
+
+	assert.Equal(t, 1, (&Config{}).GetLLMMaxIdleConns())
+
+	// The workers of all catalogs processed at once share the connections
+	config = &Config{ParallelRequests: 4, ParallelCatalogs: 3}
+	assert.Equal(t, 12, config.GetInFlightRequests())
+	assert.Equal(t, 12, config.GetLLMMaxIdleConns())
+	assert.Equal(t, 24, config.GetLLMMaxConnsPerHost())
+}
+
+func TestGetParallelCatalogs(t *testing.T) {
+	assert.Equal(t, 1, (&Config{}).GetParallelCatalogs())
+	assert.Equal(t, 1, (&Config{ParallelCatalogs: 1}).GetParallelCatalogs())
+	assert.Equal(t, 4, (&Config{ParallelCatalogs: 4}).GetParallelCatalogs())
+	assert.Equal(t, 3, (&Config{ParallelRequests: 3}).GetInFlightRequests())
+}
+
+func TestInitConfigFile(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+
+	err := InitConfigFile(configPath, false)
+	assert.NoError(t, err)
+
+	content, err := os.ReadFile(configPath)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "# Vision model used to describe images\nmodel: llava-v1.5-7b\n")
+
+	config, err := LoadConfig(configPath)
+	assert.NoError(t, err)
+	assert.Equal(t, GetDefaultConfig(), config)
+
+	err = InitConfigFile(configPath, false)
+	assert.ErrorIs(t, err, ErrConfigExists)
+
+	err = InitConfigFile(configPath, true)
+	assert.NoError(t, err)
+}
@@ -3,40 +3,201 @@ package encoder
 import (
 	"bytes"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"image"
+	"image/color"
 	"image/draw"
 	_ "image/gif"
-	_ "image/jpeg"
+	"image/jpeg"
 	"image/png"
+	"io"
+	"io/fs"
+	"net/http"
 	"os"
+	"slices"
 
+	apperrors "kbase-catalog/internal/errors"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
 	_ "golang.org/x/image/webp"
 )
 
+// ErrDecode is wrapped by the errors returned for files that are not decodable images
+var ErrDecode = errors.New("failed to decode image")
+
+// ErrHEICUnsupported is wrapped by the errors returned for HEIC images when the binary was
+// built without the heic tag
+var ErrHEICUnsupported = errors.New("HEIC images are not supported by this build, rebuild with -tags heic")
+
+// heicBrands are the ftyp brands of HEIC and HEIF images
+var heicBrands = []string{"heic", "heix", "hevc", "hevx", "heim", "heis", "mif1", "msf1"}
+
+// sniffLen is the number of leading bytes DetectMIME looks at
+const sniffLen = 512
+
+// jpegQuality is the quality of the JPEG images sent to the LLM
+const jpegQuality = 90
+
+// DetectMIME returns the MIME type of content from its leading bytes, whatever the name of the
+// file it was read from. Unknown content is application/octet-stream.
+func DetectMIME(content []byte) string {
+	if len(content) > sniffLen {
+		content = content[:sniffLen]
+	}
+	return http.DetectContentType(content)
+}
+
+// DetectFileMIME returns the MIME type of a file from its leading bytes
+func DetectFileMIME(path string) (string, error) {
+	head, err := readHead(path)
+	if err != nil {
+		return "", err
+	}
+	return DetectMIME(head), nil
+}
+
+// IsHEIC reports whether content starts like a HEIC or HEIF image
+func IsHEIC(content []byte) bool {
+	if len(content) < 12 || string(content[4:8]) != "ftyp" {
+		return false
+	}
+	return slices.Contains(heicBrands, string(content[8:12]))
+}
+
+// CheckDecodable returns ErrHEICUnsupported for a HEIC file when this build can't decode it
+func CheckDecodable(path string) error {
+	if HEICSupported {
+		return nil
+	}
+	head, err := readHead(path)
+	if err != nil {
+		return err
+	}
+	if IsHEIC(head) {
+		return ErrHEICUnsupported
+	}
+	return nil
+}
+
+// readHead returns the leading bytes of a file sniffed by DetectMIME
+func readHead(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	buf := make([]byte, sniffLen)
+	n, err := io.ReadFull(file, buf)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// EncodeImageToBase64 returns the image as a data URL. JPEG images, recognized by their
+// content rather than their extension, stay JPEG; every other format is converted to PNG.
 func EncodeImageToBase64(imagePath string) (string, error) {
-	file, err := os.Open(imagePath)
+	content, err := os.ReadFile(imagePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("failed to open image file: %w", apperrors.NewFileNotFoundError(imagePath, err))
+	}
 	if err != nil {
 		return "", fmt.Errorf("failed to open image file: %w", err)
 	}
-	defer file.Close()
 
-	img, _, err := image.Decode(file)
+	img, _, err := image.Decode(bytes.NewReader(content))
 	if err != nil {
-		return "", fmt.Errorf("failed to decode image: %w", err)
+		if !HEICSupported && IsHEIC(content) {
+			err = ErrHEICUnsupported
+		}
+		return "", fmt.Errorf("%w: %w", ErrDecode, err)
 	}
 
 	var buf bytes.Buffer
 	rgba := image.NewRGBA(img.Bounds())
 	draw.Draw(rgba, rgba.Bounds(), img, image.Point{0, 0}, draw.Src)
 
-	err = png.Encode(&buf, rgba)
+	mimeType := DetectMIME(content)
+	if mimeType == "image/jpeg" {
+		err = jpeg.Encode(&buf, rgba, &jpeg.Options{Quality: jpegQuality})
+	} else {
+		mimeType = "image/png"
+		err = png.Encode(&buf, rgba)
+	}
 	if err != nil {
-		return "", fmt.Errorf("failed to encode image to PNG: %w", err)
+		return "", fmt.Errorf("failed to encode image to %s: %w", mimeType, err)
 	}
 
 	base64Encoded := base64.StdEncoding.EncodeToString(buf.Bytes())
-	mimeType := "image/png"
 
 	return fmt.Sprintf("data:%s;base64,%s", mimeType, base64Encoded), nil
 }
+
+// ThumbnailSize bounds the width and height of the thumbnails embedded in the index
+const ThumbnailSize = 64
+
+// thumbnailQuality is the JPEG quality of the embedded thumbnails
+const thumbnailQuality = 70
+
+// EncodeThumbnail returns a JPEG data URL of the image scaled down to fit in maxSize x maxSize,
+// keeping its aspect ratio. Smaller images keep their size.
+func EncodeThumbnail(imagePath string, maxSize int) (string, error) {
+	file, err := os.Open(imagePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("failed to open image file: %w", apperrors.NewFileNotFoundError(imagePath, err))
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to open image file: %w", err)
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrDecode, err)
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, scaleDown(img, maxSize), &jpeg.Options{Quality: thumbnailQuality}); err != nil {
+		return "", fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+
+	return "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// scaleDown shrinks img to fit in maxSize x maxSize, every pixel of the result averages the
+// pixels of the source it covers
+func scaleDown(img image.Image, maxSize int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= maxSize && height <= maxSize {
+		return img
+	}
+
+	scaledWidth, scaledHeight := maxSize, maxSize
+	if width > height {
+		scaledHeight = max(height*maxSize/width, 1)
+	} else {
+		scaledWidth = max(width*maxSize/height, 1)
+	}
+
+	scaled := image.NewRGBA(image.Rect(0, 0, scaledWidth, scaledHeight))
+	for y := 0; y < scaledHeight; y++ {
+		y0, y1 := bounds.Min.Y+y*height/scaledHeight, bounds.Min.Y+(y+1)*height/scaledHeight
+		for x := 0; x < scaledWidth; x++ {
+			x0, x1 := bounds.Min.X+x*width/scaledWidth, bounds.Min.X+(x+1)*width/scaledWidth
+			var r, g, b, a, count uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := img.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					count++
+				}
+			}
+			scaled.Set(x, y, color.RGBA64{uint16(r / count), uint16(g / count), uint16(b / count), uint16(a / count)})
+		}
+	}
+	return scaled
+}
@@ -3,16 +3,24 @@ package encoder
 import (
 	"bytes"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"image"
 	"image/color"
+	"image/jpeg"
 	"image/png"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 
+	apperrors "kbase-catalog/internal/errors"
+
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
 )
 
 func TestEncodeImageToBase64(t *testing.T) {
@@ -53,7 +61,7 @@ func TestEncodeImageToBase64(t *testing.T) {
 		assert.NoError(t, err)
 		assert.NotEmpty(t, result)
 
-		// Check that the result starts with the expected data URI prefix
+		// The content is PNG despite the extension, so is the data URI
 		assert.Contains(t, result, "data:image/png;base64,")
 
 		// Verify it's valid base64 by attempting to decode it
@@ -62,11 +70,56 @@ func TestEncodeImageToBase64(t *testing.T) {
 		assert.NotEmpty(t, decoded)
 	})
 
+	for name, encode := range map[string]func(io.Writer, image.Image) error{
+		"test.bmp":  bmp.Encode,
+		"test.tiff": func(w io.Writer, img image.Image) error { return tiff.Encode(w, img, nil) },
+	} {
+		t.Run("Valid "+name, func(t *testing.T) {
+			img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+			for i := range img.Pix {
+				img.Pix[i] = 0xff
+			}
+			var buf bytes.Buffer
+			assert.NoError(t, encode(&buf, img))
+			testImagePath := filepath.Join(t.TempDir(), name)
+			assert.NoError(t, os.WriteFile(testImagePath, buf.Bytes(), 0644))
+
+			result, err := EncodeImageToBase64(testImagePath)
+			assert.NoError(t, err)
+			assert.True(t, strings.HasPrefix(result, "data:image/png;base64,"))
+
+			decoded, err := decodeBase64String(result)
+			assert.NoError(t, err)
+			converted, err := png.Decode(bytes.NewReader(decoded))
+			assert.NoError(t, err)
+			assert.Equal(t, img.Bounds(), converted.Bounds())
+		})
+	}
+
+	t.Run("JPEG content with another extension", func(t *testing.T) {
+		testImagePath := filepath.Join(t.TempDir(), "photo.dat")
+		assert.NoError(t, os.WriteFile(testImagePath, createTestJPEG(10, 10), 0644))
+
+		result, err := EncodeImageToBase64(testImagePath)
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(result, "data:image/jpeg;base64,"))
+
+		decoded, err := decodeBase64String(result)
+		assert.NoError(t, err)
+		assert.Equal(t, "image/jpeg", DetectMIME(decoded))
+	})
+
 	t.Run("File not found", func(t *testing.T) {
 		result, err := EncodeImageToBase64("/non/existent/path/image.png")
 		assert.Error(t, err)
 		assert.Empty(t, result)
 		assert.Contains(t, err.Error(), "failed to open image file")
+
+		var notFound *apperrors.FileNotFoundError
+		if assert.True(t, errors.As(err, &notFound)) {
+			assert.Equal(t, "/non/existent/path/image.png", notFound.Path)
+		}
+		assert.True(t, errors.Is(err, fs.ErrNotExist))
 	})
 
 	t.Run("Invalid image format", func(t *testing.T) {
@@ -99,6 +152,75 @@ func createTestImage(width, height int, r, g, b uint8) []byte {
 	return buf.Bytes()
 }
 
+// createTestJPEG returns a gray JPEG image
+func createTestJPEG(width, height int) []byte {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, img, nil)
+	return buf.Bytes()
+}
+
+func TestEncodeThumbnail(t *testing.T) {
+	tempDir := t.TempDir()
+
+	for _, tt := range []struct {
+		width, height int
+		expected      image.Rectangle
+	}{
+		{200, 100, image.Rect(0, 0, 64, 32)},
+		{90, 300, image.Rect(0, 0, 19, 64)},
+		{20, 10, image.Rect(0, 0, 20, 10)},
+	} {
+		imagePath := filepath.Join(tempDir, fmt.Sprintf("%dx%d.png", tt.width, tt.height))
+		assert.NoError(t, os.WriteFile(imagePath, createTestImage(tt.width, tt.height, 0, 128, 255), 0644))
+
+		result, err := EncodeThumbnail(imagePath, ThumbnailSize)
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(result, "data:image/jpeg;base64,"))
+
+		decoded, err := decodeBase64String(result)
+		assert.NoError(t, err)
+		thumbnail, err := jpeg.Decode(bytes.NewReader(decoded))
+		assert.NoError(t, err)
+		assert.Equal(t, tt.expected, thumbnail.Bounds())
+
+		// The color survives the averaging, up to the JPEG loss
+		r, g, b, _ := thumbnail.At(thumbnail.Bounds().Dx()/2, thumbnail.Bounds().Dy()/2).RGBA()
+		assert.InDelta(t, 0, r>>8, 8)
+		assert.InDelta(t, 128, g>>8, 8)
+		assert.InDelta(t, 255, b>>8, 8)
+	}
+
+	_, err := EncodeThumbnail(filepath.Join(tempDir, "missing.png"), ThumbnailSize)
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestDetectMIME(t *testing.T) {
+	assert.Equal(t, "image/jpeg", DetectMIME(createTestJPEG(10, 10)))
+	assert.Equal(t, "image/png", DetectMIME(createTestImage(10, 10, 255, 0, 0)))
+	assert.Equal(t, "application/octet-stream", DetectMIME([]byte{0x00, 0x01, 0x02}))
+}
+
+func TestDetectFileMIME(t *testing.T) {
+	tempDir := t.TempDir()
+	jpegPath := filepath.Join(tempDir, "photo.dat")
+	assert.NoError(t, os.WriteFile(jpegPath, createTestJPEG(10, 10), 0644))
+
+	mimeType, err := DetectFileMIME(jpegPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "image/jpeg", mimeType)
+
+	// Shorter than the sniffed length
+	textPath := filepath.Join(tempDir, "note.jpg")
+	assert.NoError(t, os.WriteFile(textPath, []byte("hello"), 0644))
+	mimeType, err = DetectFileMIME(textPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "text/plain; charset=utf-8", mimeType)
+
+	_, err = DetectFileMIME(filepath.Join(tempDir, "missing.png"))
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
 // Helper function to decode the base64 part of a data URI
 func decodeBase64String(dataURI string) ([]byte, error) {
 	// Extract base64 part from data URI (after the comma)
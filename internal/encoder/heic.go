@@ -0,0 +1,18 @@
+//go:build heic
+
+package encoder
+
+import (
+	"image"
+
+	"github.com/jdeng/goheif"
+)
+
+// HEICSupported reports whether the binary was built with the heic tag and decodes HEIC images
+const HEICSupported = true
+
+func init() {
+	for _, brand := range heicBrands {
+		image.RegisterFormat("heic", "????ftyp"+brand, goheif.Decode, goheif.DecodeConfig)
+	}
+}
@@ -0,0 +1,6 @@
+//go:build !heic
+
+package encoder
+
+// HEICSupported reports whether the binary was built with the heic tag and decodes HEIC images
+const HEICSupported = false
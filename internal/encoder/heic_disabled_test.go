@@ -0,0 +1,42 @@
+//go:build !heic
+
+package encoder
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// heicHeader starts a HEIC file, the rest of the file doesn't matter without a decoder
+var heicHeader = []byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1heic")
+
+func TestEncodeImageToBase64_HEICUnsupported(t *testing.T) {
+	imagePath := filepath.Join(t.TempDir(), "IMG_0001.HEIC")
+	assert.NoError(t, os.WriteFile(imagePath, heicHeader, 0644))
+
+	result, err := EncodeImageToBase64(imagePath)
+	assert.Empty(t, result)
+	assert.True(t, errors.Is(err, ErrDecode))
+	assert.True(t, errors.Is(err, ErrHEICUnsupported))
+	assert.Contains(t, err.Error(), "-tags heic")
+
+	assert.ErrorIs(t, CheckDecodable(imagePath), ErrHEICUnsupported)
+}
+
+func TestCheckDecodable(t *testing.T) {
+	imagePath := filepath.Join(t.TempDir(), "test.png")
+	assert.NoError(t, os.WriteFile(imagePath, createTestImage(2, 2, 0, 0, 0), 0644))
+	assert.NoError(t, CheckDecodable(imagePath))
+}
+
+func TestIsHEIC(t *testing.T) {
+	assert.True(t, IsHEIC(heicHeader))
+	assert.True(t, IsHEIC([]byte("\x00\x00\x00\x18ftypmif1")))
+	assert.False(t, IsHEIC([]byte("\x00\x00\x00\x18ftypisom")))
+	assert.False(t, IsHEIC(createTestJPEG(2, 2)))
+	assert.False(t, IsHEIC(nil))
+}
@@ -0,0 +1,21 @@
+//go:build heic
+
+package encoder
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeImageToBase64_HEIC(t *testing.T) {
+	// Sample image of github.com/jdeng/goheif (MIT license)
+	imagePath := filepath.Join("testdata", "sample.heic")
+
+	result, err := EncodeImageToBase64(imagePath)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(result, "data:image/png;base64,"))
+	assert.NoError(t, CheckDecodable(imagePath))
+}
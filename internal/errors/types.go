@@ -2,18 +2,92 @@ package errors
 
 import (
 	"context"
+	"fmt"
+	"runtime"
+	"strings"
 	"time"
 )
 
-// Base error types
+// maxStackDepth bounds the number of frames captured into StackTrace
+const maxStackDepth = 32
+
+// Base error types. The JSON form is the error envelope of the web API.
 type BaseError struct {
 	error
-	Code       string
-	Message    string
-	Details    interface{}
-	StackTrace string
-	Timestamp  time.Time
-	Context    context.Context
+	Code       string          `json:"code"`
+	Message    string          `json:"message"`
+	Details    interface{}     `json:"details,omitempty"`
+	StackTrace string          `json:"-"`
+	Timestamp  time.Time       `json:"timestamp"`
+	Context    context.Context `json:"-"`
+}
+
+// NewBaseError creates a BaseError wrapping the optional cause err, with the stack of the caller
+// captured into StackTrace
+func NewBaseError(code, message string, err error) BaseError {
+	return newBaseError(code, message, err)
+}
+
+// newBaseError is shared by the constructors so the captured stack starts at their caller
+func newBaseError(code, message string, err error) BaseError {
+	return BaseError{
+		error:      err,
+		Code:       code,
+		Message:    message,
+		StackTrace: captureStack(4),
+		Timestamp:  time.Now(),
+	}
+}
+
+// captureStack formats the stack of the goroutine, skipping the given number of frames
+func captureStack(skip int) string {
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(skip, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var sb strings.Builder
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(&sb, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+	return sb.String()
+}
+
+// Error formats the code, the message, the details and the cause. It is safe on the zero value.
+func (e BaseError) Error() string {
+	description := e.describe()
+	switch {
+	case e.Code != "" && description != "":
+		return e.Code + ": " + description
+	case e.Code != "":
+		return e.Code
+	case description != "":
+		return description
+	}
+	return "unknown error"
+}
+
+// describe formats the message, the details and the cause, leaving out the code
+func (e BaseError) describe() string {
+	parts := make([]string, 0, 3)
+	if e.Message != "" {
+		parts = append(parts, e.Message)
+	}
+	if e.Details != nil {
+		parts = append(parts, fmt.Sprint(e.Details))
+	}
+	if e.error != nil {
+		parts = append(parts, e.error.Error())
+	}
+	return strings.Join(parts, ": ")
+}
+
+// Unwrap returns the underlying cause, so errors.Is and errors.As see through the typed errors
+func (e BaseError) Unwrap() error {
+	return e.error
 }
 
 // Specific error types
@@ -23,12 +97,39 @@ type ConfigError struct {
 	Value interface{}
 }
 
+// NewConfigError reports an invalid value of a configuration field
+func NewConfigError(field string, value interface{}, message string) *ConfigError {
+	return &ConfigError{
+		BaseError: newBaseError("CONFIG_ERROR", message, nil),
+		Field:     field,
+		Value:     value,
+	}
+}
+
+// Error names the field and its value along with the problem
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("invalid %s %v: %s", e.Field, e.Value, e.describe())
+}
+
 type FileNotFoundError struct {
 	BaseError
 	Path        string
 	IsDirectory bool
 }
 
+// NewFileNotFoundError reports a missing file, wrapping the error of the failed file operation
+func NewFileNotFoundError(path string, err error) *FileNotFoundError {
+	return &FileNotFoundError{
+		BaseError: newBaseError("FILE_NOT_FOUND", fmt.Sprintf("file not found: %s", path), err),
+		Path:      path,
+	}
+}
+
+// Error describes the missing file
+func (e *FileNotFoundError) Error() string {
+	return e.describe()
+}
+
 type NetworkError struct {
 	BaseError
 	StatusCode int
@@ -36,6 +137,21 @@ type NetworkError struct {
 	Retryable  bool
 }
 
+// NewNetworkError reports a request that failed before a response arrived, such requests are
+// worth retrying
+func NewNetworkError(url string, err error) *NetworkError {
+	return &NetworkError{
+		BaseError: newBaseError("NETWORK_ERROR", fmt.Sprintf("request to %s failed", url), err),
+		URL:       url,
+		Retryable: true,
+	}
+}
+
+// Error describes the failed request
+func (e *NetworkError) Error() string {
+	return e.describe()
+}
+
 type ProcessingError struct {
 	BaseError
 	FileName       string
@@ -43,6 +159,27 @@ type ProcessingError struct {
 	ProcessingStep string
 }
 
+// Steps of the image pipeline reported in ProcessingError
+const (
+	StepCheckSize = "check_size"
+	StepEncode    = "encode"
+	StepLLM       = "llm"
+)
+
+// NewProcessingError reports the failure of a pipeline step for a file, wrapping its cause
+func NewProcessingError(fileName, step, message string, err error) *ProcessingError {
+	return &ProcessingError{
+		BaseError:      newBaseError("PROCESSING_ERROR", message, err),
+		FileName:       fileName,
+		ProcessingStep: step,
+	}
+}
+
+// Error describes the failed step along with its cause
+func (e *ProcessingError) Error() string {
+	return e.describe()
+}
+
 type ValidationError struct {
 	BaseError
 	Field      string
@@ -50,6 +187,27 @@ type ValidationError struct {
 	Constraint string
 }
 
+// NewValidationError reports a value violating a constraint
+func NewValidationError(field string, value interface{}, constraint string) *ValidationError {
+	return &ValidationError{
+		BaseError:  newBaseError("VALIDATION_ERROR", fmt.Sprintf("%s must be %s", field, constraint), nil),
+		Field:      field,
+		Value:      value,
+		Constraint: constraint,
+	}
+}
+
+// Error describes the violated constraint along with the rejected value
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s, got %v", e.describe(), e.Value)
+}
+
+// WebServerError is printed through BaseError.Error, code included
 type WebServerError struct {
 	BaseError
 }
+
+// NewWebServerError reports a failure of the web server, wrapping its cause
+func NewWebServerError(code, message string, err error) *WebServerError {
+	return &WebServerError{BaseError: newBaseError(code, message, err)}
+}
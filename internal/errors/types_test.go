@@ -0,0 +1,124 @@
+package errors
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessingError(t *testing.T) {
+	cause := errors.New("boom")
+	err := fmt.Errorf("processing failed: %w", NewProcessingError("cat.png", StepEncode, "failed to encode image", cause))
+
+	var procErr *ProcessingError
+	if assert.True(t, errors.As(err, &procErr)) {
+		assert.Equal(t, "cat.png", procErr.FileName)
+		assert.Equal(t, StepEncode, procErr.ProcessingStep)
+		assert.Equal(t, "PROCESSING_ERROR", procErr.Code)
+		assert.Equal(t, "failed to encode image: boom", procErr.Error())
+	}
+	assert.True(t, errors.Is(err, cause))
+}
+
+func TestProcessingError_WrapsNetworkError(t *testing.T) {
+	networkErr := &NetworkError{
+		BaseError:  BaseError{Message: "LLM API returned status code 400"},
+		StatusCode: 400,
+		URL:        "http://localhost/v1",
+	}
+	err := NewProcessingError("cat.png", StepLLM, "failed to process image with LLM", networkErr)
+
+	var target *NetworkError
+	if assert.True(t, errors.As(err, &target)) {
+		assert.Equal(t, 400, target.StatusCode)
+		assert.False(t, target.Retryable)
+	}
+	assert.Equal(t, "failed to process image with LLM: LLM API returned status code 400", err.Error())
+}
+
+func TestNetworkError(t *testing.T) {
+	cause := errors.New("connection refused")
+	err := NewNetworkError("http://localhost/v1", cause)
+
+	assert.True(t, err.Retryable)
+	assert.Equal(t, "http://localhost/v1", err.URL)
+	assert.Equal(t, "request to http://localhost/v1 failed: connection refused", err.Error())
+	assert.True(t, errors.Is(err, cause))
+}
+
+func TestFileNotFoundError(t *testing.T) {
+	err := fmt.Errorf("failed to open image file: %w", NewFileNotFoundError("/missing.png", fs.ErrNotExist))
+
+	var notFound *FileNotFoundError
+	if assert.True(t, errors.As(err, &notFound)) {
+		assert.Equal(t, "/missing.png", notFound.Path)
+		assert.Equal(t, "file not found: /missing.png: file does not exist", notFound.Error())
+	}
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+}
+
+func TestBaseError_Error(t *testing.T) {
+	t.Run("Zero value", func(t *testing.T) {
+		var err BaseError
+		assert.Equal(t, "unknown error", err.Error())
+		assert.Nil(t, err.Unwrap())
+	})
+
+	t.Run("Code, message, details and cause", func(t *testing.T) {
+		err := NewBaseError("LLM_API_ERROR", "LLM API returned status code 500", errors.New("boom"))
+		err.Details = "model unavailable"
+		assert.Equal(t, "LLM_API_ERROR: LLM API returned status code 500: model unavailable: boom", err.Error())
+	})
+
+	t.Run("Code only", func(t *testing.T) {
+		assert.Equal(t, "INTERNAL_ERROR", BaseError{Code: "INTERNAL_ERROR"}.Error())
+	})
+}
+
+func TestNewBaseError_StackTrace(t *testing.T) {
+	err := NewBaseError("TEST", "test", nil)
+
+	// The stack starts at the caller of the constructor
+	firstFrame, _, _ := strings.Cut(err.StackTrace, "\n")
+	assert.True(t, strings.HasSuffix(firstFrame, "TestNewBaseError_StackTrace"), firstFrame)
+	assert.Contains(t, err.StackTrace, "types_test.go")
+	assert.False(t, err.Timestamp.IsZero())
+
+	procErr := NewProcessingError("cat.png", StepLLM, "failed", nil)
+	firstFrame, _, _ = strings.Cut(procErr.StackTrace, "\n")
+	assert.True(t, strings.HasSuffix(firstFrame, "TestNewBaseError_StackTrace"), firstFrame)
+}
+
+func TestConfigError(t *testing.T) {
+	err := NewConfigError("task_mode", "poetry", "must be description or ocr")
+
+	var configErr *ConfigError
+	if assert.True(t, errors.As(fmt.Errorf("load: %w", err), &configErr)) {
+		assert.Equal(t, "task_mode", configErr.Field)
+	}
+	assert.Equal(t, "invalid task_mode poetry: must be description or ocr", err.Error())
+}
+
+func TestValidationError(t *testing.T) {
+	err := NewValidationError("limit", -1, "positive")
+
+	assert.Equal(t, "limit must be positive, got -1", err.Error())
+	assert.Equal(t, "VALIDATION_ERROR", err.Code)
+	assert.NotEmpty(t, err.StackTrace)
+}
+
+func TestWebServerError(t *testing.T) {
+	cause := errors.New("address already in use")
+	err := NewWebServerError("FAIL_TO_START_TASKS_QUEUE", "Failed to start task queue", cause)
+
+	assert.Equal(t, "FAIL_TO_START_TASKS_QUEUE: Failed to start task queue: address already in use", err.Error())
+	assert.True(t, errors.Is(err, cause))
+
+	// Literal values without a cause are printable as well
+	literal := &WebServerError{BaseError: BaseError{Code: "FAIL", Message: "Failed"}}
+	assert.Equal(t, "FAIL: Failed", literal.Error())
+}
@@ -0,0 +1,24 @@
+//go:build !heic
+
+package images
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"kbase-catalog/internal/config"
+	"kbase-catalog/internal/encoder"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImageConverter_convertToWebP_HEICUnsupported(t *testing.T) {
+	tempDir := t.TempDir()
+	inputPath := filepath.Join(tempDir, "IMG_0001.heic")
+	assert.NoError(t, os.WriteFile(inputPath, []byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1heic"), 0644))
+
+	converter := NewImageConverter(&config.Config{ConvertImageExtensions: []string{".heic"}})
+	err := converter.convertToWebP(inputPath, filepath.Join(tempDir, "IMG_0001.webp"), 80)
+	assert.ErrorIs(t, err, encoder.ErrHEICUnsupported)
+}
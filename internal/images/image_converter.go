@@ -2,28 +2,58 @@ package images
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"image"
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
 	"kbase-catalog/internal/config"
+	"kbase-catalog/internal/encoder"
+	"kbase-catalog/internal/metrics"
 
 	"github.com/chai2010/webp"
 )
 
+// Strategies for originals whose destination in the origin directory already exists
+const (
+	// OnConflictSkip leaves the original where it is and prints a warning
+	OnConflictSkip = "skip"
+	// OnConflictRename moves the original next to the existing file with a numeric suffix
+	OnConflictRename = "rename"
+	// OnConflictOverwrite replaces the existing file
+	OnConflictOverwrite = "overwrite"
+)
+
+// OnConflictStrategies lists the strategies accepted by SetOnConflict
+var OnConflictStrategies = []string{OnConflictSkip, OnConflictRename, OnConflictOverwrite}
+
 // ImageConverter handles image conversion to WebP format
 type ImageConverter struct {
-	config *config.Config
+	config     *config.Config
+	onConflict string
 }
 
 // NewImageConverter creates a new instance of ImageConverter
 func NewImageConverter(cfg *config.Config) *ImageConverter {
 	return &ImageConverter{
-		config: cfg,
+		config:     cfg,
+		onConflict: OnConflictRename,
+	}
+}
+
+// SetOnConflict sets what happens when an original is moved onto an existing file
+func (ic *ImageConverter) SetOnConflict(strategy string) error {
+	if !slices.Contains(OnConflictStrategies, strategy) {
+		return fmt.Errorf("unknown conflict strategy %q, expected one of %s", strategy, strings.Join(OnConflictStrategies, ", "))
 	}
+	ic.onConflict = strategy
+	return nil
 }
 
 // ConvertImages converts images in the specified directory to WebP format
@@ -57,7 +87,9 @@ func (ic *ImageConverter) ConvertImages(ctx context.Context, inputDir, originDir
 			fmt.Printf("  Warning: %s already exists.\n", outputPath)
 		} else {
 			// Convert image to WebP format
+			start := time.Now()
 			err = ic.convertToWebP(imagePath, outputPath, quality)
+			metrics.ConversionDuration.ObserveSince(start)
 			if err != nil {
 				fmt.Printf("  Error converting %s to WebP: %v\n", imagePath, err)
 				continue
@@ -143,6 +175,19 @@ func (ic *ImageConverter) moveOriginalFile(originalPath, originDir string) (stri
 	// Move file using os.Rename (which is the equivalent of shutil.move in Python)
 	destinationPath := filepath.Join(destinationDir, filepath.Base(originalPath))
 
+	// os.Rename silently replaces an existing file, so resolve conflicts first
+	if _, err := os.Stat(destinationPath); err == nil {
+		switch ic.onConflict {
+		case OnConflictSkip:
+			fmt.Printf("  Warning: %s already exists, leaving the original in place.\n", destinationPath)
+			return "", nil
+		case OnConflictOverwrite:
+			fmt.Printf("  Warning: overwriting %s.\n", destinationPath)
+		default:
+			destinationPath = availablePath(destinationPath)
+		}
+	}
+
 	fmt.Printf("  Moving original to: %s\n", destinationPath)
 
 	// Try to use os.Rename first (fastest method)
@@ -152,16 +197,9 @@ func (ic *ImageConverter) moveOriginalFile(originalPath, originDir string) (stri
 		if isCrossDeviceError(err) {
 			fmt.Printf("  Cross-device link detected. Copying instead of moving.\n")
 
-			// Copy the file
-			err = copyFile(originalPath, destinationPath)
-			if err != nil {
-				return "", fmt.Errorf("failed to copy original file: %w", err)
-			}
-
-			// Remove the original file after successful copy
-			err = os.Remove(originalPath)
+			err = moveByCopy(originalPath, destinationPath, copyFile)
 			if err != nil {
-				return "", fmt.Errorf("failed to remove original file after copying: %w", err)
+				return "", err
 			}
 		} else {
 			return "", fmt.Errorf("failed to move original file: %w", err)
@@ -171,6 +209,19 @@ func (ic *ImageConverter) moveOriginalFile(originalPath, originDir string) (stri
 	return destinationPath, nil
 }
 
+// availablePath returns path with the first numeric suffix (name-1.ext, name-2.ext, ...) that
+// doesn't exist yet
+func availablePath(path string) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for i := 1; ; i++ {
+		candidate := fmt.Sprintf("%s-%d%s", base, i, ext)
+		if _, err := os.Stat(candidate); os.IsNotExist(err) {
+			return candidate
+		}
+	}
+}
+
 // isCrossDeviceError checks if an error is a cross-device link error
 func isCrossDeviceError(err error) bool {
 	if err == nil {
@@ -183,6 +234,68 @@ func isCrossDeviceError(err error) bool {
 		strings.Contains(errStr, "cross-device link not permitted")
 }
 
+// moveByCopy moves src to dst with copy, removing src only once the copy is verified to match
+// it. A mismatching copy is removed and src is kept.
+func moveByCopy(src, dst string, copy func(src, dst string) error) error {
+	if err := copy(src, dst); err != nil {
+		return fmt.Errorf("failed to copy original file: %w", err)
+	}
+
+	if err := verifyCopy(src, dst); err != nil {
+		os.Remove(dst)
+		return fmt.Errorf("copy of original file is corrupt, keeping %s: %w", src, err)
+	}
+
+	// Remove the original file after successful copy
+	if err := os.Remove(src); err != nil {
+		return fmt.Errorf("failed to remove original file after copying: %w", err)
+	}
+	return nil
+}
+
+// verifyCopy checks that dst has the same size and SHA-256 as src
+func verifyCopy(src, dst string) error {
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	dstInfo, err := os.Stat(dst)
+	if err != nil {
+		return err
+	}
+	if srcInfo.Size() != dstInfo.Size() {
+		return fmt.Errorf("size mismatch: %d bytes copied of %d", dstInfo.Size(), srcInfo.Size())
+	}
+
+	srcHash, err := fileHash(src)
+	if err != nil {
+		return err
+	}
+	dstHash, err := fileHash(dst)
+	if err != nil {
+		return err
+	}
+	if srcHash != dstHash {
+		return fmt.Errorf("checksum mismatch: %s != %s", dstHash, srcHash)
+	}
+	return nil
+}
+
+// fileHash returns the hex encoded SHA-256 of the file content
+func fileHash(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
 // copyFile copies a file from source to destination
 func copyFile(src, dst string) error {
 	sourceFile, err := os.Open(src)
@@ -202,14 +315,18 @@ func copyFile(src, dst string) error {
 	if err != nil {
 		return fmt.Errorf("failed to create destination file: %w", err)
 	}
-	defer destFile.Close()
 
 	// Copy the file content
 	_, err = io.Copy(destFile, sourceFile)
 	if err != nil {
+		destFile.Close()
 		return fmt.Errorf("failed to copy file content: %w", err)
 	}
 
+	// Close reports the write errors of a full disk that Copy may not have seen
+	if err := destFile.Close(); err != nil {
+		return fmt.Errorf("failed to write destination file: %w", err)
+	}
 	return nil
 }
 
@@ -225,6 +342,9 @@ func (ic *ImageConverter) convertToWebP(inputPath, outputPath string, quality in
 	// Decode the input image
 	img, _, err := image.Decode(file)
 	if err != nil {
+		if heicErr := encoder.CheckDecodable(inputPath); heicErr != nil {
+			err = heicErr
+		}
 		return fmt.Errorf("failed to decode image: %w", err)
 	}
 
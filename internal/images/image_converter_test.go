@@ -96,3 +96,125 @@ func TestImageConverter_findImageFiles(t *testing.T) {
 		assert.Contains(t, files, testImage2)
 	})
 }
+
+// TestImageConverter_moveOriginalFile_Conflict tests that an original doesn't clobber a
+// same-named file already in the origin directory
+func TestImageConverter_moveOriginalFile_Conflict(t *testing.T) {
+	setup := func(t *testing.T) (string, string, string) {
+		tempDir := t.TempDir()
+		catalogDir := filepath.Join(tempDir, "catalog")
+		originDir := filepath.Join(tempDir, "origin")
+		assert.NoError(t, os.MkdirAll(catalogDir, 0755))
+		assert.NoError(t, os.MkdirAll(filepath.Join(originDir, "catalog"), 0755))
+
+		original := filepath.Join(catalogDir, "photo.png")
+		existing := filepath.Join(originDir, "catalog", "photo.png")
+		assert.NoError(t, os.WriteFile(original, []byte("new original"), 0644))
+		assert.NoError(t, os.WriteFile(existing, []byte("older original"), 0644))
+		return original, existing, originDir
+	}
+	cfg := &config.Config{ConvertImageExtensions: []string{".png"}}
+
+	t.Run("Default renames and keeps both files", func(t *testing.T) {
+		original, existing, originDir := setup(t)
+
+		movedPath, err := NewImageConverter(cfg).moveOriginalFile(original, originDir)
+		assert.NoError(t, err)
+		assert.Equal(t, filepath.Join(originDir, "catalog", "photo-1.png"), movedPath)
+
+		content, err := os.ReadFile(existing)
+		assert.NoError(t, err)
+		assert.Equal(t, "older original", string(content))
+		content, err = os.ReadFile(movedPath)
+		assert.NoError(t, err)
+		assert.Equal(t, "new original", string(content))
+		assert.NoFileExists(t, original)
+	})
+
+	t.Run("Rename picks the next free suffix", func(t *testing.T) {
+		original, _, originDir := setup(t)
+		assert.NoError(t, os.WriteFile(filepath.Join(originDir, "catalog", "photo-1.png"), []byte("taken"), 0644))
+
+		movedPath, err := NewImageConverter(cfg).moveOriginalFile(original, originDir)
+		assert.NoError(t, err)
+		assert.Equal(t, filepath.Join(originDir, "catalog", "photo-2.png"), movedPath)
+	})
+
+	t.Run("Skip leaves the original in place", func(t *testing.T) {
+		original, existing, originDir := setup(t)
+		converter := NewImageConverter(cfg)
+		assert.NoError(t, converter.SetOnConflict(OnConflictSkip))
+
+		movedPath, err := converter.moveOriginalFile(original, originDir)
+		assert.NoError(t, err)
+		assert.Empty(t, movedPath)
+		assert.FileExists(t, original)
+
+		content, err := os.ReadFile(existing)
+		assert.NoError(t, err)
+		assert.Equal(t, "older original", string(content))
+	})
+
+	t.Run("Overwrite replaces the existing file", func(t *testing.T) {
+		original, existing, originDir := setup(t)
+		converter := NewImageConverter(cfg)
+		assert.NoError(t, converter.SetOnConflict(OnConflictOverwrite))
+
+		movedPath, err := converter.moveOriginalFile(original, originDir)
+		assert.NoError(t, err)
+		assert.Equal(t, existing, movedPath)
+
+		content, err := os.ReadFile(existing)
+		assert.NoError(t, err)
+		assert.Equal(t, "new original", string(content))
+	})
+
+	t.Run("Unknown strategy", func(t *testing.T) {
+		assert.Error(t, NewImageConverter(cfg).SetOnConflict("merge"))
+	})
+}
+
+// TestMoveByCopy tests that the original is only removed after an intact copy
+func TestMoveByCopy(t *testing.T) {
+	setup := func(t *testing.T) (string, string) {
+		tempDir := t.TempDir()
+		src := filepath.Join(tempDir, "photo.png")
+		assert.NoError(t, os.WriteFile(src, []byte("original content"), 0644))
+		return src, filepath.Join(tempDir, "copy.png")
+	}
+
+	t.Run("Intact copy removes the source", func(t *testing.T) {
+		src, dst := setup(t)
+
+		assert.NoError(t, moveByCopy(src, dst, copyFile))
+		assert.NoFileExists(t, src)
+		content, err := os.ReadFile(dst)
+		assert.NoError(t, err)
+		assert.Equal(t, "original content", string(content))
+	})
+
+	t.Run("Size mismatch keeps the source", func(t *testing.T) {
+		src, dst := setup(t)
+		truncatedCopy := func(src, dst string) error {
+			return os.WriteFile(dst, []byte("original"), 0644)
+		}
+
+		err := moveByCopy(src, dst, truncatedCopy)
+		assert.ErrorContains(t, err, "size mismatch")
+		content, err := os.ReadFile(src)
+		assert.NoError(t, err)
+		assert.Equal(t, "original content", string(content))
+		assert.NoFileExists(t, dst)
+	})
+
+	t.Run("Checksum mismatch keeps the source", func(t *testing.T) {
+		src, dst := setup(t)
+		corruptCopy := func(src, dst string) error {
+			return os.WriteFile(dst, []byte("original CONTENT"), 0644)
+		}
+
+		err := moveByCopy(src, dst, corruptCopy)
+		assert.ErrorContains(t, err, "checksum mismatch")
+		assert.FileExists(t, src)
+	})
+}
@@ -0,0 +1,44 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// batchPrompt extends the instruction for a single image to a request with count images
+func batchPrompt(userPrompt string, count int) string {
+	return fmt.Sprintf(`%s
+
+You are given %d images. Respond with a JSON array of exactly %d objects, one per image in the
+order the images were given, each with the keys requested for a single image.`, userPrompt, count, count)
+}
+
+// parseBatchContent parses the answer to a batch request, mapping the keys of every result with
+// fieldMap. Models asked for JSON output often wrap the array in an object, so an object with a
+// single array field is accepted as well.
+func parseBatchContent(content string, fieldMap map[string]string) ([]*LLMResponse, error) {
+	content = strings.TrimSpace(content)
+
+	var results []json.RawMessage
+	if err := json.Unmarshal([]byte(content), &results); err != nil {
+		var wrapped map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(content), &wrapped); err != nil || len(wrapped) != 1 {
+			return nil, fmt.Errorf("%w: the answer is not a JSON array", ErrBatchMismatch)
+		}
+		for _, raw := range wrapped {
+			if err := json.Unmarshal(raw, &results); err != nil {
+				return nil, fmt.Errorf("%w: the answer is not a JSON array", ErrBatchMismatch)
+			}
+		}
+	}
+
+	responses := make([]*LLMResponse, len(results))
+	for i, raw := range results {
+		// A result that isn't an object is left nil and fails validation on its own
+		if response, err := decodeResponse(raw, fieldMap); err == nil {
+			responses[i] = response
+		}
+	}
+	return responses, nil
+}
@@ -0,0 +1,75 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"kbase-catalog/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLLMClient_AskLLMBatch(t *testing.T) {
+	var content string
+	var imageCount int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		parts := payload["messages"].([]interface{})[1].(map[string]interface{})["content"].([]interface{})
+		imageCount = len(parts) - 1
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"model":   "test-model",
+			"choices": []interface{}{map[string]interface{}{"message": map[string]interface{}{"content": content}}},
+		})
+	}))
+	defer server.Close()
+
+	client := NewLLMClient(&config.Config{APIURL: server.URL, Model: "test-model", Timeout: 10})
+	paths := []string{"/a.png", "/b.png"}
+	data := []string{"data:image/png;base64,YQ==", "data:image/png;base64,Yg=="}
+
+	t.Run("Array answer", func(t *testing.T) {
+		content = `[{"short_name": "A", "description": "First."}, {"short_name": "B", "description": "Second."}]`
+
+		responses, model, err := client.AskLLMBatch(context.Background(), paths, data)
+		assert.NoError(t, err)
+		assert.Equal(t, "test-model", model)
+		assert.Equal(t, 2, imageCount)
+		if assert.Len(t, responses, 2) {
+			assert.Equal(t, "A", responses[0].ShortName)
+			assert.Equal(t, "B", responses[1].ShortName)
+		}
+	})
+
+	t.Run("Array wrapped in an object", func(t *testing.T) {
+		content = `{"results": [{"short_name": "A", "description": "First."}, {"short_name": "B", "description": "Second."}]}`
+
+		responses, _, err := client.AskLLMBatch(context.Background(), paths, data)
+		assert.NoError(t, err)
+		assert.Len(t, responses, 2)
+	})
+
+	t.Run("Fewer results than images", func(t *testing.T) {
+		content = `[{"short_name": "A", "description": "First."}]`
+
+		_, _, err := client.AskLLMBatch(context.Background(), paths, data)
+		assert.ErrorIs(t, err, ErrBatchMismatch)
+	})
+
+	t.Run("Single object answer", func(t *testing.T) {
+		content = `{"short_name": "A", "description": "First."}`
+
+		_, _, err := client.AskLLMBatch(context.Background(), paths, data)
+		assert.ErrorIs(t, err, ErrBatchMismatch)
+	})
+}
+
+func TestBatchPrompt(t *testing.T) {
+	prompt := batchPrompt(describePrompt, 3)
+	assert.Contains(t, prompt, describePrompt)
+	assert.Contains(t, prompt, "JSON array of exactly 3 objects")
+}
@@ -4,119 +4,413 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"maps"
 	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"kbase-catalog/internal/config"
+	apperrors "kbase-catalog/internal/errors"
+	"kbase-catalog/internal/metrics"
 )
 
 type LLMResponse struct {
 	ShortName   string `json:"short_name"`
 	Description string `json:"description"`
+	// LongDescription is the optional detailed paragraph, the description being the short version
+	LongDescription string `json:"long_description,omitempty"`
+	Text            string `json:"text,omitempty"`
+	Tags            Tags   `json:"tags,omitempty"`
 }
 
+// Tags is a list of keywords returned by the model. Models don't always follow the
+// requested format, so a single comma separated string is accepted as well as an array.
+type Tags []string
+
+// UnmarshalJSON parses tags from either a JSON array or a comma separated string,
+// trimming whitespace and dropping empty and duplicate entries. Tags are optional,
+// so a value of any other shape is ignored rather than failing the whole response.
+func (t *Tags) UnmarshalJSON(data []byte) error {
+	var raw []string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		var joined string
+		if err := json.Unmarshal(data, &joined); err != nil {
+			*t = nil
+			return nil
+		}
+		raw = strings.Split(joined, ",")
+	}
+
+	seen := make(map[string]bool)
+	tags := Tags{}
+	for _, tag := range raw {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[strings.ToLower(tag)] {
+			continue
+		}
+		seen[strings.ToLower(tag)] = true
+		tags = append(tags, tag)
+	}
+
+	*t = tags
+	return nil
+}
+
+// ErrBatchMismatch means the answer to a batch request doesn't hold one result per image
+var ErrBatchMismatch = errors.New("batch response doesn't match the images")
+
+const (
+	describePrompt = "Analyze this image and provide a short name and description."
+	ocrPrompt      = `Extract all visible text from this image, preserving the reading order.
+Respond in valid JSON format ONLY, with exactly two keys:
+1. "short_name": a short title for the image based on its text.
+2. "text": the extracted text as plain text, or an empty string if there is none.`
+)
+
 type LLMClient struct {
 	config *config.Config
 	client *http.Client
+	log    *slog.Logger
+	// next counts requests to pick the first endpoint in round robin mode
+	next atomic.Uint64
 }
 
 func NewLLMClient(cfg *config.Config) *LLMClient {
 	return &LLMClient{
 		config: cfg,
 		client: &http.Client{
-			Timeout: time.Duration(cfg.Timeout) * time.Second,
+			Timeout:   time.Duration(cfg.Timeout) * time.Second,
+			Transport: newTransport(cfg),
 		},
+		log: slog.Default(),
 	}
 }
 
+// newTransport builds the connection pool of a client. The default transport keeps only two
+// idle connections per host, so with more parallel workers connections would be closed and
+// dialed again for every request.
+func newTransport(cfg *config.Config) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = cfg.GetLLMMaxIdleConns()
+	transport.MaxIdleConnsPerHost = cfg.GetLLMMaxIdleConns()
+	transport.MaxConnsPerHost = cfg.GetLLMMaxConnsPerHost()
+	return transport
+}
+
+// logger returns the injected logger, falling back to the default one for zero value clients
+func (c *LLMClient) logger() *slog.Logger {
+	if c.log == nil {
+		return slog.Default()
+	}
+	return c.log
+}
+
+// userPrompt returns the instruction sent along with the image for the configured task mode,
+// asking for the configured output language
+func (c *LLMClient) userPrompt() string {
+	language := c.config.OutputLanguage
+	if c.config.IsOCRMode() {
+		if language == "" {
+			return ocrPrompt
+		}
+		return ocrPrompt + fmt.Sprintf("\nWrite the short_name in %s, keep the extracted text in its original language.", language)
+	}
+	if language == "" {
+		return describePrompt
+	}
+	return describePrompt + fmt.Sprintf("\nRespond in %s: write the short_name, description, long_description and tags in %s.", language, language)
+}
+
+// isRetryableStatus reports whether a request failing with the status code may succeed later.
+// Client errors other than timeouts and rate limiting mean the request itself was rejected.
+func isRetryableStatus(statusCode int) bool {
+	if statusCode == http.StatusRequestTimeout || statusCode == http.StatusTooManyRequests {
+		return true
+	}
+	return statusCode < 400 || statusCode >= 500
+}
+
 func (c *LLMClient) AskLLM(ctx context.Context, imagePath string, imageData string) (*LLMResponse, string, error) {
-	payload := map[string]interface{}{
-		"model": c.config.Model,
-		"messages": []map[string]interface{}{
-			{
-				"role":    "system",
-				"content": c.config.SystemPrompt,
-			},
-			{
-				"role": "user",
-				"content": []map[string]interface{}{
-					{
-						"type": "text",
-						"text": "Analyze this image and provide a short name and description.",
-					},
-					{
-						"type": "image_url",
-						"image_url": map[string]string{
-							"url": imageData,
-						},
-					},
-				},
-			},
-		},
-		"stream": false,
+	metrics.LLMRequests.Inc()
+	start := time.Now()
+
+	response, model, err := c.askLLM(ctx, imageData)
+	metrics.LLMDuration.ObserveSince(start)
+	if err != nil {
+		metrics.LLMErrors.Inc()
 	}
 
-	jsonPayload, err := json.Marshal(payload)
+	return response, model, err
+}
+
+// AskLLMBatch describes several images with a single request. The responses are in the order
+// of the images. A server that doesn't answer with one result per image fails with
+// ErrBatchMismatch, the images should then be sent one at a time.
+func (c *LLMClient) AskLLMBatch(ctx context.Context, imagePaths []string, imageData []string) ([]*LLMResponse, string, error) {
+	metrics.LLMRequests.Inc()
+	start := time.Now()
+
+	responses, model, err := c.askLLMBatch(ctx, imageData)
+	metrics.LLMDuration.ObserveSince(start)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to marshal request payload: %w", err)
+		metrics.LLMErrors.Inc()
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", c.config.APIURL, bytes.NewBuffer(jsonPayload))
+	return responses, model, err
+}
+
+// buildPayload creates the request of the configured provider for the images given as data URLs
+func (c *LLMClient) buildPayload(userPrompt string, imageURLs []string) map[string]interface{} {
+	return c.provider().Payload(Request{
+		Model:        c.config.Model,
+		SystemPrompt: c.config.SystemPrompt,
+		UserPrompt:   userPrompt,
+		ImageURLs:    imageURLs,
+		Temperature:  c.config.Temperature,
+		MaxTokens:    c.config.MaxTokens,
+		Seed:         c.config.Seed,
+	})
+}
+
+// provider returns the wire format of the configured API
+func (c *LLMClient) provider() LLMProvider {
+	return newProvider(c.config)
+}
+
+// logRequest logs the outgoing request at debug level when debug_llm is set. The images are
+// replaced by their length and the API key is never logged.
+func (c *LLMClient) logRequest(ctx context.Context, url, userPrompt string, imageData []string) {
+	if !c.config.DebugLLM {
+		return
+	}
+
+	elided := make([]string, len(imageData))
+	for i, data := range imageData {
+		elided[i] = fmt.Sprintf("<image data elided, %d bytes>", len(data))
+	}
+	payload, err := json.Marshal(c.buildPayload(userPrompt, elided))
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to create request: %w", err)
+		return
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	authorization := "none"
+	if c.config.APIKey != "" {
+		authorization = "Bearer [REDACTED]"
+	}
+	c.logger().DebugContext(ctx, "LLM request", "url", url, "model", c.config.Model,
+		"authorization", authorization, "payload", string(payload))
+}
 
-	resp, err := c.client.Do(req)
+// logResponse logs the raw response body at debug level when debug_llm is set
+func (c *LLMClient) logResponse(ctx context.Context, url string, statusCode int, body []byte) {
+	if !c.config.DebugLLM {
+		return
+	}
+	c.logger().DebugContext(ctx, "LLM response", "url", url, "model", c.config.Model,
+		"status", statusCode, "body", string(body))
+}
+
+// requestContext bounds a request by the configured timeout. A shorter deadline of the caller
+// wins, and unlike the client timeout alone it also covers reading the response body.
+func (c *LLMClient) requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.config.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, time.Duration(c.config.Timeout)*time.Second)
+}
+
+// endpoints returns the API URLs in the order they are tried. In round robin mode every
+// request starts at the endpoint after the one the previous request started at.
+func (c *LLMClient) endpoints() []string {
+	urls := c.config.GetAPIURLs()
+	if len(urls) < 2 || c.config.APIURLMode != config.APIURLModeRoundRobin {
+		return urls
+	}
+
+	first := int((c.next.Add(1) - 1) % uint64(len(urls)))
+	return append(slices.Clone(urls[first:]), urls[:first]...)
+}
+
+// shouldFailover reports whether a request failing with err may succeed on another endpoint:
+// the endpoint was unreachable, timed out or answered with a server error, and the caller is
+// still waiting for the answer
+func shouldFailover(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var networkErr *apperrors.NetworkError
+	return errors.As(err, &networkErr) && networkErr.Retryable
+}
+
+// askLLM sends the image to the LLM API and parses the JSON answer
+func (c *LLMClient) askLLM(ctx context.Context, imageData string) (*LLMResponse, string, error) {
+	content, model, err := c.ask(ctx, c.userPrompt(), []string{imageData})
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to send request to LLM API: %w", err)
+		return nil, "", err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, "", fmt.Errorf("LLM API returned status code %d: %s", resp.StatusCode, string(body))
+	llmResponse, err := decodeResponse([]byte(content), c.config.ResponseFieldMap)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to parse LLM response as JSON: %w", err)
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	return llmResponse, model, nil
+}
+
+// decodeResponse parses the JSON object answered for an image. Some models wrap the answer in
+// an outer object, e.g. {"result": {...}}, so when the object has none of the response fields
+// the first nested object that has some is used instead.
+func decodeResponse(data []byte, fieldMap map[string]string) (*LLMResponse, error) {
+	llmResponse, err := decodeObject(data, fieldMap)
+	if err != nil || !llmResponse.isEmpty() {
+		return llmResponse, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return llmResponse, nil
+	}
+	for _, key := range slices.Sorted(maps.Keys(fields)) {
+		value := bytes.TrimSpace(fields[key])
+		if len(value) == 0 || value[0] != '{' {
+			continue
+		}
+		if nested, err := decodeObject(value, fieldMap); err == nil && !nested.isEmpty() {
+			return nested, nil
+		}
+	}
+	return llmResponse, nil
+}
+
+// isEmpty reports whether the response has none of the text fields
+func (r *LLMResponse) isEmpty() bool {
+	return r.ShortName == "" && r.Description == "" && r.LongDescription == "" && r.Text == ""
+}
+
+// decodeObject parses a JSON object into a response. The keys of fieldMap found in the object
+// are renamed to their response field first, unless the model also sent that field.
+func decodeObject(data []byte, fieldMap map[string]string) (*LLMResponse, error) {
+	var llmResponse LLMResponse
+	if len(fieldMap) == 0 {
+		if err := json.Unmarshal(data, &llmResponse); err != nil {
+			return nil, err
+		}
+		return &llmResponse, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for from, to := range fieldMap {
+		value, ok := fields[from]
+		if !ok {
+			continue
+		}
+		if _, exists := fields[to]; !exists {
+			fields[to] = value
+		}
+	}
+
+	mapped, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(mapped, &llmResponse); err != nil {
+		return nil, err
+	}
+	return &llmResponse, nil
+}
+
+// askLLMBatch sends the images to the LLM API at once and parses the JSON array answer
+func (c *LLMClient) askLLMBatch(ctx context.Context, imageData []string) ([]*LLMResponse, string, error) {
+	content, model, err := c.ask(ctx, batchPrompt(c.userPrompt(), len(imageData)), imageData)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to read response body: %w", err)
+		return nil, "", err
 	}
 
-	var response map[string]interface{}
-	err = json.Unmarshal(body, &response)
+	responses, err := parseBatchContent(content, c.config.ResponseFieldMap)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to unmarshal LLM response: %w", err)
+		return nil, "", err
+	}
+	if len(responses) != len(imageData) {
+		return nil, "", fmt.Errorf("%w: got %d results for %d images", ErrBatchMismatch, len(responses), len(imageData))
 	}
 
-	choices, ok := response["choices"].([]interface{})
-	if !ok || len(choices) == 0 {
-		return nil, "", fmt.Errorf("unexpected response format from LLM API")
+	return responses, model, nil
+}
+
+// ask sends the prompt with the images to the LLM API and returns the text answered by the
+// model. With several endpoints configured they are tried in turn until one of them answers.
+func (c *LLMClient) ask(ctx context.Context, userPrompt string, imageData []string) (string, string, error) {
+	jsonPayload, err := json.Marshal(c.buildPayload(userPrompt, imageData))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to marshal request payload: %w", err)
 	}
 
-	message, ok := choices[0].(map[string]interface{})["message"].(map[string]interface{})
-	if !ok {
-		return nil, "", fmt.Errorf("unexpected message format in LLM response")
+	var body []byte
+	endpoints := c.endpoints()
+	for i, url := range endpoints {
+		body, err = c.post(ctx, url, userPrompt, imageData, jsonPayload)
+		if err == nil || i == len(endpoints)-1 || !shouldFailover(ctx, err) {
+			break
+		}
+		c.logger().WarnContext(ctx, "LLM endpoint failed, trying the next one", "url", url, "next_url", endpoints[i+1], "error", err)
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	return c.provider().ParseResponse(body)
+}
+
+// post sends the request payload to a single endpoint and returns the body of a successful response
+func (c *LLMClient) post(ctx context.Context, url, userPrompt string, imageData []string, jsonPayload []byte) ([]byte, error) {
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+
+	c.logRequest(ctx, url, userPrompt, imageData)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonPayload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	content, ok := message["content"].(string)
-	if !ok {
-		return nil, "", fmt.Errorf("unexpected content format in LLM response")
+	req.Header.Set("Content-Type", "application/json")
+	if c.config.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.config.APIKey)
 	}
 
-	var llmResponse LLMResponse
-	err = json.Unmarshal([]byte(content), &llmResponse)
+	resp, err := c.client.Do(req)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to parse LLM response as JSON: %w", err)
+		return nil, apperrors.NewNetworkError(url, err)
 	}
+	defer resp.Body.Close()
 
-	modelName := ""
-	if model, ok := response["model"].(string); ok {
-		modelName = model
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		c.logResponse(ctx, url, resp.StatusCode, body)
+		base := apperrors.NewBaseError("LLM_API_ERROR", fmt.Sprintf("LLM API returned status code %d", resp.StatusCode), nil)
+		base.Details = string(body)
+		return nil, &apperrors.NetworkError{
+			BaseError:  base,
+			StatusCode: resp.StatusCode,
+			URL:        url,
+			Retryable:  isRetryableStatus(resp.StatusCode),
+		}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
 	}
+	c.logResponse(ctx, url, resp.StatusCode, body)
 
-	return &llmResponse, modelName, nil
+	return body, nil
 }
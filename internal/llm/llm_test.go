@@ -1,11 +1,18 @@
 package llm
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"kbase-catalog/internal/config"
+	apperrors "kbase-catalog/internal/errors"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -68,6 +75,152 @@ The JSON must contain two keys:
 	assert.Equal(t, "test-model", model)
 }
 
+func TestLLMClient_AskLLM_ResponseFieldMap(t *testing.T) {
+	var content string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"model":   "test-model",
+			"choices": []interface{}{map[string]interface{}{"message": map[string]interface{}{"content": content}}},
+		})
+	}))
+	defer server.Close()
+
+	client := NewLLMClient(&config.Config{
+		APIURL:           server.URL,
+		Model:            "test-model",
+		Timeout:          10,
+		ResponseFieldMap: map[string]string{"title": "short_name", "caption": "description", "keywords": "tags"},
+	})
+
+	t.Run("Mapped keys fill the response", func(t *testing.T) {
+		content = `{"title": "Sunset", "caption": "The sun sets over the sea.", "keywords": "sun, sea"}`
+
+		response, _, err := client.AskLLM(context.Background(), "/a.png", "data:image/png;base64,YQ==")
+		assert.NoError(t, err)
+		assert.Equal(t, &LLMResponse{ShortName: "Sunset", Description: "The sun sets over the sea.", Tags: Tags{"sun", "sea"}}, response)
+	})
+
+	t.Run("Expected keys win over mapped ones", func(t *testing.T) {
+		content = `{"short_name": "Sunset", "title": "Ignored", "caption": "The sun sets over the sea."}`
+
+		response, _, err := client.AskLLM(context.Background(), "/a.png", "data:image/png;base64,YQ==")
+		assert.NoError(t, err)
+		assert.Equal(t, "Sunset", response.ShortName)
+		assert.Equal(t, "The sun sets over the sea.", response.Description)
+	})
+
+	t.Run("Batch results are mapped", func(t *testing.T) {
+		content = `[{"title": "A", "caption": "First."}, {"title": "B", "caption": "Second."}]`
+
+		responses, _, err := client.AskLLMBatch(context.Background(), []string{"/a.png", "/b.png"}, []string{"data:image/png;base64,YQ==", "data:image/png;base64,Yg=="})
+		assert.NoError(t, err)
+		if assert.Len(t, responses, 2) {
+			assert.Equal(t, "B", responses[1].ShortName)
+			assert.Equal(t, "Second.", responses[1].Description)
+		}
+	})
+}
+
+func TestDecodeResponse_Nested(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		fieldMap map[string]string
+		expected *LLMResponse
+	}{
+		{
+			"nested under an outer key",
+			`{"result": {"short_name": "Sunset", "description": "The sun sets over the sea.", "tags": ["sun"]}}`,
+			nil,
+			&LLMResponse{ShortName: "Sunset", Description: "The sun sets over the sea.", Tags: Tags{"sun"}},
+		},
+		{
+			"other keys around the nested object",
+			`{"status": "ok", "count": 1, "image": {"short_name": "Sunset", "description": "The sun sets."}}`,
+			nil,
+			&LLMResponse{ShortName: "Sunset", Description: "The sun sets."},
+		},
+		{
+			"nested keys are mapped",
+			`{"answer": {"title": "Sunset", "caption": "The sun sets."}}`,
+			map[string]string{"title": "short_name", "caption": "description"},
+			&LLMResponse{ShortName: "Sunset", Description: "The sun sets."},
+		},
+		{
+			"top level fields win",
+			`{"short_name": "Top", "description": "Top level.", "extra": {"short_name": "Nested", "description": "Nested."}}`,
+			nil,
+			&LLMResponse{ShortName: "Top", Description: "Top level."},
+		},
+		{
+			"only one level deep",
+			`{"a": {"b": {"short_name": "Deep", "description": "Too deep."}}}`,
+			nil,
+			&LLMResponse{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := decodeResponse([]byte(tt.content), tt.fieldMap)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, response)
+		})
+	}
+}
+
+func TestLLMClient_AskLLM_NestedResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"model": "test-model",
+			"choices": []interface{}{map[string]interface{}{"message": map[string]interface{}{
+				"content": `{"result": {"short_name": "Sunset", "description": "The sun sets over the sea."}}`,
+			}}},
+		})
+	}))
+	defer server.Close()
+
+	client := NewLLMClient(&config.Config{APIURL: server.URL, Model: "test-model", Timeout: 10})
+	response, _, err := client.AskLLM(context.Background(), "/a.png", "data:image/png;base64,YQ==")
+	assert.NoError(t, err)
+	assert.Equal(t, "Sunset", response.ShortName)
+	assert.Equal(t, "The sun sets over the sea.", response.Description)
+}
+
+func TestLLMClient_AskLLM_OutputLanguage(t *testing.T) {
+	var prompt string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		parts := payload["messages"].([]interface{})[1].(map[string]interface{})["content"].([]interface{})
+		prompt = parts[0].(map[string]interface{})["text"].(string)
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []interface{}{map[string]interface{}{"message": map[string]interface{}{
+				"content": `{"short_name": "Sonnenuntergang", "description": "Die Sonne geht unter."}`,
+			}}},
+		})
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{APIURL: server.URL, Model: "test-model", Timeout: 10, OutputLanguage: "German"}
+	_, _, err := NewLLMClient(cfg).AskLLM(context.Background(), "/a.png", "data:image/png;base64,YQ==")
+	assert.NoError(t, err)
+	assert.Contains(t, prompt, describePrompt)
+	assert.Contains(t, prompt, "Respond in German")
+
+	// OCR keeps the text as it is written
+	cfg.TaskMode = config.TaskModeOCR
+	_, _, err = NewLLMClient(cfg).AskLLM(context.Background(), "/a.png", "data:image/png;base64,YQ==")
+	assert.NoError(t, err)
+	assert.Contains(t, prompt, "Write the short_name in German")
+
+	// Without a language the prompt is unchanged
+	_, _, err = NewLLMClient(&config.Config{APIURL: server.URL, Model: "test-model", Timeout: 10}).AskLLM(context.Background(), "/a.png", "data:image/png;base64,YQ==")
+	assert.NoError(t, err)
+	assert.Equal(t, describePrompt, prompt)
+}
+
 func TestLLMClient_AskLLM_Error(t *testing.T) {
 	// Create a mock server that returns an error
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -99,6 +252,182 @@ The JSON must contain two keys:
 	assert.Error(t, err)
 	assert.Nil(t, response)
 	assert.Equal(t, "", model)
+
+	var networkErr *apperrors.NetworkError
+	if assert.True(t, errors.As(err, &networkErr)) {
+		assert.Equal(t, http.StatusInternalServerError, networkErr.StatusCode)
+		assert.Equal(t, server.URL, networkErr.URL)
+		assert.True(t, networkErr.Retryable)
+	}
+}
+
+func TestLLMClient_AskLLM_ConnectionError(t *testing.T) {
+	// Close the server right away so the request can't connect
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close()
+
+	client := &LLMClient{
+		config: &config.Config{APIURL: server.URL, Model: "test-model", Timeout: 10},
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+
+	_, _, err := client.AskLLM(context.Background(), "/test/image.jpg", "data:image/jpeg;base64,test-data")
+	assert.Error(t, err)
+
+	var networkErr *apperrors.NetworkError
+	if assert.True(t, errors.As(err, &networkErr)) {
+		assert.Equal(t, 0, networkErr.StatusCode)
+		assert.Equal(t, server.URL, networkErr.URL)
+		assert.True(t, networkErr.Retryable)
+		assert.NotNil(t, networkErr.Unwrap())
+	}
+}
+
+// newEndpointServer answers with the status code and counts its requests
+func newEndpointServer(t *testing.T, statusCode int, requests *int) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		if statusCode != http.StatusOK {
+			w.WriteHeader(statusCode)
+			return
+		}
+		response := map[string]interface{}{
+			"model": "test-model",
+			"choices": []interface{}{
+				map[string]interface{}{
+					"message": map[string]interface{}{
+						"content": `{"short_name": "Test Image", "description": "This is a test image."}`,
+					},
+				},
+			},
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestLLMClient_AskLLM_Failover(t *testing.T) {
+	t.Run("Server error and unreachable endpoints are skipped", func(t *testing.T) {
+		var failingRequests, healthyRequests int
+		failing := newEndpointServer(t, http.StatusServiceUnavailable, &failingRequests)
+		healthy := newEndpointServer(t, http.StatusOK, &healthyRequests)
+
+		down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		down.Close()
+
+		client := NewLLMClient(&config.Config{APIURLs: []string{failing.URL, down.URL, healthy.URL}, Model: "test-model", Timeout: 10})
+
+		response, _, err := client.AskLLM(context.Background(), "/test/image.jpg", "data:image/jpeg;base64,test-data")
+		assert.NoError(t, err)
+		if assert.NotNil(t, response) {
+			assert.Equal(t, "Test Image", response.ShortName)
+		}
+		assert.Equal(t, 1, failingRequests)
+		assert.Equal(t, 1, healthyRequests)
+	})
+
+	t.Run("Rejected requests are not sent elsewhere", func(t *testing.T) {
+		var rejectingRequests, healthyRequests int
+		rejecting := newEndpointServer(t, http.StatusBadRequest, &rejectingRequests)
+		healthy := newEndpointServer(t, http.StatusOK, &healthyRequests)
+
+		client := NewLLMClient(&config.Config{APIURLs: []string{rejecting.URL, healthy.URL}, Model: "test-model", Timeout: 10})
+
+		_, _, err := client.AskLLM(context.Background(), "/test/image.jpg", "data:image/jpeg;base64,test-data")
+		var networkErr *apperrors.NetworkError
+		if assert.True(t, errors.As(err, &networkErr)) {
+			assert.Equal(t, http.StatusBadRequest, networkErr.StatusCode)
+			assert.Equal(t, rejecting.URL, networkErr.URL)
+		}
+		assert.Equal(t, 0, healthyRequests)
+	})
+
+	t.Run("The error of the last endpoint is returned", func(t *testing.T) {
+		var firstRequests, lastRequests int
+		first := newEndpointServer(t, http.StatusInternalServerError, &firstRequests)
+		last := newEndpointServer(t, http.StatusBadGateway, &lastRequests)
+
+		client := NewLLMClient(&config.Config{APIURLs: []string{first.URL, last.URL}, Model: "test-model", Timeout: 10})
+
+		_, _, err := client.AskLLM(context.Background(), "/test/image.jpg", "data:image/jpeg;base64,test-data")
+		var networkErr *apperrors.NetworkError
+		if assert.True(t, errors.As(err, &networkErr)) {
+			assert.Equal(t, http.StatusBadGateway, networkErr.StatusCode)
+		}
+		assert.Equal(t, 1, firstRequests)
+		assert.Equal(t, 1, lastRequests)
+	})
+}
+
+func TestLLMClient_endpoints(t *testing.T) {
+	urls := []string{"http://a", "http://b", "http://c"}
+
+	t.Run("Failover keeps the order", func(t *testing.T) {
+		client := NewLLMClient(&config.Config{APIURLs: urls})
+		assert.Equal(t, urls, client.endpoints())
+		assert.Equal(t, urls, client.endpoints())
+	})
+
+	t.Run("Round robin rotates the first endpoint", func(t *testing.T) {
+		client := NewLLMClient(&config.Config{APIURLs: urls, APIURLMode: config.APIURLModeRoundRobin})
+		assert.Equal(t, []string{"http://a", "http://b", "http://c"}, client.endpoints())
+		assert.Equal(t, []string{"http://b", "http://c", "http://a"}, client.endpoints())
+		assert.Equal(t, []string{"http://c", "http://a", "http://b"}, client.endpoints())
+		assert.Equal(t, []string{"http://a", "http://b", "http://c"}, client.endpoints())
+		// The configured order is left untouched
+		assert.Equal(t, []string{"http://a", "http://b", "http://c"}, urls)
+	})
+
+	t.Run("api_url is a single endpoint", func(t *testing.T) {
+		client := NewLLMClient(&config.Config{APIURL: "http://single", APIURLMode: config.APIURLModeRoundRobin})
+		assert.Equal(t, []string{"http://single"}, client.endpoints())
+	})
+}
+
+func TestLLMClient_AskLLM_ContextDeadline(t *testing.T) {
+	// The server only answers once the client gives up, which it notices after reading the body
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	client := NewLLMClient(&config.Config{APIURL: server.URL, Model: "test-model", Timeout: 10})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, _, err := client.AskLLM(ctx, "/test/image.jpg", "data:image/jpeg;base64,test-data")
+	elapsed := time.Since(start)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.GreaterOrEqual(t, elapsed, 100*time.Millisecond)
+	assert.Less(t, elapsed, 2*time.Second, "the request should stop at the context deadline, not the client timeout")
+}
+
+func TestLLMClient_requestContext(t *testing.T) {
+	client := &LLMClient{config: &config.Config{Timeout: 1}}
+
+	ctx, cancel := client.requestContext(context.Background())
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	if assert.True(t, ok) {
+		assert.WithinDuration(t, time.Now().Add(time.Second), deadline, 500*time.Millisecond)
+	}
+
+	// An earlier deadline of the caller is kept
+	parent, cancelParent := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancelParent()
+	parentDeadline, _ := parent.Deadline()
+	ctx, cancel = client.requestContext(parent)
+	defer cancel()
+	deadline, _ = ctx.Deadline()
+	assert.Equal(t, parentDeadline, deadline)
 }
 
 func TestLLMClient_AskLLM_InvalidResponse(t *testing.T) {
@@ -221,3 +550,130 @@ The JSON must contain two keys:
 	assert.Equal(t, "", response.Description)
 	assert.Equal(t, "test-model", model)
 }
+
+func TestLLMResponse_Tags(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected Tags
+	}{
+		{"array", `{"short_name": "A", "description": "B", "tags": ["sunset", " beach ", ""]}`, Tags{"sunset", "beach"}},
+		{"comma separated string", `{"short_name": "A", "description": "B", "tags": "sunset, beach,Sunset"}`, Tags{"sunset", "beach"}},
+		{"missing", `{"short_name": "A", "description": "B"}`, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var response LLMResponse
+			err := json.Unmarshal([]byte(tt.content), &response)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, response.Tags)
+		})
+	}
+
+	t.Run("invalid type is ignored", func(t *testing.T) {
+		var response LLMResponse
+		err := json.Unmarshal([]byte(`{"short_name": "A", "description": "B", "tags": 42}`), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, "A", response.ShortName)
+		assert.Nil(t, response.Tags)
+	})
+}
+
+func TestLLMClient_AskLLM_APIKey(t *testing.T) {
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+
+		response := map[string]interface{}{
+			"choices": []interface{}{
+				map[string]interface{}{
+					"message": map[string]interface{}{
+						"content": `{"short_name": "Test Image", "description": "This is a test image."}`,
+					},
+				},
+			},
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	client := NewLLMClient(&config.Config{APIURL: server.URL, Model: "test-model", Timeout: 10, APIKey: "secret"})
+
+	_, _, err := client.AskLLM(context.Background(), "/test/image.jpg", "data:image/jpeg;base64,test-data")
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer secret", authorization)
+}
+
+func TestNewLLMClient_Transport(t *testing.T) {
+	t.Run("Configured limits", func(t *testing.T) {
+		client := NewLLMClient(&config.Config{Timeout: 10, ParallelRequests: 3, LLMMaxIdleConns: 12, LLMMaxConnsPerHost: 20})
+
+		transport, ok := client.client.Transport.(*http.Transport)
+		if assert.True(t, ok) {
+			assert.Equal(t, 12, transport.MaxIdleConns)
+			assert.Equal(t, 12, transport.MaxIdleConnsPerHost)
+			assert.Equal(t, 20, transport.MaxConnsPerHost)
+		}
+		assert.Equal(t, 10*time.Second, client.client.Timeout)
+	})
+
+	t.Run("Defaults scale with parallel requests", func(t *testing.T) {
+		client := NewLLMClient(&config.Config{Timeout: 10, ParallelRequests: 6})
+
+		transport := client.client.Transport.(*http.Transport)
+		assert.Equal(t, 6, transport.MaxIdleConnsPerHost)
+		assert.Equal(t, 12, transport.MaxConnsPerHost)
+		// The shared default transport is left untouched
+		assert.NotSame(t, http.DefaultTransport, transport)
+	})
+}
+
+func TestLLMClient_AskLLM_DebugLogging(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := map[string]interface{}{
+			"model": "served-model",
+			"choices": []interface{}{
+				map[string]interface{}{
+					"message": map[string]interface{}{
+						"content": `{"short_name": "Test Image", "description": "This is a test image."}`,
+					},
+				},
+			},
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	imageData := "data:image/png;base64," + strings.Repeat("A", 1000)
+
+	ask := func(debug bool) string {
+		var logs bytes.Buffer
+		client := NewLLMClient(&config.Config{APIURL: server.URL, Model: "test-model", Timeout: 10, APIKey: "secret-key", DebugLLM: debug})
+		client.log = slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+		_, _, err := client.AskLLM(context.Background(), "/test/image.png", imageData)
+		assert.NoError(t, err)
+		return logs.String()
+	}
+
+	t.Run("Enabled", func(t *testing.T) {
+		logs := ask(true)
+
+		assert.Contains(t, logs, "level=DEBUG")
+		assert.Contains(t, logs, "LLM request")
+		assert.Contains(t, logs, "model=test-model")
+		assert.Contains(t, logs, "LLM response")
+		assert.Contains(t, logs, "served-model")
+
+		// The image is elided to its length and the API key is redacted
+		assert.Contains(t, logs, fmt.Sprintf("image data elided, %d bytes", len(imageData)))
+		assert.NotContains(t, logs, strings.Repeat("A", 100))
+		assert.NotContains(t, logs, "secret-key")
+		assert.Contains(t, logs, "REDACTED")
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		assert.Empty(t, ask(false))
+	})
+}
@@ -0,0 +1,170 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"kbase-catalog/internal/config"
+)
+
+// Request is the provider independent content of a request to describe images
+type Request struct {
+	Model        string
+	SystemPrompt string
+	UserPrompt   string
+	// ImageURLs are the images as data URLs, "data:image/png;base64,..."
+	ImageURLs []string
+	// Temperature and MaxTokens are sent when positive, the API defaults apply otherwise
+	Temperature float64
+	MaxTokens   int
+	// Seed makes the sampling reproducible on servers that honor it, it is sent when not 0
+	Seed int
+}
+
+// LLMProvider maps requests and responses to the wire format of an LLM API
+type LLMProvider interface {
+	// Payload returns the JSON request body for the request
+	Payload(request Request) map[string]interface{}
+	// ParseResponse extracts the text answered by the model and the model name from a response body
+	ParseResponse(body []byte) (content string, model string, err error)
+}
+
+// newProvider returns the provider selected by the provider setting, OpenAI by default
+func newProvider(cfg *config.Config) LLMProvider {
+	if cfg.Provider == config.ProviderOllama {
+		return ollamaProvider{}
+	}
+	return openAIProvider{}
+}
+
+// openAIProvider speaks the OpenAI chat completions API, which most local servers implement as well
+type openAIProvider struct{}
+
+func (openAIProvider) Payload(request Request) map[string]interface{} {
+	content := []map[string]interface{}{
+		{
+			"type": "text",
+			"text": request.UserPrompt,
+		},
+	}
+	for _, imageURL := range request.ImageURLs {
+		content = append(content, map[string]interface{}{
+			"type": "image_url",
+			"image_url": map[string]string{
+				"url": imageURL,
+			},
+		})
+	}
+
+	payload := map[string]interface{}{
+		"model": request.Model,
+		"messages": []map[string]interface{}{
+			{
+				"role":    "system",
+				"content": request.SystemPrompt,
+			},
+			{
+				"role":    "user",
+				"content": content,
+			},
+		},
+		"stream": false,
+	}
+	if request.Temperature > 0 {
+		payload["temperature"] = request.Temperature
+	}
+	if request.MaxTokens > 0 {
+		payload["max_tokens"] = request.MaxTokens
+	}
+	if request.Seed != 0 {
+		payload["seed"] = request.Seed
+	}
+	return payload
+}
+
+func (openAIProvider) ParseResponse(body []byte) (string, string, error) {
+	var response map[string]interface{}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", "", fmt.Errorf("failed to unmarshal LLM response: %w", err)
+	}
+
+	choices, ok := response["choices"].([]interface{})
+	if !ok || len(choices) == 0 {
+		return "", "", fmt.Errorf("unexpected response format from LLM API")
+	}
+
+	message, ok := choices[0].(map[string]interface{})["message"].(map[string]interface{})
+	if !ok {
+		return "", "", fmt.Errorf("unexpected message format in LLM response")
+	}
+
+	content, ok := message["content"].(string)
+	if !ok {
+		return "", "", fmt.Errorf("unexpected content format in LLM response")
+	}
+
+	model, _ := response["model"].(string)
+	return content, model, nil
+}
+
+// ollamaProvider speaks the native Ollama generate API at /api/generate
+type ollamaProvider struct{}
+
+func (ollamaProvider) Payload(request Request) map[string]interface{} {
+	images := make([]string, len(request.ImageURLs))
+	for i, imageURL := range request.ImageURLs {
+		images[i] = stripDataURL(imageURL)
+	}
+
+	payload := map[string]interface{}{
+		"model":  request.Model,
+		"system": request.SystemPrompt,
+		"prompt": request.UserPrompt,
+		"images": images,
+		"format": "json",
+		"stream": false,
+	}
+	// Ollama takes the generation parameters as options, the token limit being num_predict
+	options := map[string]interface{}{}
+	if request.Temperature > 0 {
+		options["temperature"] = request.Temperature
+	}
+	if request.MaxTokens > 0 {
+		options["num_predict"] = request.MaxTokens
+	}
+	if request.Seed != 0 {
+		options["seed"] = request.Seed
+	}
+	if len(options) > 0 {
+		payload["options"] = options
+	}
+	return payload
+}
+
+func (ollamaProvider) ParseResponse(body []byte) (string, string, error) {
+	var response struct {
+		Model    string  `json:"model"`
+		Response *string `json:"response"`
+		Error    string  `json:"error"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", "", fmt.Errorf("failed to unmarshal LLM response: %w", err)
+	}
+	if response.Error != "" {
+		return "", "", fmt.Errorf("LLM API returned an error: %s", response.Error)
+	}
+	if response.Response == nil {
+		return "", "", fmt.Errorf("unexpected response format from LLM API")
+	}
+	return *response.Response, response.Model, nil
+}
+
+// stripDataURL returns the base64 data of a data URL, Ollama expects images without the prefix
+func stripDataURL(imageURL string) string {
+	if !strings.HasPrefix(imageURL, "data:") {
+		return imageURL
+	}
+	_, data, _ := strings.Cut(imageURL, ",")
+	return data
+}
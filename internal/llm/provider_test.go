@@ -0,0 +1,131 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"kbase-catalog/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLLMClient_AskLLM_OpenAIProvider(t *testing.T) {
+	var payload map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&payload)
+		w.Write([]byte(`{"model": "gpt-4o", "choices": [{"message": {"content": "{\"short_name\": \"Cat\", \"description\": \"A cat.\"}"}}]}`))
+	}))
+	defer server.Close()
+
+	client := NewLLMClient(&config.Config{APIURL: server.URL, Model: "gpt-4o", Timeout: 10, SystemPrompt: "Describe", Provider: config.ProviderOpenAI})
+
+	response, model, err := client.AskLLM(context.Background(), "/test/image.png", "data:image/png;base64,aW1hZ2U=")
+	assert.NoError(t, err)
+	assert.Equal(t, "gpt-4o", model)
+	if assert.NotNil(t, response) {
+		assert.Equal(t, "Cat", response.ShortName)
+		assert.Equal(t, "A cat.", response.Description)
+	}
+
+	assert.Equal(t, "gpt-4o", payload["model"])
+	messages := payload["messages"].([]interface{})
+	assert.Len(t, messages, 2)
+	assert.Equal(t, "Describe", messages[0].(map[string]interface{})["content"])
+	content := messages[1].(map[string]interface{})["content"].([]interface{})
+	imageURL := content[1].(map[string]interface{})["image_url"].(map[string]interface{})
+	assert.Equal(t, "data:image/png;base64,aW1hZ2U=", imageURL["url"])
+}
+
+func TestLLMClient_AskLLM_OllamaProvider(t *testing.T) {
+	var payload map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&payload)
+		w.Write([]byte(`{"model": "llava:13b", "created_at": "2024-01-01T00:00:00Z", "response": "{\"short_name\": \"Cat\", \"description\": \"A cat.\", \"tags\": [\"cat\"]}", "done": true}`))
+	}))
+	defer server.Close()
+
+	client := NewLLMClient(&config.Config{APIURL: server.URL + "/api/generate", Model: "llava:13b", Timeout: 10, SystemPrompt: "Describe", Provider: config.ProviderOllama})
+
+	response, model, err := client.AskLLM(context.Background(), "/test/image.png", "data:image/png;base64,aW1hZ2U=")
+	assert.NoError(t, err)
+	assert.Equal(t, "llava:13b", model)
+	if assert.NotNil(t, response) {
+		assert.Equal(t, "Cat", response.ShortName)
+		assert.Equal(t, "A cat.", response.Description)
+		assert.Equal(t, Tags{"cat"}, response.Tags)
+	}
+
+	assert.Equal(t, "llava:13b", payload["model"])
+	assert.Equal(t, "Describe", payload["system"])
+	assert.Equal(t, describePrompt, payload["prompt"])
+	assert.Equal(t, []interface{}{"aW1hZ2U="}, payload["images"])
+	assert.Equal(t, "json", payload["format"])
+	assert.Equal(t, false, payload["stream"])
+}
+
+func TestLLMClient_GenerationParameters(t *testing.T) {
+	var payload map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload = nil
+		json.NewDecoder(r.Body).Decode(&payload)
+		w.Write([]byte(`{"model": "m", "response": "{}", "choices": [{"message": {"content": "{}"}}]}`))
+	}))
+	defer server.Close()
+
+	ask := func(cfg *config.Config) {
+		cfg.APIURL = server.URL
+		cfg.Model = "m"
+		cfg.Timeout = 10
+		_, _, err := NewLLMClient(cfg).AskLLM(context.Background(), "/test/image.png", "data:image/png;base64,aW1hZ2U=")
+		assert.NoError(t, err)
+	}
+
+	t.Run("OpenAI", func(t *testing.T) {
+		ask(&config.Config{Temperature: 0.2, MaxTokens: 512, Seed: 42})
+		assert.Equal(t, 0.2, payload["temperature"])
+		assert.Equal(t, float64(512), payload["max_tokens"])
+		assert.Equal(t, float64(42), payload["seed"])
+
+		ask(&config.Config{})
+		assert.NotContains(t, payload, "temperature")
+		assert.NotContains(t, payload, "max_tokens")
+		assert.NotContains(t, payload, "seed")
+	})
+
+	t.Run("Ollama", func(t *testing.T) {
+		ask(&config.Config{Provider: config.ProviderOllama, Temperature: 0.2, MaxTokens: 512, Seed: 42})
+		assert.Equal(t, map[string]interface{}{"temperature": 0.2, "num_predict": float64(512), "seed": float64(42)}, payload["options"])
+
+		ask(&config.Config{Provider: config.ProviderOllama})
+		assert.NotContains(t, payload, "options")
+	})
+}
+
+func TestOllamaProvider_ParseResponse(t *testing.T) {
+	provider := ollamaProvider{}
+
+	t.Run("Error field", func(t *testing.T) {
+		_, _, err := provider.ParseResponse([]byte(`{"error": "model 'llava' not found"}`))
+		assert.ErrorContains(t, err, "model 'llava' not found")
+	})
+
+	t.Run("OpenAI shaped response", func(t *testing.T) {
+		_, _, err := provider.ParseResponse([]byte(`{"choices": []}`))
+		assert.ErrorContains(t, err, "unexpected response format")
+	})
+
+	t.Run("Invalid JSON", func(t *testing.T) {
+		_, _, err := provider.ParseResponse([]byte(`not json`))
+		assert.ErrorContains(t, err, "failed to unmarshal LLM response")
+	})
+}
+
+func TestStripDataURL(t *testing.T) {
+	assert.Equal(t, "aW1hZ2U=", stripDataURL("data:image/png;base64,aW1hZ2U="))
+	assert.Equal(t, "aW1hZ2U=", stripDataURL("aW1hZ2U="))
+	// The placeholder used for debug logging is kept readable
+	assert.Equal(t, "<image data elided, 8 bytes>", stripDataURL("<image data elided, 8 bytes>"))
+}
@@ -0,0 +1,59 @@
+package llm
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimiter spaces out outbound LLM requests. It is a token bucket holding a single
+// token that refills at the configured rate, so bursts are smoothed into an even flow
+// regardless of how many goroutines share it.
+type RateLimiter struct {
+	mutex    sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// NewRateLimiter creates a limiter allowing requestsPerSecond requests. A non-positive
+// rate means unlimited and returns nil, which is a valid limiter that never waits.
+func NewRateLimiter(requestsPerSecond float64) *RateLimiter {
+	if requestsPerSecond <= 0 {
+		return nil
+	}
+
+	return &RateLimiter{
+		interval: time.Duration(float64(time.Second) / requestsPerSecond),
+	}
+}
+
+// Wait blocks until a token is available or ctx is done
+func (rl *RateLimiter) Wait(ctx context.Context) error {
+	if rl == nil {
+		return ctx.Err()
+	}
+
+	// Reserve the next free slot while holding the lock, then wait outside of it
+	rl.mutex.Lock()
+	now := time.Now()
+	if rl.next.Before(now) {
+		rl.next = now
+	}
+	wait := rl.next.Sub(now)
+	rl.next = rl.next.Add(rl.interval)
+	rl.mutex.Unlock()
+
+	if wait <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,57 @@
+package llm
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter_Wait(t *testing.T) {
+	t.Run("Unlimited rate never waits", func(t *testing.T) {
+		limiter := NewRateLimiter(0)
+		assert.Nil(t, limiter)
+
+		start := time.Now()
+		for i := 0; i < 100; i++ {
+			assert.NoError(t, limiter.Wait(context.Background()))
+		}
+		assert.Less(t, time.Since(start), 50*time.Millisecond)
+	})
+
+	t.Run("Requests are spaced by the rate across goroutines", func(t *testing.T) {
+		const rate = 20.0
+		const requests = 5
+		limiter := NewRateLimiter(rate)
+
+		start := time.Now()
+		var wg sync.WaitGroup
+		for i := 0; i < requests; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, limiter.Wait(context.Background()))
+			}()
+		}
+		wg.Wait()
+
+		// The first request goes through immediately, every next one waits for a token
+		minElapsed := time.Duration(float64(requests-1) / rate * float64(time.Second))
+		assert.GreaterOrEqual(t, time.Since(start), minElapsed)
+	})
+
+	t.Run("Waiting respects context cancellation", func(t *testing.T) {
+		limiter := NewRateLimiter(0.1)
+		assert.NoError(t, limiter.Wait(context.Background()))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		err := limiter.Wait(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Less(t, time.Since(start), time.Second)
+	})
+}
@@ -0,0 +1,87 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew_Level(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := New(&out, "error", FormatText)
+	assert.NoError(t, err)
+
+	logger.Info("processing image", "path", "a.png")
+	logger.Warn("slow response")
+	assert.Empty(t, out.String())
+
+	logger.Error("request failed", "status", 500)
+	assert.Contains(t, out.String(), "level=ERROR")
+	assert.Contains(t, out.String(), `msg="request failed"`)
+	assert.Contains(t, out.String(), "status=500")
+}
+
+func TestNew_JSONFormat(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := New(&out, "", FormatJSON)
+	assert.NoError(t, err)
+
+	logger.Debug("hidden")
+	logger.Info("reindexed", "catalog", "photos")
+
+	var entry map[string]interface{}
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &entry))
+	assert.Equal(t, "INFO", entry["level"])
+	assert.Equal(t, "reindexed", entry["msg"])
+	assert.Equal(t, "photos", entry["catalog"])
+}
+
+func TestNew_Invalid(t *testing.T) {
+	_, err := New(&bytes.Buffer{}, "verbose", FormatText)
+	assert.Error(t, err)
+
+	_, err = New(&bytes.Buffer{}, "info", "xml")
+	assert.Error(t, err)
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := map[string]slog.Level{
+		"":        slog.LevelInfo,
+		"DEBUG":   slog.LevelDebug,
+		"warning": slog.LevelWarn,
+		"error":   slog.LevelError,
+	}
+	for name, expected := range tests {
+		level, err := ParseLevel(name)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, level)
+	}
+}
+
+func TestNew_RequestID(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := New(&out, "", FormatJSON)
+	assert.NoError(t, err)
+
+	ctx := WithRequestID(context.Background(), "req-42")
+	assert.Equal(t, "req-42", RequestID(ctx))
+
+	logger.With("component", "search").InfoContext(ctx, "search query received")
+	logger.Info("no request")
+
+	lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+	assert.Len(t, lines, 2)
+
+	var record map[string]interface{}
+	assert.NoError(t, json.Unmarshal(lines[0], &record))
+	assert.Equal(t, "req-42", record["request_id"])
+	assert.Equal(t, "search", record["component"])
+
+	record = nil
+	assert.NoError(t, json.Unmarshal(lines[1], &record))
+	assert.NotContains(t, record, "request_id")
+}
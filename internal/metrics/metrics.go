@@ -0,0 +1,144 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Counter is a monotonically increasing value
+type Counter struct {
+	name  string
+	help  string
+	value atomic.Uint64
+}
+
+// Inc increments the counter by one
+func (c *Counter) Inc() {
+	c.value.Add(1)
+}
+
+// Value returns the current counter value
+func (c *Counter) Value() uint64 {
+	return c.value.Load()
+}
+
+// Summary tracks the number and the total duration of observed events
+type Summary struct {
+	name  string
+	help  string
+	mutex sync.Mutex
+	count uint64
+	sum   float64
+}
+
+// Observe records the duration of one event
+func (s *Summary) Observe(d time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.count++
+	s.sum += d.Seconds()
+}
+
+// ObserveSince records the time elapsed since start
+func (s *Summary) ObserveSince(start time.Time) {
+	s.Observe(time.Since(start))
+}
+
+// Count returns the number of observed events
+func (s *Summary) Count() uint64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.count
+}
+
+// Registry holds metrics and renders them in the Prometheus text format
+type Registry struct {
+	mutex     sync.Mutex
+	counters  []*Counter
+	summaries []*Summary
+}
+
+// NewRegistry creates an empty metrics registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// NewCounter creates a counter and registers it
+func (r *Registry) NewCounter(name, help string) *Counter {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	counter := &Counter{name: name, help: help}
+	r.counters = append(r.counters, counter)
+	return counter
+}
+
+// NewSummary creates a duration summary and registers it
+func (r *Registry) NewSummary(name, help string) *Summary {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	summary := &Summary{name: name, help: help}
+	r.summaries = append(r.summaries, summary)
+	return summary
+}
+
+// Write renders all registered metrics in the Prometheus text exposition format
+func (r *Registry) Write(w io.Writer) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, counter := range r.counters {
+		_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n",
+			counter.name, counter.help, counter.name, counter.name, counter.Value())
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, summary := range r.summaries {
+		summary.mutex.Lock()
+		count, sum := summary.count, summary.sum
+		summary.mutex.Unlock()
+
+		_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s summary\n%s_sum %s\n%s_count %d\n",
+			summary.name, summary.help, summary.name,
+			summary.name, strconv.FormatFloat(sum, 'g', -1, 64), summary.name, count)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Handler serves the registry for scraping
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		r.Write(w)
+	})
+}
+
+// Default is the registry exposed at /metrics
+var Default = NewRegistry()
+
+// Application metrics
+var (
+	ImagesProcessed    = Default.NewCounter("images_processed_total", "Images successfully described by the LLM")
+	ImagesFailed       = Default.NewCounter("images_failed_total", "Images that could not be processed")
+	ImagesSkipped      = Default.NewCounter("images_skipped_total", "Images skipped for exceeding the file size limit")
+	LLMRequests        = Default.NewCounter("llm_requests_total", "Requests sent to the LLM API")
+	LLMErrors          = Default.NewCounter("llm_errors_total", "LLM API requests that failed")
+	QueueTasks         = Default.NewCounter("queue_tasks_total", "Reindex tasks processed by the web task queue")
+	QueueTaskFailures  = Default.NewCounter("queue_task_failures_total", "Reindex tasks that ended with an error")
+	LLMDuration        = Default.NewSummary("llm_request_duration_seconds", "Time spent waiting for the LLM API")
+	ConversionDuration = Default.NewSummary("image_conversion_duration_seconds", "Time spent converting images to WebP")
+)
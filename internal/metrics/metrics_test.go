@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry_Write(t *testing.T) {
+	registry := NewRegistry()
+	counter := registry.NewCounter("tasks_total", "Processed tasks")
+	summary := registry.NewSummary("task_duration_seconds", "Task duration")
+
+	counter.Inc()
+	counter.Inc()
+	summary.Observe(1500 * time.Millisecond)
+	summary.Observe(500 * time.Millisecond)
+
+	var out strings.Builder
+	assert.NoError(t, registry.Write(&out))
+	assert.Equal(t, `# HELP tasks_total Processed tasks
+# TYPE tasks_total counter
+tasks_total 2
+# HELP task_duration_seconds Task duration
+# TYPE task_duration_seconds summary
+task_duration_seconds_sum 2
+task_duration_seconds_count 2
+`, out.String())
+}
+
+func TestRegistry_Handler(t *testing.T) {
+	registry := NewRegistry()
+	registry.NewCounter("tasks_total", "Processed tasks").Inc()
+
+	rec := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/plain")
+	assert.Contains(t, rec.Body.String(), "tasks_total 1\n")
+}
@@ -0,0 +1,198 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// AutoTuneMaxConcurrency caps the concurrency levels tried by AutoTune
+	AutoTuneMaxConcurrency = 16
+	// DefaultAutoTuneBudget bounds the duration of the calibration of AutoTune
+	DefaultAutoTuneBudget = 2 * time.Minute
+	// autoTuneImagesPerRequest is the number of images described per concurrent request at
+	// each level, so every level keeps its workers busy for about the same time
+	autoTuneImagesPerRequest = 2
+)
+
+// TuneMeasurement is the throughput measured by AutoTune at one concurrency level
+type TuneMeasurement struct {
+	Concurrency int
+	// Images counts the images of the sample that were described
+	Images   int
+	Duration time.Duration
+}
+
+// Throughput returns the described images per second
+func (m TuneMeasurement) Throughput() float64 {
+	if m.Duration <= 0 {
+		return 0
+	}
+	return float64(m.Images) / m.Duration.Seconds()
+}
+
+// SelectConcurrency returns the concurrency of the measurement with the highest throughput,
+// the lowest of equally fast ones, or 0 without measurements
+func SelectConcurrency(measurements []TuneMeasurement) int {
+	best := -1
+	for i, m := range measurements {
+		if best < 0 || m.Throughput() > measurements[best].Throughput() ||
+			m.Throughput() == measurements[best].Throughput() && m.Concurrency < measurements[best].Concurrency {
+			best = i
+		}
+	}
+	if best < 0 {
+		return 0
+	}
+	return measurements[best].Concurrency
+}
+
+// tuneImage is an image of the archive waiting for its description
+type tuneImage struct {
+	catalogDir string
+	path       string
+	key        string
+}
+
+// AutoTune describes samples of the images needing processing at increasing concurrency, 1, 2,
+// 4, ... up to maxConcurrency, and returns the concurrency with the most images per second
+// along with the measurements. It stops once the throughput drops below the best one, when
+// the next level would likely exceed budget or when the images run out. The sample images are
+// described for good: their records are saved to the catalog indexes, so processing the
+// archive afterwards skips them. It returns 0 when no level could be measured.
+func (cp *CatalogProcessor) AutoTune(ctx context.Context, maxConcurrency int, budget time.Duration) (int, []TuneMeasurement, error) {
+	// Requests beyond the connection limit would only wait for a free connection
+	maxConcurrency = min(maxConcurrency, cp.config.GetLLMMaxConnsPerHost())
+	if maxConcurrency < 1 {
+		return 0, nil, fmt.Errorf("invalid maximum concurrency: %d", maxConcurrency)
+	}
+
+	sampleSize := 0
+	for concurrency := 1; concurrency <= maxConcurrency; concurrency *= 2 {
+		sampleSize += concurrency * autoTuneImagesPerRequest
+	}
+	images, catalogs, err := cp.findTuneImages(sampleSize)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	start := time.Now()
+	var measurements []TuneMeasurement
+	var best TuneMeasurement
+	for concurrency := 1; concurrency <= maxConcurrency && ctx.Err() == nil; concurrency *= 2 {
+		count := concurrency * autoTuneImagesPerRequest
+		if len(images) < count {
+			break
+		}
+		// A level takes about as long as the previous one, it describes twice the images with
+		// twice the requests
+		if len(measurements) > 0 && time.Since(start)+measurements[len(measurements)-1].Duration > budget {
+			break
+		}
+
+		m := cp.measureConcurrency(ctx, images[:count], catalogs, concurrency)
+		images = images[count:]
+		if ctx.Err() != nil {
+			break
+		}
+		measurements = append(measurements, m)
+		cp.logger().Info("Measured throughput", "parallel_requests", concurrency,
+			"images", m.Images, "duration", m.Duration, "images_per_second", m.Throughput())
+
+		if m.Throughput() < best.Throughput() {
+			break // Past the peak
+		}
+		best = m
+	}
+
+	// Keep the described sample images
+	for catalogDir, data := range catalogs {
+		indexDir := cp.dp.indexDir(catalogDir)
+		if err := os.MkdirAll(indexDir, 0755); err != nil {
+			return 0, measurements, fmt.Errorf("failed to create index directory: %w", err)
+		}
+		if err := cp.dp.saveIndexJson(filepath.Join(indexDir, cp.config.GetIndexJSONName()), data); err != nil {
+			return 0, measurements, fmt.Errorf("failed to save index of %s: %w", catalogDir, err)
+		}
+	}
+
+	return SelectConcurrency(measurements), measurements, ctx.Err()
+}
+
+// measureConcurrency describes images with up to concurrency requests at once and measures
+// the throughput
+func (cp *CatalogProcessor) measureConcurrency(ctx context.Context, images []tuneImage, catalogs map[string]map[string]interface{}, concurrency int) TuneMeasurement {
+	var described atomic.Int32
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, concurrency)
+
+	start := time.Now()
+	for _, img := range images {
+		wg.Add(1)
+		go func(img tuneImage) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			data := catalogs[img.catalogDir]
+			if _, err := cp.dp.processImageShared(ctx, img.path, img.key, data); err != nil {
+				cp.logger().Error("Error processing image", "path", img.path, "error", err)
+			}
+
+			cp.dp.mutex.RLock()
+			record, ok := data[img.key].(map[string]interface{})
+			cp.dp.mutex.RUnlock()
+			if ok && isDescribed(record) {
+				described.Add(1)
+			}
+		}(img)
+	}
+	wg.Wait()
+
+	return TuneMeasurement{Concurrency: concurrency, Images: int(described.Load()), Duration: time.Since(start)}
+}
+
+// findTuneImages collects up to limit images of the archive needing processing, along with the
+// index data of their catalogs
+func (cp *CatalogProcessor) findTuneImages(limit int) ([]tuneImage, map[string]map[string]interface{}, error) {
+	entries, err := os.ReadDir(cp.archiveDir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var images []tuneImage
+	catalogs := make(map[string]map[string]interface{})
+	for _, entry := range entries {
+		catalogDir := filepath.Join(cp.archiveDir, entry.Name())
+		if !entry.IsDir() || !cp.fs.IncludesDir(catalogDir) || cp.fs.ShouldExclude(catalogDir) {
+			continue
+		}
+
+		paths, err := cp.fs.FindImages(catalogDir, cp.config.RecursiveCatalogs)
+		if err != nil || len(paths) == 0 {
+			continue
+		}
+		data, err := cp.fs.LoadExistingData(filepath.Join(cp.dp.indexDir(catalogDir), cp.config.GetIndexJSONName()))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, path := range paths {
+			key := cp.dp.recordKey(catalogDir, path)
+			if !cp.dp.recordNeedsProcessing(data, key, path) {
+				continue
+			}
+			catalogs[catalogDir] = data
+			images = append(images, tuneImage{catalogDir: catalogDir, path: path, key: key})
+			if len(images) == limit {
+				return images, catalogs, nil
+			}
+		}
+	}
+	return images, catalogs, nil
+}
@@ -0,0 +1,77 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"kbase-catalog/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectConcurrency(t *testing.T) {
+	measure := func(concurrency int, imagesPerSecond float64) TuneMeasurement {
+		return TuneMeasurement{Concurrency: concurrency, Images: int(imagesPerSecond * 10), Duration: 10 * time.Second}
+	}
+
+	t.Run("Picks the peak", func(t *testing.T) {
+		measurements := []TuneMeasurement{measure(1, 0.5), measure(2, 0.9), measure(4, 1.6), measure(8, 1.2), measure(16, 0.7)}
+		assert.Equal(t, 4, SelectConcurrency(measurements))
+	})
+
+	t.Run("Picks the last level while the throughput grows", func(t *testing.T) {
+		measurements := []TuneMeasurement{measure(1, 0.5), measure(2, 1), measure(4, 2)}
+		assert.Equal(t, 4, SelectConcurrency(measurements))
+	})
+
+	t.Run("Prefers the lower concurrency on a plateau", func(t *testing.T) {
+		measurements := []TuneMeasurement{measure(1, 0.5), measure(2, 1), measure(4, 1), measure(8, 1)}
+		assert.Equal(t, 2, SelectConcurrency(measurements))
+	})
+
+	t.Run("No measurements", func(t *testing.T) {
+		assert.Equal(t, 0, SelectConcurrency(nil))
+	})
+
+	t.Run("Levels without described images", func(t *testing.T) {
+		measurements := []TuneMeasurement{{Concurrency: 1, Duration: time.Second}, measure(2, 0.1)}
+		assert.Equal(t, 2, SelectConcurrency(measurements))
+	})
+}
+
+func TestCatalogProcessor_AutoTune(t *testing.T) {
+	var requests atomic.Int32
+	server := newCountingLLMServer(t, &requests)
+
+	archiveDir := t.TempDir()
+	catalogDir := filepath.Join(archiveDir, "shapes")
+	assert.NoError(t, os.MkdirAll(catalogDir, 0755))
+	for i := 0; i < 7; i++ {
+		name := filepath.Join(catalogDir, fmt.Sprintf("image%d.png", i))
+		assert.NoError(t, os.WriteFile(name, createTestImage(10, 10, uint8(i*30), 0, 0), 0644))
+	}
+
+	cfg := &config.Config{APIURL: server.URL, Model: "test-model", Timeout: 10, ParallelRequests: 1,
+		SupportedExtensions: []string{".png"}}
+	cp := NewCatalogProcessor(cfg, archiveDir)
+
+	// Levels 1 and 2 describe 2 and 4 images, level 4 would need 8
+	concurrency, measurements, err := cp.AutoTune(context.Background(), 4, time.Minute)
+	assert.NoError(t, err)
+	assert.Len(t, measurements, 2)
+	assert.Contains(t, []int{1, 2}, concurrency)
+	assert.Equal(t, int32(6), requests.Load())
+
+	// The sample stays described, processing only describes the remaining image
+	data, err := cp.fs.LoadExistingData(filepath.Join(catalogDir, "index.json"))
+	assert.NoError(t, err)
+	assert.Len(t, data, 6)
+
+	assert.NoError(t, cp.ProcessCatalog(context.Background()))
+	assert.Equal(t, int32(7), requests.Load())
+}
@@ -0,0 +1,374 @@
+package processor
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"kbase-catalog/internal/utils"
+)
+
+// BundleManifestFile names the manifest at the root of an export bundle
+const BundleManifestFile = "manifest.json"
+
+// bundleVersion is the format of the bundles written by ExportBundle
+const bundleVersion = 1
+
+// Strategies of ImportBundle for catalogs that already have an index
+const (
+	// ImportSkip leaves the existing catalog untouched
+	ImportSkip = "skip"
+	// ImportReplace replaces the index files and images of the existing catalog
+	ImportReplace = "replace"
+	// ImportMerge adds the records of the bundle to the existing index, replacing records with
+	// the same key, and only adds the images that are missing
+	ImportMerge = "merge"
+)
+
+// ImportStrategies lists the strategies accepted by ImportBundle
+var ImportStrategies = []string{ImportSkip, ImportReplace, ImportMerge}
+
+// bundleIndexFiles are the index files of a catalog copied into a bundle. Bundles always use
+// the default names, see localIndexName.
+var bundleIndexFiles = []string{"index.json", "index.md", "index.jsonl"}
+
+// BundleManifest describes the content of an export bundle
+type BundleManifest struct {
+	Version   int             `json:"version"`
+	CreatedAt time.Time       `json:"created_at"`
+	Images    bool            `json:"images"`
+	Catalogs  []BundleCatalog `json:"catalogs"`
+}
+
+// BundleCatalog lists the files of a catalog in a bundle, as slash separated paths relative
+// to the archive
+type BundleCatalog struct {
+	Name    string   `json:"name"`
+	Records int      `json:"records"`
+	Files   []string `json:"files"`
+}
+
+// ImportedCatalog reports what ImportBundle did with a catalog of the bundle
+type ImportedCatalog struct {
+	Name string
+	// Action is "created" for a new catalog, or the strategy applied to an existing one
+	Action string
+	Files  int
+}
+
+// ExportBundle writes the index files and catalog metadata of every catalog as a zip archive
+// with a manifest, along with the indexed images when images is set. Paths in the bundle are
+// relative to the archive, index files are taken from the output directory when one is set.
+func (cp *CatalogProcessor) ExportBundle(ctx context.Context, out io.Writer, images bool) (BundleManifest, error) {
+	manifest := BundleManifest{Version: bundleVersion, CreatedAt: time.Now().UTC(), Images: images}
+
+	entries, err := os.ReadDir(cp.archiveDir)
+	if err != nil {
+		return manifest, fmt.Errorf("failed to read catalog directories: %w", err)
+	}
+
+	writer := zip.NewWriter(out)
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return manifest, err
+		}
+
+		catalogDir := filepath.Join(cp.archiveDir, entry.Name())
+		if !entry.IsDir() || cp.fs.ShouldExclude(catalogDir) {
+			continue
+		}
+
+		catalog, err := cp.exportCatalog(writer, catalogDir, images)
+		if err != nil {
+			return manifest, fmt.Errorf("failed to export catalog %s: %w", entry.Name(), err)
+		}
+		if len(catalog.Files) > 0 {
+			manifest.Catalogs = append(manifest.Catalogs, catalog)
+		}
+	}
+
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return manifest, fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	file, err := writer.Create(BundleManifestFile)
+	if err != nil {
+		return manifest, err
+	}
+	if _, err := file.Write(content); err != nil {
+		return manifest, err
+	}
+
+	return manifest, writer.Close()
+}
+
+// exportCatalog adds the files of a catalog to the bundle
+func (cp *CatalogProcessor) exportCatalog(writer *zip.Writer, catalogDir string, images bool) (BundleCatalog, error) {
+	catalogName := filepath.Base(catalogDir)
+	catalog := BundleCatalog{Name: catalogName}
+	indexDir := cp.dp.indexDir(catalogDir)
+
+	indexJsonPath := filepath.Join(indexDir, cp.config.GetIndexJSONName())
+	if !utils.IsFileExists(indexJsonPath) {
+		return catalog, nil
+	}
+	data, err := cp.fs.LoadExistingData(indexJsonPath)
+	if err != nil {
+		return catalog, err
+	}
+	catalog.Records = len(data)
+
+	addFile := func(sourcePath, name string) error {
+		if err := addBundleFile(writer, sourcePath, name); err != nil {
+			return err
+		}
+		catalog.Files = append(catalog.Files, name)
+		return nil
+	}
+
+	for _, name := range bundleIndexFiles {
+		if sourcePath := filepath.Join(indexDir, cp.localIndexName(name)); utils.IsFileExists(sourcePath) {
+			if err := addFile(sourcePath, path.Join(catalogName, name)); err != nil {
+				return catalog, err
+			}
+		}
+	}
+
+	// Nested catalogs of recursive catalogs have their own metadata
+	err = filepath.WalkDir(catalogDir, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || d.Name() != CatalogMetaFile {
+			return err
+		}
+		rel, err := filepath.Rel(cp.archiveDir, filePath)
+		if err != nil {
+			return err
+		}
+		return addFile(filePath, filepath.ToSlash(rel))
+	})
+	if err != nil {
+		return catalog, err
+	}
+
+	if images {
+		for _, key := range slices.Sorted(maps.Keys(data)) {
+			imgPath := filepath.Join(catalogDir, filepath.FromSlash(key))
+			if !utils.IsFileExists(imgPath) {
+				continue
+			}
+			if err := addFile(imgPath, path.Join(catalogName, key)); err != nil {
+				return catalog, err
+			}
+		}
+	}
+
+	return catalog, nil
+}
+
+// localIndexName returns the configured name of an index file of a bundle
+func (cp *CatalogProcessor) localIndexName(name string) string {
+	switch name {
+	case "index.json":
+		return cp.config.GetIndexJSONName()
+	case "index.md":
+		return cp.config.GetIndexMDName()
+	case "index.jsonl":
+		return cp.config.GetIndexJSONLName()
+	}
+	return name
+}
+
+// addBundleFile copies a file into the bundle under name
+func addBundleFile(writer *zip.Writer, sourcePath, name string) error {
+	source, err := os.Open(sourcePath)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	info, err := source.Stat()
+	if err != nil {
+		return err
+	}
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	header.Method = zip.Deflate
+
+	target, err := writer.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(target, source)
+	return err
+}
+
+// ImportBundle restores the catalogs of a bundle written by ExportBundle into the archive,
+// without describing any image again. Catalogs that already have an index are handled by
+// strategy, then the root index is rebuilt.
+func (cp *CatalogProcessor) ImportBundle(ctx context.Context, bundlePath string, strategy string) ([]ImportedCatalog, error) {
+	if !slices.Contains(ImportStrategies, strategy) {
+		return nil, fmt.Errorf("unknown import strategy %q, expected one of %s", strategy, strings.Join(ImportStrategies, ", "))
+	}
+
+	reader, err := zip.OpenReader(bundlePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bundle: %w", err)
+	}
+	defer reader.Close()
+
+	files := make(map[string]*zip.File, len(reader.File))
+	for _, file := range reader.File {
+		files[file.Name] = file
+	}
+
+	manifest, err := readBundleManifest(files[BundleManifestFile])
+	if err != nil {
+		return nil, err
+	}
+
+	var results []ImportedCatalog
+	for _, catalog := range manifest.Catalogs {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+
+		result, err := cp.importCatalog(catalog, files, strategy)
+		if err != nil {
+			return results, fmt.Errorf("failed to import catalog %s: %w", catalog.Name, err)
+		}
+		results = append(results, result)
+	}
+
+	if err := cp.RebuildRootIndexFull(ctx); err != nil {
+		return results, err
+	}
+	return results, nil
+}
+
+// readBundleManifest reads and checks the manifest of a bundle
+func readBundleManifest(file *zip.File) (BundleManifest, error) {
+	var manifest BundleManifest
+	if file == nil {
+		return manifest, fmt.Errorf("the bundle has no %s", BundleManifestFile)
+	}
+
+	content, err := readBundleFile(file)
+	if err != nil {
+		return manifest, err
+	}
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return manifest, fmt.Errorf("failed to parse %s: %w", BundleManifestFile, err)
+	}
+	if manifest.Version != bundleVersion {
+		return manifest, fmt.Errorf("unsupported bundle version %d", manifest.Version)
+	}
+	return manifest, nil
+}
+
+// readBundleFile returns the content of a file of the bundle
+func readBundleFile(file *zip.File) ([]byte, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return io.ReadAll(reader)
+}
+
+// isBundlePathSafe reports whether a path of the bundle stays inside the catalog it belongs to
+func isBundlePathSafe(catalogName, name string) bool {
+	if catalogName == "" || catalogName == "." || catalogName == ".." || catalogName != path.Base(catalogName) {
+		return false
+	}
+	if strings.Contains(name, `\`) || path.IsAbs(name) {
+		return false
+	}
+	return path.Clean(name) == name && strings.HasPrefix(name, catalogName+"/")
+}
+
+// importCatalog writes the files of a catalog of the bundle
+func (cp *CatalogProcessor) importCatalog(catalog BundleCatalog, files map[string]*zip.File, strategy string) (ImportedCatalog, error) {
+	result := ImportedCatalog{Name: catalog.Name, Action: "created"}
+	catalogDir := filepath.Join(cp.archiveDir, catalog.Name)
+	indexDir := cp.dp.indexDir(catalogDir)
+
+	indexJsonPath := filepath.Join(indexDir, cp.config.GetIndexJSONName())
+	exists := utils.IsFileExists(indexJsonPath)
+	if exists {
+		result.Action = strategy
+		if strategy == ImportSkip {
+			cp.logger().Info("Catalog exists, skipping it", "catalog", catalog.Name)
+			return result, nil
+		}
+	}
+
+	var incoming map[string]interface{}
+	for _, name := range catalog.Files {
+		if !isBundlePathSafe(catalog.Name, name) {
+			return result, fmt.Errorf("unsafe path %s in the bundle", name)
+		}
+		file, ok := files[name]
+		if !ok {
+			return result, fmt.Errorf("%s is listed in the manifest but missing from the bundle", name)
+		}
+		content, err := readBundleFile(file)
+		if err != nil {
+			return result, err
+		}
+
+		rel := strings.TrimPrefix(name, catalog.Name+"/")
+		target := filepath.Join(catalogDir, filepath.FromSlash(rel))
+		if slices.Contains(bundleIndexFiles, rel) {
+			target = filepath.Join(indexDir, cp.localIndexName(rel))
+		}
+
+		if exists && strategy == ImportMerge {
+			// Merged indexes are written once all records are known
+			if rel == "index.json" {
+				if err := json.Unmarshal(content, &incoming); err != nil {
+					return result, fmt.Errorf("failed to parse %s: %w", name, err)
+				}
+				continue
+			}
+			if slices.Contains(bundleIndexFiles, rel) || utils.IsFileExists(target) {
+				continue
+			}
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return result, err
+		}
+		if err := utils.WriteFileAtomic(target, content, 0644); err != nil {
+			return result, err
+		}
+		result.Files++
+	}
+
+	if incoming != nil {
+		data, err := cp.fs.LoadExistingData(indexJsonPath)
+		if err != nil {
+			return result, err
+		}
+		maps.Copy(data, incoming)
+		if err := cp.writeCatalogIndexes(indexDir, data); err != nil {
+			return result, err
+		}
+		result.Files++
+	}
+
+	cp.logger().Info("Imported catalog", "catalog", catalog.Name, "action", result.Action, "files", result.Files)
+	return result, nil
+}
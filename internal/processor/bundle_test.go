@@ -0,0 +1,149 @@
+package processor
+
+import (
+	"archive/zip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"kbase-catalog/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// exportTestBundle exports the archive of cp to a bundle file
+func exportTestBundle(t *testing.T, cp *CatalogProcessor, images bool) (string, BundleManifest) {
+	bundlePath := filepath.Join(t.TempDir(), "bundle.zip")
+	file, err := os.Create(bundlePath)
+	assert.NoError(t, err)
+	defer file.Close()
+
+	manifest, err := cp.ExportBundle(context.Background(), file, images)
+	assert.NoError(t, err)
+	return bundlePath, manifest
+}
+
+func TestCatalogProcessor_Bundle_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	source, sourceDir := writeVerifyArchive(t)
+	assert.NoError(t, SaveCatalogMeta(filepath.Join(sourceDir, "holiday"), CatalogMeta{Title: "Summer holiday"}))
+
+	bundlePath, manifest := exportTestBundle(t, source, true)
+	assert.Equal(t, bundleVersion, manifest.Version)
+	assert.Len(t, manifest.Catalogs, 2)
+	assert.Equal(t, "holiday", manifest.Catalogs[0].Name)
+	assert.Equal(t, 2, manifest.Catalogs[0].Records)
+	assert.Contains(t, manifest.Catalogs[0].Files, "holiday/"+CatalogMetaFile)
+	assert.Contains(t, manifest.Catalogs[0].Files, "holiday/a.png")
+
+	targetDir := t.TempDir()
+	target := NewCatalogProcessor(config.GetDefaultConfig(), targetDir)
+	imported, err := target.ImportBundle(ctx, bundlePath, ImportSkip)
+	assert.NoError(t, err)
+	assert.Len(t, imported, 2)
+	assert.Equal(t, "created", imported[0].Action)
+
+	for _, name := range []string{"holiday/index.json", "shapes/index.json", "holiday/" + CatalogMetaFile} {
+		expected, err := os.ReadFile(filepath.Join(sourceDir, filepath.FromSlash(name)))
+		assert.NoError(t, err)
+		actual, err := os.ReadFile(filepath.Join(targetDir, filepath.FromSlash(name)))
+		assert.NoError(t, err, name)
+		assert.Equal(t, string(expected), string(actual), name)
+	}
+	assert.FileExists(t, filepath.Join(targetDir, "shapes", "red.png"))
+
+	report, err := target.VerifyArchive(ctx, false)
+	assert.NoError(t, err)
+	assert.Empty(t, report.Issues)
+	assert.Equal(t, 3, report.Records)
+}
+
+func TestCatalogProcessor_Bundle_WithoutImages(t *testing.T) {
+	source, _ := writeVerifyArchive(t)
+	bundlePath, manifest := exportTestBundle(t, source, false)
+	assert.False(t, manifest.Images)
+
+	reader, err := zip.OpenReader(bundlePath)
+	assert.NoError(t, err)
+	defer reader.Close()
+	var names []string
+	for _, file := range reader.File {
+		names = append(names, file.Name)
+	}
+	assert.Contains(t, names, BundleManifestFile)
+	assert.Contains(t, names, "holiday/index.json")
+	assert.NotContains(t, names, "holiday/a.png")
+}
+
+func TestCatalogProcessor_ImportBundle_Conflicts(t *testing.T) {
+	ctx := context.Background()
+	source, _ := writeVerifyArchive(t)
+	bundlePath, _ := exportTestBundle(t, source, true)
+
+	// existingArchive has a holiday catalog with a record of its own and a different a.png
+	existingArchive := func(t *testing.T) (*CatalogProcessor, string) {
+		archiveDir := t.TempDir()
+		assert.NoError(t, os.MkdirAll(filepath.Join(archiveDir, "holiday"), 0755))
+		assert.NoError(t, os.WriteFile(filepath.Join(archiveDir, "holiday", "a.png"), []byte("local"), 0644))
+		assert.NoError(t, os.WriteFile(filepath.Join(archiveDir, "holiday", "c.png"), []byte("local"), 0644))
+		index := `{"a.png": {"short_name": "Local A"}, "c.png": {"short_name": "C"}}`
+		assert.NoError(t, os.WriteFile(filepath.Join(archiveDir, "holiday", "index.json"), []byte(index), 0644))
+		return NewCatalogProcessor(config.GetDefaultConfig(), archiveDir), archiveDir
+	}
+
+	shortNames := func(t *testing.T, cp *CatalogProcessor, indexPath string) map[string]interface{} {
+		data, err := cp.fs.LoadExistingData(indexPath)
+		assert.NoError(t, err)
+		names := make(map[string]interface{}, len(data))
+		for key, record := range data {
+			names[key] = record.(map[string]interface{})["short_name"]
+		}
+		return names
+	}
+
+	t.Run("Skip", func(t *testing.T) {
+		cp, archiveDir := existingArchive(t)
+		imported, err := cp.ImportBundle(ctx, bundlePath, ImportSkip)
+		assert.NoError(t, err)
+		assert.Equal(t, ImportedCatalog{Name: "holiday", Action: ImportSkip}, imported[0])
+		assert.Equal(t, map[string]interface{}{"a.png": "Local A", "c.png": "C"}, shortNames(t, cp, filepath.Join(archiveDir, "holiday", "index.json")))
+		assert.FileExists(t, filepath.Join(archiveDir, "shapes", "index.json"))
+	})
+
+	t.Run("Replace", func(t *testing.T) {
+		cp, archiveDir := existingArchive(t)
+		_, err := cp.ImportBundle(ctx, bundlePath, ImportReplace)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"a.png": "A", "b.png": "B"}, shortNames(t, cp, filepath.Join(archiveDir, "holiday", "index.json")))
+		content, _ := os.ReadFile(filepath.Join(archiveDir, "holiday", "a.png"))
+		assert.Equal(t, "image", string(content))
+	})
+
+	t.Run("Merge", func(t *testing.T) {
+		cp, archiveDir := existingArchive(t)
+		_, err := cp.ImportBundle(ctx, bundlePath, ImportMerge)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"a.png": "A", "b.png": "B", "c.png": "C"}, shortNames(t, cp, filepath.Join(archiveDir, "holiday", "index.json")))
+		content, _ := os.ReadFile(filepath.Join(archiveDir, "holiday", "a.png"))
+		assert.Equal(t, "local", string(content))
+		assert.FileExists(t, filepath.Join(archiveDir, "holiday", "b.png"))
+		assert.FileExists(t, filepath.Join(archiveDir, "holiday", "index.md"))
+	})
+
+	t.Run("Unknown strategy", func(t *testing.T) {
+		cp, _ := existingArchive(t)
+		_, err := cp.ImportBundle(ctx, bundlePath, "overwrite")
+		assert.Error(t, err)
+	})
+}
+
+func TestIsBundlePathSafe(t *testing.T) {
+	assert.True(t, isBundlePathSafe("holiday", "holiday/index.json"))
+	assert.True(t, isBundlePathSafe("holiday", "holiday/beach/a.png"))
+	assert.False(t, isBundlePathSafe("holiday", "holiday/../shapes/a.png"))
+	assert.False(t, isBundlePathSafe("holiday", "shapes/a.png"))
+	assert.False(t, isBundlePathSafe("holiday", "/holiday/a.png"))
+	assert.False(t, isBundlePathSafe("..", "../a.png"))
+	assert.False(t, isBundlePathSafe("holiday", `holiday\..\a.png`))
+}
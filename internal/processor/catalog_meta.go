@@ -0,0 +1,83 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CatalogMetaFile names the optional file in a catalog directory holding its title and description
+const CatalogMetaFile = "_catalog.json"
+
+// CatalogMeta is the human readable title and description of a catalog
+type CatalogMeta struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// IsEmpty reports whether neither a title nor a description is set
+func (m CatalogMeta) IsEmpty() bool {
+	return m.Title == "" && m.Description == ""
+}
+
+// LoadCatalogMeta reads the metadata of the catalog directory. A missing file yields empty
+// metadata, so every catalog can be treated alike.
+func LoadCatalogMeta(catalogDir string) (CatalogMeta, error) {
+	var meta CatalogMeta
+
+	content, err := os.ReadFile(filepath.Join(catalogDir, CatalogMetaFile))
+	if os.IsNotExist(err) {
+		return meta, nil
+	}
+	if err != nil {
+		return meta, fmt.Errorf("failed to read %s: %w", CatalogMetaFile, err)
+	}
+
+	if err := json.Unmarshal(content, &meta); err != nil {
+		return CatalogMeta{}, fmt.Errorf("failed to parse %s: %w", CatalogMetaFile, err)
+	}
+
+	meta.Title = strings.TrimSpace(meta.Title)
+	meta.Description = strings.TrimSpace(meta.Description)
+	return meta, nil
+}
+
+// SaveCatalogMeta writes the metadata of the catalog directory. Empty metadata removes the file.
+func SaveCatalogMeta(catalogDir string, meta CatalogMeta) error {
+	path := filepath.Join(catalogDir, CatalogMetaFile)
+
+	meta.Title = strings.TrimSpace(meta.Title)
+	meta.Description = strings.TrimSpace(meta.Description)
+	if meta.IsEmpty() {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", CatalogMetaFile, err)
+		}
+		return nil
+	}
+
+	content, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", CatalogMetaFile, err)
+	}
+
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", CatalogMetaFile, err)
+	}
+
+	return nil
+}
+
+// applyCatalogMeta sets the title and description of a root index entry, removing stale values
+// when the metadata was cleared
+func applyCatalogMeta(catalogInfo map[string]interface{}, meta CatalogMeta) {
+	delete(catalogInfo, "title")
+	delete(catalogInfo, "description")
+	if meta.Title != "" {
+		catalogInfo["title"] = meta.Title
+	}
+	if meta.Description != "" {
+		catalogInfo["description"] = meta.Description
+	}
+}
@@ -0,0 +1,88 @@
+package processor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"kbase-catalog/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadCatalogMeta(t *testing.T) {
+	t.Run("Missing file", func(t *testing.T) {
+		meta, err := LoadCatalogMeta(t.TempDir())
+		assert.NoError(t, err)
+		assert.True(t, meta.IsEmpty())
+	})
+
+	t.Run("Title and description", func(t *testing.T) {
+		dir := t.TempDir()
+		content := `{"title": " Holiday 2024 ", "description": "Photos from the trip."}`
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, CatalogMetaFile), []byte(content), 0644))
+
+		meta, err := LoadCatalogMeta(dir)
+		assert.NoError(t, err)
+		assert.Equal(t, CatalogMeta{Title: "Holiday 2024", Description: "Photos from the trip."}, meta)
+	})
+
+	t.Run("Malformed file", func(t *testing.T) {
+		dir := t.TempDir()
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, CatalogMetaFile), []byte("{"), 0644))
+
+		meta, err := LoadCatalogMeta(dir)
+		assert.Error(t, err)
+		assert.True(t, meta.IsEmpty())
+	})
+}
+
+func TestSaveCatalogMeta(t *testing.T) {
+	dir := t.TempDir()
+
+	assert.NoError(t, SaveCatalogMeta(dir, CatalogMeta{Title: "Holiday", Description: " Beach "}))
+	meta, err := LoadCatalogMeta(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, CatalogMeta{Title: "Holiday", Description: "Beach"}, meta)
+
+	// Clearing the metadata removes the file, also when it is already gone
+	assert.NoError(t, SaveCatalogMeta(dir, CatalogMeta{Title: " "}))
+	assert.NoFileExists(t, filepath.Join(dir, CatalogMetaFile))
+	assert.NoError(t, SaveCatalogMeta(dir, CatalogMeta{}))
+}
+
+func TestCatalogProcessor_RebuildRootIndex_CatalogMeta(t *testing.T) {
+	archiveDir := t.TempDir()
+	for _, catalog := range []string{"holiday", "plain"} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(archiveDir, catalog), 0755))
+		index := `{"image.png": {"short_name": "Image", "description": "An image."}}`
+		assert.NoError(t, os.WriteFile(filepath.Join(archiveDir, catalog, "index.json"), []byte(index), 0644))
+	}
+	assert.NoError(t, SaveCatalogMeta(filepath.Join(archiveDir, "holiday"), CatalogMeta{Title: "Holiday 2024", Description: "Trip"}))
+
+	cp := NewCatalogProcessor(config.GetDefaultConfig(), archiveDir)
+	ctx := context.Background()
+	assert.NoError(t, cp.RebuildRootIndex(ctx))
+
+	rootIndex, err := cp.fs.LoadExistingData(filepath.Join(archiveDir, "index.json"))
+	assert.NoError(t, err)
+	holiday := rootIndex["holiday"].(map[string]interface{})
+	assert.Equal(t, "Holiday 2024", holiday["title"])
+	assert.Equal(t, "Trip", holiday["description"])
+	assert.NotContains(t, rootIndex["plain"], "title")
+
+	markdown, err := os.ReadFile(filepath.Join(archiveDir, "index.md"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(markdown), "- [Holiday 2024](holiday)")
+	assert.Contains(t, string(markdown), "- [plain](plain)")
+
+	// Clearing the metadata is picked up although the catalog index is unchanged
+	assert.NoError(t, SaveCatalogMeta(filepath.Join(archiveDir, "holiday"), CatalogMeta{}))
+	assert.NoError(t, cp.RebuildRootIndex(ctx))
+
+	rootIndex, err = cp.fs.LoadExistingData(filepath.Join(archiveDir, "index.json"))
+	assert.NoError(t, err)
+	assert.NotContains(t, rootIndex["holiday"], "title")
+	assert.NotContains(t, rootIndex["holiday"], "description")
+}
@@ -3,14 +3,17 @@ package processor
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"kbase-catalog/internal/config"
+	apperrors "kbase-catalog/internal/errors"
 	"kbase-catalog/internal/llm"
 	"kbase-catalog/internal/utils"
 )
@@ -23,11 +26,16 @@ type CatalogProcessor struct {
 	ip         *ImageProcessor
 	ig         *IndexGenerator
 	archiveDir string
+	log        *slog.Logger
+	progress   ProgressTracker
+	// rootIndexMutex serializes the read-modify-write updates of the root index
+	rootIndexMutex sync.Mutex
 }
 
 // NewCatalogProcessor creates a new instance of CatalogProcessor
 func NewCatalogProcessor(cfg *config.Config, archiveDir string) *CatalogProcessor {
 	fs := NewFileScanner(cfg)
+	fs.SetRoot(archiveDir)
 	ip := NewImageProcessor(cfg)
 	ig := NewIndexGenerator(cfg)
 	return &CatalogProcessor{
@@ -37,36 +45,156 @@ func NewCatalogProcessor(cfg *config.Config, archiveDir string) *CatalogProcesso
 		ip:         ip,
 		ig:         ig,
 		archiveDir: archiveDir,
+		log:        slog.Default(),
 	}
 }
 
+// logger returns the injected logger, falling back to the default one for zero value processors
+func (cp *CatalogProcessor) logger() *slog.Logger {
+	if cp.log == nil {
+		return slog.Default()
+	}
+	return cp.log
+}
+
+// SetOutputDir writes all index files below outputDir, mirroring the catalog directories of the
+// archive, instead of into the archive itself. An empty outputDir writes them in place. The
+// output directory can't be inside the archive, it would be scanned as a catalog.
+func (cp *CatalogProcessor) SetOutputDir(outputDir string) error {
+	if outputDir != "" {
+		archiveAbs, err := filepath.Abs(cp.archiveDir)
+		if err != nil {
+			return fmt.Errorf("failed to resolve archive directory: %w", err)
+		}
+		outputAbs, err := filepath.Abs(outputDir)
+		if err != nil {
+			return fmt.Errorf("failed to resolve output directory: %w", err)
+		}
+		if rel, err := filepath.Rel(archiveAbs, outputAbs); err == nil && !isOutsideRoot(rel) {
+			return fmt.Errorf("output directory %s must be outside of the archive %s", outputDir, cp.archiveDir)
+		}
+	}
+
+	cp.dp.sourceRoot = cp.archiveDir
+	cp.dp.outputRoot = outputDir
+	return nil
+}
+
+// IndexDir returns the directory holding the root index, the output directory when one is set
+func (cp *CatalogProcessor) IndexDir() string {
+	return cp.dp.indexDir(cp.archiveDir)
+}
+
+// SetProgress installs a tracker notified about the images discovered and completed by
+// ProcessCatalog and ProcessImagesCatalog
+func (cp *CatalogProcessor) SetProgress(progress ProgressTracker) {
+	cp.progress = progress
+	cp.dp.progress = progress
+}
+
+// SetLatencies installs a recorder receiving the LLM call duration of every processed image
+func (cp *CatalogProcessor) SetLatencies(latencies LatencyRecorder) {
+	cp.ip.latencies = latencies
+}
+
 // ProcessImagesCatalog processes images in the single catalog directory
 func (cp *CatalogProcessor) ProcessImagesCatalog(ctx context.Context, catalogDir string) error {
-	fmt.Printf("Starting scan in: %s\n", catalogDir)
+	if cp.progress != nil && cp.fs.IncludesDir(catalogDir) && !cp.fs.ShouldExclude(catalogDir) {
+		if images, err := cp.fs.FindImages(catalogDir, cp.config.RecursiveCatalogs); err == nil {
+			cp.progress.AddTotal(len(images))
+		}
+	}
+
+	return cp.processImagesCatalog(ctx, catalogDir)
+}
+
+// processImagesCatalog processes the images of a catalog whose images are already counted by
+// the progress tracker
+func (cp *CatalogProcessor) processImagesCatalog(ctx context.Context, catalogDir string) error {
+	cp.logger().Info("Starting scan", "path", catalogDir)
 
-	if cp.fs.ShouldExclude(catalogDir) {
+	if !cp.fs.IncludesDir(catalogDir) || cp.fs.ShouldExclude(catalogDir) {
 		return nil
 	}
 
-	fmt.Printf("\n--> Processing directory: %s\n", strings.TrimPrefix(catalogDir, catalogDir+"/"))
-
 	data, err := cp.dp.ProcessDirectory(ctx, catalogDir)
 	if err != nil {
-		return fmt.Errorf("Error processing directory %s: %v\n", catalogDir, err)
+		return fmt.Errorf("Error processing directory %s: %w\n", catalogDir, err)
 	}
 
 	err = cp.mergeWithRooIndex(catalogDir, err, data)
 	if err != nil {
-		return fmt.Errorf("Error merging with root index: %v\n", err)
+		return fmt.Errorf("Error merging with root index: %w\n", err)
 	}
 
+	// A cancelled run keeps the images described so far but is not complete
+	return ctx.Err()
+}
+
+// ReprocessImage describes a single image of a catalog again and updates the catalog and root
+// indexes. imgKey is the index key of the image: its file name, or its slash separated path
+// below the catalog in recursive mode. The old record is replaced even when it is complete.
+func (cp *CatalogProcessor) ReprocessImage(ctx context.Context, catalogDir, imgKey string) error {
+	imgPath := filepath.Join(catalogDir, filepath.FromSlash(imgKey))
+	if rel, err := filepath.Rel(catalogDir, imgPath); err != nil || rel == "." || isOutsideRoot(rel) {
+		return fmt.Errorf("image %s is outside of the catalog %s", imgKey, catalogDir)
+	}
+	if cp.dp.recordKey(catalogDir, imgPath) != imgKey {
+		return fmt.Errorf("image %s is not indexed in the catalog %s", imgKey, catalogDir)
+	}
+	if !utils.IsFileExists(imgPath) {
+		return apperrors.NewFileNotFoundError(imgPath, os.ErrNotExist)
+	}
+	if cp.fs.ShouldExclude(imgPath) {
+		return fmt.Errorf("image %s is excluded", imgKey)
+	}
+
+	indexDir := cp.dp.indexDir(catalogDir)
+	indexJsonPath := filepath.Join(indexDir, cp.config.GetIndexJSONName())
+	currentData, err := cp.fs.LoadExistingData(indexJsonPath)
+	if err != nil {
+		return fmt.Errorf("failed to load existing data: %w", err)
+	}
+
+	cp.logger().Info("Reprocessing image", "catalog", filepath.Base(catalogDir), "image", imgKey)
+
+	// Without its record the image is described like a new one, a failure leaves an error record
+	delete(currentData, imgKey)
+	_, processErr := cp.ip.ProcessImage(ctx, imgPath, imgKey, currentData)
+	if ctx.Err() != nil {
+		// The saved index still holds the previous record of the image
+		return fmt.Errorf("reprocessing of image %s interrupted: %w", imgKey, ctx.Err())
+	}
+
+	if err := os.MkdirAll(indexDir, 0755); err != nil {
+		return fmt.Errorf("failed to create index directory: %w", err)
+	}
+	if err := cp.dp.saveIndexJson(indexJsonPath, currentData); err != nil {
+		return fmt.Errorf("failed to save index.json: %w", err)
+	}
+	if err := cp.dp.generateCatalogIndexAsMarkdown(filepath.Join(indexDir, cp.config.GetIndexMDName()), currentData); err != nil {
+		return fmt.Errorf("failed to generate markdown index: %w", err)
+	}
+	if err := cp.dp.generateCatalogIndexAsJSONL(filepath.Join(indexDir, cp.config.GetIndexJSONLName()), currentData); err != nil {
+		return fmt.Errorf("failed to generate JSON Lines index: %w", err)
+	}
+	if err := cp.mergeWithRooIndex(catalogDir, nil, cp.dp.createCatalogData(currentData)); err != nil {
+		return fmt.Errorf("Error merging with root index: %w", err)
+	}
+
+	if processErr != nil {
+		return fmt.Errorf("failed to reprocess image %s: %w", imgKey, processErr)
+	}
 	return nil
 }
 
 // mergeWithRooIndex merges catalog data with the root index
 func (cp *CatalogProcessor) mergeWithRooIndex(catalogDir string, err error, data map[string]interface{}) error {
+	cp.rootIndexMutex.Lock()
+	defer cp.rootIndexMutex.Unlock()
+
 	// Load existing root index data
-	rootIndexPath := filepath.Join(cp.archiveDir, "index.json")
+	rootIndexPath := filepath.Join(cp.IndexDir(), cp.config.GetIndexJSONName())
 	var catalogData map[string]interface{}
 	if utils.IsFileExists(rootIndexPath) {
 		catalogData, err = cp.fs.LoadExistingData(rootIndexPath)
@@ -82,28 +210,61 @@ func (cp *CatalogProcessor) mergeWithRooIndex(catalogDir string, err error, data
 	catalogData[catalogName] = data
 
 	// Generate the global index with updated information
-	err = cp.ig.GenerateGlobalJsonIndex(cp.archiveDir, catalogData)
+	err = cp.ig.GenerateGlobalJsonIndex(cp.IndexDir(), catalogData)
 	if err != nil {
-		fmt.Printf("Warning: Failed to update root index: %v\n", err)
+		cp.logger().Warn("Failed to update root index", "error", err)
 	}
 
 	// Also update markdown index if needed
-	err = cp.ig.GenerateGlobalMarkdownIndex(cp.archiveDir, catalogData)
+	err = cp.ig.GenerateGlobalMarkdownIndex(cp.IndexDir(), catalogData)
 	if err != nil {
-		fmt.Printf("Warning: Failed to update root markdown index: %v\n", err)
+		cp.logger().Warn("Failed to update root markdown index", "error", err)
+	}
+
+	if err := cp.generateGlobalJSONL(catalogData); err != nil {
+		cp.logger().Warn("Failed to update root JSON Lines index", "error", err)
 	}
 	return nil
 }
 
-// RebuildRootIndex rebuilds the root index.json file that aggregates all catalogs
+// RebuildRootIndex rebuilds the root index.json file that aggregates all catalogs. Catalogs
+// whose index.json wasn't written since their root index entry was updated keep that entry
+// without being read again.
 func (cp *CatalogProcessor) RebuildRootIndex(ctx context.Context) error {
-	rootPath := cp.archiveDir
+	return cp.rebuildRootIndex(ctx, false)
+}
 
-	fmt.Printf("Rebuilding root index in: %s\n", rootPath)
+// RebuildRootIndexFull rebuilds the root index.json file reading the index of every catalog
+func (cp *CatalogProcessor) RebuildRootIndexFull(ctx context.Context) error {
+	return cp.rebuildRootIndex(ctx, true)
+}
+
+func (cp *CatalogProcessor) rebuildRootIndex(ctx context.Context, full bool) error {
+	cp.rootIndexMutex.Lock()
+	defer cp.rootIndexMutex.Unlock()
+
+	rootPath := cp.IndexDir()
+
+	cp.logger().Info("Rebuilding root index", "path", rootPath, "full", full)
 
 	catalogData := make(map[string]interface{})
 
-	err := cp.readCatalogDirectories(rootPath, catalogData)
+	// Nothing was written to the output directory yet
+	if err := os.MkdirAll(rootPath, 0755); err != nil {
+		return fmt.Errorf("failed to create index directory: %w", err)
+	}
+
+	var previous map[string]interface{}
+	rootIndexPath := filepath.Join(rootPath, cp.config.GetIndexJSONName())
+	if !full && utils.IsFileExists(rootIndexPath) {
+		var err error
+		if previous, err = cp.fs.LoadExistingData(rootIndexPath); err != nil {
+			cp.logger().Warn("Failed to load root index, reading every catalog", "path", rootIndexPath, "error", err)
+			previous = nil
+		}
+	}
+
+	err := cp.readCatalogDirectories(rootPath, catalogData, previous)
 	if err != nil {
 		return fmt.Errorf("failed to read catalog directories: %w", err)
 	}
@@ -120,18 +281,46 @@ func (cp *CatalogProcessor) RebuildRootIndex(ctx context.Context) error {
 		return fmt.Errorf("failed to generate global index: %w", err)
 	}
 
-	fmt.Printf("Root index rebuilt successfully\n")
+	if err := cp.generateGlobalJSONL(catalogData); err != nil {
+		return fmt.Errorf("failed to generate global JSON Lines index: %w", err)
+	}
+
+	cp.logger().Info("Root index rebuilt successfully")
 
 	return nil
 }
 
-// readCatalogDirectories recursively reads directories and collects catalog data
-func (cp *CatalogProcessor) readCatalogDirectories(rootPath string, catalogData map[string]interface{}) error {
+// generateGlobalJSONL writes the root index.jsonl with the records of the catalogs of the root
+// index when export_jsonl is set. Unlike the root index.json it holds every record, so each
+// catalog index is read again.
+func (cp *CatalogProcessor) generateGlobalJSONL(catalogData map[string]interface{}) error {
+	if !cp.config.ExportJSONL {
+		return nil
+	}
+
+	catalogs := make(map[string]map[string]interface{}, len(catalogData))
+	for catalogName := range catalogData {
+		indexJsonPath := filepath.Join(cp.dp.indexDir(filepath.Join(cp.archiveDir, catalogName)), cp.config.GetIndexJSONName())
+		data, err := cp.fs.LoadExistingData(indexJsonPath)
+		if err != nil {
+			cp.logger().Warn("Failed to load index.json", "path", indexJsonPath, "error", err)
+			continue
+		}
+		catalogs[catalogName] = data
+	}
+
+	return cp.ig.GenerateGlobalJSONLIndex(cp.IndexDir(), catalogs)
+}
+
+// readCatalogDirectories collects the catalog data of the directories with an index.json.
+// Entries of previous, the current root index, are reused for unchanged catalogs.
+func (cp *CatalogProcessor) readCatalogDirectories(rootPath string, catalogData map[string]interface{}, previous map[string]interface{}) error {
 	entries, err := os.ReadDir(rootPath)
 	if err != nil {
 		return err
 	}
 
+	reused := 0
 	for _, entry := range entries {
 		// Skip if it's the root path itself
 		if entry.Name() == "" {
@@ -151,67 +340,269 @@ func (cp *CatalogProcessor) readCatalogDirectories(rootPath string, catalogData
 		}
 
 		// Look for index.json in the directory to get catalog metadata
-		indexJsonPath := filepath.Join(path, "index.json")
-		if !utils.IsFileExists(indexJsonPath) {
+		indexJsonPath := filepath.Join(path, cp.config.GetIndexJSONName())
+		info, err := os.Stat(indexJsonPath)
+		if err != nil {
 			// Directory doesn't have an index.json, skip it
 			continue
 		}
 
-		// Load the existing data from index.json
-		data, err := cp.fs.LoadExistingData(indexJsonPath)
+		catalogName := entry.Name()
+		catalogInfo, ok := unchangedCatalogInfo(previous[catalogName], info.ModTime())
+		if ok {
+			reused++
+		} else {
+			// Load the existing data from index.json
+			data, err := cp.fs.LoadExistingData(indexJsonPath)
+			if err != nil {
+				cp.logger().Warn("Failed to load index.json", "path", path, "error", err)
+				continue
+			}
+			catalogInfo = newCatalogInfo(catalogName, data)
+		}
+
+		// The metadata is edited without touching index.json, so it is read for reused entries too
+		meta, err := LoadCatalogMeta(filepath.Join(cp.archiveDir, catalogName))
 		if err != nil {
-			fmt.Printf("Warning: Failed to load index.json for %s: %v\n", path, err)
-			continue
+			cp.logger().Warn("Failed to load catalog metadata", "catalog", catalogName, "error", err)
 		}
+		applyCatalogMeta(catalogInfo, meta)
 
-		// Extract catalog information from the data
-		catalogName := entry.Name()
-		if len(data) > 0 {
-			// Get the first entry to extract metadata (we don't actually use it, but it's here for completeness)
-			for _, value := range data {
-				if _, ok := value.(map[string]interface{}); ok {
-					break
-				}
-			}
+		catalogData[catalogName] = catalogInfo
+	}
+
+	if reused > 0 {
+		cp.logger().Debug("Reused root index entries of unchanged catalogs", "count", reused)
+	}
+
+	return nil
+}
+
+// unchangedCatalogInfo returns the root index entry of a catalog when its index.json was last
+// written before the entry was updated. Missing or malformed entries are never reused.
+func unchangedCatalogInfo(entry interface{}, indexModTime time.Time) (map[string]interface{}, bool) {
+	info, ok := entry.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	lastUpdateValue, _ := info["last_update"].(string)
+	lastUpdate, err := time.Parse(time.RFC3339, lastUpdateValue)
+	if err != nil {
+		return nil, false
+	}
+	if _, ok := info["image_count"].(float64); !ok {
+		return nil, false
+	}
+	return info, indexModTime.Before(lastUpdate)
+}
+
+// newCatalogInfo creates the root index entry of a catalog from its index data
+func newCatalogInfo(catalogName string, data map[string]interface{}) map[string]interface{} {
+	if len(data) == 0 {
+		// Empty directory, add basic info
+		return map[string]interface{}{
+			"name":        catalogName,
+			"image_count": 0,
+			"last_update": time.Now().Format(time.RFC3339),
+		}
+	}
 
-			catalogInfo := make(map[string]interface{})
-
-			// Add basic info
-			catalogInfo["name"] = catalogName
-			catalogInfo["image_count"] = len(data)
-
-			// Get last update time if available
-			lastUpdate := time.Now()
-			for _, value := range data {
-				if meta, ok := value.(map[string]interface{}); ok {
-					if currentDate, exists := meta["update_date"]; exists {
-						if imageUpdated, err := time.Parse(time.RFC3339, currentDate.(string)); err == nil {
-							if lastUpdate.Unix() < imageUpdated.Unix() {
-								lastUpdate = imageUpdated
-							}
-						}
+	catalogInfo := make(map[string]interface{})
+
+	// Add basic info
+	catalogInfo["name"] = catalogName
+	catalogInfo["image_count"] = len(data)
+
+	// Get last update time if available
+	lastUpdate := time.Now()
+	for _, value := range data {
+		if meta, ok := value.(map[string]interface{}); ok {
+			if currentDate, exists := meta["update_date"]; exists {
+				if imageUpdated, err := time.Parse(time.RFC3339, currentDate.(string)); err == nil {
+					if lastUpdate.Unix() < imageUpdated.Unix() {
+						lastUpdate = imageUpdated
 					}
 				}
 			}
-			catalogInfo["last_update"] = lastUpdate.Format(time.RFC3339)
+		}
+	}
+	catalogInfo["last_update"] = lastUpdate.Format(time.RFC3339)
 
-			// Add the catalog info to our main map
-			catalogData[catalogName] = catalogInfo
-		} else {
-			// Empty directory, add basic info
-			catalogInfo := map[string]interface{}{
-				"name":        catalogName,
-				"image_count": 0,
-				"last_update": time.Now().Format(time.RFC3339),
-			}
-			catalogData[catalogName] = catalogInfo
+	return catalogInfo
+}
+
+// PruneResult reports the index records removed from a single catalog
+type PruneResult struct {
+	Catalog string
+	Pruned  int
+	// Removed is set when the whole catalog directory no longer exists
+	Removed bool
+}
+
+// PruneCatalogs removes index records whose image files no longer exist from every catalog,
+// regenerates the affected catalog indexes and rebuilds the root index
+func (cp *CatalogProcessor) PruneCatalogs(ctx context.Context) ([]PruneResult, error) {
+	rootPath := cp.archiveDir
+
+	entries, err := os.ReadDir(rootPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read catalog directories: %w", err)
+	}
+
+	results := []PruneResult{}
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+
+		path := filepath.Join(rootPath, entry.Name())
+		if !entry.IsDir() || cp.fs.ShouldExclude(path) {
+			continue
+		}
+
+		pruned, err := cp.pruneCatalog(path)
+		if err != nil {
+			return results, fmt.Errorf("failed to prune catalog %s: %w", entry.Name(), err)
+		}
+		if pruned > 0 {
+			results = append(results, PruneResult{Catalog: entry.Name(), Pruned: pruned})
 		}
 	}
 
+	removed, err := cp.removedCatalogs()
+	if err != nil {
+		return results, err
+	}
+	results = append(results, removed...)
+
+	if err := cp.RebuildRootIndex(ctx); err != nil {
+		return results, err
+	}
+
+	return results, nil
+}
+
+// pruneCatalog drops the records of missing images from the catalog index and returns their count
+func (cp *CatalogProcessor) pruneCatalog(catalogDir string) (int, error) {
+	indexDir := cp.dp.indexDir(catalogDir)
+	indexJsonPath := filepath.Join(indexDir, cp.config.GetIndexJSONName())
+	if !utils.IsFileExists(indexJsonPath) {
+		return 0, nil
+	}
+
+	data, err := cp.fs.LoadExistingData(indexJsonPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load existing data: %w", err)
+	}
+
+	pruned := 0
+	for key := range data {
+		// Skip index files (they're not images)
+		if cp.fs.IsIndexFile(key) {
+			continue
+		}
+		if !utils.IsFileExists(filepath.Join(catalogDir, filepath.FromSlash(key))) {
+			delete(data, key)
+			pruned++
+		}
+	}
+
+	if pruned == 0 {
+		return 0, nil
+	}
+
+	cp.logger().Info("Pruned missing images", "path", catalogDir, "count", pruned)
+
+	return pruned, cp.writeCatalogIndexes(indexDir, data)
+}
+
+// writeCatalogIndexes replaces the index files of a catalog with data. When nothing is left to
+// index, the index files are removed like ProcessDirectory does.
+func (cp *CatalogProcessor) writeCatalogIndexes(indexDir string, data map[string]interface{}) error {
+	indexJsonPath := filepath.Join(indexDir, cp.config.GetIndexJSONName())
+	indexMdPath := filepath.Join(indexDir, cp.config.GetIndexMDName())
+	indexJsonlPath := filepath.Join(indexDir, cp.config.GetIndexJSONLName())
+
+	if len(data) == 0 {
+		os.Remove(indexJsonPath)
+		if utils.IsFileExists(indexMdPath) {
+			os.Remove(indexMdPath)
+		}
+		if utils.IsFileExists(indexJsonlPath) {
+			os.Remove(indexJsonlPath)
+		}
+		return nil
+	}
+
+	if err := cp.ig.SaveIndexJson(indexJsonPath, data); err != nil {
+		return err
+	}
+	if err := cp.ig.GenerateCatalogIndexAsMarkdown(indexMdPath, data); err != nil {
+		return fmt.Errorf("failed to generate markdown index: %w", err)
+	}
+	if err := cp.dp.generateCatalogIndexAsJSONL(indexJsonlPath, data); err != nil {
+		return fmt.Errorf("failed to generate JSON Lines index: %w", err)
+	}
+
 	return nil
 }
 
-func (cp *CatalogProcessor) TestSingleImage(ctx context.Context, imagePath string) (*llm.LLMResponse, error) {
+// removedCatalogs lists the catalogs of the root index whose directories no longer exist
+func (cp *CatalogProcessor) removedCatalogs() ([]PruneResult, error) {
+	rootIndexPath := filepath.Join(cp.IndexDir(), cp.config.GetIndexJSONName())
+	if !utils.IsFileExists(rootIndexPath) {
+		return nil, nil
+	}
+
+	catalogData, err := cp.fs.LoadExistingData(rootIndexPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load root index: %w", err)
+	}
+
+	var results []PruneResult
+	for catalogName, value := range catalogData {
+		catalogDir := filepath.Join(cp.archiveDir, catalogName)
+		if utils.IsDirectory(catalogDir) {
+			continue
+		}
+		cp.removeMirroredIndex(catalogDir)
+
+		pruned := 0
+		if info, ok := value.(map[string]interface{}); ok {
+			if count, ok := info["image_count"].(float64); ok {
+				pruned = int(count)
+			}
+		}
+		results = append(results, PruneResult{Catalog: catalogName, Pruned: pruned, Removed: true})
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Catalog < results[j].Catalog })
+
+	return results, nil
+}
+
+// removeMirroredIndex deletes the index files kept in the output directory for a catalog whose
+// source directory is gone, so rebuilding the root index doesn't bring the catalog back
+func (cp *CatalogProcessor) removeMirroredIndex(catalogDir string) {
+	if cp.dp.outputRoot == "" {
+		return
+	}
+
+	indexDir := cp.dp.indexDir(catalogDir)
+	os.Remove(filepath.Join(indexDir, cp.config.GetIndexJSONName()))
+	os.Remove(filepath.Join(indexDir, cp.config.GetIndexMDName()))
+	os.Remove(filepath.Join(indexDir, cp.config.GetIndexJSONLName()))
+	// Only succeeds once the directory is empty
+	os.Remove(indexDir)
+}
+
+// FindImages lists the supported images in dirPath, descending into subdirectories when
+// recursive is set. Excluded files and directories are skipped.
+func (cp *CatalogProcessor) FindImages(dirPath string, recursive bool) ([]string, error) {
+	return cp.fs.FindImages(dirPath, recursive)
+}
+
+// TestSingleImage sends a single image to the LLM and returns its response and the model used
+func (cp *CatalogProcessor) TestSingleImage(ctx context.Context, imagePath string) (*llm.LLMResponse, string, error) {
 	return cp.ip.TestSingleImage(ctx, imagePath)
 }
 
@@ -219,6 +610,11 @@ func (cp *CatalogProcessor) ShouldExclude(path string) bool {
 	return cp.fs.ShouldExclude(path)
 }
 
+// ReloadIgnoreFiles reads the ignore files of the archive again on their next use
+func (cp *CatalogProcessor) ReloadIgnoreFiles() {
+	cp.fs.ReloadIgnoreFiles()
+}
+
 func (cp *CatalogProcessor) ProcessCatalog(ctx context.Context) error {
 	rootPath := cp.archiveDir
 
@@ -227,22 +623,62 @@ func (cp *CatalogProcessor) ProcessCatalog(ctx context.Context) error {
 		return err
 	}
 
+	if cp.progress != nil {
+		cp.progress.AddTotal(cp.countImages(rootPath, entries))
+	}
+
+	// Up to parallel_catalogs catalogs are processed at once, merging into the root index is
+	// serialized by rootIndexMutex
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, cp.config.GetParallelCatalogs())
+
 	for _, entry := range entries {
 		catalogName := entry.Name()
 		if catalogName == "" || !entry.IsDir() {
 			continue
 		}
 
-		path := filepath.Join(rootPath, catalogName)
-
-		if err := cp.ProcessImagesCatalog(ctx, path); err != nil {
-			log.Printf("Failed to reindex catalog %s: %v", catalogName, err)
-		} else {
-			log.Printf("Successfully reindexed catalog %s", catalogName)
+		// Wait for a free slot before checking for cancellation, so a catalog cancelled while
+		// running keeps the next one from starting
+		semaphore <- struct{}{}
+		if err := ctx.Err(); err != nil {
+			wg.Wait()
+			return err
 		}
+
+		wg.Add(1)
+		go func(path string) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			if err := cp.processImagesCatalog(ctx, path); err != nil {
+				cp.logger().Error("Failed to reindex catalog", "catalog", catalogName, "error", err)
+			} else {
+				cp.logger().Info("Successfully reindexed catalog", "catalog", catalogName)
+			}
+		}(filepath.Join(rootPath, catalogName))
 	}
 
-	return nil
+	wg.Wait()
+	return ctx.Err()
+}
+
+// countImages counts the images of all catalogs which are not excluded
+func (cp *CatalogProcessor) countImages(rootPath string, entries []os.DirEntry) int {
+	total := 0
+	for _, entry := range entries {
+		path := filepath.Join(rootPath, entry.Name())
+		if !entry.IsDir() || !cp.fs.IncludesDir(path) || cp.fs.ShouldExclude(path) {
+			continue
+		}
+
+		images, err := cp.fs.FindImages(path, cp.config.RecursiveCatalogs)
+		if err != nil {
+			continue
+		}
+		total += len(images)
+	}
+	return total
 }
 
 // FixCatalogNames fix catalog names in the given path
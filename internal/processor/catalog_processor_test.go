@@ -2,9 +2,15 @@ package processor
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -169,6 +175,80 @@ func TestCatalogProcessor_RebuildRootIndex(t *testing.T) {
 	})
 }
 
+func TestCatalogProcessor_RebuildRootIndex_Incremental(t *testing.T) {
+	archiveDir := t.TempDir()
+	writeIndex := func(catalog string, images int, modTime time.Time) {
+		data := make(map[string]interface{})
+		for i := 0; i < images; i++ {
+			data[fmt.Sprintf("image%d.png", i)] = map[string]interface{}{"short_name": "Image", "description": "An image."}
+		}
+		content, err := json.Marshal(data)
+		assert.NoError(t, err)
+
+		indexPath := filepath.Join(archiveDir, catalog, "index.json")
+		assert.NoError(t, os.MkdirAll(filepath.Dir(indexPath), 0755))
+		assert.NoError(t, os.WriteFile(indexPath, content, 0644))
+		assert.NoError(t, os.Chtimes(indexPath, modTime, modTime))
+	}
+	imageCounts := func() map[string]float64 {
+		data, err := NewFileScanner(config.GetDefaultConfig()).LoadExistingData(filepath.Join(archiveDir, "index.json"))
+		assert.NoError(t, err)
+		counts := make(map[string]float64)
+		for name, info := range data {
+			counts[name] = info.(map[string]interface{})["image_count"].(float64)
+		}
+		return counts
+	}
+
+	hourAgo := time.Now().Add(-time.Hour)
+	writeIndex("stable", 1, hourAgo)
+	writeIndex("touched", 1, hourAgo)
+
+	cp := NewCatalogProcessor(config.GetDefaultConfig(), archiveDir)
+	ctx := context.Background()
+	assert.NoError(t, cp.RebuildRootIndex(ctx))
+	assert.Equal(t, map[string]float64{"stable": 1, "touched": 1}, imageCounts())
+
+	// Content changes behind an old modification time are not noticed, which shows the
+	// unchanged catalog isn't read again
+	writeIndex("stable", 3, hourAgo)
+	writeIndex("touched", 2, time.Now().Add(time.Minute))
+	// A new catalog is always read, however old its index is
+	writeIndex("fresh", 4, hourAgo)
+
+	assert.NoError(t, cp.RebuildRootIndex(ctx))
+	assert.Equal(t, map[string]float64{"stable": 1, "touched": 2, "fresh": 4}, imageCounts())
+
+	// Removed catalogs are dropped without being read
+	assert.NoError(t, os.RemoveAll(filepath.Join(archiveDir, "fresh")))
+	assert.NoError(t, cp.RebuildRootIndex(ctx))
+	assert.Equal(t, map[string]float64{"stable": 1, "touched": 2}, imageCounts())
+
+	// A full rebuild reads every catalog
+	assert.NoError(t, cp.RebuildRootIndexFull(ctx))
+	assert.Equal(t, map[string]float64{"stable": 3, "touched": 2}, imageCounts())
+}
+
+func TestUnchangedCatalogInfo(t *testing.T) {
+	lastUpdate := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	entry := map[string]interface{}{"image_count": float64(2), "last_update": lastUpdate.Format(time.RFC3339)}
+
+	info, ok := unchangedCatalogInfo(entry, lastUpdate.Add(-time.Minute))
+	assert.True(t, ok)
+	assert.Equal(t, entry, info)
+
+	_, ok = unchangedCatalogInfo(entry, lastUpdate.Add(time.Minute))
+	assert.False(t, ok)
+
+	// Missing and malformed entries are read again
+	_, ok = unchangedCatalogInfo(nil, lastUpdate.Add(-time.Minute))
+	assert.False(t, ok)
+	_, ok = unchangedCatalogInfo(map[string]interface{}{"image_count": float64(2), "last_update": "yesterday"}, lastUpdate.Add(-time.Minute))
+	assert.False(t, ok)
+	_, ok = unchangedCatalogInfo(map[string]interface{}{"last_update": lastUpdate.Format(time.RFC3339)}, lastUpdate.Add(-time.Minute))
+	assert.False(t, ok)
+}
+
 func TestFileScanner_FindImagesToProcess(t *testing.T) {
 	// Create a temporary directory structure for testing
 	tempDir := t.TempDir()
@@ -281,7 +361,7 @@ func TestProcessImagesParallel_WithContextCancellation(t *testing.T) {
 	currentData := make(map[string]interface{})
 
 	// Test the parallel processing with a cancelled context
-	newFilesFound, err := dp.processImagesParallel(ctx, imagesToProcess, currentData)
+	newFilesFound, err := dp.processImagesParallel(ctx, "", imagesToProcess, currentData, nil)
 	assert.NoError(t, err)
 	assert.False(t, newFilesFound)
 }
@@ -292,7 +372,7 @@ func TestImageProcessor_HandleProcessingError(t *testing.T) {
 
 	imgPath := "/test/image.jpg"
 
-	ip.handleProcessingError(imgPath, currentData)
+	ip.handleProcessingError(imgPath, filepath.Base(imgPath), currentData, nil)
 
 	// Check that the error was recorded correctly
 	imgKey := filepath.Base(imgPath)
@@ -361,3 +441,527 @@ func TestFixCatalogNames(t *testing.T) {
 		}
 	})
 }
+
+func TestCatalogProcessor_PruneCatalogs(t *testing.T) {
+	archiveDir := t.TempDir()
+	cfg := &config.Config{SupportedExtensions: []string{".jpg"}}
+
+	// The index references an image that was deleted from disk
+	catalogPath := filepath.Join(archiveDir, "holidays")
+	assert.NoError(t, os.MkdirAll(catalogPath, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(catalogPath, "beach.jpg"), []byte("fake image content"), 0644))
+	indexContent := `{
+  "beach.jpg": {"short_name": "Beach", "description": "Sunset over the sea"},
+  "missing.jpg": {"short_name": "Missing", "description": "Deleted from disk"}
+}`
+	assert.NoError(t, os.WriteFile(filepath.Join(catalogPath, "index.json"), []byte(indexContent), 0644))
+
+	// The root index still lists a catalog whose directory was removed
+	rootIndex := `{
+  "holidays": {"name": "holidays", "image_count": 2},
+  "gone": {"name": "gone", "image_count": 3}
+}`
+	assert.NoError(t, os.WriteFile(filepath.Join(archiveDir, "index.json"), []byte(rootIndex), 0644))
+
+	cp := NewCatalogProcessor(cfg, archiveDir)
+	results, err := cp.PruneCatalogs(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []PruneResult{
+		{Catalog: "holidays", Pruned: 1},
+		{Catalog: "gone", Pruned: 3, Removed: true},
+	}, results)
+
+	data, err := cp.fs.LoadExistingData(filepath.Join(catalogPath, "index.json"))
+	assert.NoError(t, err)
+	assert.Contains(t, data, "beach.jpg")
+	assert.NotContains(t, data, "missing.jpg")
+
+	markdown, err := os.ReadFile(filepath.Join(catalogPath, "index.md"))
+	assert.NoError(t, err)
+	assert.NotContains(t, string(markdown), "missing.jpg")
+
+	rootData, err := cp.fs.LoadExistingData(filepath.Join(archiveDir, "index.json"))
+	assert.NoError(t, err)
+	assert.Contains(t, rootData, "holidays")
+	assert.NotContains(t, rootData, "gone")
+	assert.Equal(t, float64(1), rootData["holidays"].(map[string]interface{})["image_count"])
+}
+
+func TestCatalogProcessor_OutputDir(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := map[string]interface{}{
+			"model": "test-model",
+			"choices": []interface{}{
+				map[string]interface{}{
+					"message": map[string]interface{}{
+						"content": `{"short_name": "Red square", "description": "A red square."}`,
+					},
+				},
+			},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	archiveDir := t.TempDir()
+	catalogPath := filepath.Join(archiveDir, "shapes")
+	assert.NoError(t, os.MkdirAll(catalogPath, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(catalogPath, "red.png"), createTestImage(10, 10, 255, 0, 0), 0644))
+
+	// Keep the source read-only, restoring the permissions so the temp dir can be removed
+	assert.NoError(t, os.Chmod(catalogPath, 0555))
+	assert.NoError(t, os.Chmod(archiveDir, 0555))
+	t.Cleanup(func() {
+		os.Chmod(archiveDir, 0755)
+		os.Chmod(catalogPath, 0755)
+	})
+
+	outputDir := filepath.Join(t.TempDir(), "indexes")
+
+	cfg := &config.Config{APIURL: server.URL, Model: "test-model", Timeout: 10, SupportedExtensions: []string{".png"}}
+	cp := NewCatalogProcessor(cfg, archiveDir)
+	assert.NoError(t, cp.SetOutputDir(outputDir))
+	assert.Equal(t, outputDir, cp.IndexDir())
+
+	ctx := context.Background()
+	assert.NoError(t, cp.ProcessCatalog(ctx))
+	assert.NoError(t, cp.RebuildRootIndex(ctx))
+
+	// Nothing was written into the source tree
+	var sourceFiles []string
+	filepath.WalkDir(archiveDir, func(path string, entry os.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() {
+			rel, _ := filepath.Rel(archiveDir, path)
+			sourceFiles = append(sourceFiles, rel)
+		}
+		return nil
+	})
+	assert.Equal(t, []string{filepath.Join("shapes", "red.png")}, sourceFiles)
+
+	// The index files mirror the catalog structure below the output directory
+	data, err := cp.fs.LoadExistingData(filepath.Join(outputDir, "shapes", "index.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Red square", data["red.png"].(map[string]interface{})["short_name"])
+	assert.FileExists(t, filepath.Join(outputDir, "shapes", "index.md"))
+
+	rootData, err := cp.fs.LoadExistingData(filepath.Join(outputDir, "index.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, float64(1), rootData["shapes"].(map[string]interface{})["image_count"])
+
+	// A second run reads the existing index from the output directory and skips the image
+	server.Close()
+	assert.NoError(t, cp.ProcessCatalog(ctx))
+	data, err = cp.fs.LoadExistingData(filepath.Join(outputDir, "shapes", "index.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Red square", data["red.png"].(map[string]interface{})["short_name"])
+}
+
+func TestCatalogProcessor_CustomIndexNames(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		response := map[string]interface{}{
+			"model": "test-model",
+			"choices": []interface{}{
+				map[string]interface{}{
+					"message": map[string]interface{}{
+						"content": `{"short_name": "Red square", "description": "A red square."}`,
+					},
+				},
+			},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	archiveDir := t.TempDir()
+	catalogPath := filepath.Join(archiveDir, "shapes")
+	assert.NoError(t, os.MkdirAll(catalogPath, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(catalogPath, "red.png"), createTestImage(10, 10, 255, 0, 0), 0644))
+
+	// The index files have supported extensions, they must still not be described
+	cfg := &config.Config{APIURL: server.URL, Model: "test-model", Timeout: 10, ExportJSONL: true,
+		SupportedExtensions: []string{".png", ".json", ".md", ".jsonl"}, IndexJSONName: "catalog.json", IndexMDName: "catalog.md"}
+	cp := NewCatalogProcessor(cfg, archiveDir)
+
+	ctx := context.Background()
+	assert.NoError(t, cp.ProcessCatalog(ctx))
+	assert.NoError(t, cp.RebuildRootIndex(ctx))
+	assert.NoError(t, cp.ProcessCatalog(ctx))
+	assert.Equal(t, int32(1), requests.Load())
+
+	for _, dir := range []string{archiveDir, catalogPath} {
+		assert.FileExists(t, filepath.Join(dir, "catalog.json"))
+		assert.FileExists(t, filepath.Join(dir, "catalog.md"))
+		assert.FileExists(t, filepath.Join(dir, "catalog.jsonl"))
+		assert.NoFileExists(t, filepath.Join(dir, "index.json"))
+		assert.NoFileExists(t, filepath.Join(dir, "index.md"))
+	}
+
+	data, err := cp.fs.LoadExistingData(filepath.Join(catalogPath, "catalog.json"))
+	assert.NoError(t, err)
+	assert.Len(t, data, 1)
+	assert.Contains(t, data, "red.png")
+
+	rootData, err := cp.fs.LoadExistingData(filepath.Join(archiveDir, "catalog.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, float64(1), rootData["shapes"].(map[string]interface{})["image_count"])
+}
+
+func TestCatalogProcessor_SetOutputDir(t *testing.T) {
+	archiveDir := t.TempDir()
+	cp := NewCatalogProcessor(&config.Config{}, archiveDir)
+
+	assert.Error(t, cp.SetOutputDir(archiveDir))
+	assert.Error(t, cp.SetOutputDir(filepath.Join(archiveDir, "indexes")))
+	assert.NoError(t, cp.SetOutputDir(archiveDir+"-indexes"))
+
+	assert.NoError(t, cp.SetOutputDir(""))
+	assert.Equal(t, archiveDir, cp.IndexDir())
+}
+
+func TestCatalogProcessor_RecursiveCatalogKeys(t *testing.T) {
+	var requests atomic.Int32
+	server := newCountingLLMServer(t, &requests)
+
+	archiveDir := t.TempDir()
+	catalogDir := writeNestedCatalog(t)
+	assert.NoError(t, os.Rename(catalogDir, filepath.Join(archiveDir, "shapes")))
+
+	cfg := &config.Config{
+		APIURL:              server.URL,
+		Model:               "test-model",
+		Timeout:             10,
+		SupportedExtensions: []string{".png"},
+		RecursiveCatalogs:   true,
+	}
+	cp := NewCatalogProcessor(cfg, archiveDir)
+	assert.NoError(t, cp.ProcessImagesCatalog(context.Background(), filepath.Join(archiveDir, "shapes")))
+
+	// a/x.png and b/x.png don't overwrite each other
+	data, err := cp.fs.LoadExistingData(filepath.Join(archiveDir, "shapes", "index.json"))
+	assert.NoError(t, err)
+	assert.Contains(t, data, "a/x.png")
+	assert.Contains(t, data, "b/x.png")
+
+	rootData, err := cp.fs.LoadExistingData(filepath.Join(archiveDir, "index.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, float64(3), rootData["shapes"].(map[string]interface{})["image_count"])
+
+	// Pruning resolves the relative keys against the catalog directory
+	assert.NoError(t, os.Remove(filepath.Join(archiveDir, "shapes", "a", "x.png")))
+	results, err := cp.PruneCatalogs(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []PruneResult{{Catalog: "shapes", Pruned: 1}}, results)
+
+	data, err = cp.fs.LoadExistingData(filepath.Join(archiveDir, "shapes", "index.json"))
+	assert.NoError(t, err)
+	assert.NotContains(t, data, "a/x.png")
+	assert.Contains(t, data, "b/x.png")
+}
+
+func TestCatalogProcessor_ReprocessImage(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		response := map[string]interface{}{
+			"model": "test-model",
+			"choices": []interface{}{
+				map[string]interface{}{
+					"message": map[string]interface{}{
+						"content": `{"short_name": "Red square", "description": "A red square."}`,
+					},
+				},
+			},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	archiveDir := t.TempDir()
+	catalogPath := filepath.Join(archiveDir, "shapes")
+	assert.NoError(t, os.MkdirAll(filepath.Join(catalogPath, "more"), 0755))
+	for _, name := range []string{"red.png", "blue.png", filepath.Join("more", "green.png")} {
+		assert.NoError(t, os.WriteFile(filepath.Join(catalogPath, name), createTestImage(10, 10, 255, 0, 0), 0644))
+	}
+	assert.NoError(t, os.WriteFile(filepath.Join(catalogPath, "index.json"), []byte(`{
+		"red.png": {"short_name": "Wrong", "description": "A bad description."},
+		"blue.png": {"short_name": "Blue square", "description": "A blue square."}
+	}`), 0644))
+
+	cfg := &config.Config{APIURL: server.URL, Model: "test-model", Timeout: 10, SupportedExtensions: []string{".png"}}
+	cp := NewCatalogProcessor(cfg, archiveDir)
+	ctx := context.Background()
+
+	assert.NoError(t, cp.ReprocessImage(ctx, catalogPath, "red.png"))
+	assert.Equal(t, int32(1), requests.Load())
+
+	data, err := cp.fs.LoadExistingData(filepath.Join(catalogPath, "index.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Red square", data["red.png"].(map[string]interface{})["short_name"])
+	assert.Equal(t, "Blue square", data["blue.png"].(map[string]interface{})["short_name"])
+	markdown, err := os.ReadFile(filepath.Join(catalogPath, "index.md"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(markdown), "Red square")
+	rootData, err := cp.fs.LoadExistingData(filepath.Join(archiveDir, "index.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, float64(2), rootData["shapes"].(map[string]interface{})["image_count"])
+
+	// Images outside of the catalog, missing ones and subfolder keys of flat catalogs are rejected
+	for _, imgKey := range []string{"../shapes/red.png", "..", "", "missing.png", "more/green.png"} {
+		assert.Error(t, cp.ReprocessImage(ctx, catalogPath, imgKey), imgKey)
+	}
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func TestCatalogProcessor_ReprocessImage_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The request cancels the task, like a user stopping it from the queue
+		cancel()
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	archiveDir := t.TempDir()
+	catalogPath := filepath.Join(archiveDir, "shapes")
+	assert.NoError(t, os.MkdirAll(catalogPath, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(catalogPath, "red.png"), createTestImage(10, 10, 255, 0, 0), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(catalogPath, "index.json"), []byte(`{
+		"red.png": {"short_name": "Red square", "description": "A red square."}
+	}`), 0644))
+
+	cfg := &config.Config{APIURL: server.URL, Model: "test-model", Timeout: 10, SupportedExtensions: []string{".png"}}
+	cp := NewCatalogProcessor(cfg, archiveDir)
+
+	assert.ErrorIs(t, cp.ReprocessImage(ctx, catalogPath, "red.png"), context.Canceled)
+
+	data, err := cp.fs.LoadExistingData(filepath.Join(catalogPath, "index.json"))
+	assert.NoError(t, err)
+	record := data["red.png"].(map[string]interface{})
+	assert.Equal(t, "Red square", record["short_name"])
+	assert.Equal(t, "A red square.", record["description"])
+}
+
+func TestCatalogProcessor_ProcessCatalog_Cancelled(t *testing.T) {
+	var requests atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first request cancels the run, like a user stopping a reindex
+		requests.Add(1)
+		cancel()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []interface{}{
+				map[string]interface{}{"message": map[string]interface{}{"content": `{"short_name": "Square", "description": "A square."}`}},
+			},
+		})
+	}))
+	defer server.Close()
+
+	archiveDir := t.TempDir()
+	for _, catalog := range []string{"a", "b"} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(archiveDir, catalog), 0755))
+		for i := 0; i < 3; i++ {
+			assert.NoError(t, os.WriteFile(filepath.Join(archiveDir, catalog, fmt.Sprintf("%d.png", i)), createTestImage(10, 10, 255, 0, 0), 0644))
+		}
+	}
+
+	cfg := &config.Config{APIURL: server.URL, Model: "test-model", Timeout: 10, SupportedExtensions: []string{".png"}}
+	cp := NewCatalogProcessor(cfg, archiveDir)
+
+	err := cp.ProcessCatalog(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	// No image is described after the cancellation, the catalog after it isn't even started
+	assert.Equal(t, int32(1), requests.Load())
+	assert.NoFileExists(t, filepath.Join(archiveDir, "b", "index.json"))
+}
+
+func TestCatalogProcessor_MergeWithRootIndex_Concurrent(t *testing.T) {
+	archiveDir := t.TempDir()
+	cp := NewCatalogProcessor(config.GetDefaultConfig(), archiveDir)
+
+	const catalogs = 8
+	const updates = 10
+	var wg sync.WaitGroup
+	for i := 0; i < catalogs; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			catalogDir := filepath.Join(archiveDir, fmt.Sprintf("catalog-%d", i))
+			for n := 1; n <= updates; n++ {
+				assert.NoError(t, cp.mergeWithRooIndex(catalogDir, nil, map[string]interface{}{"image_count": n}))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// Every catalog kept its last update, none was lost to another writer
+	rootIndex, err := cp.fs.LoadExistingData(filepath.Join(archiveDir, "index.json"))
+	assert.NoError(t, err)
+	assert.Len(t, rootIndex, catalogs)
+	for i := 0; i < catalogs; i++ {
+		catalog := rootIndex[fmt.Sprintf("catalog-%d", i)]
+		if assert.NotNil(t, catalog) {
+			assert.Equal(t, float64(updates), catalog.(map[string]interface{})["image_count"])
+		}
+	}
+}
+
+func TestCatalogProcessor_IgnoreFiles(t *testing.T) {
+	var requests atomic.Int32
+	server := newCountingLLMServer(t, &requests)
+
+	archiveDir := t.TempDir()
+	shapesDir := filepath.Join(archiveDir, "shapes")
+	draftsDir := filepath.Join(archiveDir, "drafts")
+	for _, dir := range []string{shapesDir, draftsDir} {
+		assert.NoError(t, os.MkdirAll(dir, 0755))
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "red.png"), createTestImage(10, 10, 255, 0, 0), 0644))
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "photo.raw"), createTestImage(10, 10, 0, 255, 0), 0644))
+	}
+	assert.NoError(t, os.WriteFile(filepath.Join(draftsDir, "draft.png"), createTestImage(10, 10, 0, 0, 255), 0644))
+
+	// The root file applies to every catalog, the catalog file only to its own directory
+	assert.NoError(t, os.WriteFile(filepath.Join(archiveDir, IgnoreFileName), []byte("# camera files\n*.raw\n"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(draftsDir, IgnoreFileName), []byte("draft.png\n"), 0644))
+
+	cfg := &config.Config{
+		APIURL:              server.URL,
+		Model:               "test-model",
+		Timeout:             10,
+		SupportedExtensions: []string{".png", ".raw"},
+	}
+	cp := NewCatalogProcessor(cfg, archiveDir)
+	assert.NoError(t, cp.ProcessCatalog(context.Background()))
+	assert.Equal(t, int32(2), requests.Load())
+
+	data, err := cp.fs.LoadExistingData(filepath.Join(shapesDir, "index.json"))
+	assert.NoError(t, err)
+	assert.Contains(t, data, "red.png")
+	assert.NotContains(t, data, "photo.raw")
+
+	data, err = cp.fs.LoadExistingData(filepath.Join(draftsDir, "index.json"))
+	assert.NoError(t, err)
+	assert.Contains(t, data, "red.png")
+	assert.NotContains(t, data, "photo.raw")
+	assert.NotContains(t, data, "draft.png")
+
+	// A changed ignore file applies once reloaded
+	assert.NoError(t, os.Remove(filepath.Join(draftsDir, IgnoreFileName)))
+	assert.True(t, cp.ShouldExclude(filepath.Join(draftsDir, "draft.png")))
+	cp.ReloadIgnoreFiles()
+	assert.False(t, cp.ShouldExclude(filepath.Join(draftsDir, "draft.png")))
+	assert.True(t, cp.ShouldExclude(filepath.Join(draftsDir, "photo.raw")))
+}
+
+func TestCatalogProcessor_IncludeFilter(t *testing.T) {
+	var requests atomic.Int32
+	server := newCountingLLMServer(t, &requests)
+
+	archiveDir := t.TempDir()
+	files := []string{"shapes/red.png", "shapes/temp/old.png", "photos/2024/beach.jpg", "photos/2024/beach.png", "drafts/sketch.png"}
+	for i, file := range files {
+		path := filepath.Join(archiveDir, filepath.FromSlash(file))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, createTestImage(10, 10, uint8(i*40), 0, 0), 0644))
+	}
+
+	// The excludes still apply to the included paths
+	cfg := &config.Config{
+		APIURL:              server.URL,
+		Model:               "test-model",
+		Timeout:             10,
+		SupportedExtensions: []string{".png", ".jpg"},
+		RecursiveCatalogs:   true,
+		IncludeFilter:       []string{"shapes", "photos/**/*.jpg"},
+		ExcludeFilter:       []string{"*/temp"},
+	}
+	cp := NewCatalogProcessor(cfg, archiveDir)
+	assert.NoError(t, cp.ProcessCatalog(context.Background()))
+	assert.Equal(t, int32(2), requests.Load())
+
+	data, err := cp.fs.LoadExistingData(filepath.Join(archiveDir, "shapes", "index.json"))
+	assert.NoError(t, err)
+	assert.Len(t, data, 1)
+	assert.Contains(t, data, "red.png")
+
+	data, err = cp.fs.LoadExistingData(filepath.Join(archiveDir, "photos", "index.json"))
+	assert.NoError(t, err)
+	assert.Len(t, data, 1)
+	assert.Contains(t, data, "2024/beach.jpg")
+
+	assert.NoFileExists(t, filepath.Join(archiveDir, "drafts", "index.json"))
+}
+
+// countingTracker counts the discovered and completed images
+type countingTracker struct {
+	total     atomic.Int64
+	completed atomic.Int64
+}
+
+func (c *countingTracker) AddTotal(n int) { c.total.Add(int64(n)) }
+func (c *countingTracker) Complete()      { c.completed.Add(1) }
+
+// TestCatalogProcessor_ParallelCatalogs is meant to run with -race: the catalogs share the
+// image processor, the progress tracker and the root index
+func TestCatalogProcessor_ParallelCatalogs(t *testing.T) {
+	var requests, inFlight, maxInFlight atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			seen := maxInFlight.Load()
+			if current <= seen || maxInFlight.CompareAndSwap(seen, current) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []interface{}{
+				map[string]interface{}{"message": map[string]interface{}{"content": `{"short_name": "Square", "description": "A square."}`}},
+			},
+		})
+	}))
+	defer server.Close()
+
+	archiveDir := t.TempDir()
+	catalogs := []string{"a", "b", "c", "d", "e", "f"}
+	for c, catalog := range catalogs {
+		assert.NoError(t, os.MkdirAll(filepath.Join(archiveDir, catalog), 0755))
+		for i := 0; i < 3; i++ {
+			image := createTestImage(10, 10, uint8(c*40), uint8(i*80), 0)
+			assert.NoError(t, os.WriteFile(filepath.Join(archiveDir, catalog, fmt.Sprintf("%d.png", i)), image, 0644))
+		}
+	}
+
+	cfg := &config.Config{APIURL: server.URL, Model: "test-model", Timeout: 10, SupportedExtensions: []string{".png"},
+		ParallelRequests: 2, ParallelCatalogs: 3}
+	cp := NewCatalogProcessor(cfg, archiveDir)
+	tracker := &countingTracker{}
+	cp.SetProgress(tracker)
+
+	assert.NoError(t, cp.ProcessCatalog(context.Background()))
+	assert.Equal(t, int32(18), requests.Load())
+	// Several catalogs ran at once, within parallel_catalogs × parallel_requests
+	assert.Greater(t, maxInFlight.Load(), int32(2))
+	assert.LessOrEqual(t, maxInFlight.Load(), int32(6))
+	assert.Equal(t, int64(18), tracker.completed.Load())
+
+	rootData, err := cp.fs.LoadExistingData(filepath.Join(archiveDir, "index.json"))
+	assert.NoError(t, err)
+	assert.Len(t, rootData, len(catalogs))
+	for _, catalog := range catalogs {
+		data, err := cp.fs.LoadExistingData(filepath.Join(archiveDir, catalog, "index.json"))
+		assert.NoError(t, err)
+		assert.Len(t, data, 3, catalog)
+		assert.Equal(t, float64(3), rootData[catalog].(map[string]interface{})["image_count"], catalog)
+	}
+}
@@ -0,0 +1,56 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// checkpoint saves the index data of a directory while its images are described, every
+// checkpoint_every processed images, so a run that crashes resumes from the last save instead
+// of describing all images again. A nil checkpoint saves nothing.
+type checkpoint struct {
+	dp            *DirectoryProcessor
+	indexJsonPath string
+	data          map[string]interface{}
+	every         int
+	mutex         sync.Mutex
+	pending       int
+}
+
+// newCheckpoint returns the checkpoint of data saved to indexJsonPath, nil when
+// checkpoint_every is off
+func (dp *DirectoryProcessor) newCheckpoint(indexJsonPath string, data map[string]interface{}) *checkpoint {
+	if dp.config == nil || dp.config.CheckpointEvery <= 0 {
+		return nil
+	}
+	return &checkpoint{dp: dp, indexJsonPath: indexJsonPath, data: data, every: dp.config.CheckpointEvery}
+}
+
+// add counts n processed images and saves the index data once checkpoint_every were processed
+// since the last save. The index is written atomically, a crash while saving keeps the
+// previous checkpoint.
+func (c *checkpoint) add(n int) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.pending += n
+	if c.pending < c.every {
+		return
+	}
+	c.pending = 0
+
+	log := c.dp.logger()
+	if err := os.MkdirAll(filepath.Dir(c.indexJsonPath), 0755); err != nil {
+		log.Warn("Failed to save checkpoint", "path", c.indexJsonPath, "error", err)
+		return
+	}
+	if err := c.dp.saveIndexJson(c.indexJsonPath, c.data); err != nil {
+		log.Warn("Failed to save checkpoint", "path", c.indexJsonPath, "error", err)
+		return
+	}
+	log.Debug("Saved checkpoint", "path", c.indexJsonPath, "records", len(c.data))
+}
@@ -4,9 +4,13 @@ import (
 	"context"
 	"fmt"
 	"kbase-catalog/internal/utils"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"kbase-catalog/internal/config"
@@ -19,6 +23,19 @@ type DirectoryProcessor struct {
 	fs     *FileScanner
 	ip     *ImageProcessor
 	ig     *IndexGenerator
+	log    *slog.Logger
+	// progress is notified about every handled image, it is optional
+	progress ProgressTracker
+	// sourceRoot and outputRoot redirect the index files of the directories below sourceRoot
+	// to the same relative paths below outputRoot. Without outputRoot indexes are written in place.
+	sourceRoot string
+	outputRoot string
+}
+
+// ProgressTracker is notified about the images discovered and completed during processing
+type ProgressTracker interface {
+	AddTotal(n int)
+	Complete()
 }
 
 // NewDirectoryProcessor creates a new instance of DirectoryProcessor
@@ -28,22 +45,76 @@ func NewDirectoryProcessor(cfg *config.Config, fs *FileScanner, ip *ImageProcess
 		fs:     fs,
 		ip:     ip,
 		ig:     ig,
+		log:    slog.Default(),
+	}
+}
+
+// logger returns the injected logger, falling back to the default one for zero value processors
+func (dp *DirectoryProcessor) logger() *slog.Logger {
+	if dp.log == nil {
+		return slog.Default()
+	}
+	return dp.log
+}
+
+// completeImage reports a handled image to the progress tracker, if any
+func (dp *DirectoryProcessor) completeImage() {
+	if dp.progress != nil {
+		dp.progress.Complete()
 	}
 }
 
+// indexDir returns the directory holding the index files of dirPath. With an output root it
+// mirrors the position of dirPath below the source root, directories outside of the source
+// root are placed directly below the output root.
+func (dp *DirectoryProcessor) indexDir(dirPath string) string {
+	if dp.outputRoot == "" {
+		return dirPath
+	}
+
+	rel, err := filepath.Rel(dp.sourceRoot, dirPath)
+	if err != nil || isOutsideRoot(rel) {
+		rel = filepath.Base(dirPath)
+	}
+	return filepath.Join(dp.outputRoot, rel)
+}
+
+// recursive reports whether catalogs include the images of their subdirectories
+func (dp *DirectoryProcessor) recursive() bool {
+	return dp.config != nil && dp.config.RecursiveCatalogs
+}
+
+// recordKey returns the index key of an image: its file name, or in recursive mode its slash
+// separated path relative to the catalog directory, so equal names in subdirectories don't collide
+func (dp *DirectoryProcessor) recordKey(dirPath, imgPath string) string {
+	if dp.recursive() {
+		if rel, err := filepath.Rel(dirPath, imgPath); err == nil && !isOutsideRoot(rel) {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return filepath.Base(imgPath)
+}
+
+// isOutsideRoot reports whether a path relative to a root leaves the root
+func isOutsideRoot(rel string) bool {
+	return rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
 // ProcessDirectory processes all images in a directory
 func (dp *DirectoryProcessor) ProcessDirectory(ctx context.Context, dirPath string) (map[string]interface{}, error) {
-	fmt.Printf("Processing directory: %s\n", dirPath)
+	dp.logger().Debug("Processing directory", "path", dirPath)
 
-	indexJsonPath := filepath.Join(dirPath, "index.json")
-	indexMdPath := filepath.Join(dirPath, "index.md")
+	indexDir := dp.indexDir(dirPath)
+	indexJsonPath := filepath.Join(indexDir, dp.config.GetIndexJSONName())
+	indexMdPath := filepath.Join(indexDir, dp.config.GetIndexMDName())
+	indexJsonlPath := filepath.Join(indexDir, dp.config.GetIndexJSONLName())
 
 	currentData, err := dp.fs.LoadExistingData(indexJsonPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load existing data: %w", err)
 	}
 
-	imagesToProcess, err := dp.fs.FindImagesToProcess(dirPath)
+	imagesToProcess, err := dp.fs.FindImages(dirPath, dp.recursive())
 	if err != nil {
 		return nil, fmt.Errorf("failed to find images: %w", err)
 	}
@@ -55,18 +126,17 @@ func (dp *DirectoryProcessor) ProcessDirectory(ctx context.Context, dirPath stri
 	// Find all files that exist in the directory
 	existingFiles := make(map[string]bool)
 	for _, imgPath := range imagesToProcess {
-		if imgPath == "index.json" || imgPath == "index.md" {
+		if dp.fs.IsIndexFile(imgPath) {
 			continue
 		}
-		baseName := filepath.Base(imgPath)
-		existingFiles[baseName] = true
+		existingFiles[dp.recordKey(dirPath, imgPath)] = true
 	}
 
 	// Remove entries from currentData for files that no longer exist
 	hasChanges := false
 	for key := range currentData {
 		// Skip index files (they're not images)
-		if key == "index.json" || key == "index.md" {
+		if dp.fs.IsIndexFile(key) {
 			continue
 		}
 
@@ -77,31 +147,52 @@ func (dp *DirectoryProcessor) ProcessDirectory(ctx context.Context, dirPath stri
 		}
 	}
 
+	// Images identical to a described one reuse its description instead of calling the LLM
+	imagesToProcess, waiting, copied := dp.copyDuplicates(dirPath, imagesToProcess, currentData)
+	hasChanges = hasChanges || copied
+
 	// Process new or updated images
 	if len(imagesToProcess) != 0 {
-		if dp.config.ParallelRequests > 1 {
-			hasChanges, err = dp.processImagesParallel(ctx, imagesToProcess, currentData)
+		ckpt := dp.newCheckpoint(indexJsonPath, currentData)
+		if dp.config.GetBatchSize() > 1 {
+			processed, err := dp.processImagesBatched(ctx, dirPath, imagesToProcess, currentData, ckpt)
+			if err != nil {
+				return nil, fmt.Errorf("failed to process images in batches: %w", err)
+			}
+			hasChanges = hasChanges || processed
+		} else if dp.config.ParallelRequests > 1 {
+			hasChanges, err = dp.processImagesParallel(ctx, dirPath, imagesToProcess, currentData, ckpt)
 			if err != nil {
 				return nil, fmt.Errorf("failed to process images in parallel: %w", err)
 			}
 		} else {
 			for _, imgPath := range imagesToProcess {
-				if imgPath == "index.json" || imgPath == "index.md" {
+				// Stop describing images once cancelled, the ones done so far are still saved
+				if ctx.Err() != nil {
+					break
+				}
+				if dp.fs.IsIndexFile(imgPath) {
 					continue
 				}
 
-				processed, err := dp.ip.ProcessSingleImage(ctx, imgPath, currentData)
+				processed, err := dp.ip.ProcessImage(ctx, imgPath, dp.recordKey(dirPath, imgPath), currentData)
+				dp.completeImage()
 				if err != nil {
-					fmt.Printf("Error processing image %s: %v\n", imgPath, err)
+					dp.logger().Error("Error processing image", "path", imgPath, "error", err)
 					continue
 				}
 				if processed {
 					hasChanges = true
+					ckpt.add(1)
 				}
 			}
 		}
 	}
 
+	if len(waiting) > 0 && dp.processWaitingDuplicates(ctx, waiting, currentData) {
+		hasChanges = true
+	}
+
 	// Save index files only if we have data to save or if there was a change
 	if hasChanges || !utils.IsFileExists(indexJsonPath) {
 		// If no images exist in directory, remove the index files
@@ -113,10 +204,17 @@ func (dp *DirectoryProcessor) ProcessDirectory(ctx context.Context, dirPath stri
 			if utils.IsFileExists(indexMdPath) {
 				os.Remove(indexMdPath)
 			}
+			if utils.IsFileExists(indexJsonlPath) {
+				os.Remove(indexJsonlPath)
+			}
 			return nil, nil
 		}
 	}
 
+	if err := os.MkdirAll(indexDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create index directory: %w", err)
+	}
+
 	if err := dp.saveIndexJson(indexJsonPath, currentData); err != nil {
 		return nil, fmt.Errorf("failed to save index.json: %w", err)
 	}
@@ -127,6 +225,9 @@ func (dp *DirectoryProcessor) ProcessDirectory(ctx context.Context, dirPath stri
 		if err != nil {
 			return nil, fmt.Errorf("failed to generate markdown index: %w", err)
 		}
+		if err := dp.generateCatalogIndexAsJSONL(indexJsonlPath, currentData); err != nil {
+			return nil, fmt.Errorf("failed to generate JSON Lines index: %w", err)
+		}
 	}
 
 	catalogData := dp.createCatalogData(currentData)
@@ -158,8 +259,9 @@ func (dp *DirectoryProcessor) createCatalogData(currentData map[string]interface
 	return catalogData
 }
 
-// processImagesParallel processes images in parallel
-func (dp *DirectoryProcessor) processImagesParallel(ctx context.Context, imagesToProcess []string, currentData map[string]interface{}) (bool, error) {
+// processImagesParallel processes the images of the directory dirPath in parallel, counting the
+// processed ones towards ckpt
+func (dp *DirectoryProcessor) processImagesParallel(ctx context.Context, dirPath string, imagesToProcess []string, currentData map[string]interface{}, ckpt *checkpoint) (bool, error) {
 	if len(imagesToProcess) == 0 {
 		return false, nil
 	}
@@ -169,12 +271,14 @@ func (dp *DirectoryProcessor) processImagesParallel(ctx context.Context, imagesT
 		return false, fmt.Errorf("invalid ParallelRequests configuration: %d", dp.config.ParallelRequests)
 	}
 
-	fmt.Printf("Processing %d images in parallel (max %d concurrent requests)\n", len(imagesToProcess), dp.config.ParallelRequests)
+	dp.logger().Info("Processing images in parallel", "images", len(imagesToProcess), "parallel_requests", dp.config.ParallelRequests)
 
 	var filteredImages []string
 	for _, imgPath := range imagesToProcess {
-		if dp.needsProcessing(currentData, imgPath) {
+		if dp.recordNeedsProcessing(currentData, dp.recordKey(dirPath, imgPath), imgPath) {
 			filteredImages = append(filteredImages, imgPath)
+		} else {
+			dp.completeImage()
 		}
 	}
 
@@ -196,6 +300,7 @@ func (dp *DirectoryProcessor) processImagesParallel(ctx context.Context, imagesT
 
 		go func(path string) {
 			defer wg.Done()
+			defer dp.completeImage()
 
 			select {
 			case <-ctx.Done():
@@ -211,7 +316,10 @@ func (dp *DirectoryProcessor) processImagesParallel(ctx context.Context, imagesT
 				}()
 			}
 
-			processed, err := dp.ip.ProcessSingleImage(ctx, path, currentData)
+			processed, err := dp.processImageShared(ctx, path, dp.recordKey(dirPath, path), currentData)
+			if processed {
+				ckpt.add(1)
+			}
 			if err != nil {
 				errors <- fmt.Errorf("error processing %s: %w", path, err)
 				return
@@ -234,32 +342,128 @@ func (dp *DirectoryProcessor) processImagesParallel(ctx context.Context, imagesT
 	}
 
 	for err := range errors {
-		fmt.Printf("Parallel processing error: %v\n", err)
+		dp.logger().Error("Parallel processing error", "error", err)
 		newFilesFound = true
 	}
 
 	return newFilesFound, nil
 }
 
-// needsProcessing checks if an image needs processing
-func (dp *DirectoryProcessor) needsProcessing(currentData map[string]interface{}, imgPath string) bool {
+// processImageShared processes an image on a copy of its record, so workers running in parallel
+// only touch the shared index data while holding the lock
+func (dp *DirectoryProcessor) processImageShared(ctx context.Context, imgPath, imgKey string, currentData map[string]interface{}) (bool, error) {
+	imageData := make(map[string]interface{}, 1)
 	dp.mutex.RLock()
-	defer dp.mutex.RUnlock()
+	if record, exists := currentData[imgKey]; exists {
+		imageData[imgKey] = record
+	}
+	dp.mutex.RUnlock()
 
-	imgKey := filepath.Base(imgPath)
-	record, exists := currentData[imgKey]
+	processed, err := dp.ip.ProcessImage(ctx, imgPath, imgKey, imageData)
 
-	if !exists {
-		return true
+	if record, exists := imageData[imgKey]; exists {
+		dp.mutex.Lock()
+		currentData[imgKey] = record
+		dp.mutex.Unlock()
 	}
+	return processed, err
+}
 
-	if recordMap, ok := record.(map[string]interface{}); ok {
-		if shortName, ok := recordMap["short_name"].(string); ok && shortName == "error_processing" {
-			return true
+// processImagesBatched describes the images of the directory dirPath in batches of batch_size
+// images per LLM request, running up to parallel_requests batches at once, counting the
+// processed batches towards ckpt
+func (dp *DirectoryProcessor) processImagesBatched(ctx context.Context, dirPath string, imagesToProcess []string, currentData map[string]interface{}, ckpt *checkpoint) (bool, error) {
+	batchSize := dp.config.GetBatchSize()
+
+	var filteredImages []string
+	for _, imgPath := range imagesToProcess {
+		if dp.recordNeedsProcessing(currentData, dp.recordKey(dirPath, imgPath), imgPath) {
+			filteredImages = append(filteredImages, imgPath)
+		} else {
+			dp.completeImage()
 		}
 	}
 
-	return false
+	if len(filteredImages) == 0 {
+		return false, nil
+	}
+
+	dp.logger().Info("Processing images in batches", "images", len(filteredImages), "batch_size", batchSize, "parallel_requests", dp.config.ParallelRequests)
+
+	var wg sync.WaitGroup
+	var processedAny atomic.Bool
+	semaphore := make(chan struct{}, max(dp.config.ParallelRequests, 1))
+
+	for batch := range slices.Chunk(filteredImages, batchSize) {
+		keys := make([]string, len(batch))
+		for i, imgPath := range batch {
+			keys[i] = dp.recordKey(dirPath, imgPath)
+		}
+
+		select {
+		case <-ctx.Done():
+			for range batch {
+				dp.completeImage()
+			}
+			continue
+		case semaphore <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			processed, err := dp.processBatchShared(ctx, batch, keys, currentData)
+			for range batch {
+				dp.completeImage()
+			}
+			if processed {
+				processedAny.Store(true)
+				ckpt.add(len(batch))
+			}
+			if err != nil {
+				dp.logger().Error("Batch processing error", "error", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return processedAny.Load(), nil
+}
+
+// processBatchShared processes a batch of images on copies of their records, like processImageShared
+func (dp *DirectoryProcessor) processBatchShared(ctx context.Context, imgPaths, imgKeys []string, currentData map[string]interface{}) (bool, error) {
+	batchData := make(map[string]interface{}, len(imgKeys))
+	dp.mutex.RLock()
+	for _, imgKey := range imgKeys {
+		if record, exists := currentData[imgKey]; exists {
+			batchData[imgKey] = record
+		}
+	}
+	dp.mutex.RUnlock()
+
+	processed, err := dp.ip.ProcessBatch(ctx, imgPaths, imgKeys, batchData)
+
+	dp.mutex.Lock()
+	for imgKey, record := range batchData {
+		currentData[imgKey] = record
+	}
+	dp.mutex.Unlock()
+	return processed, err
+}
+
+// needsProcessing checks if an image needs processing
+func (dp *DirectoryProcessor) needsProcessing(currentData map[string]interface{}, imgPath string) bool {
+	return dp.recordNeedsProcessing(currentData, filepath.Base(imgPath), imgPath)
+}
+
+// recordNeedsProcessing checks if the image at imgPath, recorded under imgKey, needs processing
+func (dp *DirectoryProcessor) recordNeedsProcessing(currentData map[string]interface{}, imgKey, imgPath string) bool {
+	dp.mutex.RLock()
+	defer dp.mutex.RUnlock()
+
+	return recordNeedsProcessing(currentData, imgKey, imgPath, dp.config)
 }
 
 // saveIndexJson saves the index data to JSON file
@@ -277,3 +481,15 @@ func (dp *DirectoryProcessor) generateCatalogIndexAsMarkdown(mdPath string, data
 
 	return dp.ig.GenerateCatalogIndexAsMarkdown(mdPath, data)
 }
+
+// generateCatalogIndexAsJSONL generates the JSON Lines index from data when export_jsonl is set
+func (dp *DirectoryProcessor) generateCatalogIndexAsJSONL(jsonlPath string, data map[string]interface{}) error {
+	if !dp.config.ExportJSONL {
+		return nil
+	}
+
+	dp.mutex.Lock()
+	defer dp.mutex.Unlock()
+
+	return dp.ig.GenerateCatalogIndexAsJSONL(jsonlPath, data)
+}
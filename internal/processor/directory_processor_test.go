@@ -3,8 +3,13 @@ package processor
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
 
 	"kbase-catalog/internal/config"
@@ -163,7 +168,7 @@ func TestProcessImagesParallel_InvalidConfig(t *testing.T) {
 	currentData := map[string]interface{}{}
 
 	ctx := context.Background()
-	result, err := dp.processImagesParallel(ctx, imagesToProcess, currentData)
+	result, err := dp.processImagesParallel(ctx, "", imagesToProcess, currentData, nil)
 
 	assert.Error(t, err)
 	assert.False(t, result)
@@ -183,7 +188,7 @@ func TestProcessImagesParallel_NoImages(t *testing.T) {
 	currentData := map[string]interface{}{}
 
 	ctx := context.Background()
-	result, err := dp.processImagesParallel(ctx, imagesToProcess, currentData)
+	result, err := dp.processImagesParallel(ctx, "", imagesToProcess, currentData, nil)
 
 	assert.NoError(t, err)
 	assert.False(t, result)
@@ -193,3 +198,350 @@ func TestProcessImagesParallel_NoImages(t *testing.T) {
 func TestProcessImagesParallel_ContextCancelled(t *testing.T) {
 	t.Skip("Skipping context cancellation test as it's complex to simulate properly")
 }
+
+// writeNestedCatalog creates a catalog with an image at the top and two equally named images in subfolders
+func writeNestedCatalog(t *testing.T) string {
+	catalogDir := t.TempDir()
+	// The images differ slightly, identical ones would share a single description
+	for i, name := range []string{"top.png", filepath.Join("a", "x.png"), filepath.Join("b", "x.png")} {
+		path := filepath.Join(catalogDir, name)
+		setupTestDir(t, filepath.Dir(path))
+		assert.NoError(t, os.WriteFile(path, createTestImage(4, 4, uint8(i), 0, 255), 0644))
+	}
+	return catalogDir
+}
+
+// newCountingLLMServer answers every request with the same description and counts the requests
+func newCountingLLMServer(t *testing.T, requests *atomic.Int32) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		response := map[string]interface{}{
+			"model": "test-model",
+			"choices": []interface{}{
+				map[string]interface{}{
+					"message": map[string]interface{}{
+						"content": `{"short_name": "Blue square", "description": "A blue square."}`,
+					},
+				},
+			},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestProcessDirectory_Flat(t *testing.T) {
+	var requests atomic.Int32
+	server := newCountingLLMServer(t, &requests)
+	catalogDir := writeNestedCatalog(t)
+
+	cfg := &config.Config{APIURL: server.URL, Model: "test-model", Timeout: 10, SupportedExtensions: []string{".png"}}
+	dp := NewDirectoryProcessor(cfg, NewFileScanner(cfg), NewImageProcessor(cfg), NewIndexGenerator(cfg))
+
+	_, err := dp.ProcessDirectory(context.Background(), catalogDir)
+	assert.NoError(t, err)
+
+	// Subfolders are left to be processed as catalogs of their own
+	data, err := dp.fs.LoadExistingData(filepath.Join(catalogDir, "index.json"))
+	assert.NoError(t, err)
+	assert.Len(t, data, 1)
+	assert.Contains(t, data, "top.png")
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func TestProcessDirectory_Recursive(t *testing.T) {
+	for _, parallel := range []int{1, 3} {
+		t.Run(fmt.Sprintf("parallel_requests=%d", parallel), func(t *testing.T) {
+			var requests atomic.Int32
+			server := newCountingLLMServer(t, &requests)
+			catalogDir := writeNestedCatalog(t)
+
+			cfg := &config.Config{
+				APIURL:              server.URL,
+				Model:               "test-model",
+				Timeout:             10,
+				SupportedExtensions: []string{".png"},
+				ParallelRequests:    parallel,
+				RecursiveCatalogs:   true,
+			}
+			dp := NewDirectoryProcessor(cfg, NewFileScanner(cfg), NewImageProcessor(cfg), NewIndexGenerator(cfg))
+			ctx := context.Background()
+
+			_, err := dp.ProcessDirectory(ctx, catalogDir)
+			assert.NoError(t, err)
+
+			// Both x.png get a record of their own, keyed by the relative path
+			indexPath := filepath.Join(catalogDir, "index.json")
+			data, err := dp.fs.LoadExistingData(indexPath)
+			assert.NoError(t, err)
+			assert.Len(t, data, 3)
+			for _, key := range []string{"top.png", "a/x.png", "b/x.png"} {
+				if assert.Contains(t, data, key) {
+					assert.Equal(t, "Blue square", data[key].(map[string]interface{})["short_name"])
+				}
+			}
+			assert.Equal(t, int32(3), requests.Load())
+			assert.NoFileExists(t, filepath.Join(catalogDir, "a", "index.json"))
+
+			// The next run finds every image by its relative key and sends nothing
+			_, err = dp.ProcessDirectory(ctx, catalogDir)
+			assert.NoError(t, err)
+			assert.Equal(t, int32(3), requests.Load())
+
+			// Deleting one of the duplicates only drops its own record
+			assert.NoError(t, os.Remove(filepath.Join(catalogDir, "b", "x.png")))
+			_, err = dp.ProcessDirectory(ctx, catalogDir)
+			assert.NoError(t, err)
+
+			data, err = dp.fs.LoadExistingData(indexPath)
+			assert.NoError(t, err)
+			assert.Contains(t, data, "a/x.png")
+			assert.NotContains(t, data, "b/x.png")
+		})
+	}
+}
+
+// newBatchLLMServer answers a request with one numbered result per image, or with 400 for
+// requests with several images when batches are not supported. It counts the images of every request.
+func newBatchLLMServer(t *testing.T, supportsBatches bool, mutex *sync.Mutex, requestSizes *[]int) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Messages []struct {
+				Content json.RawMessage `json:"content"`
+			} `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+
+		var parts []map[string]interface{}
+		json.Unmarshal(payload.Messages[1].Content, &parts)
+		images := len(parts) - 1
+
+		mutex.Lock()
+		*requestSizes = append(*requestSizes, images)
+		mutex.Unlock()
+
+		if images > 1 && !supportsBatches {
+			http.Error(w, "only one image per request is supported", http.StatusBadRequest)
+			return
+		}
+
+		var results []map[string]string
+		for i := 1; i <= images; i++ {
+			results = append(results, map[string]string{"short_name": fmt.Sprintf("Image %d", i), "description": "A blue square."})
+		}
+		var content []byte
+		if images == 1 {
+			content, _ = json.Marshal(results[0])
+		} else {
+			content, _ = json.Marshal(results)
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"model":   "test-model",
+			"choices": []interface{}{map[string]interface{}{"message": map[string]interface{}{"content": string(content)}}},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestProcessDirectory_Batches(t *testing.T) {
+	catalogDir := t.TempDir()
+	names := []string{"a.png", "b.png", "c.png", "d.png", "e.png"}
+	for i, name := range names {
+		assert.NoError(t, os.WriteFile(filepath.Join(catalogDir, name), createTestImage(4, 4, uint8(i), 0, 255), 0644))
+	}
+
+	t.Run("Results are mapped back to the file names", func(t *testing.T) {
+		var mutex sync.Mutex
+		var requestSizes []int
+		server := newBatchLLMServer(t, true, &mutex, &requestSizes)
+
+		cfg := &config.Config{APIURL: server.URL, Model: "test-model", Timeout: 10, SupportedExtensions: []string{".png"}, ParallelRequests: 1, BatchSize: 2}
+		dp := NewDirectoryProcessor(cfg, NewFileScanner(cfg), NewImageProcessor(cfg), NewIndexGenerator(cfg))
+
+		_, err := dp.ProcessDirectory(context.Background(), catalogDir)
+		assert.NoError(t, err)
+
+		data, err := dp.fs.LoadExistingData(filepath.Join(catalogDir, "index.json"))
+		assert.NoError(t, err)
+		assert.Len(t, data, 5)
+		// Images are found in name order and every batch numbers its results from 1
+		expected := map[string]string{"a.png": "Image 1", "b.png": "Image 2", "c.png": "Image 1", "d.png": "Image 2", "e.png": "Image 1"}
+		for name, shortName := range expected {
+			if assert.Contains(t, data, name) {
+				record := data[name].(map[string]interface{})
+				assert.Equal(t, shortName, record["short_name"])
+				assert.Equal(t, name, record["original_name"])
+			}
+		}
+		assert.Equal(t, []int{2, 2, 1}, requestSizes)
+
+		assert.NoError(t, os.Remove(filepath.Join(catalogDir, "index.json")))
+		assert.NoError(t, os.Remove(filepath.Join(catalogDir, "index.md")))
+	})
+
+	t.Run("Falls back to one image per request", func(t *testing.T) {
+		var mutex sync.Mutex
+		var requestSizes []int
+		server := newBatchLLMServer(t, false, &mutex, &requestSizes)
+
+		cfg := &config.Config{APIURL: server.URL, Model: "test-model", Timeout: 10, SupportedExtensions: []string{".png"}, ParallelRequests: 1, BatchSize: 3}
+		dp := NewDirectoryProcessor(cfg, NewFileScanner(cfg), NewImageProcessor(cfg), NewIndexGenerator(cfg))
+
+		_, err := dp.ProcessDirectory(context.Background(), catalogDir)
+		assert.NoError(t, err)
+
+		data, err := dp.fs.LoadExistingData(filepath.Join(catalogDir, "index.json"))
+		assert.NoError(t, err)
+		for _, name := range names {
+			if assert.Contains(t, data, name) {
+				assert.Equal(t, "Image 1", data[name].(map[string]interface{})["short_name"])
+			}
+		}
+		// The rejected batch disables batching, the second batch is sent image by image right away
+		assert.Equal(t, []int{3, 1, 1, 1, 1, 1}, requestSizes)
+	})
+}
+
+func TestProcessDirectory_Duplicates(t *testing.T) {
+	modes := map[string]*config.Config{
+		"sequential": {ParallelRequests: 1},
+		"parallel":   {ParallelRequests: 3},
+		"batches":    {ParallelRequests: 1, BatchSize: 2},
+	}
+	for mode, cfg := range modes {
+		t.Run(mode, func(t *testing.T) {
+			var requests atomic.Int32
+			server := newCountingLLMServer(t, &requests)
+			cfg.APIURL = server.URL
+			cfg.Model = "test-model"
+			cfg.Timeout = 10
+			cfg.SupportedExtensions = []string{".png"}
+
+			catalogDir := t.TempDir()
+			for _, name := range []string{"a.png", "copy-of-a.png"} {
+				assert.NoError(t, os.WriteFile(filepath.Join(catalogDir, name), createTestImage(4, 4, 0, 0, 255), 0644))
+			}
+			dp := NewDirectoryProcessor(cfg, NewFileScanner(cfg), NewImageProcessor(cfg), NewIndexGenerator(cfg))
+			ctx := context.Background()
+
+			_, err := dp.ProcessDirectory(ctx, catalogDir)
+			assert.NoError(t, err)
+			assert.Equal(t, int32(1), requests.Load(), "identical images are described once")
+
+			indexPath := filepath.Join(catalogDir, "index.json")
+			data, err := dp.fs.LoadExistingData(indexPath)
+			assert.NoError(t, err)
+			original := data["a.png"].(map[string]interface{})
+			duplicate := data["copy-of-a.png"].(map[string]interface{})
+			assert.NotEmpty(t, original["content_hash"])
+			assert.NotContains(t, original, "duplicate_of")
+			assert.Equal(t, "a.png", duplicate["duplicate_of"])
+			assert.Equal(t, original["content_hash"], duplicate["content_hash"])
+			assert.Equal(t, "Blue square", duplicate["short_name"])
+			assert.Equal(t, "copy-of-a.png", duplicate["original_name"])
+
+			// A copy added later reuses the stored description as well
+			assert.NoError(t, os.WriteFile(filepath.Join(catalogDir, "another-copy.png"), createTestImage(4, 4, 0, 0, 255), 0644))
+			assert.NoError(t, os.WriteFile(filepath.Join(catalogDir, "other.png"), createTestImage(4, 4, 255, 0, 0), 0644))
+			_, err = dp.ProcessDirectory(ctx, catalogDir)
+			assert.NoError(t, err)
+			assert.Equal(t, int32(2), requests.Load(), "only the different image is described")
+
+			data, err = dp.fs.LoadExistingData(indexPath)
+			assert.NoError(t, err)
+			assert.Equal(t, "a.png", data["another-copy.png"].(map[string]interface{})["duplicate_of"])
+			assert.NotContains(t, data["other.png"], "duplicate_of")
+		})
+	}
+}
+
+func TestProcessDirectory_Checkpoint(t *testing.T) {
+	catalogDir := t.TempDir()
+	indexPath := filepath.Join(catalogDir, "index.json")
+	for i := 0; i < 5; i++ {
+		name := filepath.Join(catalogDir, fmt.Sprintf("image%d.png", i))
+		assert.NoError(t, os.WriteFile(name, createTestImage(10, 10, uint8(i*40), 0, 0), 0644))
+	}
+
+	// The third request finds the checkpoint of the first two images, a crash at this point
+	// would leave it as the index
+	var requests atomic.Int32
+	var checkpoint []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 3 {
+			checkpoint, _ = os.ReadFile(indexPath)
+		}
+		response := map[string]interface{}{
+			"model": "test-model",
+			"choices": []interface{}{
+				map[string]interface{}{
+					"message": map[string]interface{}{
+						"content": `{"short_name": "Red square", "description": "A red square."}`,
+					},
+				},
+			},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{APIURL: server.URL, Model: "test-model", Timeout: 10, ParallelRequests: 1,
+		SupportedExtensions: []string{".png"}, CheckpointEvery: 2}
+	dp := NewDirectoryProcessor(cfg, NewFileScanner(cfg), NewImageProcessor(cfg), NewIndexGenerator(cfg))
+
+	_, err := dp.ProcessDirectory(context.Background(), catalogDir)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(5), requests.Load())
+
+	var saved map[string]interface{}
+	assert.NoError(t, json.Unmarshal(checkpoint, &saved))
+	assert.Len(t, saved, 2)
+
+	// Resuming from the checkpoint only describes the three remaining images
+	assert.NoError(t, os.WriteFile(indexPath, checkpoint, 0644))
+	_, err = dp.ProcessDirectory(context.Background(), catalogDir)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(8), requests.Load())
+
+	data, err := dp.fs.LoadExistingData(indexPath)
+	assert.NoError(t, err)
+	assert.Len(t, data, 5)
+}
+
+func TestProcessDirectory_CheckpointOff(t *testing.T) {
+	catalogDir := t.TempDir()
+	indexPath := filepath.Join(catalogDir, "index.json")
+	for i := 0; i < 3; i++ {
+		name := filepath.Join(catalogDir, fmt.Sprintf("image%d.png", i))
+		assert.NoError(t, os.WriteFile(name, createTestImage(10, 10, uint8(i*40), 0, 0), 0644))
+	}
+
+	// Without checkpoint_every the index is only written once the directory is done
+	var requests atomic.Int32
+	var indexSeen atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if _, err := os.Stat(indexPath); err == nil {
+			indexSeen.Store(true)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"model":   "test-model",
+			"choices": []interface{}{map[string]interface{}{"message": map[string]interface{}{"content": `{"short_name": "Red", "description": "Red."}`}}},
+		})
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{APIURL: server.URL, Model: "test-model", Timeout: 10, ParallelRequests: 1, SupportedExtensions: []string{".png"}}
+	dp := NewDirectoryProcessor(cfg, NewFileScanner(cfg), NewImageProcessor(cfg), NewIndexGenerator(cfg))
+
+	_, err := dp.ProcessDirectory(context.Background(), catalogDir)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), requests.Load())
+	assert.False(t, indexSeen.Load())
+	assert.FileExists(t, indexPath)
+}
@@ -0,0 +1,157 @@
+package processor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// duplicateImage is an image waiting for the description of an identical image of the same run
+type duplicateImage struct {
+	path string
+	key  string
+	hash string
+}
+
+// fileHash returns the hex encoded SHA-256 of the file content
+func fileHash(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// isDescribed reports whether a record holds a description, as opposed to a failure or skip marker
+func isDescribed(recordMap map[string]interface{}) bool {
+	switch recordMap["short_name"] {
+	case StatusErrorProcessing, StatusFailed, SkippedTooLarge:
+		return false
+	}
+	return true
+}
+
+// describedHashes maps the content hashes of the described images to their keys, preferring
+// records that were not copied from another image themselves
+func describedHashes(currentData map[string]interface{}) map[string]string {
+	hashes := make(map[string]string)
+	for key, record := range currentData {
+		recordMap, ok := record.(map[string]interface{})
+		if !ok || !isDescribed(recordMap) {
+			continue
+		}
+		hash, ok := recordMap["content_hash"].(string)
+		if !ok || hash == "" {
+			continue
+		}
+		if existing, ok := hashes[hash]; ok {
+			_, copied := recordMap["duplicate_of"]
+			_, existingCopied := currentData[existing].(map[string]interface{})["duplicate_of"]
+			if (copied && !existingCopied) || (copied == existingCopied && existing < key) {
+				continue
+			}
+		}
+		hashes[hash] = key
+	}
+	return hashes
+}
+
+// copyDuplicates records the images identical to an already described image of the catalog
+// with a copy of its record, saving the LLM call. It returns the images to describe, and the
+// images identical to one of those, which wait until it is described. Images that don't need
+// processing are passed on untouched.
+func (dp *DirectoryProcessor) copyDuplicates(dirPath string, images []string, currentData map[string]interface{}) ([]string, []duplicateImage, bool) {
+	hashes := describedHashes(currentData)
+	pending := make(map[string]bool)
+
+	var unique []string
+	var waiting []duplicateImage
+	copied := false
+	for _, imgPath := range images {
+		imgKey := dp.recordKey(dirPath, imgPath)
+		if !dp.recordNeedsProcessing(currentData, imgKey, imgPath) {
+			unique = append(unique, imgPath)
+			continue
+		}
+
+		hash, err := fileHash(imgPath)
+		if err != nil {
+			// Describing the image reports the problem
+			unique = append(unique, imgPath)
+			continue
+		}
+
+		if originalKey, ok := hashes[hash]; ok && originalKey != imgKey {
+			dp.copyRecord(currentData, imgPath, imgKey, originalKey)
+			dp.completeImage()
+			copied = true
+			continue
+		}
+		if pending[hash] {
+			waiting = append(waiting, duplicateImage{path: imgPath, key: imgKey, hash: hash})
+			continue
+		}
+		pending[hash] = true
+		unique = append(unique, imgPath)
+	}
+
+	return unique, waiting, copied
+}
+
+// processWaitingDuplicates copies the records of the images identical to an image described in
+// this run. When describing that image failed, they are described on their own.
+func (dp *DirectoryProcessor) processWaitingDuplicates(ctx context.Context, waiting []duplicateImage, currentData map[string]interface{}) bool {
+	changed := false
+	for _, dup := range waiting {
+		if ctx.Err() != nil {
+			break
+		}
+
+		if originalKey, ok := describedHashes(currentData)[dup.hash]; ok && originalKey != dup.key {
+			dp.copyRecord(currentData, dup.path, dup.key, originalKey)
+			changed = true
+		} else {
+			processed, err := dp.ip.ProcessImage(ctx, dup.path, dup.key, currentData)
+			if err != nil {
+				dp.logger().Error("Error processing image", "path", dup.path, "error", err)
+			}
+			changed = changed || processed
+		}
+		dp.completeImage()
+	}
+	return changed
+}
+
+// copyRecord records the image imgPath under imgKey with the description of the identical image
+// recorded under originalKey
+func (dp *DirectoryProcessor) copyRecord(currentData map[string]interface{}, imgPath, imgKey, originalKey string) {
+	dp.mutex.Lock()
+	defer dp.mutex.Unlock()
+
+	original, ok := currentData[originalKey].(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	record := maps.Clone(original)
+	delete(record, "processing_ms")
+	record["original_name"] = filepath.Base(imgPath)
+	record["duplicate_of"] = originalKey
+	record["update_date"] = time.Now().Format(time.RFC3339)
+	recordSource(record, imgPath)
+	currentData[imgKey] = record
+
+	dp.logger().Info("Image is a duplicate, copied its description", "path", imgPath, "duplicate_of", originalKey)
+}
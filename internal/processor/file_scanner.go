@@ -1,24 +1,47 @@
 package processor
 
 import (
+	"bufio"
 	"encoding/json"
 	"fmt"
 	"kbase-catalog/internal/utils"
+	"log/slog"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"kbase-catalog/internal/config"
 
 	"github.com/moby/patternmatcher"
 )
 
+// IgnoreFileName is the file holding additional exclude patterns for the directory it is in and
+// the directories below it
+const IgnoreFileName = ".kbaseignore"
+
 type FileScanner struct {
 	config  *config.Config
+	include *patternmatcher.PatternMatcher
 	exclude *patternmatcher.PatternMatcher
+	// root is the archive directory, ignore files are read from it and the directories below it
+	root string
+	// ignores caches the parsed ignore file of each directory, nil when it has none
+	ignores     map[string]*patternmatcher.PatternMatcher
+	ignoresLock sync.Mutex
 }
 
 func NewFileScanner(cfg *config.Config) *FileScanner {
+	var include *patternmatcher.PatternMatcher = nil
+	if len(cfg.IncludeFilter) != 0 {
+		m, err := patternmatcher.New(cfg.IncludeFilter)
+		if err != nil {
+			panic(err)
+		}
+		include = m
+	}
+
 	var matcher *patternmatcher.PatternMatcher = nil
 	if len(cfg.ExcludeFilter) != 0 {
 		m, err := patternmatcher.New(cfg.ExcludeFilter)
@@ -30,6 +53,7 @@ func NewFileScanner(cfg *config.Config) *FileScanner {
 
 	return &FileScanner{
 		config:  cfg,
+		include: include,
 		exclude: matcher,
 	}
 }
@@ -54,6 +78,12 @@ func (fs *FileScanner) HasImages(dirPath string) bool {
 	return false
 }
 
+// IsIndexFile reports whether the file at path is named like one of the configured index files
+func (fs *FileScanner) IsIndexFile(path string) bool {
+	name := filepath.Base(path)
+	return name == fs.config.GetIndexJSONName() || name == fs.config.GetIndexMDName() || name == fs.config.GetIndexJSONLName()
+}
+
 func (fs *FileScanner) FindImagesToProcess(dirPath string) ([]string, error) {
 	var images []string
 
@@ -75,18 +105,46 @@ func (fs *FileScanner) FindImagesToProcess(dirPath string) ([]string, error) {
 
 	var filteredImages []string
 	for _, img := range images {
-		baseName := filepath.Base(img)
-		if baseName != "index.json" && baseName != "index.md" {
+		if !fs.IsIndexFile(img) && fs.IsIncluded(img) {
 			filteredImages = append(filteredImages, img)
 		}
 	}
 
 	// Apply exclusion patterns
-	if len(fs.config.ExcludeFilter) > 0 {
-		filteredImages = fs.FilterExcludedFiles(filteredImages)
+	return fs.FilterExcludedFiles(filteredImages), nil
+}
+
+// FindImages lists the supported images in dirPath, descending depth-first into subdirectories
+// when recursive is set. Excluded files and directories are skipped.
+func (fs *FileScanner) FindImages(dirPath string, recursive bool) ([]string, error) {
+	if !recursive {
+		return fs.FindImagesToProcess(dirPath)
+	}
+
+	var images []string
+	err := filepath.WalkDir(dirPath, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+		if path != dirPath && (!fs.IncludesDir(path) || fs.ShouldExclude(path)) {
+			return filepath.SkipDir
+		}
+
+		found, err := fs.FindImagesToProcess(path)
+		if err != nil {
+			return err
+		}
+		images = append(images, found...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk %s: %w", dirPath, err)
 	}
 
-	return filteredImages, nil
+	return images, nil
 }
 
 func (fs *FileScanner) LoadExistingData(indexJsonPath string) (map[string]interface{}, error) {
@@ -100,7 +158,7 @@ func (fs *FileScanner) LoadExistingData(indexJsonPath string) (map[string]interf
 
 		err = json.Unmarshal(content, &data)
 		if err != nil {
-			fmt.Printf("Error reading %s, creating new data.\n", indexJsonPath)
+			slog.Warn("Error reading index, creating new data", "path", indexJsonPath, "error", err)
 			return make(map[string]interface{}), nil
 		}
 	}
@@ -108,14 +166,192 @@ func (fs *FileScanner) LoadExistingData(indexJsonPath string) (map[string]interf
 	return data, nil
 }
 
-func (fs *FileScanner) ShouldExclude(file string) bool {
-	if fs.exclude == nil {
-		return false // When no exclude, don't exclude anything
+// SetRoot sets the archive directory whose ignore files ShouldExclude applies
+func (fs *FileScanner) SetRoot(root string) {
+	fs.root = root
+	fs.ReloadIgnoreFiles()
+}
+
+// ReloadIgnoreFiles drops the cached ignore files, they are read again on their next use
+func (fs *FileScanner) ReloadIgnoreFiles() {
+	fs.ignoresLock.Lock()
+	defer fs.ignoresLock.Unlock()
+	fs.ignores = nil
+}
+
+// IsIncluded reports whether the image file matches the include_filter of the configuration,
+// always true without one. The patterns match the path relative to the archive root, like the
+// ones of exclude_filter.
+func (fs *FileScanner) IsIncluded(file string) bool {
+	if fs.include == nil {
+		return true
 	}
-	matched, _ := fs.exclude.MatchesOrParentMatches(file)
+	rel, _ := fs.relativePath(file)
+	matched, _ := fs.include.MatchesOrParentMatches(rel)
 	return matched
 }
 
+// IncludesDir reports whether the directory dir can hold images matching the include_filter of
+// the configuration: it matches a pattern, lies below a match or leads to the paths of a
+// pattern. Always true without an include_filter.
+func (fs *FileScanner) IncludesDir(dir string) bool {
+	if fs.include == nil {
+		return true
+	}
+	rel, _ := fs.relativePath(dir)
+	if rel == "." {
+		return true
+	}
+	if matched, _ := fs.include.MatchesOrParentMatches(rel); matched {
+		return true
+	}
+	for _, pattern := range fs.config.IncludeFilter {
+		if !strings.HasPrefix(pattern, "!") && leadsToPattern(filepath.ToSlash(rel), pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// leadsToPattern reports whether the slash separated directory path dir matches the leading
+// elements of pattern, so paths below dir may match the whole pattern
+func leadsToPattern(dir, pattern string) bool {
+	patternParts := strings.Split(strings.Trim(filepath.ToSlash(filepath.Clean(pattern)), "/"), "/")
+	for i, part := range strings.Split(dir, "/") {
+		if i >= len(patternParts) || strings.Contains(patternParts[i], "**") {
+			return true
+		}
+		if matched, err := path.Match(patternParts[i], part); err != nil || !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// ShouldExclude reports whether file matches the exclude_filter of the configuration or an
+// ignore file of the archive root or of one of the directories between it and file. The
+// exclude_filter patterns match the path of file relative to the archive root, whether file is
+// given as an absolute or relative path, so */temp/* excludes the temp folders of every catalog.
+// Files outside of the archive, or any file when no root is set, are matched as given.
+func (fs *FileScanner) ShouldExclude(file string) bool {
+	rel, inRoot := fs.relativePath(file)
+	if rel == "." {
+		return false // The archive itself is never excluded
+	}
+	if fs.exclude != nil {
+		if matched, _ := fs.exclude.MatchesOrParentMatches(rel); matched {
+			return true
+		}
+	}
+	return inRoot && fs.ignoredByFiles(rel)
+}
+
+// relativePath returns file relative to the archive root and true, or file unchanged and false
+// when it is outside of the root or no root is set
+func (fs *FileScanner) relativePath(file string) (string, bool) {
+	if fs.root == "" {
+		return file, false
+	}
+	root, err := filepath.Abs(fs.root)
+	if err != nil {
+		return file, false
+	}
+	path, err := filepath.Abs(file)
+	if err != nil {
+		return file, false
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil || isOutsideRoot(rel) {
+		return file, false
+	}
+	return rel, true
+}
+
+// ignoredByFiles reports whether an ignore file excludes the file at rel, relative to the
+// archive root. The patterns of an ignore file match the path relative to its directory.
+func (fs *FileScanner) ignoredByFiles(rel string) bool {
+	root, err := filepath.Abs(fs.root)
+	if err != nil {
+		return false
+	}
+
+	parts := strings.Split(rel, string(filepath.Separator))
+	dir := root
+	for i := range parts {
+		if matcher := fs.ignoreFile(dir); matcher != nil {
+			if matched, _ := matcher.MatchesOrParentMatches(filepath.Join(parts[i:]...)); matched {
+				return true
+			}
+		}
+		dir = filepath.Join(dir, parts[i])
+	}
+	return false
+}
+
+// ignoreFile returns the patterns of the ignore file in dir, nil when it has none or it can't
+// be parsed
+func (fs *FileScanner) ignoreFile(dir string) *patternmatcher.PatternMatcher {
+	fs.ignoresLock.Lock()
+	defer fs.ignoresLock.Unlock()
+
+	if matcher, ok := fs.ignores[dir]; ok {
+		return matcher
+	}
+	if fs.ignores == nil {
+		fs.ignores = make(map[string]*patternmatcher.PatternMatcher)
+	}
+
+	path := filepath.Join(dir, IgnoreFileName)
+	matcher, err := loadIgnoreFile(path)
+	if err != nil {
+		slog.Warn("Ignoring unreadable ignore file", "path", path, "error", err)
+	}
+	fs.ignores[dir] = matcher
+	return matcher
+}
+
+// loadIgnoreFile parses a .gitignore like file: one pattern per line, blank lines and lines
+// starting with # are skipped, ! negates a pattern, a leading / anchors it to the directory of
+// the file and patterns without a slash match at any depth. A missing file has no patterns.
+func loadIgnoreFile(path string) (*patternmatcher.PatternMatcher, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		negated := strings.HasPrefix(line, "!")
+		pattern := strings.TrimSuffix(strings.TrimPrefix(line, "!"), "/")
+		if strings.HasPrefix(pattern, "/") {
+			pattern = strings.TrimPrefix(pattern, "/")
+		} else if !strings.Contains(pattern, "/") {
+			pattern = "**/" + pattern
+		}
+		if negated {
+			pattern = "!" + pattern
+		}
+		patterns = append(patterns, pattern)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	return patternmatcher.New(patterns)
+}
+
 func (fs *FileScanner) FilterExcludedFiles(files []string) []string {
 	var result []string
 	for _, file := range files {
@@ -368,3 +368,114 @@ func cleanupFileScannerTestDir(t *testing.T, dirPath string) {
 	err := os.RemoveAll(dirPath)
 	assert.NoError(t, err)
 }
+
+func TestLoadIgnoreFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), IgnoreFileName)
+	content := "# comment\n\n*.raw\n/top.png\nsub/nested.png\ncache/\n!keep.raw\n"
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+	matcher, err := loadIgnoreFile(path)
+	assert.NoError(t, err)
+
+	for file, excluded := range map[string]bool{
+		"photo.raw":          true,
+		"deep/dir/photo.raw": true,
+		"keep.raw":           false,
+		"top.png":            true,
+		"deep/top.png":       false,
+		"sub/nested.png":     true,
+		"other/nested.png":   false,
+		"cache/image.png":    true,
+		"deep/cache/a.png":   true,
+		"image.png":          false,
+	} {
+		matched, err := matcher.MatchesOrParentMatches(file)
+		assert.NoError(t, err)
+		assert.Equal(t, excluded, matched, file)
+	}
+
+	t.Run("Missing file has no patterns", func(t *testing.T) {
+		matcher, err := loadIgnoreFile(filepath.Join(t.TempDir(), IgnoreFileName))
+		assert.NoError(t, err)
+		assert.Nil(t, matcher)
+	})
+}
+
+func TestShouldExclude_RelativeToRoot(t *testing.T) {
+	cfg := &config.Config{ExcludeFilter: []string{"*/temp/*", "*.tmp", "shapes/private"}}
+	cases := map[string]bool{
+		"shapes/temp/a.png":        true,
+		"shapes/a.png":             false,
+		"notes.tmp":                true,
+		"shapes/notes.tmp":         false,
+		"shapes/private":           true,
+		"shapes/private/a.png":     true,
+		"shapes/sub/private/a.png": false,
+		".":                        false,
+	}
+
+	cwd, err := os.Getwd()
+	assert.NoError(t, err)
+	for _, root := range []string{"archive", "/srv/archive", "/home/user/photos/archive", filepath.Join(cwd, "archive")} {
+		fs := NewFileScanner(cfg)
+		fs.SetRoot(root)
+		for rel, excluded := range cases {
+			path := filepath.Join(root, filepath.FromSlash(rel))
+			assert.Equal(t, excluded, fs.ShouldExclude(path), "%s below %s", rel, root)
+		}
+	}
+
+	t.Run("Relative and absolute forms of the same path", func(t *testing.T) {
+		fs := NewFileScanner(cfg)
+		fs.SetRoot("archive")
+		assert.True(t, fs.ShouldExclude(filepath.Join(cwd, "archive", "shapes", "temp", "a.png")))
+
+		fs.SetRoot(filepath.Join(cwd, "archive"))
+		assert.True(t, fs.ShouldExclude(filepath.Join("archive", "shapes", "temp", "a.png")))
+	})
+
+	t.Run("Paths outside of the root are matched as given", func(t *testing.T) {
+		fs := NewFileScanner(cfg)
+		fs.SetRoot("/srv/archive")
+		assert.True(t, fs.ShouldExclude("elsewhere/temp/a.png"))
+		assert.False(t, fs.ShouldExclude("/srv/other/temp/a.png"))
+	})
+}
+
+func TestIncludeFilter(t *testing.T) {
+	cfg := &config.Config{IncludeFilter: []string{"shapes", "photos/**/*.jpg"}}
+	fs := NewFileScanner(cfg)
+	fs.SetRoot("/srv/archive")
+
+	for rel, included := range map[string]bool{
+		"shapes/a.png":         true,
+		"shapes/sub/b.png":     true,
+		"photos/2024/a.jpg":    true,
+		"photos/a.jpg":         true,
+		"photos/2024/a.png":    false,
+		"drafts/a.png":         false,
+		"shapes-old/a.png":     false,
+		"other/photos/a.jpg":   false,
+		"photos/2024/x/yz.jpg": true,
+	} {
+		assert.Equal(t, included, fs.IsIncluded(filepath.Join("/srv/archive", rel)), rel)
+	}
+
+	for rel, included := range map[string]bool{
+		".":            true,
+		"shapes":       true,
+		"shapes/sub":   true,
+		"photos":       true,
+		"photos/2024":  true,
+		"drafts":       false,
+		"other/photos": false,
+	} {
+		assert.Equal(t, included, fs.IncludesDir(filepath.Join("/srv/archive", rel)), rel)
+	}
+
+	t.Run("Empty include filter includes everything", func(t *testing.T) {
+		fs := NewFileScanner(&config.Config{})
+		assert.True(t, fs.IsIncluded("/srv/archive/drafts/a.png"))
+		assert.True(t, fs.IncludesDir("/srv/archive/drafts"))
+	})
+}
@@ -2,97 +2,393 @@ package processor
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"kbase-catalog/internal/config"
 	"kbase-catalog/internal/encoder"
+	apperrors "kbase-catalog/internal/errors"
 	"kbase-catalog/internal/llm"
+	"kbase-catalog/internal/metrics"
+)
+
+// Short names of records for images that were not described
+const (
+	// StatusErrorProcessing marks a temporary failure, the image is retried on the next run
+	StatusErrorProcessing = "error_processing"
+	// StatusFailed marks a permanent failure, the image is only retried with --retry-failed
+	StatusFailed = "failed"
+	// SkippedTooLarge marks images exceeding max_file_size_mb, they are not retried
+	SkippedTooLarge = "skipped_too_large"
 )
 
 type ImageProcessor struct {
-	config *config.Config
+	config  *config.Config
+	limiter *llm.RateLimiter
+	log     *slog.Logger
+
+	// client is shared by all images so HTTP connections to the LLM API are kept alive and reused
+	client     *llm.LLMClient
+	clientOnce sync.Once
+	// batchDisabled is set once the LLM API failed to handle a batch request
+	batchDisabled atomic.Bool
+	// latencies receives the LLM call duration of every processed image, it is optional
+	latencies LatencyRecorder
+}
+
+// LatencyRecorder collects the time spent on the LLM call of each processed image
+type LatencyRecorder interface {
+	Observe(d time.Duration)
 }
 
 func NewImageProcessor(cfg *config.Config) *ImageProcessor {
 	return &ImageProcessor{
-		config: cfg,
+		config:  cfg,
+		limiter: llm.NewRateLimiter(cfg.RequestsPerSecond),
+		log:     slog.Default(),
+		client:  llm.NewLLMClient(cfg),
 	}
 }
 
+// llmClient returns the shared LLM client, creating it on first use for zero value processors
+func (ip *ImageProcessor) llmClient() *llm.LLMClient {
+	ip.clientOnce.Do(func() {
+		if ip.client == nil {
+			ip.client = llm.NewLLMClient(ip.config)
+		}
+	})
+	return ip.client
+}
+
+// ProcessSingleImage describes the image and records the result in currentData under its file name
 func (ip *ImageProcessor) ProcessSingleImage(ctx context.Context, imgPath string, currentData map[string]interface{}) (bool, error) {
-	imgKey := filepath.Base(imgPath)
-	record, exists := currentData[imgKey]
+	return ip.ProcessImage(ctx, imgPath, filepath.Base(imgPath), currentData)
+}
 
-	if !ip.needsProcessing(currentData, imgPath) {
-		return false, nil
+// ProcessImage describes the image and records the result in currentData under imgKey. It
+// returns false when the record doesn't need processing.
+func (ip *ImageProcessor) ProcessImage(ctx context.Context, imgPath, imgKey string, currentData map[string]interface{}) (bool, error) {
+	img, processed, err := ip.prepareImage(imgPath, imgKey, currentData)
+	if img == nil {
+		return processed, err
+	}
+	return ip.describeImage(ctx, img, currentData)
+}
+
+// ProcessBatch describes several images with a single LLM request and records the results in
+// currentData under the matching imgKeys. Images the batch answer has no valid result for are
+// described one at a time, as are all images once the server failed to handle a batch.
+func (ip *ImageProcessor) ProcessBatch(ctx context.Context, imgPaths, imgKeys []string, currentData map[string]interface{}) (bool, error) {
+	var prepared []*preparedImage
+	var errs []error
+	processedAny := false
+	for i, imgPath := range imgPaths {
+		img, processed, err := ip.prepareImage(imgPath, imgKeys[i], currentData)
+		if img != nil {
+			prepared = append(prepared, img)
+			continue
+		}
+		processedAny = processedAny || processed
+		if err != nil {
+			errs = append(errs, err)
+		}
 	}
 
-	var logMsg string
-	if exists {
-		if recordMap, ok := record.(map[string]interface{}); ok {
-			if shortName, ok := recordMap["short_name"].(string); ok && shortName == "error_processing" {
-				logMsg = fmt.Sprintf("RETRY: %s (previous attempt failed)", imgPath)
-			} else {
-				logMsg = fmt.Sprintf("Processing: %s", imgPath)
+	var responses []*llm.LLMResponse
+	var model string
+	var share time.Duration
+	if len(prepared) > 1 && !ip.batchDisabled.Load() {
+		// Wait for the shared rate limiter so parallel workers don't overwhelm the model
+		if err := ip.limiter.Wait(ctx); err != nil {
+			return processedAny, fmt.Errorf("cancelled while waiting for rate limiter: %w", err)
+		}
+
+		paths := make([]string, len(prepared))
+		data := make([]string, len(prepared))
+		for i, img := range prepared {
+			paths[i] = img.path
+			data[i] = img.data
+		}
+
+		start := time.Now()
+		var err error
+		responses, model, err = ip.llmClient().AskLLMBatch(ctx, paths, data)
+		share = time.Since(start) / time.Duration(len(prepared))
+		if err != nil {
+			if ctx.Err() != nil {
+				return processedAny, err
 			}
-		} else {
-			logMsg = fmt.Sprintf("Processing: %s", imgPath)
+			if !isBatchUnsupported(err) {
+				ip.logger().Warn("Batch request failed, describing the images one at a time", "images", len(prepared), "error", err)
+			} else if !ip.batchDisabled.Swap(true) {
+				ip.logger().Warn("LLM API doesn't support batches, describing images one at a time from now on", "error", err)
+			}
+			responses = nil
 		}
-	} else {
-		logMsg = fmt.Sprintf("Processing: %s", imgPath)
 	}
 
-	fmt.Printf("%s\n", logMsg)
+	for i, img := range prepared {
+		if responses != nil && ip.validateResponse(responses[i]) {
+			ip.recordResponse(img, responses[i], model, share, currentData)
+			processedAny = true
+			continue
+		}
+		if responses != nil {
+			ip.logger().Warn("No valid result in the batch answer, describing the image on its own", "path", img.path)
+		}
 
-	imageData, err := encoder.EncodeImageToBase64(imgPath)
+		processed, err := ip.describeImage(ctx, img, currentData)
+		processedAny = processedAny || processed
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return processedAny, errors.Join(errs...)
+}
+
+// isBatchUnsupported reports whether a failed batch request means the server can't handle
+// batches at all, rather than a temporary failure
+func isBatchUnsupported(err error) bool {
+	if errors.Is(err, llm.ErrBatchMismatch) {
+		return true
+	}
+	var networkErr *apperrors.NetworkError
+	return errors.As(err, &networkErr) && !networkErr.Retryable
+}
+
+// preparedImage is an image encoded for the LLM
+type preparedImage struct {
+	path string
+	key  string
+	data string
+	size int64
+}
+
+// prepareImage checks and encodes an image for the LLM. It returns no image when the image
+// doesn't need processing or was recorded as too large or failed, along with the result of
+// ProcessImage for it.
+func (ip *ImageProcessor) prepareImage(imgPath, imgKey string, currentData map[string]interface{}) (*preparedImage, bool, error) {
+	record, exists := currentData[imgKey]
+
+	if !recordNeedsProcessing(currentData, imgKey, imgPath, ip.config) {
+		return nil, false, nil
+	}
+
+	message := "Processing image"
+	if recordMap, ok := record.(map[string]interface{}); exists && ok {
+		if isRetryable(recordMap, ip.retryFailed()) {
+			message = "Retrying image, previous attempt failed"
+		} else if isStale(recordMap, imgPath) {
+			message = "Processing image again, the file changed"
+		} else if isTooShort(recordMap, ip.config) {
+			message = "Processing image again, the description is too short"
+		}
+	}
+	ip.logger().Info(message, "path", imgPath)
+
+	// Check the size before the image is decoded and encoded in memory
+	tooLarge, size, err := ip.exceedsMaxFileSize(imgPath)
 	if err != nil {
-		ip.handleProcessingError(imgPath, currentData)
-		return true, fmt.Errorf("failed to encode image: %w", err)
+		ip.handleProcessingError(imgPath, imgKey, currentData, err)
+		return nil, true, apperrors.NewProcessingError(imgKey, apperrors.StepCheckSize, "failed to check image size", err)
+	}
+	if tooLarge {
+		ip.markTooLarge(imgPath, imgKey, size, currentData)
+		return nil, true, nil
 	}
 
-	client := llm.NewLLMClient(ip.config)
-	llmResponse, model, err := client.AskLLM(ctx, imgPath, imageData)
+	imageData, err := encoder.EncodeImageToBase64(imgPath)
 	if err != nil {
-		ip.handleProcessingError(imgPath, currentData)
-		return true, fmt.Errorf("failed to process image with LLM: %w", err)
+		ip.handleProcessingError(imgPath, imgKey, currentData, err)
+		procErr := apperrors.NewProcessingError(imgKey, apperrors.StepEncode, "failed to encode image", err)
+		procErr.FileSize = size
+		return nil, true, procErr
 	}
 
-	if llmResponse != nil && ValidateResponse(llmResponse) {
-		currentData[imgKey] = map[string]interface{}{
-			"short_name":    llmResponse.ShortName,
-			"description":   llmResponse.Description,
-			"original_name": filepath.Base(imgPath),
-			"vl_model":      model,
-			"update_date":   time.Now().Format(time.RFC3339),
+	return &preparedImage{path: imgPath, key: imgKey, data: imageData, size: size}, false, nil
+}
+
+// describeImage sends a prepared image to the LLM on its own and records the result
+func (ip *ImageProcessor) describeImage(ctx context.Context, img *preparedImage, currentData map[string]interface{}) (bool, error) {
+	// Wait for the shared rate limiter so parallel workers don't overwhelm the model
+	if err := ip.limiter.Wait(ctx); err != nil {
+		return false, fmt.Errorf("cancelled while waiting for rate limiter: %w", err)
+	}
+
+	start := time.Now()
+	llmResponse, model, err := ip.llmClient().AskLLM(ctx, img.path, img.data)
+	elapsed := time.Since(start)
+	if err != nil {
+		// An interrupted request says nothing about the image, leave its record for the next run
+		if ctx.Err() != nil {
+			return false, err
 		}
-		fmt.Printf("  -> Successfully processed: %s\n", llmResponse.ShortName)
-		return true, nil
+		ip.handleProcessingError(img.path, img.key, currentData, err)
+		procErr := apperrors.NewProcessingError(img.key, apperrors.StepLLM, "failed to process image with LLM", err)
+		procErr.FileSize = img.size
+		return true, procErr
 	}
 
-	ip.handleProcessingError(imgPath, currentData)
+	ip.recordResponse(img, llmResponse, model, elapsed, currentData)
 	return true, nil
 }
 
+// recordResponse records the answer of the LLM for an image, or a failure when the answer is unusable
+func (ip *ImageProcessor) recordResponse(img *preparedImage, llmResponse *llm.LLMResponse, model string, elapsed time.Duration, currentData map[string]interface{}) {
+	if llmResponse == nil || !ip.validateResponse(llmResponse) {
+		ip.handleProcessingError(img.path, img.key, currentData, nil)
+		return
+	}
+
+	record := ip.buildRecord(img.path, llmResponse, model, elapsed)
+	currentData[img.key] = record
+	metrics.ImagesProcessed.Inc()
+	if ip.latencies != nil {
+		ip.latencies.Observe(elapsed)
+	}
+	ip.logger().Info("Image processed", "path", img.path, "short_name", record["short_name"], "processing_ms", record["processing_ms"])
+}
+
 func (ip *ImageProcessor) needsProcessing(currentData map[string]interface{}, imgPath string) bool {
-	imgKey := filepath.Base(imgPath)
-	record, exists := currentData[imgKey]
+	return recordNeedsProcessing(currentData, filepath.Base(imgPath), imgPath, ip.config)
+}
 
+// recordNeedsProcessing reports whether the image recorded under imgKey is missing from the
+// index, marked to be processed again, recorded for an older version of the file at imgPath
+// or, when rechecking, described too briefly. Described images modified before the since
+// cutoff of cfg are left alone. cfg may be nil.
+func recordNeedsProcessing(currentData map[string]interface{}, imgKey, imgPath string, cfg *config.Config) bool {
+	record, exists := currentData[imgKey]
 	if !exists {
 		return true
 	}
 
 	if recordMap, ok := record.(map[string]interface{}); ok {
-		if shortName, ok := recordMap["short_name"].(string); ok && shortName == "error_processing" {
+		retryFailed := cfg != nil && cfg.RetryFailed
+		if isRetryable(recordMap, retryFailed) {
 			return true
 		}
+		if cfg != nil && modifiedBefore(imgPath, cfg.Since) {
+			return false
+		}
+		return isStale(recordMap, imgPath) || isTooShort(recordMap, cfg)
 	}
 
 	return false
 }
 
+// modifiedBefore reports whether the image file was last modified before since, false when
+// since is not set
+func modifiedBefore(imgPath string, since time.Time) bool {
+	if since.IsZero() {
+		return false
+	}
+	info, err := os.Stat(imgPath)
+	return err == nil && info.ModTime().Before(since)
+}
+
+// isTooShort reports whether a described image has a description shorter than
+// min_description_length while rechecking descriptions. Records of images that were not
+// described are left to the other checks.
+func isTooShort(recordMap map[string]interface{}, cfg *config.Config) bool {
+	if cfg == nil || !cfg.RecheckDescriptions || cfg.MinDescriptionLength <= 0 {
+		return false
+	}
+
+	shortName, _ := recordMap["short_name"].(string)
+	if shortName == StatusErrorProcessing || shortName == StatusFailed || shortName == SkippedTooLarge {
+		return false
+	}
+
+	description, _ := recordMap["description"].(string)
+	return utf8.RuneCountInString(strings.TrimSpace(description)) < cfg.MinDescriptionLength
+}
+
+// recordSource stores the modification time and size of the image file in its record, so an
+// image edited in place is described again
+func recordSource(record map[string]interface{}, imgPath string) {
+	info, err := os.Stat(imgPath)
+	if err != nil {
+		return
+	}
+	record["source_mtime"] = info.ModTime().UTC().Format(time.RFC3339Nano)
+	record["source_size"] = info.Size()
+}
+
+// isStale reports whether the image file changed since the record was written. The size is
+// compared as well, as the modification time alone can be unreliable, e.g. after the clock was
+// set back. Records written before the file was tracked are never stale.
+func isStale(recordMap map[string]interface{}, imgPath string) bool {
+	mtime, hasMtime := recordMap["source_mtime"].(string)
+	size, hasSize := recordNumber(recordMap["source_size"])
+	if !hasMtime && !hasSize {
+		return false
+	}
+
+	info, err := os.Stat(imgPath)
+	if err != nil {
+		return false
+	}
+	if hasMtime && mtime != info.ModTime().UTC().Format(time.RFC3339Nano) {
+		return true
+	}
+	return hasSize && size != info.Size()
+}
+
+// recordNumber converts a number stored in a record, which is a float64 once read from index.json
+func recordNumber(value interface{}) (int64, bool) {
+	switch size := value.(type) {
+	case int64:
+		return size, true
+	case int:
+		return int64(size), true
+	case float64:
+		return int64(size), true
+	default:
+		return 0, false
+	}
+}
+
+// retryFailed reports whether permanently failed images should be processed again
+func (ip *ImageProcessor) retryFailed() bool {
+	return ip.config != nil && ip.config.RetryFailed
+}
+
+// isRetryable reports whether an existing record marks an image to process again
+func isRetryable(recordMap map[string]interface{}, retryFailed bool) bool {
+	shortName, _ := recordMap["short_name"].(string)
+	return shortName == StatusErrorProcessing || (retryFailed && shortName == StatusFailed)
+}
+
+// isPermanentFailure reports whether processing the image again can't succeed: the file is not
+// a decodable image or the API rejected the request. Network errors, timeouts and server
+// errors are temporary.
+func isPermanentFailure(err error) bool {
+	if errors.Is(err, encoder.ErrDecode) {
+		return true
+	}
+
+	var networkErr *apperrors.NetworkError
+	return errors.As(err, &networkErr) && !networkErr.Retryable
+}
+
+// logger returns the injected logger, falling back to the default one for zero value processors
+func (ip *ImageProcessor) logger() *slog.Logger {
+	if ip.log == nil {
+		return slog.Default()
+	}
+	return ip.log
+}
+
 // NeedsProcessing is a public wrapper for the internal needsProcessing function
 func NeedsProcessing(currentData map[string]interface{}, imgPath string) bool {
 	imgKey := filepath.Base(imgPath)
@@ -108,11 +404,71 @@ func NeedsProcessing(currentData map[string]interface{}, imgPath string) bool {
 		return true
 	}
 
-	if shortName, ok := recordMap["short_name"].(string); ok && shortName == "error_processing" {
-		return true
+	return isRetryable(recordMap, false) || isStale(recordMap, imgPath)
+}
+
+// buildRecord creates the index record for a successfully processed image, elapsed is the
+// duration of the LLM call
+func (ip *ImageProcessor) buildRecord(imgPath string, response *llm.LLMResponse, model string, elapsed time.Duration) map[string]interface{} {
+	record := map[string]interface{}{
+		"short_name":    response.ShortName,
+		"description":   response.Description,
+		"original_name": filepath.Base(imgPath),
+		"vl_model":      model,
+		"update_date":   time.Now().Format(time.RFC3339),
+		"processing_ms": elapsed.Milliseconds(),
+	}
+	recordSource(record, imgPath)
+	// The hash lets identical images of the catalog reuse the description
+	if hash, err := fileHash(imgPath); err == nil {
+		record["content_hash"] = hash
 	}
 
-	return false
+	if response.LongDescription != "" {
+		record["long_description"] = response.LongDescription
+	}
+
+	if len(response.Tags) > 0 {
+		record["tags"] = []string(response.Tags)
+	}
+
+	if ip.config.OutputLanguage != "" {
+		record["language"] = ip.config.OutputLanguage
+	}
+
+	if ip.config.EmbedThumbnails {
+		if thumbnail, err := encoder.EncodeThumbnail(imgPath, encoder.ThumbnailSize); err == nil {
+			record["thumbnail"] = thumbnail
+		} else {
+			ip.logger().Warn("Failed to create thumbnail", "path", imgPath, "error", err)
+		}
+	}
+
+	if ip.config.IsOCRMode() {
+		if response.ShortName == "" {
+			record["short_name"] = strings.TrimSuffix(filepath.Base(imgPath), filepath.Ext(imgPath))
+		}
+		record["ocr_text"] = response.Text
+	}
+
+	return record
+}
+
+// validateResponse checks the LLM response against the contract of the configured task mode
+func (ip *ImageProcessor) validateResponse(response *llm.LLMResponse) bool {
+	if ip.config.IsOCRMode() {
+		return ValidateOCRResponse(response)
+	}
+	return ValidateResponse(response)
+}
+
+// ValidateOCRResponse checks an OCR mode response. Images without any visible text
+// are valid as long as the model named them, so the text itself may be empty.
+func ValidateOCRResponse(response *llm.LLMResponse) bool {
+	if response == nil {
+		return false
+	}
+	return response.ShortName != "" || response.Text != ""
 }
 
 // ValidateResponse is a public wrapper for the internal validateResponse function
@@ -123,58 +479,125 @@ func ValidateResponse(response *llm.LLMResponse) bool {
 	return response.ShortName != "" && response.Description != ""
 }
 
-func (ip *ImageProcessor) handleProcessingError(imgPath string, currentData map[string]interface{}) {
-	imgKey := filepath.Base(imgPath)
-	currentData[imgKey] = map[string]interface{}{
-		"short_name":    "error_processing",
-		"description":   "Error processing file (retry will be attempted)",
+// handleProcessingError records a failed image along with the reason. A nil err means the
+// LLM answer was unusable.
+func (ip *ImageProcessor) handleProcessingError(imgPath, imgKey string, currentData map[string]interface{}, err error) {
+	reason := "invalid LLM response"
+	if err != nil {
+		reason = err.Error()
+	}
+
+	// Temporary failures are counted across runs, so an image that never succeeds isn't
+	// retried by every run forever
+	retryCount := int64(1)
+	if previous, ok := currentData[imgKey].(map[string]interface{}); ok && previous["short_name"] == StatusErrorProcessing {
+		count, _ := recordNumber(previous["retry_count"])
+		retryCount = count + 1
+	}
+	exhausted := ip.config != nil && ip.config.MaxRetries > 0 && retryCount >= int64(ip.config.MaxRetries)
+
+	status := StatusErrorProcessing
+	description := "Error processing file (retry will be attempted)"
+	if isPermanentFailure(err) || exhausted {
+		status = StatusFailed
+		description = "Error processing file (will not be retried)"
+	}
+
+	record := map[string]interface{}{
+		"short_name":    status,
+		"description":   description,
+		"error":         reason,
+		"retry_count":   retryCount,
 		"original_name": filepath.Base(imgPath),
 		"vl_model":      "unknown",
 		"update_date":   time.Now().Format(time.RFC3339),
 	}
-	fmt.Printf("  -> Recognition error. Will be retried.\n")
+	recordSource(record, imgPath)
+	currentData[imgKey] = record
+	metrics.ImagesFailed.Inc()
+
+	switch {
+	case status == StatusErrorProcessing:
+		ip.logger().Warn("Recognition error, will be retried", "path", imgPath, "error", reason, "retry_count", retryCount)
+	case exhausted:
+		ip.logger().Error("Recognition failed too many times, won't be retried", "path", imgPath, "error", reason, "retry_count", retryCount)
+	default:
+		ip.logger().Error("Recognition failed permanently, won't be retried", "path", imgPath, "error", reason)
+	}
+}
+
+// exceedsMaxFileSize reports whether the image is larger than max_file_size_mb, along with its size
+func (ip *ImageProcessor) exceedsMaxFileSize(imgPath string) (bool, int64, error) {
+	limit := ip.config.GetMaxFileSize()
+	if limit <= 0 {
+		return false, 0, nil
+	}
+
+	info, err := os.Stat(imgPath)
+	if err != nil {
+		return false, 0, err
+	}
+	return info.Size() > limit, info.Size(), nil
+}
+
+// markTooLarge records an image that was skipped for exceeding the file size limit
+func (ip *ImageProcessor) markTooLarge(imgPath, imgKey string, size int64, currentData map[string]interface{}) {
+	record := map[string]interface{}{
+		"short_name":    SkippedTooLarge,
+		"description":   fmt.Sprintf("File is too large to process (%d bytes, limit is %d MB)", size, ip.config.MaxFileSizeMB),
+		"original_name": filepath.Base(imgPath),
+		"vl_model":      "unknown",
+		"update_date":   time.Now().Format(time.RFC3339),
+	}
+	recordSource(record, imgPath)
+	currentData[imgKey] = record
+	metrics.ImagesSkipped.Inc()
+	ip.logger().Warn("Image exceeds the file size limit, skipped", "path", imgPath, "size", size, "max_file_size_mb", ip.config.MaxFileSizeMB)
 }
 
 // HandleProcessingError is a public wrapper for the internal handleProcessingError function
 func HandleProcessingError(imgPath string, currentData map[string]interface{}) {
 	imgKey := filepath.Base(imgPath)
 	currentData[imgKey] = map[string]interface{}{
-		"short_name":    "error_processing",
+		"short_name":    StatusErrorProcessing,
 		"description":   "Error processing file (retry will be attempted)",
 		"original_name": filepath.Base(imgPath),
 		"vl_model":      "unknown",
 		"update_date":   time.Now().Format(time.RFC3339),
 	}
-	fmt.Printf("  -> Recognition error. Will be retried.\n")
+	slog.Warn("Recognition error, will be retried", "path", imgPath)
 }
 
-func (ip *ImageProcessor) TestSingleImage(ctx context.Context, imagePath string) (*llm.LLMResponse, error) {
+func (ip *ImageProcessor) TestSingleImage(ctx context.Context, imagePath string) (*llm.LLMResponse, string, error) {
 	if _, err := os.Stat(imagePath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("file not found: %s", imagePath)
+		return nil, "", fmt.Errorf("file not found: %s", imagePath)
 	}
 
-	fmt.Printf("Testing image: %s\n", imagePath)
-	fmt.Printf("Directory: %s\n", filepath.Base(filepath.Dir(imagePath)))
-	fmt.Printf("Filename: %s\n", filepath.Base(imagePath))
+	tooLarge, size, err := ip.exceedsMaxFileSize(imagePath)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to check image size: %w", err)
+	}
+	if tooLarge {
+		return nil, "", fmt.Errorf("image is too large: %d bytes, limit is %d MB", size, ip.config.MaxFileSizeMB)
+	}
 
 	imageData, err := encoder.EncodeImageToBase64(imagePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to encode image: %w", err)
+		return nil, "", fmt.Errorf("failed to encode image: %w", err)
+	}
+
+	if err := ip.limiter.Wait(ctx); err != nil {
+		return nil, "", fmt.Errorf("cancelled while waiting for rate limiter: %w", err)
 	}
 
-	client := llm.NewLLMClient(ip.config)
-	llmResponse, model, err := client.AskLLM(ctx, imagePath, imageData)
+	llmResponse, model, err := ip.llmClient().AskLLM(ctx, imagePath, imageData)
 	if err != nil {
-		return nil, fmt.Errorf("failed to process image with LLM: %w", err)
+		return nil, "", fmt.Errorf("failed to process image with LLM: %w", err)
 	}
 
-	if llmResponse != nil && llmResponse.ShortName != "" && llmResponse.Description != "" {
-		fmt.Printf("\n✅ Successfully obtained result:\n")
-		fmt.Printf("Short name: %s\n", llmResponse.ShortName)
-		fmt.Printf("Description: %s\n", llmResponse.Description)
-		fmt.Printf("Vision model: %s\n", model)
-		return llmResponse, nil
+	if llmResponse != nil && ip.validateResponse(llmResponse) {
+		return llmResponse, model, nil
 	}
 
-	return nil, fmt.Errorf("invalid LLM response")
+	return nil, "", fmt.Errorf("invalid LLM response")
 }
@@ -3,19 +3,29 @@ package processor
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"image"
 	"image/color"
+	_ "image/jpeg"
 	"image/png"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"kbase-catalog/internal/config"
+	"kbase-catalog/internal/encoder"
+	apperrors "kbase-catalog/internal/errors"
 	"kbase-catalog/internal/llm"
+	"kbase-catalog/internal/metrics"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -122,6 +132,645 @@ The JSON must contain two keys:
 	})
 }
 
+// TestImageProcessor_ProcessSingleImage_OCRMode tests that OCR mode stores the extracted text
+func TestImageProcessor_ProcessSingleImage_OCRMode(t *testing.T) {
+	tempDir := t.TempDir()
+
+	testImagePath := filepath.Join(tempDir, "scan.png")
+	err := os.WriteFile(testImagePath, createTestImage(10, 10, 255, 255, 255), 0644)
+	assert.NoError(t, err)
+
+	var userPrompt string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := make(map[string]interface{})
+		json.NewDecoder(r.Body).Decode(&body)
+
+		// Remember the instruction sent with the image
+		messages := body["messages"].([]interface{})
+		content := messages[1].(map[string]interface{})["content"].([]interface{})
+		userPrompt, _ = content[0].(map[string]interface{})["text"].(string)
+
+		response := map[string]interface{}{
+			"model": "test-model",
+			"choices": []interface{}{
+				map[string]interface{}{
+					"message": map[string]interface{}{
+						"content": `{"short_name": "Invoice", "text": "Invoice No. 42\nTotal due: 100 EUR"}`,
+					},
+				},
+			},
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		APIURL:   server.URL,
+		Model:    "test-model",
+		Timeout:  10,
+		TaskMode: config.TaskModeOCR,
+	}
+
+	processor := NewImageProcessor(cfg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	currentData := make(map[string]interface{})
+
+	processed, err := processor.ProcessSingleImage(ctx, testImagePath, currentData)
+	assert.NoError(t, err)
+	assert.True(t, processed)
+	assert.Contains(t, userPrompt, `"text"`)
+
+	record := currentData["scan.png"].(map[string]interface{})
+	assert.Equal(t, "Invoice", record["short_name"])
+	assert.Equal(t, "Invoice No. 42\nTotal due: 100 EUR", record["ocr_text"])
+}
+
+func TestImageProcessor_ProcessSingleImage_Metrics(t *testing.T) {
+	tempDir := t.TempDir()
+
+	testImagePath := filepath.Join(tempDir, "metrics.png")
+	err := os.WriteFile(testImagePath, createTestImage(10, 10, 0, 128, 255), 0644)
+	assert.NoError(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := map[string]interface{}{
+			"model": "test-model",
+			"choices": []interface{}{
+				map[string]interface{}{
+					"message": map[string]interface{}{
+						"content": `{"short_name": "Blue square", "description": "A blue square."}`,
+					},
+				},
+			},
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	// scrape returns the value of a counter exposed at /metrics
+	scrape := func(name string) int {
+		rec := httptest.NewRecorder()
+		metrics.Default.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+		for _, line := range strings.Split(rec.Body.String(), "\n") {
+			if value, found := strings.CutPrefix(line, name+" "); found {
+				count, err := strconv.Atoi(value)
+				assert.NoError(t, err)
+				return count
+			}
+		}
+		t.Fatalf("metric %s not found", name)
+		return 0
+	}
+
+	processedBefore := scrape("images_processed_total")
+	requestsBefore := scrape("llm_requests_total")
+
+	processor := NewImageProcessor(&config.Config{APIURL: server.URL, Model: "test-model", Timeout: 10})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	processed, err := processor.ProcessSingleImage(ctx, testImagePath, make(map[string]interface{}))
+	assert.NoError(t, err)
+	assert.True(t, processed)
+
+	assert.Equal(t, processedBefore+1, scrape("images_processed_total"))
+	assert.Equal(t, requestsBefore+1, scrape("llm_requests_total"))
+}
+
+func TestImageProcessor_ProcessSingleImage_MaxFileSize(t *testing.T) {
+	tempDir := t.TempDir()
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		response := map[string]interface{}{
+			"model": "test-model",
+			"choices": []interface{}{
+				map[string]interface{}{
+					"message": map[string]interface{}{
+						"content": `{"short_name": "Small image", "description": "A small image."}`,
+					},
+				},
+			},
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	processor := NewImageProcessor(&config.Config{
+		APIURL:        server.URL,
+		Model:         "test-model",
+		Timeout:       10,
+		MaxFileSizeMB: 1,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	t.Run("Over-limit file is skipped", func(t *testing.T) {
+		largeImagePath := filepath.Join(tempDir, "large.png")
+		err := os.WriteFile(largeImagePath, make([]byte, 1024*1024+1), 0644)
+		assert.NoError(t, err)
+
+		currentData := make(map[string]interface{})
+		processed, err := processor.ProcessSingleImage(ctx, largeImagePath, currentData)
+		assert.NoError(t, err)
+		assert.True(t, processed)
+		assert.Equal(t, 0, requests)
+
+		record := currentData["large.png"].(map[string]interface{})
+		assert.Equal(t, SkippedTooLarge, record["short_name"])
+
+		// Skipped images are not retried
+		assert.False(t, NeedsProcessing(currentData, largeImagePath))
+	})
+
+	t.Run("Under-limit file is processed", func(t *testing.T) {
+		smallImagePath := filepath.Join(tempDir, "small.png")
+		err := os.WriteFile(smallImagePath, createTestImage(10, 10, 255, 0, 0), 0644)
+		assert.NoError(t, err)
+
+		currentData := make(map[string]interface{})
+		processed, err := processor.ProcessSingleImage(ctx, smallImagePath, currentData)
+		assert.NoError(t, err)
+		assert.True(t, processed)
+		assert.Equal(t, 1, requests)
+
+		record := currentData["small.png"].(map[string]interface{})
+		assert.Equal(t, "Small image", record["short_name"])
+	})
+}
+
+func TestImageProcessor_ProcessSingleImage_FailureClassification(t *testing.T) {
+	tempDir := t.TempDir()
+
+	statusCode := http.StatusInternalServerError
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(statusCode)
+		w.Write([]byte("model unavailable"))
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{APIURL: server.URL, Model: "test-model", Timeout: 10}
+	processor := NewImageProcessor(cfg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	t.Run("Corrupt image fails permanently", func(t *testing.T) {
+		corruptPath := filepath.Join(tempDir, "corrupt.png")
+		err := os.WriteFile(corruptPath, []byte("not an image"), 0644)
+		assert.NoError(t, err)
+
+		currentData := make(map[string]interface{})
+		processed, err := processor.ProcessSingleImage(ctx, corruptPath, currentData)
+		assert.Error(t, err)
+		assert.True(t, processed)
+
+		var procErr *apperrors.ProcessingError
+		if assert.True(t, errors.As(err, &procErr)) {
+			assert.Equal(t, "corrupt.png", procErr.FileName)
+			assert.Equal(t, apperrors.StepEncode, procErr.ProcessingStep)
+		}
+		assert.True(t, errors.Is(err, encoder.ErrDecode))
+
+		record := currentData["corrupt.png"].(map[string]interface{})
+		assert.Equal(t, StatusFailed, record["short_name"])
+		assert.Contains(t, record["error"], "failed to decode image")
+
+		// The next run skips it
+		processed, err = processor.ProcessSingleImage(ctx, corruptPath, currentData)
+		assert.NoError(t, err)
+		assert.False(t, processed)
+		assert.False(t, NeedsProcessing(currentData, corruptPath))
+
+		// Unless failed images are retried explicitly
+		retrying := NewImageProcessor(&config.Config{APIURL: server.URL, Model: "test-model", Timeout: 10, RetryFailed: true})
+		assert.True(t, retrying.needsProcessing(currentData, corruptPath))
+	})
+
+	imgPath := filepath.Join(tempDir, "image.png")
+	err := os.WriteFile(imgPath, createTestImage(10, 10, 0, 255, 0), 0644)
+	assert.NoError(t, err)
+
+	t.Run("Server error stays retryable", func(t *testing.T) {
+		statusCode = http.StatusInternalServerError
+
+		currentData := make(map[string]interface{})
+		_, err := processor.ProcessSingleImage(ctx, imgPath, currentData)
+		assert.Error(t, err)
+
+		record := currentData["image.png"].(map[string]interface{})
+		assert.Equal(t, StatusErrorProcessing, record["short_name"])
+		assert.Contains(t, record["error"], "status code 500")
+		assert.True(t, NeedsProcessing(currentData, imgPath))
+	})
+
+	t.Run("Rejected request fails permanently", func(t *testing.T) {
+		statusCode = http.StatusBadRequest
+
+		currentData := make(map[string]interface{})
+		_, err := processor.ProcessSingleImage(ctx, imgPath, currentData)
+		assert.Error(t, err)
+
+		var procErr *apperrors.ProcessingError
+		if assert.True(t, errors.As(err, &procErr)) {
+			assert.Equal(t, "image.png", procErr.FileName)
+			assert.Equal(t, apperrors.StepLLM, procErr.ProcessingStep)
+		}
+		var networkErr *apperrors.NetworkError
+		if assert.True(t, errors.As(err, &networkErr)) {
+			assert.Equal(t, http.StatusBadRequest, networkErr.StatusCode)
+			assert.Equal(t, server.URL, networkErr.URL)
+			assert.False(t, networkErr.Retryable)
+		}
+
+		record := currentData["image.png"].(map[string]interface{})
+		assert.Equal(t, StatusFailed, record["short_name"])
+		assert.False(t, NeedsProcessing(currentData, imgPath))
+	})
+
+	t.Run("Rate limiting stays retryable", func(t *testing.T) {
+		statusCode = http.StatusTooManyRequests
+
+		currentData := make(map[string]interface{})
+		_, err := processor.ProcessSingleImage(ctx, imgPath, currentData)
+		assert.Error(t, err)
+
+		record := currentData["image.png"].(map[string]interface{})
+		assert.Equal(t, StatusErrorProcessing, record["short_name"])
+	})
+}
+
+// latencyRecorder collects the observed durations
+type latencyRecorder struct {
+	durations []time.Duration
+}
+
+func (r *latencyRecorder) Observe(d time.Duration) {
+	r.durations = append(r.durations, d)
+}
+
+// TestImageProcessor_ProcessSingleImage_ProcessingTime tests that the LLM call duration is recorded
+func TestImageProcessor_ProcessSingleImage_ProcessingTime(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		response := map[string]interface{}{
+			"model": "test-model",
+			"choices": []interface{}{
+				map[string]interface{}{
+					"message": map[string]interface{}{
+						"content": `{"short_name": "Test Image", "description": "This is a test image."}`,
+					},
+				},
+			},
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	imgPath := filepath.Join(t.TempDir(), "image.png")
+	assert.NoError(t, os.WriteFile(imgPath, createTestImage(10, 10, 255, 0, 0), 0644))
+
+	recorder := &latencyRecorder{}
+	processor := NewImageProcessor(&config.Config{APIURL: server.URL, Model: "test-model", Timeout: 10})
+	processor.latencies = recorder
+
+	currentData := make(map[string]interface{})
+	processed, err := processor.ProcessSingleImage(context.Background(), imgPath, currentData)
+	assert.NoError(t, err)
+	assert.True(t, processed)
+
+	record := currentData["image.png"].(map[string]interface{})
+	processingMs, ok := record["processing_ms"].(int64)
+	if assert.True(t, ok) {
+		assert.GreaterOrEqual(t, processingMs, int64(20))
+	}
+	if assert.Len(t, recorder.durations, 1) {
+		assert.Equal(t, processingMs, recorder.durations[0].Milliseconds())
+	}
+}
+
+func TestImageProcessor_ProcessSingleImage_ChangedFile(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		response := map[string]interface{}{
+			"choices": []interface{}{
+				map[string]interface{}{
+					"message": map[string]interface{}{
+						"content": `{"short_name": "Test Image", "description": "This is a test image."}`,
+					},
+				},
+			},
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	imgPath := filepath.Join(t.TempDir(), "image.png")
+	assert.NoError(t, os.WriteFile(imgPath, createTestImage(10, 10, 255, 0, 0), 0644))
+	modified := time.Now().Add(-time.Hour).Truncate(time.Second)
+	assert.NoError(t, os.Chtimes(imgPath, modified, modified))
+
+	processor := NewImageProcessor(&config.Config{APIURL: server.URL, Model: "test-model", Timeout: 10})
+	currentData := make(map[string]interface{})
+	processed, err := processor.ProcessSingleImage(context.Background(), imgPath, currentData)
+	assert.NoError(t, err)
+	assert.True(t, processed)
+	assert.Equal(t, int32(1), calls.Load())
+
+	// The record survives a round trip through index.json, which reads the size as float64
+	content, err := json.Marshal(currentData)
+	assert.NoError(t, err)
+	currentData = make(map[string]interface{})
+	assert.NoError(t, json.Unmarshal(content, &currentData))
+
+	t.Run("Untouched file is skipped", func(t *testing.T) {
+		processed, err := processor.ProcessSingleImage(context.Background(), imgPath, currentData)
+		assert.NoError(t, err)
+		assert.False(t, processed)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("Edited file is processed again", func(t *testing.T) {
+		assert.NoError(t, os.WriteFile(imgPath, createTestImage(20, 20, 0, 0, 255), 0644))
+
+		processed, err := processor.ProcessSingleImage(context.Background(), imgPath, currentData)
+		assert.NoError(t, err)
+		assert.True(t, processed)
+		assert.Equal(t, int32(2), calls.Load())
+
+		processed, err = processor.ProcessSingleImage(context.Background(), imgPath, currentData)
+		assert.NoError(t, err)
+		assert.False(t, processed)
+		assert.Equal(t, int32(2), calls.Load())
+	})
+}
+
+func TestRecordNeedsProcessing_ShortDescription(t *testing.T) {
+	imgPath := filepath.Join(t.TempDir(), "cat.png")
+	assert.NoError(t, os.WriteFile(imgPath, createTestImage(10, 10, 255, 0, 0), 0644))
+
+	currentData := map[string]interface{}{
+		"cat.png":    map[string]interface{}{"short_name": "Cat", "description": "Cat"},
+		"dog.png":    map[string]interface{}{"short_name": "Dog", "description": "A brown dog sleeping on a sofa."},
+		"failed.png": map[string]interface{}{"short_name": StatusFailed, "description": ""},
+		"large.png":  map[string]interface{}{"short_name": SkippedTooLarge, "description": "Big"},
+	}
+
+	cfg := &config.Config{MinDescriptionLength: 20}
+	assert.False(t, recordNeedsProcessing(currentData, "cat.png", imgPath, cfg), "only rechecked with --recheck")
+
+	cfg.RecheckDescriptions = true
+	assert.True(t, recordNeedsProcessing(currentData, "cat.png", imgPath, cfg))
+	assert.False(t, recordNeedsProcessing(currentData, "dog.png", imgPath, cfg))
+	assert.False(t, recordNeedsProcessing(currentData, "failed.png", imgPath, cfg))
+	assert.False(t, recordNeedsProcessing(currentData, "large.png", imgPath, cfg))
+
+	cfg.MinDescriptionLength = 0
+	assert.False(t, recordNeedsProcessing(currentData, "cat.png", imgPath, cfg))
+	assert.False(t, recordNeedsProcessing(currentData, "cat.png", imgPath, nil))
+}
+
+func TestRecordNeedsProcessing_Since(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	ages := map[string]time.Duration{
+		"old.png":       30 * 24 * time.Hour,
+		"old-new.png":   30 * 24 * time.Hour,
+		"old-error.png": 30 * 24 * time.Hour,
+		"week.png":      8 * 24 * time.Hour,
+		"recent.png":    2 * 24 * time.Hour,
+		"today.png":     time.Hour,
+	}
+	for name, age := range ages {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.WriteFile(path, createTestImage(10, 10, 255, 0, 0), 0644))
+		assert.NoError(t, os.Chtimes(path, now.Add(-age), now.Add(-age)))
+	}
+
+	// Every described image is rechecked for its short description, old-new.png has no record
+	currentData := map[string]interface{}{
+		"old.png":       map[string]interface{}{"short_name": "Old", "description": "Old"},
+		"old-error.png": map[string]interface{}{"short_name": StatusErrorProcessing, "description": ""},
+		"week.png":      map[string]interface{}{"short_name": "Week", "description": "Week"},
+		"recent.png":    map[string]interface{}{"short_name": "Recent", "description": "Recent"},
+		"today.png":     map[string]interface{}{"short_name": "Today", "description": "Today"},
+	}
+	cfg := &config.Config{MinDescriptionLength: 20, RecheckDescriptions: true, Since: now.Add(-7 * 24 * time.Hour)}
+
+	var selected []string
+	for name := range ages {
+		if recordNeedsProcessing(currentData, name, filepath.Join(dir, name), cfg) {
+			selected = append(selected, name)
+		}
+	}
+	assert.ElementsMatch(t, []string{"old-new.png", "old-error.png", "recent.png", "today.png"}, selected)
+
+	// Without the cutoff every short description is rechecked
+	cfg.Since = time.Time{}
+	assert.True(t, recordNeedsProcessing(currentData, "old.png", filepath.Join(dir, "old.png"), cfg))
+}
+
+func TestImageProcessor_ProcessSingleImage_Recheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []interface{}{map[string]interface{}{"message": map[string]interface{}{
+				"content": `{"short_name": "Red square", "description": "A red square on a white page."}`,
+			}}},
+		})
+	}))
+	defer server.Close()
+
+	imgPath := filepath.Join(t.TempDir(), "image.png")
+	assert.NoError(t, os.WriteFile(imgPath, createTestImage(10, 10, 255, 0, 0), 0644))
+	currentData := map[string]interface{}{"image.png": map[string]interface{}{"short_name": "Red", "description": "Red"}}
+
+	cfg := &config.Config{APIURL: server.URL, Model: "test-model", Timeout: 10, MinDescriptionLength: 20, RecheckDescriptions: true}
+	processed, err := NewImageProcessor(cfg).ProcessSingleImage(context.Background(), imgPath, currentData)
+	assert.NoError(t, err)
+	assert.True(t, processed)
+	assert.Equal(t, "A red square on a white page.", currentData["image.png"].(map[string]interface{})["description"])
+}
+
+func TestImageProcessor_ProcessSingleImage_LongDescription(t *testing.T) {
+	content := `{"short_name": "Red square", "description": "A red square.", "long_description": "A plain red square filling the whole picture, without any text."}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []interface{}{map[string]interface{}{"message": map[string]interface{}{"content": content}}},
+		})
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	cfg := &config.Config{APIURL: server.URL, Model: "test-model", Timeout: 10}
+	currentData := map[string]interface{}{}
+
+	imgPath := filepath.Join(dir, "image.png")
+	assert.NoError(t, os.WriteFile(imgPath, createTestImage(10, 10, 255, 0, 0), 0644))
+	processed, err := NewImageProcessor(cfg).ProcessSingleImage(context.Background(), imgPath, currentData)
+	assert.NoError(t, err)
+	assert.True(t, processed)
+
+	record := currentData["image.png"].(map[string]interface{})
+	assert.Equal(t, "Red square", record["short_name"])
+	assert.Equal(t, "A red square.", record["description"])
+	assert.Equal(t, "A plain red square filling the whole picture, without any text.", record["long_description"])
+
+	// Answers without a long description don't store an empty one
+	content = `{"short_name": "Red square", "description": "A red square."}`
+	otherPath := filepath.Join(dir, "other.png")
+	assert.NoError(t, os.WriteFile(otherPath, createTestImage(10, 10, 255, 0, 0), 0644))
+	_, err = NewImageProcessor(cfg).ProcessSingleImage(context.Background(), otherPath, currentData)
+	assert.NoError(t, err)
+	assert.NotContains(t, currentData["other.png"], "long_description")
+}
+
+func TestIsStale(t *testing.T) {
+	imgPath := filepath.Join(t.TempDir(), "image.png")
+	assert.NoError(t, os.WriteFile(imgPath, []byte("image"), 0644))
+	modified := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	assert.NoError(t, os.Chtimes(imgPath, modified, modified))
+
+	record := map[string]interface{}{}
+	recordSource(record, imgPath)
+	assert.Equal(t, "2024-05-01T10:00:00Z", record["source_mtime"])
+	assert.Equal(t, int64(5), record["source_size"])
+	assert.False(t, isStale(record, imgPath))
+
+	// Only the modification time changed
+	touched := modified.Add(time.Minute)
+	assert.NoError(t, os.Chtimes(imgPath, touched, touched))
+	assert.True(t, isStale(record, imgPath))
+
+	// Only the size changed, e.g. a copy keeping the old modification time
+	assert.NoError(t, os.WriteFile(imgPath, []byte("edited image"), 0644))
+	assert.NoError(t, os.Chtimes(imgPath, modified, modified))
+	assert.True(t, isStale(record, imgPath))
+
+	// Records written before the file was tracked, and missing files, are not stale
+	assert.False(t, isStale(map[string]interface{}{"short_name": "Image"}, imgPath))
+	assert.False(t, isStale(record, filepath.Join(t.TempDir(), "missing.png")))
+}
+
+func TestImageProcessor_ProcessSingleImage_Language(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []interface{}{map[string]interface{}{"message": map[string]interface{}{
+				"content": `{"short_name": "Rotes Quadrat", "description": "Ein rotes Quadrat."}`,
+			}}},
+		})
+	}))
+	defer server.Close()
+
+	imgPath := filepath.Join(t.TempDir(), "image.png")
+	assert.NoError(t, os.WriteFile(imgPath, createTestImage(10, 10, 255, 0, 0), 0644))
+
+	processor := NewImageProcessor(&config.Config{APIURL: server.URL, Model: "test-model", Timeout: 10, OutputLanguage: "German"})
+	currentData := make(map[string]interface{})
+	_, err := processor.ProcessSingleImage(context.Background(), imgPath, currentData)
+	assert.NoError(t, err)
+	assert.Equal(t, "German", currentData["image.png"].(map[string]interface{})["language"])
+}
+
+func TestImageProcessor_ProcessSingleImage_EmbedThumbnails(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []interface{}{map[string]interface{}{"message": map[string]interface{}{
+				"content": `{"short_name": "Red square", "description": "A red square."}`,
+			}}},
+		})
+	}))
+	defer server.Close()
+
+	imgPath := filepath.Join(t.TempDir(), "image.png")
+	assert.NoError(t, os.WriteFile(imgPath, createTestImage(400, 300, 255, 0, 0), 0644))
+
+	cfg := &config.Config{APIURL: server.URL, Model: "test-model", Timeout: 10}
+	currentData := make(map[string]interface{})
+	_, err := NewImageProcessor(cfg).ProcessSingleImage(context.Background(), imgPath, currentData)
+	assert.NoError(t, err)
+	assert.NotContains(t, currentData["image.png"], "thumbnail")
+
+	cfg.EmbedThumbnails = true
+	currentData = make(map[string]interface{})
+	_, err = NewImageProcessor(cfg).ProcessSingleImage(context.Background(), imgPath, currentData)
+	assert.NoError(t, err)
+
+	thumbnail, ok := currentData["image.png"].(map[string]interface{})["thumbnail"].(string)
+	assert.True(t, ok)
+	encoded, ok := strings.CutPrefix(thumbnail, "data:image/jpeg;base64,")
+	assert.True(t, ok)
+	assert.Less(t, len(thumbnail), 4096)
+
+	content, err := base64.StdEncoding.DecodeString(encoded)
+	assert.NoError(t, err)
+	img, _, err := image.Decode(bytes.NewReader(content))
+	assert.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, encoder.ThumbnailSize, 48), img.Bounds())
+}
+
+// TestImageProcessor_ReusesLLMClient tests that one LLM client and its connections serve all images
+func TestImageProcessor_ReusesLLMClient(t *testing.T) {
+	var connections atomic.Int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := map[string]interface{}{
+			"model": "test-model",
+			"choices": []interface{}{
+				map[string]interface{}{
+					"message": map[string]interface{}{
+						"content": `{"short_name": "Test Image", "description": "This is a test image."}`,
+					},
+				},
+			},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			connections.Add(1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	imgPath := filepath.Join(tempDir, "image.png")
+	assert.NoError(t, os.WriteFile(imgPath, createTestImage(10, 10, 255, 0, 0), 0644))
+
+	cfg := &config.Config{APIURL: server.URL, Model: "test-model", Timeout: 10}
+	processor := NewImageProcessor(cfg)
+	client := processor.llmClient()
+
+	for i := 0; i < 3; i++ {
+		currentData := make(map[string]interface{})
+		processed, err := processor.ProcessSingleImage(context.Background(), imgPath, currentData)
+		assert.NoError(t, err)
+		assert.True(t, processed)
+	}
+
+	assert.Same(t, client, processor.llmClient())
+	assert.Equal(t, int32(1), connections.Load(), "requests should share one keep-alive connection")
+
+	// Processors built without the constructor create the client on first use
+	zero := &ImageProcessor{config: cfg}
+	assert.NotNil(t, zero.llmClient())
+	assert.Same(t, zero.llmClient(), zero.llmClient())
+}
+
 // TestImageProcessor_needsProcessing tests the needsProcessing function
 func TestImageProcessor_needsProcessing(t *testing.T) {
 	t.Run("Should need processing if file doesn't exist in data", func(t *testing.T) {
@@ -207,6 +856,96 @@ func TestImageProcessor_validateResponse(t *testing.T) {
 	})
 }
 
+func TestImageProcessor_RetryCap(t *testing.T) {
+	imgPath := filepath.Join(t.TempDir(), "image.png")
+	assert.NoError(t, os.WriteFile(imgPath, createTestImage(10, 10, 255, 0, 0), 0644))
+
+	cfg := &config.Config{MaxRetries: 3}
+	ip := NewImageProcessor(cfg)
+	currentData := map[string]interface{}{}
+
+	// Every run fails temporarily, the index is saved and read back in between
+	runs := 0
+	for range 10 {
+		if !recordNeedsProcessing(currentData, "image.png", imgPath, cfg) {
+			break
+		}
+		runs++
+		ip.handleProcessingError(imgPath, "image.png", currentData, errors.New("connection reset"))
+
+		content, err := json.Marshal(currentData)
+		assert.NoError(t, err)
+		currentData = map[string]interface{}{}
+		assert.NoError(t, json.Unmarshal(content, &currentData))
+
+		record := currentData["image.png"].(map[string]interface{})
+		assert.Equal(t, float64(runs), record["retry_count"])
+	}
+
+	assert.Equal(t, 3, runs)
+	assert.Equal(t, StatusFailed, currentData["image.png"].(map[string]interface{})["short_name"])
+
+	// --retry-failed tries the image again, with a fresh count
+	cfg.RetryFailed = true
+	assert.True(t, recordNeedsProcessing(currentData, "image.png", imgPath, cfg))
+	ip.handleProcessingError(imgPath, "image.png", currentData, errors.New("connection reset"))
+	record := currentData["image.png"].(map[string]interface{})
+	assert.Equal(t, StatusErrorProcessing, record["short_name"])
+	assert.Equal(t, int64(1), record["retry_count"])
+}
+
+// TestImageProcessor_ProcessSingleImage_Cancelled tests that an interrupted request leaves the
+// record and its retry count alone
+func TestImageProcessor_ProcessSingleImage_Cancelled(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	imgPath := filepath.Join(t.TempDir(), "image.png")
+	assert.NoError(t, os.WriteFile(imgPath, createTestImage(10, 10, 255, 0, 0), 0644))
+
+	processor := NewImageProcessor(&config.Config{APIURL: server.URL, Model: "test-model", Timeout: 10, MaxRetries: 3})
+	currentData := map[string]interface{}{
+		"image.png": map[string]interface{}{
+			"short_name":  StatusErrorProcessing,
+			"description": "connection reset",
+			"retry_count": int64(1),
+		},
+	}
+
+	for range 3 {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		processed, err := processor.ProcessSingleImage(ctx, imgPath, currentData)
+		cancel()
+		assert.Error(t, err)
+		assert.False(t, processed)
+	}
+
+	record := currentData["image.png"].(map[string]interface{})
+	assert.Equal(t, StatusErrorProcessing, record["short_name"])
+	assert.Equal(t, int64(1), record["retry_count"])
+	assert.True(t, recordNeedsProcessing(currentData, "image.png", imgPath, processor.config))
+}
+
+func TestImageProcessor_RetryCap_Disabled(t *testing.T) {
+	ip := NewImageProcessor(&config.Config{})
+	currentData := map[string]interface{}{}
+
+	for range 5 {
+		ip.handleProcessingError("/test/image.png", "image.png", currentData, errors.New("connection reset"))
+	}
+
+	record := currentData["image.png"].(map[string]interface{})
+	assert.Equal(t, StatusErrorProcessing, record["short_name"])
+	assert.Equal(t, int64(5), record["retry_count"])
+}
+
 // TestImageProcessor_handleProcessingError tests the handleProcessingError function
 func TestImageProcessor_handleProcessingError(t *testing.T) {
 	t.Run("Should properly handle processing error", func(t *testing.T) {
@@ -277,9 +1016,10 @@ The JSON must contain two keys:
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
-		response, err := processor.TestSingleImage(ctx, testImagePath)
+		response, model, err := processor.TestSingleImage(ctx, testImagePath)
 		assert.NoError(t, err)
 		assert.NotNil(t, response)
+		assert.Equal(t, "test-model", model)
 		assert.Equal(t, "Test Image", response.ShortName)
 		assert.Equal(t, "This is a test image.", response.Description)
 	})
@@ -288,7 +1028,7 @@ The JSON must contain two keys:
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
-		response, err := processor.TestSingleImage(ctx, "/non/existent/path/image.png")
+		response, _, err := processor.TestSingleImage(ctx, "/non/existent/path/image.png")
 		assert.Error(t, err)
 		assert.Nil(t, response)
 		assert.Contains(t, err.Error(), "file not found")
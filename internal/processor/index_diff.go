@@ -0,0 +1,96 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+)
+
+// diffFields are the record fields compared by DiffIndexes
+var diffFields = []string{"short_name", "description"}
+
+// IndexDiff lists the records added, removed and changed between two indexes
+type IndexDiff struct {
+	Added     []DiffRecord   `json:"added"`
+	Removed   []DiffRecord   `json:"removed"`
+	Changed   []RecordChange `json:"changed"`
+	Unchanged int            `json:"unchanged"`
+}
+
+// DiffRecord is a record present in only one of the indexes
+type DiffRecord struct {
+	Key       string `json:"key"`
+	ShortName string `json:"short_name,omitempty"`
+}
+
+// RecordChange lists the fields that differ for a record present in both indexes
+type RecordChange struct {
+	Key    string        `json:"key"`
+	Fields []FieldChange `json:"fields"`
+}
+
+// FieldChange is the old and new value of a field of a changed record
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// LoadIndexFile reads an index.json file. Unlike FileScanner.LoadExistingData it fails on
+// missing and unreadable files.
+func LoadIndexFile(path string) (map[string]interface{}, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal(content, &data); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return data, nil
+}
+
+// DiffIndexes compares the short_name and description of the records of two indexes, e.g. of
+// two runs with different prompts or models. Records are matched by key and listed in key order.
+func DiffIndexes(oldData, newData map[string]interface{}) IndexDiff {
+	diff := IndexDiff{Added: []DiffRecord{}, Removed: []DiffRecord{}, Changed: []RecordChange{}}
+
+	for _, key := range slices.Sorted(maps.Keys(oldData)) {
+		if _, ok := newData[key]; !ok {
+			diff.Removed = append(diff.Removed, DiffRecord{Key: key, ShortName: diffField(oldData[key], "short_name")})
+		}
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(newData)) {
+		oldRecord, ok := oldData[key]
+		if !ok {
+			diff.Added = append(diff.Added, DiffRecord{Key: key, ShortName: diffField(newData[key], "short_name")})
+			continue
+		}
+
+		change := RecordChange{Key: key}
+		for _, field := range diffFields {
+			oldValue, newValue := diffField(oldRecord, field), diffField(newData[key], field)
+			if oldValue != newValue {
+				change.Fields = append(change.Fields, FieldChange{Field: field, Old: oldValue, New: newValue})
+			}
+		}
+		if len(change.Fields) > 0 {
+			diff.Changed = append(diff.Changed, change)
+		} else {
+			diff.Unchanged++
+		}
+	}
+
+	return diff
+}
+
+// diffField returns a string field of a record, empty when the record or the field is missing
+func diffField(record interface{}, field string) string {
+	recordMap, _ := record.(map[string]interface{})
+	value, _ := recordMap[field].(string)
+	return value
+}
@@ -0,0 +1,57 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffIndexes(t *testing.T) {
+	oldData := map[string]interface{}{
+		"same.png":    map[string]interface{}{"short_name": "Same", "description": "Unchanged.", "update_date": "2024-01-01T00:00:00Z"},
+		"renamed.png": map[string]interface{}{"short_name": "Old name", "description": "Kept."},
+		"both.png":    map[string]interface{}{"short_name": "Old", "description": "Old description."},
+		"gone.png":    map[string]interface{}{"short_name": "Gone"},
+		"broken.png":  "not a record",
+	}
+	newData := map[string]interface{}{
+		"same.png":    map[string]interface{}{"short_name": "Same", "description": "Unchanged.", "update_date": "2024-06-01T00:00:00Z"},
+		"renamed.png": map[string]interface{}{"short_name": "New name", "description": "Kept."},
+		"both.png":    map[string]interface{}{"short_name": "New", "description": "New description."},
+		"new.png":     map[string]interface{}{"short_name": "New image"},
+		"broken.png":  map[string]interface{}{"short_name": "Fixed"},
+	}
+
+	diff := DiffIndexes(oldData, newData)
+
+	assert.Equal(t, []DiffRecord{{Key: "new.png", ShortName: "New image"}}, diff.Added)
+	assert.Equal(t, []DiffRecord{{Key: "gone.png", ShortName: "Gone"}}, diff.Removed)
+	assert.Equal(t, []RecordChange{
+		{Key: "both.png", Fields: []FieldChange{
+			{Field: "short_name", Old: "Old", New: "New"},
+			{Field: "description", Old: "Old description.", New: "New description."},
+		}},
+		{Key: "broken.png", Fields: []FieldChange{{Field: "short_name", Old: "", New: "Fixed"}}},
+		{Key: "renamed.png", Fields: []FieldChange{{Field: "short_name", Old: "Old name", New: "New name"}}},
+	}, diff.Changed)
+	// Fields other than short_name and description are ignored
+	assert.Equal(t, 1, diff.Unchanged)
+
+	empty := DiffIndexes(map[string]interface{}{}, map[string]interface{}{})
+	assert.Empty(t, empty.Added)
+	assert.Empty(t, empty.Changed)
+}
+
+func TestLoadIndexFile(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := LoadIndexFile(filepath.Join(dir, "missing.json"))
+	assert.Error(t, err)
+
+	invalid := filepath.Join(dir, "invalid.json")
+	assert.NoError(t, os.WriteFile(invalid, []byte("{"), 0644))
+	_, err = LoadIndexFile(invalid)
+	assert.ErrorContains(t, err, "failed to parse")
+}
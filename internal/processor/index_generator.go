@@ -1,14 +1,17 @@
 package processor
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
-	"os"
+	"maps"
+	"net/url"
 	"path/filepath"
 	"sort"
 	"strings"
 
 	"kbase-catalog/internal/config"
+	"kbase-catalog/internal/utils"
 )
 
 type IndexGenerator struct {
@@ -21,13 +24,15 @@ func NewIndexGenerator(cfg *config.Config) *IndexGenerator {
 	}
 }
 
+// SaveIndexJson writes the catalog index. The file is replaced atomically, so an interrupted
+// write leaves the previous index intact instead of a truncated one.
 func (ig *IndexGenerator) SaveIndexJson(indexJsonPath string, data map[string]interface{}) error {
 	content, err := json.MarshalIndent(data, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal JSON: %w", err)
 	}
 
-	err = os.WriteFile(indexJsonPath, content, 0644)
+	err = utils.WriteFileAtomic(indexJsonPath, content, 0644)
 	if err != nil {
 		return fmt.Errorf("failed to write index.json: %w", err)
 	}
@@ -60,12 +65,12 @@ func (ig *IndexGenerator) GenerateCatalogIndexAsMarkdown(mdPath string, data map
 				description = desc
 			}
 
-			lines = append(lines, fmt.Sprintf("| [%s](%s) | %s |", shortName, key, description))
+			lines = append(lines, fmt.Sprintf("| [%s](%s) | %s |", escapeTableCell(escapeLinkLabel(shortName)), escapeLinkTarget(key), escapeTableCell(description)))
 		}
 	}
 
 	content := strings.Join(lines, "\n")
-	err := os.WriteFile(mdPath, []byte(content), 0644)
+	err := utils.WriteFileAtomic(mdPath, []byte(content), 0644)
 	if err != nil {
 		return fmt.Errorf("failed to write index.md: %w", err)
 	}
@@ -73,17 +78,99 @@ func (ig *IndexGenerator) GenerateCatalogIndexAsMarkdown(mdPath string, data map
 	return nil
 }
 
+// GenerateCatalogIndexAsJSONL writes the catalog index as JSON Lines: one record per line,
+// sorted by key, with the key added as "filename". Every line is a complete JSON object.
+func (ig *IndexGenerator) GenerateCatalogIndexAsJSONL(jsonlPath string, data map[string]interface{}) error {
+	var content bytes.Buffer
+	if err := writeJSONLRecords(&content, "", data); err != nil {
+		return err
+	}
+
+	if err := utils.WriteFileAtomic(jsonlPath, content.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write index.jsonl: %w", err)
+	}
+
+	return nil
+}
+
+// writeJSONLRecords appends the records of a catalog index to content, one JSON object per
+// line. A non-empty catalog is added to each record as "catalog".
+func writeJSONLRecords(content *bytes.Buffer, catalog string, data map[string]interface{}) error {
+	var sortedKeys []string
+	for key := range data {
+		sortedKeys = append(sortedKeys, key)
+	}
+	sort.Strings(sortedKeys)
+
+	for _, key := range sortedKeys {
+		infoMap, ok := data[key].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		record := maps.Clone(infoMap)
+		record["filename"] = key
+		if catalog != "" {
+			record["catalog"] = catalog
+		}
+
+		// Marshal escapes newlines inside strings, so a record never spans several lines
+		line, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("failed to marshal record %s: %w", key, err)
+		}
+		content.Write(line)
+		content.WriteByte('\n')
+	}
+
+	return nil
+}
+
+// tableCellReplacer keeps text on a single table row: pipes would start a new column and
+// newlines end the row
+var tableCellReplacer = strings.NewReplacer("|", `\|`, "\r\n", "<br>", "\n", "<br>", "\r", "<br>")
+
+// linkLabelReplacer escapes the characters with a meaning inside a link label
+var linkLabelReplacer = strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`, "`", "\\`", "*", `\*`, "_", `\_`, "<", `\<`)
+
+// escapeTableCell makes text safe to place in a cell of a markdown table
+func escapeTableCell(text string) string {
+	return tableCellReplacer.Replace(text)
+}
+
+// escapeLinkLabel makes text safe to use as the label of a markdown link, on a single line
+func escapeLinkLabel(text string) string {
+	return linkLabelReplacer.Replace(strings.Join(strings.Fields(text), " "))
+}
+
+// escapeLinkTarget escapes each segment of a slash separated relative path for a markdown link,
+// so spaces, parentheses and a leading # don't end or redirect the link
+func escapeLinkTarget(target string) string {
+	segments := strings.Split(target, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
 func (ig *IndexGenerator) GenerateGlobalMarkdownIndex(rootPath string, catalogData map[string]interface{}) error {
-	rootMdPath := filepath.Join(rootPath, "index.md")
+	rootMdPath := filepath.Join(rootPath, ig.config.GetIndexMDName())
 
 	lines := []string{}
 	lines = append(lines, "# Directory List")
-	for k, _ := range catalogData {
-		lines = append(lines, fmt.Sprintf("- [%s](%s)", k, k))
+	for k, v := range catalogData {
+		// Catalogs with metadata are listed by their title
+		label := k
+		if info, ok := v.(map[string]interface{}); ok {
+			if title, ok := info["title"].(string); ok && title != "" {
+				label = title
+			}
+		}
+		lines = append(lines, fmt.Sprintf("- [%s](%s)", escapeLinkLabel(label), escapeLinkTarget(k)))
 	}
 
 	content := strings.Join(lines, "\n")
-	if err := os.WriteFile(rootMdPath, []byte(content), 0644); err != nil {
+	if err := utils.WriteFileAtomic(rootMdPath, []byte(content), 0644); err != nil {
 		return fmt.Errorf("Error writing root index.md: %v\n", err)
 	}
 
@@ -92,17 +179,42 @@ func (ig *IndexGenerator) GenerateGlobalMarkdownIndex(rootPath string, catalogDa
 
 // GenerateGlobalJsonIndex creates a global index of all catalogs with their metadata
 func (ig *IndexGenerator) GenerateGlobalJsonIndex(rootPath string, catalogData map[string]interface{}) error {
-	globalIndexPath := filepath.Join(rootPath, "index.json")
+	globalIndexPath := filepath.Join(rootPath, ig.config.GetIndexJSONName())
 
 	content, err := json.MarshalIndent(catalogData, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal global index JSON: %w", err)
 	}
 
-	err = os.WriteFile(globalIndexPath, content, 0644)
+	err = utils.WriteFileAtomic(globalIndexPath, content, 0644)
 	if err != nil {
 		return fmt.Errorf("failed to write global index.json: %w", err)
 	}
 
 	return nil
 }
+
+// GenerateGlobalJSONLIndex writes the records of every catalog to the root index.jsonl, one
+// per line with its catalog and filename, sorted by catalog and key
+func (ig *IndexGenerator) GenerateGlobalJSONLIndex(rootPath string, catalogs map[string]map[string]interface{}) error {
+	globalIndexPath := filepath.Join(rootPath, ig.config.GetIndexJSONLName())
+
+	var catalogNames []string
+	for catalogName := range catalogs {
+		catalogNames = append(catalogNames, catalogName)
+	}
+	sort.Strings(catalogNames)
+
+	var content bytes.Buffer
+	for _, catalogName := range catalogNames {
+		if err := writeJSONLRecords(&content, catalogName, catalogs[catalogName]); err != nil {
+			return err
+		}
+	}
+
+	if err := utils.WriteFileAtomic(globalIndexPath, content.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write global index.jsonl: %w", err)
+	}
+
+	return nil
+}
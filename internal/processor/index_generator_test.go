@@ -0,0 +1,176 @@
+package processor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"kbase-catalog/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// tableColumns counts the columns of a markdown table row, ignoring escaped pipes
+func tableColumns(row string) int {
+	row = strings.ReplaceAll(row, `\|`, "")
+	return strings.Count(row, "|") - 1
+}
+
+func TestIndexGenerator_GenerateCatalogIndexAsMarkdown(t *testing.T) {
+	mdPath := filepath.Join(t.TempDir(), "index.md")
+	data := map[string]interface{}{
+		"b.png": map[string]interface{}{
+			"short_name":  "Pipes | and [brackets]",
+			"description": "First line | with a pipe\nsecond line",
+		},
+		"a (1).png": map[string]interface{}{
+			"short_name":  "Plain",
+			"description": "A plain image.",
+		},
+		"#tag.png": map[string]interface{}{
+			"short_name":  "Hash",
+			"description": "Windows\r\nline end",
+		},
+	}
+
+	ig := NewIndexGenerator(config.GetDefaultConfig())
+	assert.NoError(t, ig.GenerateCatalogIndexAsMarkdown(mdPath, data))
+
+	content, err := os.ReadFile(mdPath)
+	assert.NoError(t, err)
+	lines := strings.Split(string(content), "\n")
+
+	// Header, separator and one row per image, still sorted by key
+	if assert.Len(t, lines, 6) {
+		assert.Equal(t, []string{
+			"# Image Catalog",
+			"| Image | Description |",
+			"|---|---|",
+			"| [Hash](%23tag.png) | Windows<br>line end |",
+			"| [Plain](a%20%281%29.png) | A plain image. |",
+			`| [Pipes \| and \[brackets\]](b.png) | First line \| with a pipe<br>second line |`,
+		}, lines)
+	}
+	for _, row := range lines[1:] {
+		assert.Equal(t, 2, tableColumns(row), row)
+	}
+}
+
+func TestIndexGenerator_GenerateGlobalMarkdownIndex(t *testing.T) {
+	rootPath := t.TempDir()
+	catalogData := map[string]interface{}{
+		"my trip": map[string]interface{}{"title": "Trip [2024]\nSummer"},
+	}
+
+	ig := NewIndexGenerator(config.GetDefaultConfig())
+	assert.NoError(t, ig.GenerateGlobalMarkdownIndex(rootPath, catalogData))
+
+	content, err := os.ReadFile(filepath.Join(rootPath, "index.md"))
+	assert.NoError(t, err)
+	assert.Equal(t, "# Directory List\n- [Trip \\[2024\\] Summer](my%20trip)", string(content))
+}
+
+func TestIndexGenerator_SaveIndexJson_InterruptedWrite(t *testing.T) {
+	dir := t.TempDir()
+	indexPath := filepath.Join(dir, "index.json")
+	cfg := &config.Config{SupportedExtensions: []string{".png"}}
+	ig := NewIndexGenerator(cfg)
+	fs := NewFileScanner(cfg)
+
+	assert.NoError(t, ig.SaveIndexJson(indexPath, map[string]interface{}{"a.png": map[string]interface{}{"short_name": "A"}}))
+
+	// A write killed before its rename leaves a truncated temporary file behind
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, ".index.json.123.tmp"), []byte(`{"a.png": {"short_na`), 0644))
+
+	data, err := fs.LoadExistingData(indexPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "A", data["a.png"].(map[string]interface{})["short_name"])
+
+	images, err := fs.FindImages(dir, false)
+	assert.NoError(t, err)
+	assert.Empty(t, images)
+}
+
+func TestEscapeLinkTarget(t *testing.T) {
+	assert.Equal(t, "2024/summer/beach.png", escapeLinkTarget("2024/summer/beach.png"))
+	assert.Equal(t, "my%20photos/a%7Cb.png", escapeLinkTarget("my photos/a|b.png"))
+}
+
+// readJSONLines unmarshals every line of a JSON Lines file on its own
+func readJSONLines(t *testing.T, path string) []map[string]interface{} {
+	file, err := os.Open(path)
+	assert.NoError(t, err)
+	defer file.Close()
+
+	var records []map[string]interface{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record map[string]interface{}
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &record), scanner.Text())
+		records = append(records, record)
+	}
+	assert.NoError(t, scanner.Err())
+	return records
+}
+
+func TestIndexGenerator_GenerateCatalogIndexAsJSONL(t *testing.T) {
+	jsonlPath := filepath.Join(t.TempDir(), "index.jsonl")
+	data := map[string]interface{}{
+		"b.png": map[string]interface{}{
+			"short_name":  "Multi\nline",
+			"description": "First line\nsecond line\u2028third",
+			"tags":        []interface{}{"a", "b"},
+		},
+		"2024/a.png": map[string]interface{}{"short_name": "Nested"},
+		"broken":     "not a record",
+	}
+
+	ig := NewIndexGenerator(config.GetDefaultConfig())
+	assert.NoError(t, ig.GenerateCatalogIndexAsJSONL(jsonlPath, data))
+
+	records := readJSONLines(t, jsonlPath)
+	assert.Len(t, records, 2)
+	assert.Equal(t, "2024/a.png", records[0]["filename"])
+	assert.Equal(t, "b.png", records[1]["filename"])
+	assert.Equal(t, "First line\nsecond line\u2028third", records[1]["description"])
+	assert.Equal(t, []interface{}{"a", "b"}, records[1]["tags"])
+	assert.NotContains(t, records[0], "catalog")
+
+	// The index data is left untouched
+	assert.NotContains(t, data["b.png"], "filename")
+}
+
+func TestCatalogProcessor_ExportJSONL(t *testing.T) {
+	archiveDir := t.TempDir()
+	for catalog, index := range map[string]string{
+		"holiday": `{"b.png": {"short_name": "B"}, "a.png": {"short_name": "A"}}`,
+		"plain":   `{"c.png": {"short_name": "C"}}`,
+	} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(archiveDir, catalog), 0755))
+		assert.NoError(t, os.WriteFile(filepath.Join(archiveDir, catalog, "index.json"), []byte(index), 0644))
+	}
+
+	cfg := config.GetDefaultConfig()
+	ctx := context.Background()
+
+	t.Run("Disabled", func(t *testing.T) {
+		assert.NoError(t, NewCatalogProcessor(cfg, archiveDir).RebuildRootIndex(ctx))
+		assert.NoFileExists(t, filepath.Join(archiveDir, "index.jsonl"))
+	})
+
+	t.Run("Root aggregate", func(t *testing.T) {
+		cfg.ExportJSONL = true
+		assert.NoError(t, NewCatalogProcessor(cfg, archiveDir).RebuildRootIndex(ctx))
+
+		records := readJSONLines(t, filepath.Join(archiveDir, "index.jsonl"))
+		var names []string
+		for _, record := range records {
+			names = append(names, record["catalog"].(string)+"/"+record["filename"].(string))
+		}
+		assert.Equal(t, []string{"holiday/a.png", "holiday/b.png", "plain/c.png"}, names)
+	})
+}
@@ -0,0 +1,272 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"kbase-catalog/internal/utils"
+)
+
+// Problems reported by VerifyArchive
+const (
+	// IssueInvalidIndex is a catalog index.json that isn't a JSON object
+	IssueInvalidIndex = "invalid_index"
+	// IssueMalformedRecord is a catalog index entry that isn't a record object
+	IssueMalformedRecord = "malformed_record"
+	// IssueMissingImage is a record whose image file no longer exists
+	IssueMissingImage = "missing_image"
+	// IssueInvalidRootIndex is a root index.json that is missing or isn't a JSON object
+	IssueInvalidRootIndex = "invalid_root_index"
+	// IssueRootEntryMissing is a catalog with images but without a root index entry
+	IssueRootEntryMissing = "root_entry_missing"
+	// IssueRootEntryStale is a root index entry of a catalog that has no index
+	IssueRootEntryStale = "root_entry_stale"
+	// IssueRootEntryMismatch is a root index entry whose image count or last update doesn't
+	// match the catalog index
+	IssueRootEntryMismatch = "root_entry_mismatch"
+)
+
+// corruptIndexSuffix is appended to an unreadable index.json set aside by VerifyArchive
+const corruptIndexSuffix = ".corrupt"
+
+// VerifyIssue is a problem found in the indexes of the archive
+type VerifyIssue struct {
+	Catalog string
+	// Record is the index key of the record concerned, empty for catalog level problems
+	Record  string
+	Problem string
+	Detail  string
+	// Fixed is set when the problem was repaired
+	Fixed bool
+}
+
+// VerifyReport lists the problems found in the indexes of the archive
+type VerifyReport struct {
+	Catalogs int
+	Records  int
+	Issues   []VerifyIssue
+}
+
+// Unfixed returns the number of problems left unrepaired
+func (r VerifyReport) Unfixed() int {
+	count := 0
+	for _, issue := range r.Issues {
+		if !issue.Fixed {
+			count++
+		}
+	}
+	return count
+}
+
+// catalogIndexState is what VerifyArchive learned about the index of a catalog
+type catalogIndexState struct {
+	// valid is set for a readable index, invalid for an index that can't be read
+	valid      bool
+	invalid    bool
+	records    int
+	lastUpdate time.Time
+}
+
+// VerifyArchive checks that every catalog index is valid JSON whose records reference existing
+// images, and that the root index matches the catalog indexes. With fix, records of missing
+// images and malformed records are dropped, unreadable indexes are renamed with a .corrupt
+// suffix so the catalog is described again, and the root index is rebuilt.
+func (cp *CatalogProcessor) VerifyArchive(ctx context.Context, fix bool) (VerifyReport, error) {
+	report := VerifyReport{}
+
+	entries, err := os.ReadDir(cp.archiveDir)
+	if err != nil {
+		return report, fmt.Errorf("failed to read catalog directories: %w", err)
+	}
+
+	catalogs := make(map[string]catalogIndexState)
+	changed := false
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		catalogDir := filepath.Join(cp.archiveDir, entry.Name())
+		if !entry.IsDir() || cp.fs.ShouldExclude(catalogDir) {
+			continue
+		}
+
+		state, issues, err := cp.verifyCatalog(catalogDir, fix)
+		if err != nil {
+			return report, fmt.Errorf("failed to verify catalog %s: %w", entry.Name(), err)
+		}
+		if len(issues) > 0 && fix {
+			changed = true
+		}
+		if state.valid {
+			report.Catalogs++
+			report.Records += state.records
+		}
+		catalogs[entry.Name()] = state
+		report.Issues = append(report.Issues, issues...)
+	}
+
+	rootIssues := cp.verifyRootIndex(catalogs)
+	if fix && (changed || len(rootIssues) > 0) {
+		if err := cp.RebuildRootIndexFull(ctx); err != nil {
+			return report, err
+		}
+		for i := range rootIssues {
+			rootIssues[i].Fixed = true
+		}
+	}
+	report.Issues = append(report.Issues, rootIssues...)
+
+	return report, nil
+}
+
+// verifyCatalog checks the index of a single catalog, repairing it with fix
+func (cp *CatalogProcessor) verifyCatalog(catalogDir string, fix bool) (catalogIndexState, []VerifyIssue, error) {
+	catalogName := filepath.Base(catalogDir)
+	indexJsonPath := filepath.Join(cp.dp.indexDir(catalogDir), cp.config.GetIndexJSONName())
+	if !utils.IsFileExists(indexJsonPath) {
+		return catalogIndexState{}, nil, nil
+	}
+
+	content, err := os.ReadFile(indexJsonPath)
+	if err != nil {
+		return catalogIndexState{}, nil, err
+	}
+
+	// LoadExistingData treats an unreadable index as empty, which would hide the problem
+	var data map[string]interface{}
+	if err := json.Unmarshal(content, &data); err != nil || data == nil {
+		issue := VerifyIssue{Catalog: catalogName, Problem: IssueInvalidIndex, Detail: "index.json is not a JSON object"}
+		if err != nil {
+			issue.Detail = err.Error()
+		}
+		if fix {
+			if err := os.Rename(indexJsonPath, indexJsonPath+corruptIndexSuffix); err != nil {
+				return catalogIndexState{}, nil, err
+			}
+			issue.Fixed = true
+		}
+		return catalogIndexState{invalid: true}, []VerifyIssue{issue}, nil
+	}
+
+	var issues []VerifyIssue
+	var lastUpdate time.Time
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		record, ok := data[key].(map[string]interface{})
+		if !ok {
+			issues = append(issues, VerifyIssue{Catalog: catalogName, Record: key, Problem: IssueMalformedRecord, Detail: "record is not an object"})
+			delete(data, key)
+			continue
+		}
+		if !utils.IsFileExists(filepath.Join(catalogDir, filepath.FromSlash(key))) {
+			issues = append(issues, VerifyIssue{Catalog: catalogName, Record: key, Problem: IssueMissingImage, Detail: "image file not found"})
+			delete(data, key)
+			continue
+		}
+		if updateDate, ok := record["update_date"].(string); ok {
+			if updated, err := time.Parse(time.RFC3339, updateDate); err == nil && updated.After(lastUpdate) {
+				lastUpdate = updated
+			}
+		}
+	}
+
+	if fix && len(issues) > 0 {
+		if err := cp.writeCatalogIndexes(cp.dp.indexDir(catalogDir), data); err != nil {
+			return catalogIndexState{}, nil, err
+		}
+		for i := range issues {
+			issues[i].Fixed = true
+		}
+	}
+
+	return catalogIndexState{valid: true, records: len(data), lastUpdate: lastUpdate}, issues, nil
+}
+
+// verifyRootIndex compares the root index with the state of the catalog indexes. Catalogs
+// without images may be missing from the root index or listed with a null entry.
+func (cp *CatalogProcessor) verifyRootIndex(catalogs map[string]catalogIndexState) []VerifyIssue {
+	hasImages := false
+	for _, state := range catalogs {
+		hasImages = hasImages || state.records > 0
+	}
+
+	rootIndexPath := filepath.Join(cp.IndexDir(), cp.config.GetIndexJSONName())
+	if !utils.IsFileExists(rootIndexPath) {
+		if !hasImages {
+			return nil
+		}
+		return []VerifyIssue{{Problem: IssueInvalidRootIndex, Detail: "index.json is missing"}}
+	}
+
+	content, err := os.ReadFile(rootIndexPath)
+	var rootIndex map[string]interface{}
+	if err == nil {
+		err = json.Unmarshal(content, &rootIndex)
+	}
+	if err != nil {
+		return []VerifyIssue{{Problem: IssueInvalidRootIndex, Detail: err.Error()}}
+	}
+
+	var issues []VerifyIssue
+	names := make([]string, 0, len(catalogs)+len(rootIndex))
+	for name := range catalogs {
+		names = append(names, name)
+	}
+	for name := range rootIndex {
+		if _, ok := catalogs[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		state := catalogs[name]
+		// A catalog with an unreadable index is already reported
+		if state.invalid {
+			continue
+		}
+
+		entry, listed := rootIndex[name].(map[string]interface{})
+		if !listed {
+			if state.records > 0 {
+				issues = append(issues, VerifyIssue{Catalog: name, Problem: IssueRootEntryMissing, Detail: fmt.Sprintf("catalog has %d images", state.records)})
+			}
+			continue
+		}
+
+		count, _ := entry["image_count"].(float64)
+		if !state.valid {
+			if count > 0 {
+				issues = append(issues, VerifyIssue{Catalog: name, Problem: IssueRootEntryStale, Detail: "catalog has no index"})
+			}
+			continue
+		}
+		if int(count) != state.records {
+			issues = append(issues, VerifyIssue{Catalog: name, Problem: IssueRootEntryMismatch,
+				Detail: fmt.Sprintf("image_count is %d, the catalog has %d images", int(count), state.records)})
+			continue
+		}
+
+		lastUpdateValue, _ := entry["last_update"].(string)
+		lastUpdate, err := time.Parse(time.RFC3339, lastUpdateValue)
+		if err != nil {
+			issues = append(issues, VerifyIssue{Catalog: name, Problem: IssueRootEntryMismatch, Detail: fmt.Sprintf("invalid last_update %q", lastUpdateValue)})
+		} else if lastUpdate.Before(state.lastUpdate.Truncate(time.Second)) {
+			issues = append(issues, VerifyIssue{Catalog: name, Problem: IssueRootEntryMismatch,
+				Detail: fmt.Sprintf("last_update %s is older than the latest image update %s", lastUpdateValue, state.lastUpdate.Format(time.RFC3339))})
+		}
+	}
+
+	return issues
+}
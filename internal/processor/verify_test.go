@@ -0,0 +1,110 @@
+package processor
+
+import (
+	"context"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"kbase-catalog/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeVerifyArchive creates an archive with two catalogs whose indexes match their images,
+// and its root index
+func writeVerifyArchive(t *testing.T) (*CatalogProcessor, string) {
+	archiveDir := t.TempDir()
+	indexes := map[string]string{
+		"holiday": `{"a.png": {"short_name": "A", "update_date": "2024-01-01T00:00:00Z"}, "b.png": {"short_name": "B"}}`,
+		"shapes":  `{"red.png": {"short_name": "Red", "update_date": "2024-02-01T00:00:00Z"}}`,
+	}
+	for catalog, index := range indexes {
+		assert.NoError(t, os.MkdirAll(filepath.Join(archiveDir, catalog), 0755))
+		assert.NoError(t, os.WriteFile(filepath.Join(archiveDir, catalog, "index.json"), []byte(index), 0644))
+	}
+	for _, image := range []string{"holiday/a.png", "holiday/b.png", "shapes/red.png"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(archiveDir, filepath.FromSlash(image)), []byte("image"), 0644))
+	}
+
+	cp := NewCatalogProcessor(config.GetDefaultConfig(), archiveDir)
+	assert.NoError(t, cp.RebuildRootIndexFull(context.Background()))
+	return cp, archiveDir
+}
+
+// issueProblems lists the problems of a report as catalog/record: problem
+func issueProblems(report VerifyReport) []string {
+	var problems []string
+	for _, issue := range report.Issues {
+		location := issue.Catalog
+		if issue.Record != "" {
+			location += "/" + issue.Record
+		}
+		problems = append(problems, location+": "+issue.Problem)
+	}
+	return problems
+}
+
+func TestCatalogProcessor_VerifyArchive(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Healthy archive", func(t *testing.T) {
+		cp, _ := writeVerifyArchive(t)
+
+		report, err := cp.VerifyArchive(ctx, false)
+		assert.NoError(t, err)
+		assert.Empty(t, report.Issues)
+		assert.Equal(t, 2, report.Catalogs)
+		assert.Equal(t, 3, report.Records)
+	})
+
+	t.Run("Corrupt archive", func(t *testing.T) {
+		cp, archiveDir := writeVerifyArchive(t)
+		assert.NoError(t, os.Remove(filepath.Join(archiveDir, "holiday", "b.png")))
+		assert.NoError(t, os.WriteFile(filepath.Join(archiveDir, "shapes", "index.json"), []byte(`{"red.png": {"short_`), 0644))
+		assert.NoError(t, os.MkdirAll(filepath.Join(archiveDir, "new"), 0755))
+		assert.NoError(t, os.WriteFile(filepath.Join(archiveDir, "new", "x.png"), []byte("image"), 0644))
+		assert.NoError(t, os.WriteFile(filepath.Join(archiveDir, "new", "index.json"), []byte(`{"x.png": {"short_name": "X"}, "broken": "text"}`), 0644))
+
+		report, err := cp.VerifyArchive(ctx, false)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			"holiday/b.png: " + IssueMissingImage,
+			"new/broken: " + IssueMalformedRecord,
+			"shapes: " + IssueInvalidIndex,
+			"holiday: " + IssueRootEntryMismatch,
+			"new: " + IssueRootEntryMissing,
+		}, issueProblems(report))
+		assert.Equal(t, len(report.Issues), report.Unfixed())
+
+		// Without --fix nothing is touched
+		assert.FileExists(t, filepath.Join(archiveDir, "shapes", "index.json"))
+
+		report, err = cp.VerifyArchive(ctx, true)
+		assert.NoError(t, err)
+		assert.Len(t, report.Issues, 5)
+		assert.Zero(t, report.Unfixed())
+		assert.FileExists(t, filepath.Join(archiveDir, "shapes", "index.json"+corruptIndexSuffix))
+
+		data, err := cp.fs.LoadExistingData(filepath.Join(archiveDir, "holiday", "index.json"))
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"a.png"}, slices.Sorted(maps.Keys(data)))
+
+		// Once repaired the archive is healthy
+		report, err = cp.VerifyArchive(ctx, false)
+		assert.NoError(t, err)
+		assert.Empty(t, report.Issues)
+		assert.Equal(t, 2, report.Records)
+	})
+
+	t.Run("Root entry of a removed catalog", func(t *testing.T) {
+		cp, archiveDir := writeVerifyArchive(t)
+		assert.NoError(t, os.RemoveAll(filepath.Join(archiveDir, "shapes")))
+
+		report, err := cp.VerifyArchive(ctx, false)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"shapes: " + IssueRootEntryStale}, issueProblems(report))
+	})
+}
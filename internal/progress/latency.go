@@ -0,0 +1,80 @@
+package progress
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Latencies collects the processing durations of the images of a run. It is safe for concurrent use.
+type Latencies struct {
+	mutex     sync.Mutex
+	durations []time.Duration
+}
+
+// NewLatencies creates an empty collector
+func NewLatencies() *Latencies {
+	return &Latencies{}
+}
+
+// Observe records the duration of one image
+func (l *Latencies) Observe(d time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.durations = append(l.durations, d)
+}
+
+// Summary summarizes the durations observed so far
+func (l *Latencies) Summary() LatencySummary {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return Summarize(l.durations)
+}
+
+// LatencySummary describes the distribution of processing durations
+type LatencySummary struct {
+	Count int
+	Min   time.Duration
+	Avg   time.Duration
+	Max   time.Duration
+	P95   time.Duration
+}
+
+// Summarize computes the minimum, average, maximum and 95th percentile of the durations. The
+// percentile uses the nearest rank, so it is always one of the observed durations.
+func Summarize(durations []time.Duration) LatencySummary {
+	if len(durations) == 0 {
+		return LatencySummary{}
+	}
+
+	sorted := slices.Clone(durations)
+	slices.Sort(sorted)
+
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+
+	// Nearest rank: the smallest value with at least 95% of the durations at or below it
+	rank := (len(sorted)*95 + 99) / 100
+
+	return LatencySummary{
+		Count: len(sorted),
+		Min:   sorted[0],
+		Avg:   total / time.Duration(len(sorted)),
+		Max:   sorted[len(sorted)-1],
+		P95:   sorted[rank-1],
+	}
+}
+
+// String formats the summary on a single line
+func (s LatencySummary) String() string {
+	if s.Count == 0 {
+		return "Latency: no images processed"
+	}
+	return fmt.Sprintf("Latency: %d images | min %s | avg %s | max %s | p95 %s", s.Count,
+		s.Min.Round(time.Millisecond), s.Avg.Round(time.Millisecond), s.Max.Round(time.Millisecond), s.P95.Round(time.Millisecond))
+}
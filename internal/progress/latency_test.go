@@ -0,0 +1,56 @@
+package progress
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummarize(t *testing.T) {
+	t.Run("Distribution", func(t *testing.T) {
+		// 1..20 seconds in reverse order
+		var durations []time.Duration
+		for i := 20; i >= 1; i-- {
+			durations = append(durations, time.Duration(i)*time.Second)
+		}
+
+		summary := Summarize(durations)
+		assert.Equal(t, 20, summary.Count)
+		assert.Equal(t, time.Second, summary.Min)
+		assert.Equal(t, 10500*time.Millisecond, summary.Avg)
+		assert.Equal(t, 20*time.Second, summary.Max)
+		assert.Equal(t, 19*time.Second, summary.P95)
+		// The input is left in its order
+		assert.Equal(t, 20*time.Second, durations[0])
+	})
+
+	t.Run("Single duration", func(t *testing.T) {
+		summary := Summarize([]time.Duration{time.Second})
+		assert.Equal(t, LatencySummary{Count: 1, Min: time.Second, Avg: time.Second, Max: time.Second, P95: time.Second}, summary)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		assert.Equal(t, LatencySummary{}, Summarize(nil))
+		assert.Equal(t, "Latency: no images processed", Summarize(nil).String())
+	})
+}
+
+func TestLatencies(t *testing.T) {
+	latencies := NewLatencies()
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			latencies.Observe(time.Duration(i) * time.Second)
+		}()
+	}
+	wg.Wait()
+
+	summary := latencies.Summary()
+	assert.Equal(t, 4, summary.Count)
+	assert.Equal(t, "Latency: 4 images | min 1s | avg 2.5s | max 4s | p95 4s", summary.String())
+}
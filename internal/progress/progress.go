@@ -0,0 +1,159 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultInterval is how often the Reporter prints the progress line
+const DefaultInterval = time.Second
+
+// rateWindow is the number of most recent completions used to estimate the rate
+const rateWindow = 50
+
+// Reporter periodically prints the number of completed images out of the discovered ones,
+// together with the processing rate and the estimated time left. It is safe for concurrent use.
+type Reporter struct {
+	out         io.Writer
+	interval    time.Duration
+	interactive bool
+
+	mutex       sync.Mutex
+	total       int
+	completed   int
+	completions []time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewReporter creates a Reporter writing to out. On an interactive terminal the progress line
+// is redrawn in place, otherwise every update is printed on its own line.
+func NewReporter(out io.Writer, interval time.Duration, interactive bool) *Reporter {
+	return &Reporter{
+		out:         out,
+		interval:    interval,
+		interactive: interactive,
+	}
+}
+
+// IsTerminal reports whether the file is attached to a terminal
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// AddTotal adds n discovered images to the total
+func (r *Reporter) AddTotal(n int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.total += n
+}
+
+// Complete records one finished image
+func (r *Reporter) Complete() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.completed++
+	r.completions = append(r.completions, time.Now())
+	if len(r.completions) > rateWindow {
+		r.completions = r.completions[len(r.completions)-rateWindow:]
+	}
+}
+
+// Start prints the progress every interval until Stop is called
+func (r *Reporter) Start() {
+	r.stop = make(chan struct{})
+	r.done = make(chan struct{})
+
+	go func() {
+		defer close(r.done)
+
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				r.print()
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the periodic output and prints the final progress
+func (r *Reporter) Stop() {
+	if r.stop == nil {
+		return
+	}
+	close(r.stop)
+	<-r.done
+	r.stop = nil
+
+	r.print()
+	if r.interactive {
+		fmt.Fprintln(r.out)
+	}
+}
+
+// print writes the current progress line
+func (r *Reporter) print() {
+	line := r.String()
+	if r.interactive {
+		// Clear the rest of the previous line
+		fmt.Fprintf(r.out, "\r%s\033[K", line)
+	} else {
+		fmt.Fprintln(r.out, line)
+	}
+}
+
+// String formats the current progress
+func (r *Reporter) String() string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	percent := 0.0
+	if r.total > 0 {
+		percent = float64(r.completed) / float64(r.total) * 100
+	}
+
+	line := fmt.Sprintf("Progress: %d/%d images (%.0f%%)", r.completed, r.total, percent)
+
+	rate, eta, ok := Estimate(r.completions, r.total-r.completed)
+	if ok {
+		line += fmt.Sprintf(" | %.2f img/s | ETA %s", rate, eta.Round(time.Second))
+	}
+	return line
+}
+
+// Estimate computes the processing rate in images per second from the completion timestamps
+// and the time needed for the remaining images at that rate. It returns false when there are
+// not enough completions spread over time to estimate anything.
+func Estimate(completions []time.Time, remaining int) (float64, time.Duration, bool) {
+	if len(completions) < 2 {
+		return 0, 0, false
+	}
+
+	elapsed := completions[len(completions)-1].Sub(completions[0])
+	if elapsed <= 0 {
+		return 0, 0, false
+	}
+
+	rate := float64(len(completions)-1) / elapsed.Seconds()
+	if remaining < 0 {
+		remaining = 0
+	}
+	eta := time.Duration(float64(remaining) / rate * float64(time.Second))
+
+	return rate, eta, true
+}
@@ -0,0 +1,71 @@
+package progress
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEstimate(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("Steady rate", func(t *testing.T) {
+		// 5 completions two seconds apart: 4 intervals over 8 seconds
+		var completions []time.Time
+		for i := 0; i < 5; i++ {
+			completions = append(completions, start.Add(time.Duration(i)*2*time.Second))
+		}
+
+		rate, eta, ok := Estimate(completions, 10)
+		assert.True(t, ok)
+		assert.InDelta(t, 0.5, rate, 0.0001)
+		assert.Equal(t, 20*time.Second, eta)
+	})
+
+	t.Run("Nothing left", func(t *testing.T) {
+		completions := []time.Time{start, start.Add(time.Second)}
+
+		rate, eta, ok := Estimate(completions, 0)
+		assert.True(t, ok)
+		assert.InDelta(t, 1.0, rate, 0.0001)
+		assert.Equal(t, time.Duration(0), eta)
+	})
+
+	t.Run("Not enough data", func(t *testing.T) {
+		_, _, ok := Estimate(nil, 10)
+		assert.False(t, ok)
+
+		_, _, ok = Estimate([]time.Time{start}, 10)
+		assert.False(t, ok)
+
+		_, _, ok = Estimate([]time.Time{start, start}, 10)
+		assert.False(t, ok)
+	})
+}
+
+func TestReporter(t *testing.T) {
+	var out bytes.Buffer
+	reporter := NewReporter(&out, time.Hour, false)
+	reporter.AddTotal(4)
+	reporter.AddTotal(6)
+
+	// Completions come from several goroutines in parallel mode
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reporter.Complete()
+		}()
+	}
+	wg.Wait()
+
+	assert.Contains(t, reporter.String(), "Progress: 5/10 images (50%)")
+
+	reporter.Start()
+	reporter.Stop()
+	assert.Contains(t, out.String(), "Progress: 5/10 images (50%)")
+}
@@ -2,7 +2,10 @@ package utils
 
 import (
 	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"sync"
 )
 
 func IsDirectory(path string) bool {
@@ -28,3 +31,61 @@ func IsFileExists(filename string) bool {
 	// If it's a directory, return false since we only want to identify files
 	return !fileInfo.IsDir()
 }
+
+// fileLocks holds a mutex per file written by WriteFileAtomic
+var fileLocks sync.Map
+
+// lockFile serializes the writers of a file within the process and returns the unlock function
+func lockFile(path string) func() {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	mutex, _ := fileLocks.LoadOrStore(path, &sync.Mutex{})
+	mutex.(*sync.Mutex).Lock()
+	return mutex.(*sync.Mutex).Unlock
+}
+
+// WriteFileAtomic writes data to a temporary file next to path and renames it into place, so
+// readers see either the previous or the new content but never a partially written file, even
+// when the process dies while writing. Concurrent writers of the same path are serialized.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	unlock := lockFile(path)
+	defer unlock()
+
+	tmpPath, err := writeTemp(path, data, perm)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return nil
+}
+
+// writeTemp writes data to a new temporary file in the directory of path and returns its name
+func writeTemp(path string, data []byte, perm os.FileMode) (string, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file for %s: %w", path, err)
+	}
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		// Flush to disk before the rename, otherwise a crash may leave an empty file in place
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), perm)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to write temporary file for %s: %w", path, err)
+	}
+
+	return tmp.Name(), nil
+}
@@ -1,8 +1,11 @@
 package utils
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -59,3 +62,59 @@ func TestIsFileExists(t *testing.T) {
 	result = IsFileExists(tempDir)
 	assert.False(t, result)
 }
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "index.json")
+
+	assert.NoError(t, WriteFileAtomic(path, []byte(`{"a.png": {}}`), 0644))
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"a.png": {}}`, string(content))
+
+	t.Run("A crash before the rename keeps the previous content", func(t *testing.T) {
+		tmpPath, err := writeTemp(path, []byte(`{"a.png": {"short_na`), 0644)
+		assert.NoError(t, err)
+		defer os.Remove(tmpPath)
+
+		content, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"a.png": {}}`, string(content))
+	})
+
+	t.Run("The file is replaced without leftovers", func(t *testing.T) {
+		assert.NoError(t, WriteFileAtomic(path, []byte(`{"b.png": {}}`), 0600))
+		content, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"b.png": {}}`, string(content))
+
+		info, err := os.Stat(path)
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+		entries, err := os.ReadDir(dir)
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1)
+	})
+
+	t.Run("Concurrent writers never mix their content", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				assert.NoError(t, WriteFileAtomic(path, []byte(strings.Repeat(fmt.Sprint(i%10), 10000)), 0644))
+			}(i)
+		}
+		wg.Wait()
+
+		content, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Len(t, content, 10000)
+		assert.Equal(t, strings.Repeat(string(content[0]), 10000), string(content))
+	})
+
+	t.Run("Missing directory", func(t *testing.T) {
+		assert.Error(t, WriteFileAtomic(filepath.Join(dir, "missing", "index.json"), []byte("{}"), 0644))
+	})
+}
@@ -0,0 +1,187 @@
+package api
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// DefaultCompressionMinSize is the response size from which compression pays off
+const DefaultCompressionMinSize = 1024
+
+// CompressionMiddleware compresses text and JSON responses of at least minSize bytes with gzip
+// or deflate, depending on the Accept-Encoding header of the request. Requests to paths with
+// one of skipPrefixes, e.g. already compressed images, are passed through untouched.
+func CompressionMiddleware(minSize int, skipPrefixes ...string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+			if encoding == "" || r.Method == http.MethodHead || r.Header.Get("Range") != "" || hasAnyPrefix(r.URL.Path, skipPrefixes) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{ResponseWriter: w, encoding: encoding, minSize: minSize, status: http.StatusOK}
+			next.ServeHTTP(cw, r)
+
+			// Not deferred, on panic the buffered response is dropped in favour of the recovery error
+			if err := cw.Close(); err != nil {
+				slog.Warn("Failed to write compressed response", "path", r.URL.Path, "error", err)
+			}
+		})
+	}
+}
+
+// negotiateEncoding picks the supported encoding accepted by the client, preferring gzip
+func negotiateEncoding(acceptEncoding string) string {
+	accepted := map[string]bool{}
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		// q=0 explicitly refuses an encoding
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if value, err := strconv.ParseFloat(q, 64); err == nil && value == 0 {
+				continue
+			}
+		}
+		accepted[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+
+	switch {
+	case accepted["gzip"] || accepted["*"]:
+		return "gzip"
+	case accepted["deflate"]:
+		return "deflate"
+	default:
+		return ""
+	}
+}
+
+// hasAnyPrefix reports whether path starts with one of the prefixes
+func hasAnyPrefix(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isCompressible reports whether a content type benefits from compression
+func isCompressible(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.TrimSpace(strings.ToLower(mediaType))
+	return strings.HasPrefix(mediaType, "text/") ||
+		mediaType == "application/json" ||
+		mediaType == "application/javascript" ||
+		mediaType == "image/svg+xml"
+}
+
+// compressWriter buffers the start of a response until it knows whether to compress it
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+
+	status  int
+	buf     []byte
+	decided bool
+	writer  io.WriteCloser
+}
+
+// WriteHeader records the status, it is sent once the response is known to be compressed or not
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.decided {
+		return
+	}
+	cw.status = status
+}
+
+// Write buffers the response until minSize bytes are available
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if cw.decided {
+		if cw.writer != nil {
+			return cw.writer.Write(p)
+		}
+		return cw.ResponseWriter.Write(p)
+	}
+
+	cw.buf = append(cw.buf, p...)
+	if len(cw.buf) >= cw.minSize {
+		if err := cw.decide(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush sends the buffered data to the client
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		cw.decide()
+	}
+	if flusher, ok := cw.writer.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Close finishes the response, writing a short one uncompressed
+func (cw *compressWriter) Close() error {
+	if !cw.decided {
+		if err := cw.decide(); err != nil {
+			return err
+		}
+	}
+	if cw.writer != nil {
+		return cw.writer.Close()
+	}
+	return nil
+}
+
+// decide sends the headers and the buffered data, compressed when worthwhile
+func (cw *compressWriter) decide() error {
+	cw.decided = true
+
+	header := cw.Header()
+	contentType := header.Get("Content-Type")
+	if contentType == "" && len(cw.buf) > 0 {
+		contentType = http.DetectContentType(cw.buf)
+	}
+
+	compress := len(cw.buf) >= cw.minSize &&
+		cw.status != http.StatusNoContent && cw.status != http.StatusNotModified &&
+		header.Get("Content-Encoding") == "" &&
+		isCompressible(contentType)
+
+	if compress {
+		header.Set("Content-Encoding", cw.encoding)
+		header.Add("Vary", "Accept-Encoding")
+		header.Del("Content-Length")
+
+		if cw.encoding == "gzip" {
+			cw.writer = gzip.NewWriter(cw.ResponseWriter)
+		} else {
+			cw.writer, _ = flate.NewWriter(cw.ResponseWriter, flate.DefaultCompression)
+		}
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if cw.writer != nil {
+		_, err := cw.writer.Write(buf)
+		return err
+	}
+	_, err := cw.ResponseWriter.Write(buf)
+	return err
+}
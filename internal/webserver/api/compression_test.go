@@ -0,0 +1,112 @@
+package api
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompressionMiddleware(t *testing.T) {
+	catalogs := []map[string]interface{}{}
+	for i := 0; i < 100; i++ {
+		catalogs = append(catalogs, map[string]interface{}{"name": "catalog", "image_count": i})
+	}
+	largeJSON, err := json.Marshal(catalogs)
+	assert.NoError(t, err)
+
+	handler := CompressionMiddleware(DefaultCompressionMinSize, "/archive/")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/catalog", "/archive/catalog/index.json":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(largeJSON)
+		case "/small":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"status": "ok"}`))
+		case "/image":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(make([]byte, 4096))
+		}
+	}))
+
+	serve := func(path, acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Large JSON is gzip encoded", func(t *testing.T) {
+		rec := serve("/api/catalog", "deflate, gzip;q=1.0")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+		assert.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"))
+		assert.Less(t, rec.Body.Len(), len(largeJSON))
+
+		reader, err := gzip.NewReader(rec.Body)
+		assert.NoError(t, err)
+		body, err := io.ReadAll(reader)
+		assert.NoError(t, err)
+		assert.JSONEq(t, string(largeJSON), string(body))
+	})
+
+	t.Run("Deflate is used when gzip isn't accepted", func(t *testing.T) {
+		rec := serve("/api/catalog", "gzip;q=0, deflate")
+		assert.Equal(t, "deflate", rec.Header().Get("Content-Encoding"))
+
+		body, err := io.ReadAll(flate.NewReader(rec.Body))
+		assert.NoError(t, err)
+		assert.JSONEq(t, string(largeJSON), string(body))
+	})
+
+	t.Run("Uncompressed without Accept-Encoding", func(t *testing.T) {
+		rec := serve("/api/catalog", "")
+		assert.Empty(t, rec.Header().Get("Content-Encoding"))
+		assert.Equal(t, string(largeJSON), rec.Body.String())
+	})
+
+	t.Run("Small responses stay uncompressed", func(t *testing.T) {
+		rec := serve("/small", "gzip")
+		assert.Empty(t, rec.Header().Get("Content-Encoding"))
+		assert.Equal(t, `{"status": "ok"}`, rec.Body.String())
+	})
+
+	t.Run("Images are not compressed", func(t *testing.T) {
+		rec := serve("/image", "gzip")
+		assert.Empty(t, rec.Header().Get("Content-Encoding"))
+		assert.Equal(t, 4096, rec.Body.Len())
+	})
+
+	t.Run("Skipped prefixes are passed through", func(t *testing.T) {
+		rec := serve("/archive/catalog/index.json", "gzip")
+		assert.Empty(t, rec.Header().Get("Content-Encoding"))
+		assert.Equal(t, string(largeJSON), rec.Body.String())
+	})
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := map[string]string{
+		"":                  "",
+		"gzip":              "gzip",
+		"GZIP":              "gzip",
+		"deflate":           "deflate",
+		"deflate, gzip":     "gzip",
+		"gzip;q=0, deflate": "deflate",
+		"gzip; q=0.0":       "",
+		"br":                "",
+		"*":                 "gzip",
+	}
+
+	for acceptEncoding, expected := range tests {
+		assert.Equal(t, expected, negotiateEncoding(acceptEncoding), strings.TrimSpace(acceptEncoding))
+	}
+}
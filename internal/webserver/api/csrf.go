@@ -0,0 +1,116 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	// CSRFHeaderName is the request header carrying the CSRF token, HTMX sends it via hx-headers
+	CSRFHeaderName = "X-CSRF-Token"
+	// CSRFFormField is the form field accepted as an alternative to the header
+	CSRFFormField = "csrf_token"
+	// csrfCookieName names the cookie holding the random session the token is bound to
+	csrfCookieName = "kbase_csrf"
+)
+
+// CSRFProtector issues CSRF tokens and validates them on state changing requests. A token is
+// the HMAC of a random session id kept in a cookie, signed with a secret generated at startup,
+// so a page on another origin can neither read nor forge it.
+type CSRFProtector struct {
+	secret []byte
+	// apiToken lets clients authenticated with the web API token through without a CSRF token
+	apiToken string
+}
+
+// NewCSRFProtector creates a protector with a fresh random secret
+func NewCSRFProtector(apiToken string) (*CSRFProtector, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate CSRF secret: %w", err)
+	}
+	return &CSRFProtector{secret: secret, apiToken: apiToken}, nil
+}
+
+// Token returns the CSRF token for the session of the request, starting a new session cookie
+// when the request has none
+func (c *CSRFProtector) Token(w http.ResponseWriter, r *http.Request) string {
+	if cookie, err := r.Cookie(csrfCookieName); err == nil && cookie.Value != "" {
+		return c.sign(cookie.Value)
+	}
+
+	sessionBytes := make([]byte, 32)
+	if _, err := rand.Read(sessionBytes); err != nil {
+		return ""
+	}
+	session := base64.RawURLEncoding.EncodeToString(sessionBytes)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookieName,
+		Value:    session,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return c.sign(session)
+}
+
+// Middleware rejects POST, PUT, PATCH and DELETE requests without a valid CSRF token with 403
+func (c *CSRFProtector) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if c.hasAPIToken(r) || c.valid(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		writeError(w, r, http.StatusForbidden, ErrCodeInvalidCSRFToken, "Invalid CSRF token")
+	})
+}
+
+// valid checks the token of the request against its session cookie
+func (c *CSRFProtector) valid(r *http.Request) bool {
+	cookie, err := r.Cookie(csrfCookieName)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+
+	token := r.Header.Get(CSRFHeaderName)
+	if token == "" {
+		token = r.PostFormValue(CSRFFormField)
+	}
+	if token == "" {
+		return false
+	}
+
+	return hmac.Equal([]byte(token), []byte(c.sign(cookie.Value)))
+}
+
+// hasAPIToken reports whether the request carries the configured web API token. Browsers never
+// add it on their own, so such requests can't be forged by another page.
+func (c *CSRFProtector) hasAPIToken(r *http.Request) bool {
+	if c.apiToken == "" {
+		return false
+	}
+	bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && secureEqual(bearer, c.apiToken)
+}
+
+// sign computes the token of a session
+func (c *CSRFProtector) sign(session string) string {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write([]byte(session))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
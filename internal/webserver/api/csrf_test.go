@@ -0,0 +1,109 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"kbase-catalog/web"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCSRFProtector(t *testing.T) {
+	csrf, err := NewCSRFProtector("api-token")
+	assert.NoError(t, err)
+
+	handler := csrf.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// Render a page to obtain the session cookie and its token
+	rec := httptest.NewRecorder()
+	token := csrf.Token(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	cookies := rec.Result().Cookies()
+	assert.Len(t, cookies, 1)
+	assert.NotEmpty(t, token)
+
+	post := func(setup func(r *http.Request)) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/reindex", nil)
+		if setup != nil {
+			setup(req)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	t.Run("Rejects requests without a token", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, post(nil))
+		assert.Equal(t, http.StatusForbidden, post(func(r *http.Request) { r.AddCookie(cookies[0]) }))
+	})
+
+	t.Run("Rejects a token of another session", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, post(func(r *http.Request) {
+			r.AddCookie(&http.Cookie{Name: csrfCookieName, Value: "other-session"})
+			r.Header.Set(CSRFHeaderName, token)
+		}))
+	})
+
+	t.Run("Accepts a valid token in the header", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, post(func(r *http.Request) {
+			r.AddCookie(cookies[0])
+			r.Header.Set(CSRFHeaderName, token)
+		}))
+	})
+
+	t.Run("Accepts a valid token in the form", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/api/reindex", strings.NewReader(url.Values{CSRFFormField: {token}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(cookies[0])
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("Accepts requests with the web API token", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, post(func(r *http.Request) { r.Header.Set("Authorization", "Bearer api-token") }))
+		assert.Equal(t, http.StatusForbidden, post(func(r *http.Request) { r.Header.Set("Authorization", "Bearer wrong") }))
+	})
+
+	t.Run("Reuses the session of the request", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(cookies[0])
+		rec := httptest.NewRecorder()
+		assert.Equal(t, token, csrf.Token(rec, req))
+		assert.Empty(t, rec.Result().Cookies())
+	})
+
+	t.Run("Lets safe methods through", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/queue", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+}
+
+func TestHandleIndex_CSRFToken(t *testing.T) {
+	web.InitTemplateFS(false, "")
+	h := newTestAPIHandler(t, t.TempDir())
+
+	rec := httptest.NewRecorder()
+	h.HandleIndex(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	cookies := rec.Result().Cookies()
+	assert.Len(t, cookies, 1)
+	token := h.csrf.sign(cookies[0].Value)
+	assert.Contains(t, rec.Body.String(), token)
+
+	// The token embedded in the page authorizes a reindex
+	reindex := h.CSRFMiddleware(http.HandlerFunc(h.HandleReindex))
+	req := httptest.NewRequest(http.MethodPost, "/api/reindex", nil)
+	req.AddCookie(cookies[0])
+	req.Header.Set(CSRFHeaderName, token)
+	rec = httptest.NewRecorder()
+	reindex.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
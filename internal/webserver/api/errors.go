@@ -0,0 +1,49 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"kbase-catalog/internal/errors"
+)
+
+// Error codes of the JSON error envelope
+const (
+	ErrCodeMethodNotAllowed  = "METHOD_NOT_ALLOWED"
+	ErrCodeInvalidParameter  = "INVALID_PARAMETER"
+	ErrCodeMissingParameter  = "MISSING_PARAMETER"
+	ErrCodeInvalidRequest    = "INVALID_REQUEST"
+	ErrCodeCatalogsFailed    = "FAIL_TO_LOAD_CATALOGS"
+	ErrCodeSearchFailed      = "FAIL_TO_SEARCH"
+	ErrCodeReindexFailed     = "FAIL_TO_QUEUE_REINDEX"
+	ErrCodeQueueFull         = "QUEUE_FULL"
+	ErrCodeCatalogNotFound   = "CATALOG_NOT_FOUND"
+	ErrCodeImageNotFound     = "IMAGE_NOT_FOUND"
+	ErrCodeCatalogMetaFailed = "FAIL_TO_UPDATE_CATALOG_META"
+	ErrCodeUnauthorized      = "UNAUTHORIZED"
+	ErrCodeInvalidCSRFToken  = "INVALID_CSRF_TOKEN"
+	ErrCodeInternal          = "INTERNAL_ERROR"
+)
+
+// writeJSONError writes an errors.BaseError as the {code, message, timestamp} JSON envelope
+func writeJSONError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errors.BaseError{
+		Code:      code,
+		Message:   message,
+		Timestamp: time.Now().UTC(),
+	})
+}
+
+// writeError answers HTMX requests with a plain text error and everything else with the
+// JSON envelope
+func writeError(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	if r.Header.Get("HX-Request") == "true" {
+		http.Error(w, message, status)
+		return
+	}
+	writeJSONError(w, status, code, message)
+}
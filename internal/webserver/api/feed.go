@@ -0,0 +1,166 @@
+package api
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"kbase-catalog/internal/utils"
+	"kbase-catalog/internal/webserver/services"
+)
+
+// feedItemLimit is the number of most recently updated images listed in a catalog feed
+const feedItemLimit = 50
+
+// rssFeed is an RSS 2.0 document. The XML encoder escapes the text of the records.
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	Description string `xml:"description,omitempty"`
+	GUID        string `xml:"guid"`
+	PubDate     string `xml:"pubDate,omitempty"`
+}
+
+// feedEntry is an image of the catalog index with its parsed update date
+type feedEntry struct {
+	key     string
+	record  map[string]interface{}
+	updated time.Time
+}
+
+// HandleCatalogFeed serves an RSS feed of the most recently updated images of a top level
+// catalog, newest first
+func (h *APIHandler) HandleCatalogFeed(w http.ResponseWriter, r *http.Request) {
+	catalog, folder, ok := services.SplitCatalogPath(r.PathValue("catalog"))
+	if !ok || folder != "" || !utils.IsDirectory(filepath.Join(h.archivePath, catalog)) {
+		writeError(w, r, http.StatusNotFound, ErrCodeCatalogNotFound, "Catalog not found")
+		return
+	}
+
+	indexData, err := h.catalogService.GetCatalogImages(r.Context(), catalog)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "Error getting catalog images", "catalog", catalog, "error", err)
+		writeError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Failed to load catalog")
+		return
+	}
+
+	meta, err := h.catalogService.GetCatalogMeta(catalog)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to load catalog metadata", "catalog", catalog, "error", err)
+	}
+	title := meta.Title
+	if title == "" {
+		title = catalog
+	}
+	description := meta.Description
+	if description == "" {
+		description = "Recently added images of " + title
+	}
+
+	baseURL := requestBaseURL(r, h.config.GetBasePath())
+	feed := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:       title,
+			Link:        baseURL + "/catalog/" + url.PathEscape(catalog),
+			Description: description,
+		},
+	}
+
+	for _, entry := range recentImages(indexData, feedItemLimit) {
+		link := services.ArchiveImageURL(h.config.GetArchiveBaseURL(), catalog, entry.key)
+		// Images served by another host already have an absolute URL
+		if strings.HasPrefix(link, "/") {
+			link = baseURL + link
+		}
+		item := rssItem{
+			Title:       entry.key,
+			Link:        link,
+			Description: stringField(entry.record, "description"),
+			GUID:        link,
+		}
+		if shortName := stringField(entry.record, "short_name"); shortName != "" {
+			item.Title = shortName
+		}
+		// OCR records carry the extracted text instead of a description
+		if item.Description == "" {
+			item.Description = stringField(entry.record, "text")
+		}
+		if !entry.updated.IsZero() {
+			item.PubDate = entry.updated.Format(time.RFC1123Z)
+			if feed.Channel.LastBuildDate == "" {
+				feed.Channel.LastBuildDate = item.PubDate
+			}
+		}
+		feed.Channel.Items = append(feed.Channel.Items, item)
+	}
+
+	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	io.WriteString(w, xml.Header)
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(feed); err != nil {
+		h.logger.ErrorContext(r.Context(), "Failed to write catalog feed", "catalog", catalog, "error", err)
+	}
+}
+
+// recentImages returns up to limit records of a catalog index sorted by update date, newest
+// first. Records without a valid update date come last.
+func recentImages(indexData map[string]interface{}, limit int) []feedEntry {
+	entries := make([]feedEntry, 0, len(indexData))
+	for key, value := range indexData {
+		record, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		updated, _ := time.Parse(time.RFC3339, stringField(record, "update_date"))
+		entries = append(entries, feedEntry{key: key, record: record, updated: updated})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].updated.Equal(entries[j].updated) {
+			return entries[i].updated.After(entries[j].updated)
+		}
+		return entries[i].key < entries[j].key
+	})
+
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries
+}
+
+// stringField returns a string field of a record, empty when it is missing or not a string
+func stringField(record map[string]interface{}, field string) string {
+	value, _ := record[field].(string)
+	return value
+}
+
+// requestBaseURL returns the scheme and host the request was sent to followed by basePath, for
+// absolute links
+func requestBaseURL(r *http.Request, basePath string) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + basePath
+}
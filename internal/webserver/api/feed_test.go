@@ -0,0 +1,101 @@
+package api
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"kbase-catalog/internal/processor"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// getFeed requests the feed of a catalog
+func getFeed(t *testing.T, h *APIHandler, catalog string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/catalog/"+catalog+"/feed.xml", nil)
+	req.SetPathValue("catalog", catalog)
+	rec := httptest.NewRecorder()
+	h.HandleCatalogFeed(rec, req)
+	return rec
+}
+
+func TestHandleCatalogFeed(t *testing.T) {
+	archivePath := t.TempDir()
+	catalogDir := filepath.Join(archivePath, "holidays")
+	assert.NoError(t, os.MkdirAll(catalogDir, 0755))
+	index := `{
+		"old.jpg": {"short_name": "Old", "description": "Oldest", "update_date": "2024-01-01T10:00:00Z"},
+		"new beach.jpg": {"short_name": "Sun & <sea>", "description": "Waves \"high\" & <b>loud</b>", "update_date": "2024-03-01T10:00:00Z"},
+		"mid.jpg": {"short_name": "Mid", "description": "Middle", "update_date": "2024-02-01T10:00:00Z"},
+		"undated.jpg": {"short_name": "Undated"}
+	}`
+	assert.NoError(t, os.WriteFile(filepath.Join(catalogDir, "index.json"), []byte(index), 0644))
+	assert.NoError(t, processor.SaveCatalogMeta(catalogDir, processor.CatalogMeta{Title: "Holidays & trips"}))
+
+	h := newTestAPIHandler(t, archivePath)
+
+	t.Run("Items newest first", func(t *testing.T) {
+		rec := getFeed(t, h, "holidays")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/rss+xml; charset=utf-8", rec.Header().Get("Content-Type"))
+
+		var feed rssFeed
+		assert.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &feed), rec.Body.String())
+		assert.Equal(t, "2.0", feed.Version)
+		assert.Equal(t, "Holidays & trips", feed.Channel.Title)
+		assert.Equal(t, "http://example.com/catalog/holidays", feed.Channel.Link)
+
+		var titles []string
+		for _, item := range feed.Channel.Items {
+			titles = append(titles, item.Title)
+		}
+		assert.Equal(t, []string{"Sun & <sea>", "Mid", "Old", "Undated"}, titles)
+
+		newest := feed.Channel.Items[0]
+		assert.Equal(t, "http://example.com/archive/holidays/new%20beach.jpg", newest.Link)
+		assert.Equal(t, `Waves "high" & <b>loud</b>`, newest.Description)
+		assert.Equal(t, "Fri, 01 Mar 2024 10:00:00 +0000", newest.PubDate)
+		assert.Equal(t, newest.PubDate, feed.Channel.LastBuildDate)
+		assert.Empty(t, feed.Channel.Items[3].PubDate)
+
+		// The markup of the records is escaped, not embedded
+		assert.NotContains(t, rec.Body.String(), "<b>")
+	})
+
+	t.Run("Archive base URL", func(t *testing.T) {
+		h.config.ArchiveBaseURL = "https://cdn.example.com/archive"
+		defer func() { h.config.ArchiveBaseURL = "" }()
+
+		var feed rssFeed
+		assert.NoError(t, xml.Unmarshal(getFeed(t, h, "holidays").Body.Bytes(), &feed))
+		assert.Equal(t, "https://cdn.example.com/archive/holidays/new%20beach.jpg", feed.Channel.Items[0].Link)
+	})
+
+	t.Run("Unknown catalog", func(t *testing.T) {
+		for _, catalog := range []string{"missing", "..", "holidays/2024"} {
+			assert.Equal(t, http.StatusNotFound, getFeed(t, h, catalog).Code, catalog)
+		}
+	})
+}
+
+func TestRecentImages(t *testing.T) {
+	indexData := map[string]interface{}{"broken": "not a record"}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		indexData[fmt.Sprintf("%d.jpg", i)] = map[string]interface{}{
+			"update_date": start.AddDate(0, 0, i).Format(time.RFC3339),
+		}
+	}
+
+	entries := recentImages(indexData, 3)
+	var keys []string
+	for _, entry := range entries {
+		keys = append(keys, entry.key)
+	}
+	assert.Equal(t, []string{"4.jpg", "3.jpg", "2.jpg"}, keys)
+}
@@ -2,18 +2,34 @@ package api
 
 import (
 	"encoding/json"
-	"kbase-catalog/internal/errors"
-	"kbase-catalog/internal/utils"
-	"log"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
 	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"kbase-catalog/internal/config"
+	"kbase-catalog/internal/encoder"
+	apperrors "kbase-catalog/internal/errors"
 	"kbase-catalog/internal/processor"
+	"kbase-catalog/internal/utils"
 	"kbase-catalog/internal/webserver/queue"
 	"kbase-catalog/internal/webserver/services"
 	"kbase-catalog/internal/webserver/watch"
+	"kbase-catalog/web"
+)
+
+const (
+	// defaultGlobalSearchLimit is the number of results returned by a global search without a limit
+	defaultGlobalSearchLimit = 100
+	// maxGlobalSearchLimit caps the limit a client can request for a global search
+	maxGlobalSearchLimit = 1000
 )
 
 // APIHandler represents the API handlers
@@ -24,7 +40,9 @@ type APIHandler struct {
 	templateRenderer *services.TemplateRenderer
 	taskQueue        *queue.TaskQueue
 	watcher          *watch.CatalogWatcher
+	csrf             *CSRFProtector
 	archivePath      string
+	logger           *slog.Logger
 }
 
 // NewAPIHandler creates a new API handler instance
@@ -32,10 +50,17 @@ func NewAPIHandler(cfg *config.Config, catalogProcessor *processor.CatalogProces
 	taskQueue := queue.NewTaskQueue(cfg, catalogProcessor, archivePath)
 	watcher, err := watch.NewCatalogWatcher(taskQueue, archivePath)
 	if err != nil {
-		log.Printf("Failed to create watcher: %v", err)
+		slog.Error("Failed to create watcher", "error", err)
+	} else {
+		watcher.SetIgnoreReloader(catalogProcessor)
+	}
+
+	csrf, err := NewCSRFProtector(cfg.WebAPIToken)
+	if err != nil {
+		return nil, err
 	}
 
-	catalogService := &services.CatalogService{Config: cfg, Processor: catalogProcessor, ArchiveDir: archivePath}
+	catalogService := &services.CatalogService{Config: cfg, Processor: catalogProcessor, ArchiveDir: archivePath, IndexDir: catalogProcessor.IndexDir()}
 
 	return &APIHandler{
 		config:           cfg,
@@ -44,7 +69,9 @@ func NewAPIHandler(cfg *config.Config, catalogProcessor *processor.CatalogProces
 		templateRenderer: services.NewTemplateRenderer(catalogService),
 		taskQueue:        taskQueue,
 		watcher:          watcher,
+		csrf:             csrf,
 		archivePath:      archivePath,
+		logger:           slog.Default(),
 	}, nil
 }
 
@@ -61,7 +88,7 @@ func (h *APIHandler) HandleIndex(w http.ResponseWriter, r *http.Request) {
 
 	catalogs, err := h.catalogService.GetCatalogs(r.Context())
 	if err != nil {
-		log.Printf("Error getting catalogs for index: %v", err)
+		h.logger.ErrorContext(r.Context(), "Error getting catalogs for index", "error", err)
 		http.Error(w, "Failed to load catalog list", http.StatusInternalServerError)
 		return
 	}
@@ -70,6 +97,7 @@ func (h *APIHandler) HandleIndex(w http.ResponseWriter, r *http.Request) {
 
 	err = h.templateRenderer.RenderTemplate(w, r, "templates/index.html", "templates/catalog-list-fragment.html", map[string]interface{}{
 		"CatalogList": h.templateRenderer.RenderCatalogList(catalogs),
+		"CSRFToken":   h.csrf.Token(w, r),
 	})
 	if err != nil {
 		return // Error already handled by RenderTemplate
@@ -84,8 +112,8 @@ func (h *APIHandler) HandleApiCatalog(w http.ResponseWriter, r *http.Request) {
 
 	catalogs, err := h.catalogService.GetCatalogs(r.Context())
 	if err != nil {
-		log.Printf("Error getting catalogs: %v", err)
-		http.Error(w, "Failed to retrieve catalogs", http.StatusInternalServerError)
+		h.logger.ErrorContext(r.Context(), "Error getting catalogs", "error", err)
+		writeError(w, r, http.StatusInternalServerError, ErrCodeCatalogsFailed, "Failed to retrieve catalogs")
 		return
 	}
 
@@ -93,8 +121,8 @@ func (h *APIHandler) HandleApiCatalog(w http.ResponseWriter, r *http.Request) {
 
 	jsonData, err := json.Marshal(catalogs)
 	if err != nil {
-		log.Printf("Error marshaling JSON: %v", err)
-		http.Error(w, "Failed to marshal JSON", http.StatusInternalServerError)
+		h.logger.ErrorContext(r.Context(), "Error marshaling JSON", "error", err)
+		writeError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Failed to marshal JSON")
 		return
 	}
 
@@ -113,16 +141,17 @@ func (h *APIHandler) HandleApiSearch(w http.ResponseWriter, r *http.Request) {
 		query = r.FormValue("q")
 	}
 
-	log.Printf("Search query received: '%s'", query)
+	fuzzy := isFuzzyRequest(r)
+
+	h.logger.DebugContext(r.Context(), "Search query received", "query", query, "fuzzy", fuzzy)
 
 	// Get sort parameters from query string for search results
-	sortBy := r.URL.Query().Get("sort")
-	sortOrder := r.URL.Query().Get("order")
+	sortBy, sortOrder := searchSortParams(r, fuzzy && query != "")
 
-	catalogs, err := h.catalogService.SearchCatalogs(r.Context(), query)
+	catalogs, err := h.catalogService.SearchCatalogs(r.Context(), query, fuzzy)
 	if err != nil {
-		log.Printf("Error during search: %v", err)
-		http.Error(w, "Failed to perform search", http.StatusInternalServerError)
+		h.logger.ErrorContext(r.Context(), "Error during search", "error", err)
+		writeError(w, r, http.StatusInternalServerError, ErrCodeSearchFailed, "Failed to perform search")
 		return
 	}
 
@@ -139,7 +168,7 @@ func (h *APIHandler) HandleApiSearch(w http.ResponseWriter, r *http.Request) {
 // HandleApiCatalogSearch handles searching for images within a specific catalog
 func (h *APIHandler) HandleApiCatalogSearch(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
@@ -147,22 +176,28 @@ func (h *APIHandler) HandleApiCatalogSearch(w http.ResponseWriter, r *http.Reque
 	catalogName := r.URL.Query().Get("catalog")
 	query := r.URL.Query().Get("q")
 
-	log.Printf("Catalog search query received: catalog='%s', query='%s'", catalogName, query)
+	// Tags can be repeated (tag=a&tag=b) or comma separated (tag=a,b)
+	searchOptions := services.ImageSearchOptions{
+		Tags:         parseTagParams(r.URL.Query()["tag"]),
+		MatchAllTags: r.URL.Query().Get("tag_mode") == "all",
+		Fuzzy:        isFuzzyRequest(r),
+	}
+
+	h.logger.DebugContext(r.Context(), "Catalog search query received", "catalog", catalogName, "query", query, "tags", searchOptions.Tags, "fuzzy", searchOptions.Fuzzy)
 
 	if catalogName == "" {
-		http.Error(w, "Missing 'catalog' parameter", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, ErrCodeMissingParameter, "Missing 'catalog' parameter")
 		return
 	}
 
 	// Get sort parameters from query string for search results
-	sortBy := r.URL.Query().Get("sort")
-	sortOrder := r.URL.Query().Get("order")
+	sortBy, sortOrder := searchSortParams(r, searchOptions.Fuzzy && query != "")
 
 	// Search within the specific catalog
-	indexData, err := h.catalogService.SearchCatalogImages(r.Context(), catalogName, query)
+	indexData, err := h.catalogService.SearchCatalogImages(r.Context(), catalogName, query, searchOptions)
 	if err != nil {
-		log.Printf("Error during catalog search: %v", err)
-		http.Error(w, "Failed to perform catalog search", http.StatusInternalServerError)
+		h.logger.ErrorContext(r.Context(), "Error during catalog search", "error", err)
+		writeError(w, r, http.StatusInternalServerError, ErrCodeSearchFailed, "Failed to perform catalog search")
 		return
 	}
 
@@ -178,13 +213,111 @@ func (h *APIHandler) HandleApiCatalogSearch(w http.ResponseWriter, r *http.Reque
 
 	// For HTMX requests, render the fragment
 	err = h.templateRenderer.RenderTemplate(w, r, "", "templates/catalog-images-fragment.html", map[string]interface{}{
-		"CatalogImages": h.templateRenderer.RenderCatalogImages(sortedIndexData, catalogName),
+		"CatalogImages": h.templateRenderer.RenderSearchImages(sortedIndexData, services.CatalogRoot(catalogName)),
 	})
 	if err != nil {
 		return // Error already handled by RenderTemplate
 	}
 }
 
+// HandleApiGlobalSearch searches images by description across all catalogs
+func (h *APIHandler) HandleApiGlobalSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	query := r.URL.Query().Get("q")
+	searchOptions := services.ImageSearchOptions{
+		Tags:         parseTagParams(r.URL.Query()["tag"]),
+		MatchAllTags: r.URL.Query().Get("tag_mode") == "all",
+		Fuzzy:        isFuzzyRequest(r),
+	}
+
+	// Bound the size of the response
+	limit := defaultGlobalSearchLimit
+	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+		parsed, err := strconv.Atoi(limitParam)
+		if err != nil || parsed <= 0 {
+			writeError(w, r, http.StatusBadRequest, ErrCodeInvalidParameter, "Invalid 'limit' parameter")
+			return
+		}
+		limit = min(parsed, maxGlobalSearchLimit)
+	}
+
+	h.logger.DebugContext(r.Context(), "Global image search query received", "query", query, "tags", searchOptions.Tags, "limit", limit)
+
+	if query == "" && len(searchOptions.Tags) == 0 {
+		writeError(w, r, http.StatusBadRequest, ErrCodeMissingParameter, "Missing 'q' or 'tag' parameter")
+		return
+	}
+
+	// Get sort parameters from query string for search results
+	sortBy, sortOrder := searchSortParams(r, searchOptions.Fuzzy && query != "")
+
+	// Collect every match so the limit keeps the first results of the requested order
+	images, err := h.catalogService.SearchAllCatalogImages(r.Context(), query, searchOptions)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "Error during global image search", "error", err)
+		writeError(w, r, http.StatusInternalServerError, ErrCodeSearchFailed, "Failed to perform search")
+		return
+	}
+
+	images = SortImages(images, sortBy, sortOrder)
+	if len(images) > limit {
+		images = images[:limit]
+	}
+
+	// For non-HTMX requests, return JSON response
+	if r.Header.Get("HX-Request") != "true" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(images)
+		return
+	}
+
+	err = h.templateRenderer.RenderTemplate(w, r, "", "templates/catalog-images-fragment.html", map[string]interface{}{
+		"CatalogImages": h.templateRenderer.RenderSearchImages(images, ""),
+	})
+	if err != nil {
+		return // Error already handled by RenderTemplate
+	}
+}
+
+// isFuzzyRequest reports whether the client asked for typo tolerant search
+func isFuzzyRequest(r *http.Request) bool {
+	fuzzy, _ := strconv.ParseBool(r.URL.Query().Get("fuzzy"))
+	return fuzzy
+}
+
+// searchSortParams returns the requested sort parameters. Ranked results are ordered
+// by relevance, best matches first, unless the client asked for another order.
+func searchSortParams(r *http.Request, ranked bool) (string, string) {
+	sortBy := r.URL.Query().Get("sort")
+	sortOrder := r.URL.Query().Get("order")
+
+	if ranked && sortBy == "" {
+		sortBy = "score"
+		if sortOrder == "" {
+			sortOrder = "desc"
+		}
+	}
+
+	return sortBy, sortOrder
+}
+
+// parseTagParams flattens repeated and comma separated tag query parameters
+func parseTagParams(values []string) []string {
+	var tags []string
+	for _, value := range values {
+		for _, tag := range strings.Split(value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags
+}
+
 // HandleCatalogDetail serves individual catalog detail pages
 func (h *APIHandler) HandleCatalogDetail(w http.ResponseWriter, r *http.Request) {
 	catalogName := strings.TrimPrefix(r.URL.Path, "/catalog/")
@@ -201,34 +334,101 @@ func (h *APIHandler) HandleCatalogDetail(w http.ResponseWriter, r *http.Request)
 	// Get the index.json for this catalog
 	indexData, err := h.catalogService.GetCatalogImages(r.Context(), catalogName)
 	if err != nil {
-		log.Printf("Error getting catalog images: %v", err)
+		h.logger.ErrorContext(r.Context(), "Error getting catalog images", "error", err)
 		http.NotFound(w, r)
 		return
 	}
 
 	sortedIndexData := SortCatalogImages(indexData, sortBy, sortOrder)
 
+	// The page is titled by the catalog title, or its directory name without one
+	meta, err := h.catalogService.GetCatalogMeta(catalogName)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to load catalog metadata", "catalog", catalogName, "error", err)
+	}
+	catalogTitle := meta.Title
+	if catalogTitle == "" {
+		catalogTitle = path.Base(catalogName)
+	}
+
+	// Folders of a catalog indexed with --recursive can be browsed as nested catalogs
+	children, err := h.catalogService.GetCatalogChildren(r.Context(), catalogName)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to list nested catalogs", "catalog", catalogName, "error", err)
+	}
+
+	// Image URLs and reindexing refer to the top level catalog owning the index
+	catalogRoot := services.CatalogRoot(catalogName)
+
 	err = h.templateRenderer.RenderTemplate(w, r, "templates/catalog-detail.html", "templates/catalog-images-fragment.html", map[string]interface{}{
-		"CatalogName":   catalogName,
-		"CatalogImages": h.templateRenderer.RenderCatalogImages(sortedIndexData, catalogName),
+		"CatalogName":        catalogName,
+		"CatalogRoot":        catalogRoot,
+		"CatalogTitle":       catalogTitle,
+		"CatalogDescription": meta.Description,
+		"Breadcrumbs":        services.CatalogBreadcrumbs(catalogName),
+		"Children":           h.templateRenderer.RenderCatalogList(children),
+		"HasChildren":        len(children) > 0,
+		"CatalogImages":      h.templateRenderer.RenderCatalogImages(sortedIndexData, catalogRoot),
+		"CSRFToken":          h.csrf.Token(w, r),
+	})
+	if err != nil {
+		return // Error already handled by RenderTemplate
+	}
+}
+
+// HandleApiCatalogChildren lists the catalogs directly below the catalog path given by the
+// "path" parameter, the top level catalogs without one. HTMX requests get the catalog list.
+func (h *APIHandler) HandleApiCatalogChildren(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	catalogPath := r.URL.Query().Get("path")
+	children, err := h.catalogService.GetCatalogChildren(r.Context(), catalogPath)
+	if errors.Is(err, services.ErrCatalogNotFound) {
+		writeError(w, r, http.StatusNotFound, ErrCodeCatalogNotFound, "Catalog not found")
+		return
+	}
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "Error listing nested catalogs", "path", catalogPath, "error", err)
+		writeError(w, r, http.StatusInternalServerError, ErrCodeCatalogsFailed, "Failed to retrieve catalogs")
+		return
+	}
+
+	children = SortCatalogs(children, r.URL.Query().Get("sort"), r.URL.Query().Get("order"))
+
+	if r.Header.Get("HX-Request") != "true" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(children)
+		return
+	}
+
+	err = h.templateRenderer.RenderTemplate(w, r, "", "templates/catalog-list-fragment.html", map[string]interface{}{
+		"CatalogList": h.templateRenderer.RenderCatalogList(children),
 	})
 	if err != nil {
 		return // Error already handled by RenderTemplate
 	}
 }
 
+// CSRFMiddleware rejects state changing requests without a valid CSRF token, see CSRFProtector
+func (h *APIHandler) CSRFMiddleware(next http.Handler) http.Handler {
+	return h.csrf.Middleware(next)
+}
+
 // HandleReindex handles manual reindex requests
 func (h *APIHandler) HandleReindex(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	// Parse form data
 	err := r.ParseForm()
 	if err != nil {
-		log.Printf("Failed to parse form data: %v", err)
-		http.Error(w, "Invalid request format", http.StatusBadRequest)
+		h.logger.WarnContext(r.Context(), "Failed to parse form data", "error", err)
+		writeError(w, r, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid request format")
 		return
 	}
 
@@ -239,31 +439,48 @@ func (h *APIHandler) HandleReindex(w http.ResponseWriter, r *http.Request) {
 		// Get all catalogs
 		catalogs, err := h.catalogService.GetCatalogs(r.Context())
 		if err != nil {
-			log.Printf("Error getting catalogs for reindex: %v", err)
-			http.Error(w, "Failed to get catalog list", http.StatusInternalServerError)
+			h.logger.ErrorContext(r.Context(), "Error getting catalogs for reindex", "error", err)
+			writeError(w, r, http.StatusInternalServerError, ErrCodeCatalogsFailed, "Failed to get catalog list")
 			return
 		}
 
-		// Add tasks for each catalog to the queue
+		var names []string
 		for _, catalog := range catalogs {
 			if name, ok := catalog["name"].(string); ok && name != "" {
-				if err := h.taskQueue.AddTask(name, "manual"); err != nil {
-					log.Printf("Failed to add reindex task for catalog %s: %v", name, err)
-				} else {
-					log.Printf("Reindex task queued for catalog: %s", name)
-				}
+				names = append(names, name)
 			}
 		}
 
+		// Waits for room while the queue is full rather than dropping tasks, until the client
+		// goes away or the queue stops
+		queued, err := h.taskQueue.AddTasks(r.Context(), names, "manual")
+		dropped := len(names) - queued
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "Failed to add reindex tasks", "queued", queued, "dropped", dropped, "error", err)
+		} else {
+			h.logger.InfoContext(r.Context(), "Reindex tasks queued", "count", queued)
+		}
+
+		status := "success"
+		alert := "alert-success"
+		message := "Reindex tasks queued for all catalogs"
+		if dropped > 0 {
+			status = "partial"
+			alert = "alert-warning"
+			message = fmt.Sprintf("Reindex tasks queued for %d of %d catalogs, %d dropped", queued, len(names), dropped)
+		}
+
 		// For HTMX requests, return a simple HTML message instead of JSON
 		if r.Header.Get("HX-Request") == "true" {
 			w.Header().Set("Content-Type", "text/html")
-			w.Write([]byte(`<span class="alert alert-success">Reindex tasks queued for all catalogs</span>`))
+			w.Write([]byte(`<span class="alert ` + alert + `">` + template.HTMLEscapeString(message) + `</span>`))
 		} else {
 			w.Header().Set("Content-Type", "application/json")
 			json.NewEncoder(w).Encode(map[string]interface{}{
-				"status":  "success",
-				"message": "Reindex tasks queued for all catalogs",
+				"status":  status,
+				"message": message,
+				"queued":  queued,
+				"dropped": dropped,
 			})
 		}
 		return
@@ -271,15 +488,16 @@ func (h *APIHandler) HandleReindex(w http.ResponseWriter, r *http.Request) {
 
 	// Add the reindex task to the queue for specific catalog
 	if err := h.taskQueue.AddTask(catalogName, "manual"); err != nil {
-		log.Printf("Failed to add reindex task: %v", err)
-		http.Error(w, "Failed to queue reindex task", http.StatusInternalServerError)
+		h.logger.ErrorContext(r.Context(), "Failed to add reindex task", "catalog", catalogName, "error", err)
+		writeQueueError(w, r, err, "Failed to queue reindex task")
 		return
 	}
+	h.logger.InfoContext(r.Context(), "Reindex task queued", "catalog", catalogName)
 
 	// For HTMX requests, return a simple HTML message instead of JSON
 	if r.Header.Get("HX-Request") == "true" {
 		w.Header().Set("Content-Type", "text/html")
-		w.Write([]byte(`<span class="alert alert-success">Reindex task queued for catalog: ` + catalogName + `</span>`))
+		w.Write([]byte(`<span class="alert alert-success">Reindex task queued for catalog: ` + template.HTMLEscapeString(catalogName) + `</span>`))
 	} else {
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
@@ -289,6 +507,272 @@ func (h *APIHandler) HandleReindex(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// catalogMetaRequest is the JSON body accepted by HandleApiUpdateCatalogMeta
+type catalogMetaRequest struct {
+	Catalog     string `json:"catalog"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+// HandleApiUpdateCatalogMeta returns the title and description of a catalog on GET and replaces
+// them on POST or PUT. The update is read from a JSON body or from form values, empty values
+// remove the metadata.
+func (h *APIHandler) HandleApiUpdateCatalogMeta(w http.ResponseWriter, r *http.Request) {
+	var request catalogMetaRequest
+
+	switch r.Method {
+	case http.MethodGet:
+		request.Catalog = r.URL.Query().Get("catalog")
+	case http.MethodPost, http.MethodPut:
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				h.logger.WarnContext(r.Context(), "Failed to parse catalog metadata", "error", err)
+				writeError(w, r, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid request format")
+				return
+			}
+		} else {
+			if err := r.ParseForm(); err != nil {
+				h.logger.WarnContext(r.Context(), "Failed to parse form data", "error", err)
+				writeError(w, r, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid request format")
+				return
+			}
+			request.Catalog = r.FormValue("catalog")
+			request.Title = r.FormValue("title")
+			request.Description = r.FormValue("description")
+		}
+	default:
+		writeError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if request.Catalog == "" {
+		writeError(w, r, http.StatusBadRequest, ErrCodeMissingParameter, "Missing 'catalog' parameter")
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		meta := processor.CatalogMeta{Title: request.Title, Description: request.Description}
+		err := h.catalogService.UpdateCatalogMeta(r.Context(), request.Catalog, meta)
+		if errors.Is(err, services.ErrCatalogNotFound) {
+			writeError(w, r, http.StatusNotFound, ErrCodeCatalogNotFound, "Catalog not found")
+			return
+		}
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "Failed to update catalog metadata", "catalog", request.Catalog, "error", err)
+			writeError(w, r, http.StatusInternalServerError, ErrCodeCatalogMetaFailed, "Failed to update catalog metadata")
+			return
+		}
+		h.logger.InfoContext(r.Context(), "Catalog metadata updated", "catalog", request.Catalog)
+	}
+
+	meta, err := h.catalogService.GetCatalogMeta(request.Catalog)
+	if errors.Is(err, services.ErrCatalogNotFound) {
+		writeError(w, r, http.StatusNotFound, ErrCodeCatalogNotFound, "Catalog not found")
+		return
+	}
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "Failed to load catalog metadata", "catalog", request.Catalog, "error", err)
+		writeError(w, r, http.StatusInternalServerError, ErrCodeCatalogMetaFailed, "Failed to load catalog metadata")
+		return
+	}
+
+	// For HTMX requests, return a simple HTML message instead of JSON
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<span class="alert alert-success">Catalog details saved for: ` + template.HTMLEscapeString(request.Catalog) + `</span>`))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(catalogMetaRequest{
+		Catalog:     request.Catalog,
+		Title:       meta.Title,
+		Description: meta.Description,
+	})
+}
+
+// writeQueueError reports a task the queue didn't accept. A full queue is a temporary
+// condition the client may retry, anything else is a server error.
+func writeQueueError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	if errors.Is(err, queue.ErrQueueFull) {
+		writeError(w, r, http.StatusServiceUnavailable, ErrCodeQueueFull, message+": the queue is full, try again later")
+		return
+	}
+	writeError(w, r, http.StatusInternalServerError, ErrCodeReindexFailed, message)
+}
+
+// HandleApiReprocessImage queues a task describing a single image of a catalog again, for
+// POST /api/catalog/{catalog}/image/{filename}/reprocess. The filename is the index key of the
+// image, with escaped slashes (2024%2Fbeach.jpg) for images in subfolders of recursive catalogs.
+func (h *APIHandler) HandleApiReprocessImage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	catalogName := r.PathValue("catalog")
+	filename := r.PathValue("filename")
+
+	// Only top level catalogs own an index, images of nested catalogs are addressed by their key
+	catalog, folder, ok := services.SplitCatalogPath(catalogName)
+	if !ok || folder != "" || !utils.IsDirectory(filepath.Join(h.archivePath, catalog)) {
+		writeError(w, r, http.StatusNotFound, ErrCodeCatalogNotFound, "Catalog not found")
+		return
+	}
+
+	// Only recursive catalogs index images of subfolders
+	nested := strings.Contains(filename, "/") && !h.config.RecursiveCatalogs
+
+	imagePath, ok := resolveWithin(filepath.Join(h.archivePath, catalog), filename)
+	if !ok || nested || filename == "" || !utils.IsFileExists(imagePath) {
+		h.logger.WarnContext(r.Context(), "Rejected image to reprocess", "catalog", catalog, "image", filename)
+		writeError(w, r, http.StatusNotFound, ErrCodeImageNotFound, "Image not found")
+		return
+	}
+
+	if err := h.taskQueue.AddImageTask(catalog, filename, "manual"); err != nil {
+		h.logger.ErrorContext(r.Context(), "Failed to add reprocess task", "catalog", catalog, "image", filename, "error", err)
+		writeQueueError(w, r, err, "Failed to queue reprocess task")
+		return
+	}
+	h.logger.InfoContext(r.Context(), "Reprocess task queued", "catalog", catalog, "image", filename)
+
+	// For HTMX requests, return a simple HTML message instead of JSON
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`<span class="alert alert-success">Reprocess task queued for image: ` + template.HTMLEscapeString(filename) + `</span>`))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Reprocess task queued for image: " + filename,
+	})
+}
+
+// HandleApiQueueStatus returns the number of pending reindex tasks and the current/last processed catalog
+func (h *APIHandler) HandleApiQueueStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.taskQueue.GetStatus())
+}
+
+// HandleApiCancelTask cancels the running reindex task and drops the pending ones of the catalog
+// given by the "catalog" parameter, or of every catalog without one
+func (h *APIHandler) HandleApiCancelTask(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		h.logger.WarnContext(r.Context(), "Failed to parse form data", "error", err)
+		writeError(w, r, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid request format")
+		return
+	}
+
+	catalogName := r.FormValue("catalog")
+	cancelled := h.taskQueue.CancelTasks(catalogName)
+	h.logger.InfoContext(r.Context(), "Reindex tasks cancelled", "catalog", catalogName, "running", cancelled)
+
+	message := "Reindex tasks cancelled for all catalogs"
+	if catalogName != "" {
+		message = "Reindex tasks cancelled for catalog: " + catalogName
+	}
+
+	// For HTMX requests, return a simple HTML message instead of JSON
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<span class="alert alert-success">` + template.HTMLEscapeString(message) + `</span>`))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":            "success",
+		"message":           message,
+		"cancelled_running": cancelled,
+	})
+}
+
+// HandleApiQueueFailures returns the reindex tasks that failed after exhausting their retries
+func (h *APIHandler) HandleApiQueueFailures(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"failed_tasks": h.taskQueue.GetFailedTasks(),
+	})
+}
+
+// eventsKeepAlive is how often an idle event stream sends a comment, so proxies don't close it
+const eventsKeepAlive = 30 * time.Second
+
+// HandleApiEvents streams the task events of the queue as server-sent events until the client
+// disconnects. Each event is named by its type and carries the queue.Event as JSON data.
+func (h *APIHandler) HandleApiEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Streaming is not supported")
+		return
+	}
+
+	events, unsubscribe := h.taskQueue.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	// The comment commits the response, so clients see the stream is open
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				h.logger.WarnContext(r.Context(), "Failed to marshal task event", "error", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
 // HandleArchiveFiles serves static files from the archive directory
 func (h *APIHandler) HandleArchiveFiles(w http.ResponseWriter, r *http.Request) {
 	// Serve files from archive directory
@@ -299,73 +783,82 @@ func (h *APIHandler) HandleArchiveFiles(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Construct the full file path using configured archive directory
-	fullPath := h.archivePath + "/" + path
+	fullPath, ok := resolveWithin(h.archivePath, path)
+	if !ok {
+		h.logger.WarnContext(r.Context(), "Rejected path outside of the archive", "path", r.URL.Path, "remote", r.RemoteAddr)
+		http.NotFound(w, r)
+		return
+	}
 
 	// Check if file exists
-	if !utils.IsFileExists(fullPath) {
+	info, err := os.Stat(fullPath)
+	if err != nil || info.IsDir() {
 		http.NotFound(w, r)
 		return
 	}
 
+	// ServeFile answers If-None-Match with 304 when the ETag is set
+	w.Header().Set("ETag", fileETag(info))
+
+	// ServeFile types files by extension, images without one or with a wrong one are typed by
+	// their content
+	if mimeType, err := encoder.DetectFileMIME(fullPath); err == nil && strings.HasPrefix(mimeType, "image/") {
+		w.Header().Set("Content-Type", mimeType)
+	}
+
 	// Serve the file
 	http.ServeFile(w, r, fullPath)
 }
 
-// HandleStaticFiles serves static files from the web/static directory
+// fileETag derives a validator from the size and the modification time of a file, both change
+// whenever an image is replaced
+func fileETag(info os.FileInfo) string {
+	return fmt.Sprintf(`"%x-%x"`, info.Size(), info.ModTime().UnixNano())
+}
+
+// HandleStaticFiles serves static assets from web.FS, so the embedded assets are used unless
+// the local filesystem was selected with --use-fs
 func (h *APIHandler) HandleStaticFiles(w http.ResponseWriter, r *http.Request) {
-	// Serve files from web/static directory
-	path := strings.TrimPrefix(r.URL.Path, "/static/")
-	if path == "" {
+	if !strings.HasPrefix(r.URL.Path, "/static/") {
 		http.NotFound(w, r)
 		return
 	}
 
-	// Construct the full file path
-	fullPath := "web/static/" + path
+	web.HandleEmbeddedFile(w, r)
+}
 
-	// Check if file exists
-	if !utils.IsFileExists(fullPath) {
-		http.NotFound(w, r)
-		return
+// resolveWithin joins a slash separated URL path to root. It reports false when the cleaned
+// result would escape root, e.g. through ".." segments.
+func resolveWithin(root, path string) (string, bool) {
+	fullPath := filepath.Join(root, filepath.FromSlash(path))
+
+	rel, err := filepath.Rel(root, fullPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
 	}
 
-	// Serve the file
-	http.ServeFile(w, r, fullPath)
+	return fullPath, true
 }
 
-func (h *APIHandler) Start() *errors.WebServerError {
+func (h *APIHandler) Start() *apperrors.WebServerError {
 	// Start the task queue
 	if err := h.taskQueue.Start(); err != nil {
-		log.Printf("Failed to start task queue: %v", err)
-		return &errors.WebServerError{
-			BaseError: errors.BaseError{
-				Code:      "FAIL_TO_START_TASKS_QUEUE",
-				Message:   "Failed to start task queue",
-				Timestamp: time.Now(),
-				Details:   err.Error(),
-			},
-		}
+		h.logger.Error("Failed to start task queue", "error", err)
+		return apperrors.NewWebServerError("FAIL_TO_START_TASKS_QUEUE", "Failed to start task queue", err)
 	} else {
-		log.Printf("Task queue started successfully")
+		h.logger.Info("Task queue started successfully")
 	}
 
 	// Start the file watcher
 	if h.watcher != nil {
 		if err := h.watcher.Start(); err != nil {
-			log.Printf("Failed to start file watcher: %v", err)
-			return &errors.WebServerError{
-				BaseError: errors.BaseError{
-					Code:      "FAIL_TO_START_CATALOG_WATCHER",
-					Message:   "Failed to start catalog watcher",
-					Timestamp: time.Now(),
-					Details:   err.Error(),
-				},
-			}
+			h.logger.Error("Failed to start file watcher", "error", err)
+			return apperrors.NewWebServerError("FAIL_TO_START_CATALOG_WATCHER", "Failed to start catalog watcher", err)
 		} else {
-			log.Printf("File watcher started successfully")
+			h.logger.Info("File watcher started successfully")
 		}
 	} else {
-		log.Printf("No file watcher created - check configuration")
+		h.logger.Warn("No file watcher created - check configuration")
 	}
 
 	return nil
@@ -0,0 +1,651 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"kbase-catalog/internal/config"
+	"kbase-catalog/internal/processor"
+	"kbase-catalog/internal/webserver/queue"
+	"kbase-catalog/web"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// serveFile calls a file handler and returns the response
+func serveFile(handler http.HandlerFunc, path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec
+}
+
+func TestHandleArchiveFiles_PathTraversal(t *testing.T) {
+	rootDir := t.TempDir()
+	archivePath := filepath.Join(rootDir, "archive")
+	assert.NoError(t, os.MkdirAll(filepath.Join(archivePath, "holidays", "2024"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(archivePath, "holidays", "2024", "beach.jpg"), []byte("image"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(rootDir, "secret.txt"), []byte("secret"), 0644))
+
+	h := newTestAPIHandler(t, archivePath)
+
+	t.Run("Serves nested files", func(t *testing.T) {
+		rec := serveFile(h.HandleArchiveFiles, "/archive/holidays/2024/beach.jpg")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "image", rec.Body.String())
+	})
+
+	for _, path := range []string{
+		"/archive/../secret.txt",
+		"/archive/holidays/../../secret.txt",
+		"/archive/..%2fsecret.txt",
+		"/archive/%2e%2e/secret.txt",
+		"/archive/holidays/2024/../../../secret.txt",
+	} {
+		t.Run("Rejects "+path, func(t *testing.T) {
+			rec := serveFile(h.HandleArchiveFiles, path)
+			assert.Equal(t, http.StatusNotFound, rec.Code)
+			assert.NotContains(t, rec.Body.String(), "secret")
+		})
+	}
+}
+
+func TestHandleArchiveFiles_ETag(t *testing.T) {
+	archivePath := t.TempDir()
+	imagePath := filepath.Join(archivePath, "holidays", "beach.jpg")
+	assert.NoError(t, os.MkdirAll(filepath.Dir(imagePath), 0755))
+	assert.NoError(t, os.WriteFile(imagePath, []byte("image"), 0644))
+
+	h := newTestAPIHandler(t, archivePath)
+
+	rec := serveFile(h.HandleArchiveFiles, "/archive/holidays/beach.jpg")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	etag := rec.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	// Revisit with the validator
+	req := httptest.NewRequest(http.MethodGet, "/archive/holidays/beach.jpg", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	h.HandleArchiveFiles(rec, req)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Empty(t, rec.Body.String())
+
+	// A replaced image gets a new ETag
+	assert.NoError(t, os.WriteFile(imagePath, []byte("new image"), 0644))
+	req = httptest.NewRequest(http.MethodGet, "/archive/holidays/beach.jpg", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	h.HandleArchiveFiles(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "new image", rec.Body.String())
+	assert.NotEqual(t, etag, rec.Header().Get("ETag"))
+}
+
+func TestHandleArchiveFiles_ContentType(t *testing.T) {
+	archivePath := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(archivePath, "holidays"), 0755))
+
+	var jpegImage bytes.Buffer
+	assert.NoError(t, jpeg.Encode(&jpegImage, image.NewGray(image.Rect(0, 0, 4, 4)), nil))
+	for _, name := range []string{"beach.dat", "beach", "beach.png"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(archivePath, "holidays", name), jpegImage.Bytes(), 0644))
+	}
+	assert.NoError(t, os.WriteFile(filepath.Join(archivePath, "holidays", "notes.txt"), []byte("notes"), 0644))
+
+	h := newTestAPIHandler(t, archivePath)
+	for _, name := range []string{"beach.dat", "beach", "beach.png"} {
+		rec := serveFile(h.HandleArchiveFiles, "/archive/holidays/"+name)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "image/jpeg", rec.Header().Get("Content-Type"), name)
+	}
+
+	rec := serveFile(h.HandleArchiveFiles, "/archive/holidays/notes.txt")
+	assert.Equal(t, "text/plain; charset=utf-8", rec.Header().Get("Content-Type"))
+}
+
+func TestHandleStaticFiles(t *testing.T) {
+	web.InitTemplateFS(false, "")
+
+	// Run from a directory without web/static, so only the embedded assets are available
+	h := newTestAPIHandler(t, t.TempDir())
+	t.Chdir(t.TempDir())
+
+	rec := serveFile(h.HandleStaticFiles, "/static/htmx.min.js")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/javascript", rec.Header().Get("Content-Type"))
+	assert.NotEmpty(t, rec.Body.String())
+
+	for _, path := range []string{"/static/missing.js", "/static/../templates/index.html", "/static/..%2ftemplates/index.html", "/templates/index.html"} {
+		rec := serveFile(h.HandleStaticFiles, path)
+		assert.Equal(t, http.StatusNotFound, rec.Code, path)
+	}
+}
+
+func TestResolveWithin(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+		ok       bool
+	}{
+		{"catalog/image.png", filepath.Join("root", "catalog", "image.png"), true},
+		{"catalog/../image.png", filepath.Join("root", "image.png"), true},
+		{"..", "", false},
+		{"../other/image.png", "", false},
+		{"catalog/../../image.png", "", false},
+		{"..image.png", filepath.Join("root", "..image.png"), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			fullPath, ok := resolveWithin("root", tt.path)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.expected, fullPath)
+		})
+	}
+}
+
+// decodeErrorEnvelope decodes a JSON error response
+func decodeErrorEnvelope(t *testing.T, rec *httptest.ResponseRecorder) map[string]interface{} {
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	var body map[string]interface{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	return body
+}
+
+func TestHandleApiGlobalSearch_ErrorEnvelope(t *testing.T) {
+	archivePath := t.TempDir()
+	catalogPath := filepath.Join(archivePath, "holidays")
+	assert.NoError(t, os.MkdirAll(catalogPath, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(catalogPath, "index.json"), []byte(`{"beach.png": {"short_name": "Beach"}}`), 0644))
+
+	h := newTestAPIHandler(t, archivePath)
+
+	t.Run("Missing query", func(t *testing.T) {
+		rec := serveFile(h.HandleApiGlobalSearch, "/api/search-images")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+		body := decodeErrorEnvelope(t, rec)
+		assert.Equal(t, ErrCodeMissingParameter, body["code"])
+		assert.Equal(t, "Missing 'q' or 'tag' parameter", body["message"])
+		assert.NotEmpty(t, body["timestamp"])
+	})
+
+	t.Run("Failed search", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/search-images?q=beach", nil).WithContext(ctx)
+		rec := httptest.NewRecorder()
+		h.HandleApiGlobalSearch(rec, req)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+		body := decodeErrorEnvelope(t, rec)
+		assert.Equal(t, ErrCodeSearchFailed, body["code"])
+		assert.Equal(t, "Failed to perform search", body["message"])
+	})
+
+	t.Run("Wrong method", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.HandleApiGlobalSearch(rec, httptest.NewRequest(http.MethodPost, "/api/search-images?q=beach", nil))
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+		assert.Equal(t, ErrCodeMethodNotAllowed, decodeErrorEnvelope(t, rec)["code"])
+	})
+
+	t.Run("HTMX requests get plain text", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/search-images", nil)
+		req.Header.Set("HX-Request", "true")
+		rec := httptest.NewRecorder()
+		h.HandleApiGlobalSearch(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Header().Get("Content-Type"), "text/plain")
+	})
+}
+
+func TestHandleApiGlobalSearch_SortBeforeLimit(t *testing.T) {
+	archivePath := t.TempDir()
+	indexes := map[string]string{
+		"animals": `{"cat.png": {"short_name": "Alpha", "description": "Sunset cat"}}`,
+		"places":  `{"beach.png": {"short_name": "Zulu", "description": "Sunset beach"}}`,
+	}
+	for catalogName, content := range indexes {
+		catalogPath := filepath.Join(archivePath, catalogName)
+		assert.NoError(t, os.MkdirAll(catalogPath, 0755))
+		assert.NoError(t, os.WriteFile(filepath.Join(catalogPath, "index.json"), []byte(content), 0644))
+	}
+
+	h := newTestAPIHandler(t, archivePath)
+
+	// The top result of the requested order is in the last catalog
+	rec := serveFile(h.HandleApiGlobalSearch, "/api/search-images?q=sunset&sort=shortName&order=desc&limit=1")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var images []map[string]interface{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &images))
+	if assert.Len(t, images, 1) {
+		assert.Equal(t, "Zulu", images[0]["short_name"])
+		assert.Equal(t, "places", images[0]["catalog"])
+	}
+}
+
+func TestHandleApiGlobalSearch_FuzzyRankBeforeLimit(t *testing.T) {
+	archivePath := t.TempDir()
+	catalogPath := filepath.Join(archivePath, "holidays")
+	assert.NoError(t, os.MkdirAll(catalogPath, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(catalogPath, "index.json"), []byte(`{
+  "a.png": {"short_name": "Sea", "description": "Sunsat at sea"},
+  "z.png": {"short_name": "Sea", "description": "Sunset at sea"}
+}`), 0644))
+
+	h := newTestAPIHandler(t, archivePath)
+
+	// The exact match comes last in filename order
+	rec := serveFile(h.HandleApiGlobalSearch, "/api/search-images?q=sunset&fuzzy=true&limit=1")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var images []map[string]interface{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &images))
+	if assert.Len(t, images, 1) {
+		assert.Equal(t, "z.png", images[0]["filename"])
+		assert.Equal(t, 1.0, images[0]["score"])
+	}
+}
+
+func TestHandleApiUpdateCatalogMeta(t *testing.T) {
+	archivePath := t.TempDir()
+	catalogPath := filepath.Join(archivePath, "holidays")
+	assert.NoError(t, os.MkdirAll(catalogPath, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(catalogPath, "index.json"), []byte(`{"beach.png": {"short_name": "Beach"}}`), 0644))
+
+	h := newTestAPIHandler(t, archivePath)
+	assert.NoError(t, h.processor.RebuildRootIndex(context.Background()))
+
+	// callMeta calls the handler and decodes the returned metadata
+	callMeta := func(req *http.Request) (int, map[string]interface{}) {
+		rec := httptest.NewRecorder()
+		h.HandleApiUpdateCatalogMeta(rec, req)
+		var body map[string]interface{}
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		return rec.Code, body
+	}
+
+	t.Run("JSON update", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPut, "/api/catalog-meta", strings.NewReader(`{"catalog": "holidays", "title": "Holidays 2024", "description": "Beach trip"}`))
+		req.Header.Set("Content-Type", "application/json")
+		code, body := callMeta(req)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, map[string]interface{}{"catalog": "holidays", "title": "Holidays 2024", "description": "Beach trip"}, body)
+
+		// The listing and the root index show the title
+		catalogs, err := h.catalogService.GetCatalogs(context.Background())
+		assert.NoError(t, err)
+		if assert.Len(t, catalogs, 1) {
+			assert.Equal(t, "Holidays 2024", catalogs[0]["title"])
+			assert.Equal(t, "Beach trip", catalogs[0]["description"])
+		}
+		rootIndex, err := os.ReadFile(filepath.Join(archivePath, "index.json"))
+		assert.NoError(t, err)
+		assert.Contains(t, string(rootIndex), "Holidays 2024")
+	})
+
+	t.Run("Read", func(t *testing.T) {
+		code, body := callMeta(httptest.NewRequest(http.MethodGet, "/api/catalog-meta?catalog=holidays", nil))
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "Holidays 2024", body["title"])
+	})
+
+	t.Run("Form update clears the description", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/api/catalog-meta", strings.NewReader("catalog=holidays&title=Summer"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		code, body := callMeta(req)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "Summer", body["title"])
+		assert.Equal(t, "", body["description"])
+	})
+
+	t.Run("Unknown catalog", func(t *testing.T) {
+		for _, catalog := range []string{"missing", "..", "holidays/../.."} {
+			req := httptest.NewRequest(http.MethodPost, "/api/catalog-meta", strings.NewReader(`{"catalog": "`+catalog+`", "title": "X"}`))
+			req.Header.Set("Content-Type", "application/json")
+			code, body := callMeta(req)
+			assert.Equal(t, http.StatusNotFound, code, catalog)
+			assert.Equal(t, ErrCodeCatalogNotFound, body["code"], catalog)
+		}
+		assert.NoFileExists(t, filepath.Join(filepath.Dir(archivePath), "_catalog.json"))
+	})
+
+	t.Run("Missing catalog", func(t *testing.T) {
+		code, body := callMeta(httptest.NewRequest(http.MethodGet, "/api/catalog-meta", nil))
+		assert.Equal(t, http.StatusBadRequest, code)
+		assert.Equal(t, ErrCodeMissingParameter, body["code"])
+	})
+
+	t.Run("Wrong method", func(t *testing.T) {
+		code, body := callMeta(httptest.NewRequest(http.MethodDelete, "/api/catalog-meta?catalog=holidays", nil))
+		assert.Equal(t, http.StatusMethodNotAllowed, code)
+		assert.Equal(t, ErrCodeMethodNotAllowed, body["code"])
+	})
+}
+
+func TestHandleApiCatalogChildren(t *testing.T) {
+	archivePath := t.TempDir()
+	catalogPath := filepath.Join(archivePath, "holidays")
+	assert.NoError(t, os.MkdirAll(catalogPath, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(catalogPath, "index.json"),
+		[]byte(`{"beach.jpg": {}, "2024/a.jpg": {}, "2024/summer/b.jpg": {}}`), 0644))
+
+	h := newTestAPIHandler(t, archivePath)
+
+	// children lists the names returned for a catalog path
+	children := func(catalogPath string) []string {
+		rec := serveFile(h.HandleApiCatalogChildren, "/api/catalog-children?path="+catalogPath)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var body []map[string]interface{}
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		names := []string{}
+		for _, child := range body {
+			names = append(names, child["name"].(string))
+		}
+		return names
+	}
+
+	assert.Equal(t, []string{"holidays"}, children(""))
+	assert.Equal(t, []string{"holidays/2024"}, children("holidays"))
+	assert.Equal(t, []string{"holidays/2024/summer"}, children("holidays/2024"))
+	assert.Equal(t, []string{}, children("holidays/2024/summer"))
+
+	rec := serveFile(h.HandleApiCatalogChildren, "/api/catalog-children?path=holidays/../..")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, ErrCodeCatalogNotFound, decodeErrorEnvelope(t, rec)["code"])
+}
+
+func TestHandleCatalogDetail_Nested(t *testing.T) {
+	web.InitTemplateFS(false, "")
+
+	archivePath := t.TempDir()
+	catalogPath := filepath.Join(archivePath, "holidays")
+	assert.NoError(t, os.MkdirAll(catalogPath, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(catalogPath, "index.json"),
+		[]byte(`{"beach.jpg": {"short_name": "Beach"}, "2024/summer/b.jpg": {"short_name": "Boat"}}`), 0644))
+
+	h := newTestAPIHandler(t, archivePath)
+
+	rec := serveFile(h.HandleCatalogDetail, "/catalog/holidays/2024")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	body := rec.Body.String()
+	// Breadcrumbs, the nested catalog below and the image addressed by its catalog key
+	assert.Contains(t, body, `<a href="/catalog/holidays">holidays</a>`)
+	assert.Contains(t, body, `<a href="/catalog/holidays/2024">2024</a>`)
+	assert.Contains(t, body, `href="/catalog/holidays/2024/summer"`)
+	assert.Contains(t, body, `src="/archive/holidays/2024/summer/b.jpg"`)
+	assert.NotContains(t, body, "Beach")
+	// Reindexing applies to the top level catalog
+	assert.Contains(t, body, `{"catalog": "holidays"}`)
+
+	rec = serveFile(h.HandleCatalogDetail, "/catalog/holidays/../secret")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestHandleApiReprocessImage(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"model": "test-model",
+			"choices": []interface{}{
+				map[string]interface{}{
+					"message": map[string]interface{}{
+						"content": `{"short_name": "Red square", "description": "A red square."}`,
+					},
+				},
+			},
+		})
+	}))
+	defer server.Close()
+
+	archivePath := t.TempDir()
+	catalogPath := filepath.Join(archivePath, "shapes")
+	assert.NoError(t, os.MkdirAll(catalogPath, 0755))
+	var img bytes.Buffer
+	assert.NoError(t, png.Encode(&img, image.NewRGBA(image.Rect(0, 0, 10, 10))))
+	for _, name := range []string{"red.png", "blue.png"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(catalogPath, name), img.Bytes(), 0644))
+	}
+	assert.NoError(t, os.WriteFile(filepath.Join(archivePath, "secret.png"), img.Bytes(), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(catalogPath, "index.json"), []byte(`{
+		"red.png": {"short_name": "Wrong", "description": "A bad description."},
+		"blue.png": {"short_name": "Blue square", "description": "A blue square."}
+	}`), 0644))
+
+	cfg := &config.Config{APIURL: server.URL, Model: "test-model", Timeout: 10, SupportedExtensions: []string{".png"}}
+	h, err := NewAPIHandler(cfg, processor.NewCatalogProcessor(cfg, archivePath), archivePath)
+	assert.NoError(t, err)
+	assert.NoError(t, h.taskQueue.Start())
+	defer h.taskQueue.Stop()
+
+	// reprocess calls the handler with the path values the server mux extracts
+	reprocess := func(catalog, filename string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/catalog/"+catalog+"/image/"+filename+"/reprocess", nil)
+		req.SetPathValue("catalog", catalog)
+		req.SetPathValue("filename", filename)
+		rec := httptest.NewRecorder()
+		h.HandleApiReprocessImage(rec, req)
+		return rec
+	}
+
+	rec := reprocess("shapes", "red.png")
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+
+	// The previously described image gets a fresh record, the others are left alone
+	records := func() map[string]interface{} {
+		data, err := processor.NewFileScanner(cfg).LoadExistingData(filepath.Join(catalogPath, "index.json"))
+		assert.NoError(t, err)
+		return data
+	}
+	assert.Eventually(t, func() bool {
+		record, _ := records()["red.png"].(map[string]interface{})
+		return record["short_name"] == "Red square"
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "Blue square", records()["blue.png"].(map[string]interface{})["short_name"])
+	assert.Equal(t, int32(1), requests.Load())
+
+	for _, tt := range []struct {
+		catalog  string
+		filename string
+		code     string
+	}{
+		{"missing", "red.png", ErrCodeCatalogNotFound},
+		{"..", "secret.png", ErrCodeCatalogNotFound},
+		{"shapes/..", "secret.png", ErrCodeCatalogNotFound},
+		{"shapes", "../secret.png", ErrCodeImageNotFound},
+		{"shapes", "green.png", ErrCodeImageNotFound},
+		{"shapes", "", ErrCodeImageNotFound},
+	} {
+		rec := reprocess(tt.catalog, tt.filename)
+		assert.Equal(t, http.StatusNotFound, rec.Code, tt.catalog+"/"+tt.filename)
+		assert.Equal(t, tt.code, decodeErrorEnvelope(t, rec)["code"], tt.catalog+"/"+tt.filename)
+	}
+}
+
+func TestHandleReindex_AllCatalogs(t *testing.T) {
+	archivePath := t.TempDir()
+	rootIndex := `{
+		"a": {"name": "a", "image_count": 1, "last_update": "2024-01-01T00:00:00Z"},
+		"b": {"name": "b", "image_count": 1, "last_update": "2024-01-01T00:00:00Z"},
+		"c": {"name": "c", "image_count": 1, "last_update": "2024-01-01T00:00:00Z"}
+	}`
+	assert.NoError(t, os.WriteFile(filepath.Join(archivePath, "index.json"), []byte(rootIndex), 0644))
+	h := newTestAPIHandler(t, archivePath)
+
+	reindexAll := func() map[string]interface{} {
+		rec := httptest.NewRecorder()
+		h.HandleReindex(rec, httptest.NewRequest(http.MethodPost, "/api/reindex", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var body map[string]interface{}
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		return body
+	}
+
+	// Tasks that couldn't be queued are reported instead of claimed
+	body := reindexAll()
+	assert.Equal(t, "partial", body["status"])
+	assert.Equal(t, float64(0), body["queued"])
+	assert.Equal(t, float64(3), body["dropped"])
+	assert.Equal(t, "Reindex tasks queued for 0 of 3 catalogs, 3 dropped", body["message"])
+
+	assert.NoError(t, h.taskQueue.Start())
+	defer h.taskQueue.Stop()
+
+	body = reindexAll()
+	assert.Equal(t, "success", body["status"])
+	assert.Equal(t, float64(3), body["queued"])
+	assert.Equal(t, float64(0), body["dropped"])
+}
+
+// stuckIndexer is a stub processor keeping the queue worker busy until the test ends
+type stuckIndexer struct{}
+
+func (stuckIndexer) ProcessImagesCatalog(ctx context.Context, catalogDir string) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestHandleReindex_QueueErrors(t *testing.T) {
+	h := newTestAPIHandler(t, t.TempDir())
+
+	reindex := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/reindex", strings.NewReader("catalog=holidays"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		h.HandleReindex(rec, req)
+		return rec
+	}
+
+	// The queue is not running
+	rec := reindex()
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, ErrCodeReindexFailed, decodeErrorEnvelope(t, rec)["code"])
+
+	h.taskQueue = queue.NewTaskQueue(h.config, stuckIndexer{}, h.archivePath)
+	assert.NoError(t, h.taskQueue.Start())
+	defer h.taskQueue.Stop()
+	// Fill the queue behind the stuck task
+	for h.taskQueue.AddTask("filler", "manual") == nil {
+	}
+
+	rec = reindex()
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, ErrCodeQueueFull, decodeErrorEnvelope(t, rec)["code"])
+}
+
+func TestHandleReindex_EscapesCatalogName(t *testing.T) {
+	h := newTestAPIHandler(t, t.TempDir())
+	h.taskQueue = queue.NewTaskQueue(h.config, stuckIndexer{}, h.archivePath)
+	assert.NoError(t, h.taskQueue.Start())
+	defer h.taskQueue.Stop()
+
+	form := url.Values{"catalog": {`"><script>alert(1)</script>`}}
+	req := httptest.NewRequest(http.MethodPost, "/api/reindex", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("HX-Request", "true")
+	rec := httptest.NewRecorder()
+	h.HandleReindex(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "<script>")
+	assert.Contains(t, rec.Body.String(), "&#34;&gt;&lt;script&gt;alert(1)&lt;/script&gt;")
+}
+
+func TestHandleApiCancelTask(t *testing.T) {
+	h := newTestAPIHandler(t, t.TempDir())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/queue/cancel", strings.NewReader("catalog=holidays"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	h.HandleApiCancelTask(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var body map[string]interface{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, "Reindex tasks cancelled for catalog: holidays", body["message"])
+	assert.Equal(t, false, body["cancelled_running"])
+
+	rec = httptest.NewRecorder()
+	h.HandleApiCancelTask(rec, httptest.NewRequest(http.MethodGet, "/api/queue/cancel", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestHandleApiEvents(t *testing.T) {
+	archiveDir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(archiveDir, "shapes"), 0755))
+	h := newTestAPIHandler(t, archiveDir)
+	assert.NoError(t, h.taskQueue.Start())
+	defer h.taskQueue.Stop()
+
+	server := httptest.NewServer(http.HandlerFunc(h.HandleApiEvents))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	// The first line tells the subscription is in place
+	lines := bufio.NewScanner(resp.Body)
+	assert.True(t, lines.Scan())
+	assert.Equal(t, ": connected", lines.Text())
+
+	assert.NoError(t, h.taskQueue.AddTask("shapes", "manual"))
+
+	// Collect the event names until the task completed, the data belongs to the named event
+	var names []string
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for lines.Scan() {
+			line := lines.Text()
+			if name, ok := strings.CutPrefix(line, "event: "); ok {
+				names = append(names, name)
+			}
+			if data, ok := strings.CutPrefix(line, "data: "); ok {
+				var event map[string]interface{}
+				assert.NoError(t, json.Unmarshal([]byte(data), &event))
+				assert.Equal(t, "shapes", event["catalog"])
+				if event["type"] == "completed" {
+					return
+				}
+			}
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the task didn't complete")
+	}
+	assert.Contains(t, names, "queued")
+	assert.Contains(t, names, "started")
+	assert.Equal(t, "completed", names[len(names)-1])
+
+	// Disconnecting ends the handler, otherwise closing the server would hang
+	resp.Body.Close()
+
+	rec := httptest.NewRecorder()
+	h.HandleApiEvents(rec, httptest.NewRequest(http.MethodPost, "/api/events", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
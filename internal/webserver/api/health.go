@@ -0,0 +1,69 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+)
+
+// HealthStatus is the JSON body returned by the health and readiness checks
+type HealthStatus struct {
+	Status           string `json:"status"`
+	ArchiveReachable bool   `json:"archive_reachable"`
+	QueueRunning     bool   `json:"queue_running"`
+	WatcherRunning   bool   `json:"watcher_running"`
+}
+
+// HandleHealthz reports that the server is alive. It always answers 200 so orchestrators
+// only restart the process when it stops responding.
+func (h *APIHandler) HandleHealthz(w http.ResponseWriter, r *http.Request) {
+	status := h.healthStatus()
+	status.Status = "ok"
+
+	writeHealthStatus(w, http.StatusOK, status)
+}
+
+// HandleReadyz reports whether the server can serve requests: the archive directory is
+// readable and the task queue and file watcher are running. Answers 503 otherwise.
+func (h *APIHandler) HandleReadyz(w http.ResponseWriter, r *http.Request) {
+	status := h.healthStatus()
+
+	// The watcher is optional, it may have failed to initialize
+	watcherReady := h.watcher == nil || status.WatcherRunning
+	if status.ArchiveReachable && status.QueueRunning && watcherReady {
+		status.Status = "ready"
+		writeHealthStatus(w, http.StatusOK, status)
+		return
+	}
+
+	status.Status = "not_ready"
+	writeHealthStatus(w, http.StatusServiceUnavailable, status)
+}
+
+// healthStatus collects the state of the server components
+func (h *APIHandler) healthStatus() HealthStatus {
+	return HealthStatus{
+		ArchiveReachable: isDirReadable(h.archivePath),
+		QueueRunning:     h.taskQueue != nil && h.taskQueue.IsRunning(),
+		WatcherRunning:   h.watcher != nil && h.watcher.IsRunning(),
+	}
+}
+
+// isDirReadable reports whether path is a directory whose entries can be listed
+func isDirReadable(path string) bool {
+	dir, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer dir.Close()
+
+	_, err = dir.Readdirnames(1)
+	return err == nil || err == io.EOF
+}
+
+func writeHealthStatus(w http.ResponseWriter, code int, status HealthStatus) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(status)
+}
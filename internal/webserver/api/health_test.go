@@ -0,0 +1,80 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"kbase-catalog/internal/config"
+	"kbase-catalog/internal/processor"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestAPIHandler creates an API handler serving the given archive directory
+func newTestAPIHandler(t *testing.T, archivePath string) *APIHandler {
+	cfg := &config.Config{}
+	h, err := NewAPIHandler(cfg, processor.NewCatalogProcessor(cfg, archivePath), archivePath)
+	assert.NoError(t, err)
+	return h
+}
+
+// getHealth calls a health handler and decodes its response
+func getHealth(t *testing.T, handler http.HandlerFunc, path string) (int, map[string]interface{}) {
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	var body map[string]interface{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	return rec.Code, body
+}
+
+func TestHandleHealthz(t *testing.T) {
+	h := newTestAPIHandler(t, t.TempDir())
+
+	code, body := getHealth(t, h.HandleHealthz, "/healthz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok", body["status"])
+	assert.Equal(t, true, body["archive_reachable"])
+	assert.Equal(t, false, body["queue_running"])
+	assert.Contains(t, body, "watcher_running")
+}
+
+func TestHandleReadyz(t *testing.T) {
+	t.Run("Ready once started", func(t *testing.T) {
+		h := newTestAPIHandler(t, t.TempDir())
+
+		code, body := getHealth(t, h.HandleReadyz, "/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, "not_ready", body["status"])
+
+		assert.Nil(t, h.Start())
+		defer h.Stop()
+
+		code, body = getHealth(t, h.HandleReadyz, "/readyz")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "ready", body["status"])
+		assert.Equal(t, true, body["archive_reachable"])
+		assert.Equal(t, true, body["queue_running"])
+	})
+
+	t.Run("Not ready when the archive directory is missing", func(t *testing.T) {
+		h := newTestAPIHandler(t, filepath.Join(t.TempDir(), "missing"))
+		assert.NoError(t, h.taskQueue.Start())
+		defer h.Stop()
+
+		code, body := getHealth(t, h.HandleReadyz, "/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, "not_ready", body["status"])
+		assert.Equal(t, false, body["archive_reachable"])
+		assert.Equal(t, true, body["queue_running"])
+
+		// Liveness doesn't depend on the archive
+		code, body = getHealth(t, h.HandleHealthz, "/healthz")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, false, body["archive_reachable"])
+	})
+}
@@ -2,23 +2,93 @@ package api
 
 import (
 	"context"
-	"log"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"log/slog"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
+
+	"kbase-catalog/internal/logging"
 )
 
 // Middleware defines the signature for HTTP middleware
 type Middleware func(http.Handler) http.Handler
 
+// BasePathMiddleware serves the application under basePath: the prefix is stripped from the
+// request path before routing, other paths are not found and basePath itself redirects to
+// basePath/. An empty basePath serves the application at the root.
+func BasePathMiddleware(basePath string) Middleware {
+	return func(next http.Handler) http.Handler {
+		if basePath == "" {
+			return next
+		}
+
+		stripped := http.StripPrefix(basePath, next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == basePath {
+				http.Redirect(w, r, basePath+"/", http.StatusMovedPermanently)
+				return
+			}
+			if !strings.HasPrefix(r.URL.Path, basePath+"/") {
+				http.NotFound(w, r)
+				return
+			}
+			stripped.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequestIDHeader carries the ID correlating a request with its log records
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds client supplied request IDs
+const maxRequestIDLength = 128
+
+// RequestIDMiddleware keeps the request ID supplied by the client or generates a new one. The
+// ID is stored in the request context for logging and echoed in the response header.
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if !isValidRequestID(requestID) {
+			requestID = newRequestID()
+		}
+
+		w.Header().Set(RequestIDHeader, requestID)
+		next.ServeHTTP(w, r.WithContext(logging.WithRequestID(r.Context(), requestID)))
+	})
+}
+
+// isValidRequestID accepts reasonably short IDs of printable ASCII characters, so client input
+// can't garble the logs
+func isValidRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range requestID {
+		if c <= ' ' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID generates a random request ID
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
 // LoggingMiddleware logs all incoming requests
 func LoggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
-		log.Printf("%s %s %s", r.RemoteAddr, r.Method, r.URL.Path)
-
 		next.ServeHTTP(w, r)
 
-		log.Printf("Request completed in %v", time.Since(start))
+		slog.InfoContext(r.Context(), "Request completed", "remote", r.RemoteAddr, "method", r.Method, "path", r.URL.Path, "duration", time.Since(start))
 	})
 }
 
@@ -27,8 +97,8 @@ func RecoveryMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if err := recover(); err != nil {
-				log.Printf("Panic occurred: %v", err)
-				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				slog.ErrorContext(r.Context(), "Panic occurred", "error", err)
+				writeError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Internal Server Error")
 			}
 		}()
 
@@ -41,7 +111,8 @@ func CORSMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, "+RequestIDHeader)
+		w.Header().Set("Access-Control-Expose-Headers", RequestIDHeader)
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -64,3 +135,45 @@ func TimeoutMiddleware(timeout time.Duration) Middleware {
 		})
 	}
 }
+
+// AuthMiddleware requires either HTTP Basic credentials matching user and password or an
+// "Authorization: Bearer" header matching token. Empty credentials disable the respective
+// method, the middleware is a no-op when both are disabled. Requests to publicPaths are
+// always let through.
+func AuthMiddleware(user, password, token string, publicPaths ...string) Middleware {
+	return func(next http.Handler) http.Handler {
+		if user == "" && token == "" {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if slices.Contains(publicPaths, r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if token != "" {
+				if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && secureEqual(bearer, token) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			if user != "" {
+				if u, p, ok := r.BasicAuth(); ok && secureEqual(u, user) && secureEqual(p, password) {
+					next.ServeHTTP(w, r)
+					return
+				}
+				// Let browsers prompt for the credentials
+				w.Header().Set("WWW-Authenticate", `Basic realm="kbase-catalog"`)
+			}
+
+			writeError(w, r, http.StatusUnauthorized, ErrCodeUnauthorized, "Unauthorized")
+		})
+	}
+}
+
+// secureEqual compares secrets in constant time
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
@@ -0,0 +1,167 @@
+package api
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"kbase-catalog/internal/logging"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthMiddleware(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	serve := func(handler http.Handler, path string, setup func(r *http.Request)) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		if setup != nil {
+			setup(req)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Disabled without credentials", func(t *testing.T) {
+		handler := AuthMiddleware("", "", "")(next)
+		assert.Equal(t, http.StatusOK, serve(handler, "/api/reindex", nil).Code)
+	})
+
+	t.Run("Basic auth", func(t *testing.T) {
+		handler := AuthMiddleware("admin", "secret", "", "/healthz")(next)
+
+		rec := serve(handler, "/api/reindex", nil)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Equal(t, `Basic realm="kbase-catalog"`, rec.Header().Get("WWW-Authenticate"))
+
+		rec = serve(handler, "/api/reindex", func(r *http.Request) { r.SetBasicAuth("admin", "wrong") })
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+		rec = serve(handler, "/api/reindex", func(r *http.Request) { r.SetBasicAuth("admin", "secret") })
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		// Public paths don't need credentials
+		assert.Equal(t, http.StatusOK, serve(handler, "/healthz", nil).Code)
+	})
+
+	t.Run("API token", func(t *testing.T) {
+		handler := AuthMiddleware("", "", "token123")(next)
+
+		rec := serve(handler, "/api/reindex", nil)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Empty(t, rec.Header().Get("WWW-Authenticate"))
+
+		rec = serve(handler, "/api/reindex", func(r *http.Request) { r.Header.Set("Authorization", "Bearer wrong") })
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+		rec = serve(handler, "/api/reindex", func(r *http.Request) { r.Header.Set("Authorization", "Bearer token123") })
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("Either method is accepted when both are configured", func(t *testing.T) {
+		handler := AuthMiddleware("admin", "secret", "token123")(next)
+
+		rec := serve(handler, "/api/reindex", func(r *http.Request) { r.SetBasicAuth("admin", "secret") })
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		rec = serve(handler, "/api/reindex", func(r *http.Request) { r.Header.Set("Authorization", "Bearer token123") })
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+}
+
+func TestBasePathMiddleware(t *testing.T) {
+	var routed string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		routed = r.URL.Path
+	})
+
+	serve := func(handler http.Handler, path string) *httptest.ResponseRecorder {
+		routed = ""
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	handler := BasePathMiddleware("/kbase")(next)
+
+	assert.Equal(t, http.StatusOK, serve(handler, "/kbase/api/catalog").Code)
+	assert.Equal(t, "/api/catalog", routed)
+	assert.Equal(t, http.StatusOK, serve(handler, "/kbase/").Code)
+	assert.Equal(t, "/", routed)
+
+	rec := serve(handler, "/kbase")
+	assert.Equal(t, http.StatusMovedPermanently, rec.Code)
+	assert.Equal(t, "/kbase/", rec.Header().Get("Location"))
+
+	for _, path := range []string{"/api/catalog", "/kbasement/", "/"} {
+		assert.Equal(t, http.StatusNotFound, serve(handler, path).Code, path)
+		assert.Empty(t, routed, path)
+	}
+
+	// Without a base path the application is served at the root
+	assert.Equal(t, http.StatusOK, serve(BasePathMiddleware("")(next), "/api/catalog").Code)
+	assert.Equal(t, "/api/catalog", routed)
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	var contextID string
+	handler := RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contextID = logging.RequestID(r.Context())
+	}))
+
+	t.Run("Generates an ID", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/search", nil))
+
+		requestID := rec.Header().Get(RequestIDHeader)
+		assert.NotEmpty(t, requestID)
+		assert.Equal(t, requestID, contextID)
+
+		// Every request gets its own ID
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/search", nil))
+		assert.NotEqual(t, requestID, rec.Header().Get(RequestIDHeader))
+	})
+
+	t.Run("Preserves a supplied ID", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/search", nil)
+		req.Header.Set(RequestIDHeader, "client-id-1")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, "client-id-1", rec.Header().Get(RequestIDHeader))
+		assert.Equal(t, "client-id-1", contextID)
+	})
+
+	t.Run("Replaces an invalid ID", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/search", nil)
+		req.Header.Set(RequestIDHeader, "bad id\nwith newline")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.NotEqual(t, "bad id\nwith newline", rec.Header().Get(RequestIDHeader))
+		assert.NotEmpty(t, rec.Header().Get(RequestIDHeader))
+	})
+}
+
+func TestLoggingMiddleware_RequestID(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := logging.New(&out, "info", logging.FormatText)
+	assert.NoError(t, err)
+
+	previous := slog.Default()
+	slog.SetDefault(logger)
+	defer slog.SetDefault(previous)
+
+	handler := RequestIDMiddleware(LoggingMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+	req := httptest.NewRequest(http.MethodGet, "/api/search", nil)
+	req.Header.Set(RequestIDHeader, "req-7")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Contains(t, out.String(), "request_id=req-7")
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// sitemapNamespace is the XML namespace of the sitemaps protocol
+const sitemapNamespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// HandleSitemap lists the home page and the page of every catalog in the sitemaps format. The
+// last modification of a catalog is its last update, the home page takes the latest one. An
+// empty archive lists the home page only.
+func (h *APIHandler) HandleSitemap(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	catalogs, err := h.catalogService.GetCatalogs(r.Context())
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "Error getting catalogs", "error", err)
+		writeError(w, r, http.StatusInternalServerError, ErrCodeCatalogsFailed, "Failed to load catalogs")
+		return
+	}
+
+	baseURL := requestBaseURL(r, h.config.GetBasePath())
+	var catalogURLs []sitemapURL
+	var latest time.Time
+	for _, catalog := range catalogs {
+		name, _ := catalog["name"].(string)
+		if name == "" {
+			continue
+		}
+
+		entry := sitemapURL{Loc: baseURL + "/catalog/" + url.PathEscape(name)}
+		lastUpdateValue, _ := catalog["lastUpdate"].(string)
+		if lastUpdate, err := time.Parse(time.RFC3339, lastUpdateValue); err == nil {
+			entry.LastMod = lastUpdate.Format(time.RFC3339)
+			if lastUpdate.After(latest) {
+				latest = lastUpdate
+			}
+		}
+		catalogURLs = append(catalogURLs, entry)
+	}
+	sort.Slice(catalogURLs, func(i, j int) bool { return catalogURLs[i].Loc < catalogURLs[j].Loc })
+
+	home := sitemapURL{Loc: baseURL + "/"}
+	if !latest.IsZero() {
+		home.LastMod = latest.Format(time.RFC3339)
+	}
+	sitemap := sitemapURLSet{Xmlns: sitemapNamespace, URLs: append([]sitemapURL{home}, catalogURLs...)}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	io.WriteString(w, xml.Header)
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(sitemap); err != nil {
+		h.logger.ErrorContext(r.Context(), "Failed to write sitemap", "error", err)
+	}
+}
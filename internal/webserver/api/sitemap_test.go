@@ -0,0 +1,59 @@
+package api
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// getSitemap requests the sitemap and decodes it
+func getSitemap(t *testing.T, h *APIHandler) sitemapURLSet {
+	rec := httptest.NewRecorder()
+	h.HandleSitemap(rec, httptest.NewRequest(http.MethodGet, "http://example.com/sitemap.xml", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/xml; charset=utf-8", rec.Header().Get("Content-Type"))
+
+	var sitemap sitemapURLSet
+	assert.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &sitemap), rec.Body.String())
+	assert.Equal(t, sitemapNamespace, sitemap.XMLName.Space)
+	return sitemap
+}
+
+func TestHandleSitemap(t *testing.T) {
+	t.Run("Catalogs", func(t *testing.T) {
+		archivePath := t.TempDir()
+		rootIndex := `{
+			"holidays": {"name": "holidays", "image_count": 2, "last_update": "2024-03-01T10:00:00Z"},
+			"my shapes": {"name": "my shapes", "image_count": 1, "last_update": "2024-01-01T10:00:00+02:00"}
+		}`
+		assert.NoError(t, os.WriteFile(filepath.Join(archivePath, "index.json"), []byte(rootIndex), 0644))
+
+		sitemap := getSitemap(t, newTestAPIHandler(t, archivePath))
+		assert.Len(t, sitemap.URLs, 3)
+		assert.Equal(t, sitemapURL{Loc: "http://example.com/", LastMod: "2024-03-01T10:00:00Z"}, sitemap.URLs[0])
+		assert.Equal(t, "http://example.com/catalog/holidays", sitemap.URLs[1].Loc)
+		assert.Equal(t, "http://example.com/catalog/my%20shapes", sitemap.URLs[2].Loc)
+
+		for _, entry := range sitemap.URLs {
+			_, err := time.Parse(time.RFC3339, entry.LastMod)
+			assert.NoError(t, err, entry.Loc)
+		}
+	})
+
+	t.Run("Empty archive", func(t *testing.T) {
+		sitemap := getSitemap(t, newTestAPIHandler(t, t.TempDir()))
+		assert.Equal(t, []sitemapURL{{Loc: "http://example.com/"}}, sitemap.URLs)
+	})
+
+	t.Run("Method not allowed", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		newTestAPIHandler(t, t.TempDir()).HandleSitemap(rec, httptest.NewRequest(http.MethodPost, "/sitemap.xml", nil))
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	})
+}
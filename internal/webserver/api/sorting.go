@@ -2,6 +2,8 @@ package api
 
 import (
 	"sort"
+	"strings"
+	"time"
 )
 
 // sortCatalogs sorts catalogs based on specified criteria
@@ -38,12 +40,18 @@ func SortCatalogs(catalogs []map[string]interface{}, sortBy, sortOrder string) [
 			sort.SliceStable(sortedCatalogs, func(i, j int) bool {
 				count1, _ := sortedCatalogs[i]["imageCount"].(int)
 				count2, _ := sortedCatalogs[j]["imageCount"].(int)
+				if count1 == count2 {
+					return lessByName(sortedCatalogs[i], sortedCatalogs[j])
+				}
 				return count1 > count2
 			})
 		} else {
 			sort.SliceStable(sortedCatalogs, func(i, j int) bool {
 				count1, _ := sortedCatalogs[i]["imageCount"].(int)
 				count2, _ := sortedCatalogs[j]["imageCount"].(int)
+				if count1 == count2 {
+					return lessByName(sortedCatalogs[i], sortedCatalogs[j])
+				}
 				return count1 < count2
 			})
 		}
@@ -52,13 +60,33 @@ func SortCatalogs(catalogs []map[string]interface{}, sortBy, sortOrder string) [
 			sort.SliceStable(sortedCatalogs, func(i, j int) bool {
 				update1, _ := sortedCatalogs[i]["lastUpdate"].(string)
 				update2, _ := sortedCatalogs[j]["lastUpdate"].(string)
-				return update1 > update2
+				if result := compareDates(update1, update2); result != 0 {
+					return result > 0
+				}
+				return lessByName(sortedCatalogs[i], sortedCatalogs[j])
 			})
 		} else {
 			sort.SliceStable(sortedCatalogs, func(i, j int) bool {
 				update1, _ := sortedCatalogs[i]["lastUpdate"].(string)
 				update2, _ := sortedCatalogs[j]["lastUpdate"].(string)
-				return update1 < update2
+				if result := compareDates(update1, update2); result != 0 {
+					return result < 0
+				}
+				return lessByName(sortedCatalogs[i], sortedCatalogs[j])
+			})
+		}
+	case "score":
+		if sortOrder == "desc" {
+			sort.SliceStable(sortedCatalogs, func(i, j int) bool {
+				score1, _ := sortedCatalogs[i]["score"].(float64)
+				score2, _ := sortedCatalogs[j]["score"].(float64)
+				return score1 > score2
+			})
+		} else {
+			sort.SliceStable(sortedCatalogs, func(i, j int) bool {
+				score1, _ := sortedCatalogs[i]["score"].(float64)
+				score2, _ := sortedCatalogs[j]["score"].(float64)
+				return score1 < score2
 			})
 		}
 	default:
@@ -96,20 +124,25 @@ func SortCatalogImages(indexData map[string]interface{}, sortBy, sortOrder strin
 		}
 	}
 
+	return SortImages(images, sortBy, sortOrder)
+}
+
+// SortImages sorts a list of image records carrying a "filename" field in place
+func SortImages(images []map[string]interface{}, sortBy, sortOrder string) []map[string]interface{} {
 	// Sort the array based on the specified criteria
 	switch sortBy {
 	case "shortName":
 		if sortOrder == "desc" {
 			sort.SliceStable(images, func(i, j int) bool {
-				filename1, _ := images[i]["short_name"].(string)
-				filename2, _ := images[j]["short_name"].(string)
-				return filename1 > filename2
+				shortName1, _ := images[i]["short_name"].(string)
+				shortName2, _ := images[j]["short_name"].(string)
+				return shortName1 > shortName2
 			})
 		} else {
 			sort.SliceStable(images, func(i, j int) bool {
-				filename1, _ := images[i]["filename"].(string)
-				filename2, _ := images[j]["filename"].(string)
-				return filename1 < filename2
+				shortName1, _ := images[i]["short_name"].(string)
+				shortName2, _ := images[j]["short_name"].(string)
+				return shortName1 < shortName2
 			})
 		}
 	case "description":
@@ -126,6 +159,34 @@ func SortCatalogImages(indexData map[string]interface{}, sortBy, sortOrder strin
 				return filename1 < filename2
 			})
 		}
+	case "updateDate":
+		if sortOrder == "desc" {
+			sort.SliceStable(images, func(i, j int) bool {
+				date1, _ := images[i]["update_date"].(string)
+				date2, _ := images[j]["update_date"].(string)
+				return compareDates(date1, date2) > 0
+			})
+		} else {
+			sort.SliceStable(images, func(i, j int) bool {
+				date1, _ := images[i]["update_date"].(string)
+				date2, _ := images[j]["update_date"].(string)
+				return compareDates(date1, date2) < 0
+			})
+		}
+	case "score":
+		if sortOrder == "desc" {
+			sort.SliceStable(images, func(i, j int) bool {
+				score1, _ := images[i]["score"].(float64)
+				score2, _ := images[j]["score"].(float64)
+				return score1 > score2
+			})
+		} else {
+			sort.SliceStable(images, func(i, j int) bool {
+				score1, _ := images[i]["score"].(float64)
+				score2, _ := images[j]["score"].(float64)
+				return score1 < score2
+			})
+		}
 	// Add other sorting cases as needed
 	default:
 		// Default to filename sorting if an invalid sort parameter is provided
@@ -138,3 +199,27 @@ func SortCatalogImages(indexData map[string]interface{}, sortBy, sortOrder strin
 
 	return images
 }
+
+// lessByName orders catalogs by name, used as a tiebreaker to keep the output deterministic
+func lessByName(catalog1, catalog2 map[string]interface{}) bool {
+	name1, _ := catalog1["name"].(string)
+	name2, _ := catalog2["name"].(string)
+	return name1 < name2
+}
+
+// compareDates compares two RFC3339 timestamps chronologically, returning -1, 0 or 1.
+// Values that can't be parsed, like missing dates, come before every timestamp and are
+// compared as raw strings among themselves, so the order stays consistent.
+func compareDates(date1, date2 string) int {
+	time1, err1 := time.Parse(time.RFC3339, date1)
+	time2, err2 := time.Parse(time.RFC3339, date2)
+	switch {
+	case err1 != nil && err2 != nil:
+		return strings.Compare(date1, date2)
+	case err1 != nil:
+		return -1
+	case err2 != nil:
+		return 1
+	}
+	return time1.Compare(time2)
+}
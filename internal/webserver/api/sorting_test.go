@@ -0,0 +1,135 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// filenames extracts the filename of each sorted image
+func filenames(images []map[string]interface{}) []string {
+	var names []string
+	for _, img := range images {
+		names = append(names, img["filename"].(string))
+	}
+	return names
+}
+
+func TestSortCatalogImages(t *testing.T) {
+	newIndexData := func() map[string]interface{} {
+		return map[string]interface{}{
+			"a.png": map[string]interface{}{
+				"short_name":  "Cherry",
+				"description": "Second",
+				"update_date": "2024-03-01T12:00:00Z",
+			},
+			"b.png": map[string]interface{}{
+				"short_name":  "Apple",
+				"description": "First",
+				"update_date": "2024-01-15T08:30:00+02:00",
+			},
+			"c.png": map[string]interface{}{
+				"short_name":  "Banana",
+				"description": "Third",
+				// Earlier instant than a.png despite the larger local time
+				"update_date": "2024-03-01T13:00:00+04:00",
+			},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		sortBy    string
+		sortOrder string
+		expected  []string
+	}{
+		{"default is filename ascending", "", "", []string{"a.png", "b.png", "c.png"}},
+		{"shortName ascending", "shortName", "asc", []string{"b.png", "c.png", "a.png"}},
+		{"shortName descending", "shortName", "desc", []string{"a.png", "c.png", "b.png"}},
+		{"description descending", "description", "desc", []string{"c.png", "a.png", "b.png"}},
+		{"updateDate ascending", "updateDate", "asc", []string{"b.png", "c.png", "a.png"}},
+		{"updateDate descending", "updateDate", "desc", []string{"a.png", "c.png", "b.png"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sorted := SortCatalogImages(newIndexData(), tt.sortBy, tt.sortOrder)
+			assert.Equal(t, tt.expected, filenames(sorted))
+		})
+	}
+}
+
+func TestCompareDates(t *testing.T) {
+	tests := []struct {
+		name     string
+		date1    string
+		date2    string
+		expected int
+	}{
+		{"same instant with different offsets", "2024-03-01T12:00:00Z", "2024-03-01T16:00:00+04:00", 0},
+		{"earlier instant with larger local time", "2024-03-01T13:00:00+04:00", "2024-03-01T12:00:00Z", -1},
+		{"later instant", "2024-03-02T00:00:00Z", "2024-03-01T00:00:00Z", 1},
+		{"unparseable sorts first", "not a date", "2024-03-01T00:00:00Z", -1},
+		{"unparseable sorts first on either side", "2024-03-01T00:00:00Z", "not a date", 1},
+		{"unparseable dates compare as strings", "not a date", "later", 1},
+		{"missing date sorts first", "", "2024-03-01T00:00:00Z", -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, compareDates(tt.date1, tt.date2))
+		})
+	}
+}
+
+// TestSortImages_MixedDates tests that valid and unparseable dates sort consistently, the
+// string order of "z" > "2024..." > "" would otherwise contradict the chronological order
+func TestSortImages_MixedDates(t *testing.T) {
+	newImages := func() []map[string]interface{} {
+		return []map[string]interface{}{
+			{"filename": "new.png", "update_date": "2024-06-01T00:00:00Z"},
+			{"filename": "bad.png", "update_date": "z"},
+			{"filename": "old.png", "update_date": "2024-01-01T00:00:00+02:00"},
+			{"filename": "none.png"},
+		}
+	}
+
+	assert.Equal(t, []string{"none.png", "bad.png", "old.png", "new.png"}, filenames(SortImages(newImages(), "updateDate", "asc")))
+	assert.Equal(t, []string{"new.png", "old.png", "bad.png", "none.png"}, filenames(SortImages(newImages(), "updateDate", "desc")))
+}
+
+func TestSortCatalogs(t *testing.T) {
+	catalogs := []map[string]interface{}{
+		{"name": "Zeta", "imageCount": 5, "lastUpdate": "2024-03-01T12:00:00Z"},
+		{"name": "Alpha", "imageCount": 5, "lastUpdate": "2024-03-01T16:00:00+04:00"},
+		// Earlier instant than the others despite the larger local time
+		{"name": "Beta", "imageCount": 2, "lastUpdate": "2024-03-01T14:00:00+04:00"},
+		{"name": "Gamma", "imageCount": 9, "lastUpdate": "2024-03-01T11:30:00-01:00"},
+	}
+
+	names := func(sorted []map[string]interface{}) []string {
+		var result []string
+		for _, catalog := range sorted {
+			result = append(result, catalog["name"].(string))
+		}
+		return result
+	}
+
+	tests := []struct {
+		name      string
+		sortBy    string
+		sortOrder string
+		expected  []string
+	}{
+		{"default is name ascending", "", "", []string{"Alpha", "Beta", "Gamma", "Zeta"}},
+		{"lastUpdate ascending compares instants", "lastUpdate", "asc", []string{"Beta", "Alpha", "Zeta", "Gamma"}},
+		{"lastUpdate descending compares instants", "lastUpdate", "desc", []string{"Gamma", "Alpha", "Zeta", "Beta"}},
+		{"imageCount ties are ordered by name", "imageCount", "desc", []string{"Gamma", "Alpha", "Zeta", "Beta"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, names(SortCatalogs(catalogs, tt.sortBy, tt.sortOrder)))
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package queue
+
+import (
+	"time"
+)
+
+// Event types published along the life of a task
+const (
+	EventQueued    = "queued"
+	EventStarted   = "started"
+	EventProgress  = "progress"
+	EventCompleted = "completed"
+	EventFailed    = "failed"
+	EventCancelled = "cancelled"
+)
+
+// subscriberBuffer is the number of events held for a subscriber that doesn't keep up, later
+// events are dropped for it
+const subscriberBuffer = 64
+
+// Event describes a change of a task, streamed to the web interface
+type Event struct {
+	Type    string `json:"type"`
+	Catalog string `json:"catalog"`
+	Image   string `json:"image,omitempty"`
+	Source  string `json:"source,omitempty"`
+	// Done and Total count the images of the running task, set on progress events
+	Done  int       `json:"done,omitempty"`
+	Total int       `json:"total,omitempty"`
+	Error string    `json:"error,omitempty"`
+	Time  time.Time `json:"time"`
+}
+
+// Subscribe returns a channel receiving the events of the queue and a function ending the
+// subscription, which closes the channel. The queue never waits for a subscriber: events are
+// dropped while its buffer is full.
+func (q *TaskQueue) Subscribe() (<-chan Event, func()) {
+	events := make(chan Event, subscriberBuffer)
+
+	q.subscribersMutex.Lock()
+	q.subscribers[events] = struct{}{}
+	q.subscribersMutex.Unlock()
+
+	unsubscribe := func() {
+		q.subscribersMutex.Lock()
+		defer q.subscribersMutex.Unlock()
+
+		if _, ok := q.subscribers[events]; ok {
+			delete(q.subscribers, events)
+			close(events)
+		}
+	}
+	return events, unsubscribe
+}
+
+// publish sends an event about a task to every subscriber
+func (q *TaskQueue) publish(eventType string, task *ReindexTask, err error) {
+	event := Event{
+		Type:    eventType,
+		Catalog: task.CatalogName,
+		Image:   task.Image,
+		Source:  task.Source,
+		Time:    time.Now(),
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	if eventType == EventProgress {
+		q.statusMutex.Lock()
+		event.Done, event.Total = q.progressDone, q.progressTotal
+		q.statusMutex.Unlock()
+	}
+
+	q.subscribersMutex.Lock()
+	defer q.subscribersMutex.Unlock()
+
+	for events := range q.subscribers {
+		select {
+		case events <- event:
+		default:
+		}
+	}
+}
+
+// AddTotal adds images discovered by the running task, making the queue a
+// processor.ProgressTracker
+func (q *TaskQueue) AddTotal(n int) {
+	task := q.addProgress(n, 0)
+	if task != nil {
+		q.publish(EventProgress, task, nil)
+	}
+}
+
+// Complete counts an image handled by the running task
+func (q *TaskQueue) Complete() {
+	task := q.addProgress(0, 1)
+	if task != nil {
+		q.publish(EventProgress, task, nil)
+	}
+}
+
+// addProgress updates the image counts of the running task, which it returns
+func (q *TaskQueue) addProgress(total, done int) *ReindexTask {
+	q.statusMutex.Lock()
+	defer q.statusMutex.Unlock()
+
+	if q.current == nil {
+		return nil
+	}
+	q.progressTotal += total
+	q.progressDone += done
+	return q.current
+}
@@ -2,48 +2,148 @@ package queue
 
 import (
 	"context"
-	"log"
+	"errors"
+	"log/slog"
 	"path/filepath"
+	"slices"
 	"sync"
 	"time"
 
 	"kbase-catalog/internal/config"
+	"kbase-catalog/internal/metrics"
 	"kbase-catalog/internal/processor"
 )
 
 // ReindexTask represents a task to reindex a catalog
 type ReindexTask struct {
 	CatalogName string
-	Source      string // "manual" or "watcher"
-	CreatedAt   time.Time
+	// Image is the index key of the single image to describe again, empty to reindex the catalog
+	Image     string
+	Source    string // "manual" or "watcher"
+	CreatedAt time.Time
+	Attempts  int // Number of failed attempts so far
+}
+
+// FailedTask records a reindex task that kept failing after all retries
+type FailedTask struct {
+	CatalogName string    `json:"catalog"`
+	Image       string    `json:"image,omitempty"`
+	Source      string    `json:"source"`
+	Attempts    int       `json:"attempts"`
+	Error       string    `json:"error"`
+	FailedAt    time.Time `json:"failed_at"`
+}
+
+// QueueStatus describes the state of the task queue
+type QueueStatus struct {
+	Running          bool       `json:"running"`
+	Pending          int        `json:"pending"`
+	CurrentCatalog   string     `json:"current_catalog,omitempty"`
+	CurrentStartedAt *time.Time `json:"current_started_at,omitempty"`
+	LastCatalog      string     `json:"last_catalog,omitempty"`
+	LastStartedAt    *time.Time `json:"last_started_at,omitempty"`
+	LastFinishedAt   *time.Time `json:"last_finished_at,omitempty"`
+	LastError        string     `json:"last_error,omitempty"`
+}
+
+// ErrQueueNotRunning is returned for tasks added to a queue that is not running, or that
+// stopped while waiting for room
+var ErrQueueNotRunning = errors.New("task queue is not running")
+
+// ErrQueueFull is returned for tasks dropped because the queue holds as many tasks as it can
+var ErrQueueFull = errors.New("task queue is full")
+
+// maxFailedTasks caps the in-memory failure log, the oldest entries are dropped first
+const maxFailedTasks = 100
+
+// CatalogIndexer reindexes a single catalog directory, implemented by processor.CatalogProcessor
+type CatalogIndexer interface {
+	ProcessImagesCatalog(ctx context.Context, catalogDir string) error
+}
+
+// ImageReprocessor describes a single image of a catalog again, implemented by
+// processor.CatalogProcessor
+type ImageReprocessor interface {
+	ReprocessImage(ctx context.Context, catalogDir, imgKey string) error
+}
+
+// ProgressReporter reports the images discovered and completed while indexing, implemented by
+// processor.CatalogProcessor. The queue installs itself to publish progress events.
+type ProgressReporter interface {
+	SetProgress(progress processor.ProgressTracker)
 }
 
 // TaskQueue manages reindex tasks with concurrency control
 type TaskQueue struct {
-	tasks      chan *ReindexTask
-	wg         sync.WaitGroup
-	ctx        context.Context
-	cancel     context.CancelFunc
-	processor  *processor.CatalogProcessor
-	config     *config.Config
-	isRunning  bool
-	mutex      sync.RWMutex
-	archiveDir string
+	tasks       chan *ReindexTask
+	wg          sync.WaitGroup
+	ctx         context.Context
+	cancel      context.CancelFunc
+	processor   CatalogIndexer
+	config      *config.Config
+	isRunning   bool
+	mutex       sync.RWMutex
+	archiveDir  string
+	taskTimeout time.Duration
+	// drainTimeout bounds how long Stop waits for the running task before cancelling it
+	drainTimeout time.Duration
+	// draining is closed by Stop, the queue then accepts no tasks and starts no pending ones
+	draining    chan struct{}
+	drainOnce   sync.Once
+	maxRetries  int
+	retryDelay  time.Duration
+	failedTasks []FailedTask
+	current     *ReindexTask
+	currentAt   time.Time
+	// currentCancel cancels the context of the current task
+	currentCancel context.CancelFunc
+	// cancelledAt holds when the tasks of a catalog were last cancelled, the empty name stands
+	// for every catalog. Pending tasks created before are skipped.
+	cancelledAt map[string]time.Time
+	// progressDone and progressTotal count the images of the current task
+	progressDone  int
+	progressTotal int
+	last          *ReindexTask
+	lastStarted   time.Time
+	lastEnded     time.Time
+	lastError     error
+	logger        *slog.Logger
+	// statusMutex guards the failure log and the current/last task separately from mutex,
+	// as the worker updates them while Stop holds mutex waiting for it
+	statusMutex sync.Mutex
+	// subscribers receive the task events, see Subscribe
+	subscribers      map[chan Event]struct{}
+	subscribersMutex sync.Mutex
 }
 
 // NewTaskQueue creates a new task queue for reindexing
-func NewTaskQueue(cfg *config.Config, processor *processor.CatalogProcessor, archivePath string) *TaskQueue {
+func NewTaskQueue(cfg *config.Config, indexer CatalogIndexer, archivePath string) *TaskQueue {
 	ctx, cancel := context.WithCancel(context.Background())
 
-	return &TaskQueue{
-		tasks:      make(chan *ReindexTask, 100), // Buffered channel with capacity of 100
-		ctx:        ctx,
-		cancel:     cancel,
-		processor:  processor,
-		config:     cfg,
-		isRunning:  false,
-		archiveDir: archivePath,
+	q := &TaskQueue{
+		tasks:        make(chan *ReindexTask, cfg.GetQueueSize()),
+		ctx:          ctx,
+		cancel:       cancel,
+		processor:    indexer,
+		config:       cfg,
+		isRunning:    false,
+		archiveDir:   archivePath,
+		taskTimeout:  cfg.GetTaskTimeout(),
+		drainTimeout: cfg.GetQueueDrainTimeout(),
+		draining:     make(chan struct{}),
+		maxRetries:   cfg.QueueMaxRetries,
+		retryDelay:   cfg.GetQueueRetryDelay(),
+		failedTasks:  []FailedTask{},
+		cancelledAt:  make(map[string]time.Time),
+		logger:       slog.Default(),
+		subscribers:  make(map[chan Event]struct{}),
 	}
+
+	if reporter, ok := indexer.(ProgressReporter); ok {
+		reporter.SetProgress(q)
+	}
+
+	return q
 }
 
 // Start starts the task queue processing
@@ -66,10 +166,22 @@ func (q *TaskQueue) Start() error {
 				if !ok {
 					return // Channel closed
 				}
+				if q.isDraining() {
+					return // Stopping, pending tasks are dropped
+				}
+
+				// Tasks cancelled while pending are dropped
+				if q.isCancelled(task) {
+					q.logger.Info("Skipping cancelled reindex task", "catalog", task.CatalogName, "image", task.Image)
+					q.publish(EventCancelled, task, nil)
+					continue
+				}
 
 				// Process the reindex task
 				q.processTask(task)
 
+			case <-q.draining:
+				return // Stopping
 			case <-q.ctx.Done():
 				return // Context cancelled
 			}
@@ -79,67 +191,355 @@ func (q *TaskQueue) Start() error {
 	return nil
 }
 
-// Stop stops the task queue processing
+// Stop stops the task queue processing. New tasks are rejected at once and pending ones are
+// dropped, while the running task may finish for up to the drain timeout before it is
+// cancelled, so its catalog index isn't left half updated.
 func (q *TaskQueue) Stop() error {
+	if !q.IsRunning() {
+		return nil // Already stopped
+	}
+
+	// Producers waiting for room in AddTasks hold the read lock, draining releases them
+	q.drainOnce.Do(func() { close(q.draining) })
+
+	stopped := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(stopped)
+	}()
+
+	if q.drainTimeout > 0 {
+		select {
+		case <-stopped:
+		case <-time.After(q.drainTimeout):
+			q.logger.Warn("Running task didn't finish within the drain timeout, cancelling it", "timeout", q.drainTimeout)
+		}
+	}
+	q.cancel()
+	<-stopped
+
 	q.mutex.Lock()
 	defer q.mutex.Unlock()
 
 	if !q.isRunning {
-		return nil // Already stopped
+		return nil // Stopped concurrently
 	}
 
-	q.cancel()
 	close(q.tasks)
-	q.wg.Wait()
 	q.isRunning = false
 
 	return nil
 }
 
-// AddTask adds a reindex task to the queue
-func (q *TaskQueue) AddTask(catalogName, source string) error {
+// isDraining reports whether Stop was called
+func (q *TaskQueue) isDraining() bool {
+	select {
+	case <-q.draining:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsRunning reports whether the queue is processing tasks
+func (q *TaskQueue) IsRunning() bool {
 	q.mutex.RLock()
 	defer q.mutex.RUnlock()
 
-	if !q.isRunning {
-		log.Printf("Task queue not running - cannot add task for catalog %s", catalogName)
-		return nil // Queue not running
+	return q.isRunning
+}
+
+// AddTask adds a reindex task to the queue. It returns ErrQueueNotRunning when the queue is
+// not running and ErrQueueFull when the task was dropped because the queue is full.
+func (q *TaskQueue) AddTask(catalogName, source string) error {
+	task := &ReindexTask{
+		CatalogName: catalogName,
+		Source:      source,
+		CreatedAt:   time.Now(),
 	}
 
+	return q.enqueue(task)
+}
+
+// AddImageTask adds a task describing a single image of a catalog again. The task runs on the
+// same worker as the reindex tasks, so it never writes the catalog index concurrently with them.
+// It fails like AddTask.
+func (q *TaskQueue) AddImageTask(catalogName, imgKey, source string) error {
 	task := &ReindexTask{
 		CatalogName: catalogName,
+		Image:       imgKey,
 		Source:      source,
 		CreatedAt:   time.Now(),
 	}
 
+	return q.enqueue(task)
+}
+
+// AddTasks adds a reindex task for each catalog, waiting for room while the queue is full
+// instead of dropping tasks. It returns the number of tasks queued, fewer than the catalogs
+// when ctx is done or the queue stops first.
+func (q *TaskQueue) AddTasks(ctx context.Context, catalogNames []string, source string) (int, error) {
+	for i, catalogName := range catalogNames {
+		task := &ReindexTask{
+			CatalogName: catalogName,
+			Source:      source,
+			CreatedAt:   time.Now(),
+		}
+
+		if err := q.enqueueWait(ctx, task); err != nil {
+			return i, err
+		}
+	}
+	return len(catalogNames), nil
+}
+
+// enqueueWait puts a task on the queue, waiting while the queue is full
+func (q *TaskQueue) enqueueWait(ctx context.Context, task *ReindexTask) error {
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+
+	if !q.isRunning || q.isDraining() {
+		return ErrQueueNotRunning
+	}
+
 	select {
 	case q.tasks <- task:
-		log.Printf("Added reindex task for catalog %s (source: %s)", catalogName, source)
+		q.logger.Info("Added reindex task", "catalog", task.CatalogName, "source", task.Source)
+		q.publish(EventQueued, task, nil)
 		return nil
-	default:
-		// Channel is full, log warning but still add task
-		log.Printf("Task queue is full - dropping task for catalog %s", catalogName)
-		// For now we'll silently drop if full, but in a more robust implementation,
-		// we might want to retry or queue with backoff
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-q.draining:
+		return ErrQueueNotRunning
+	}
+}
+
+// enqueue puts a task on the queue, dropping it if the queue is not running or full
+func (q *TaskQueue) enqueue(task *ReindexTask) error {
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+
+	if !q.isRunning || q.isDraining() {
+		q.logger.Warn("Task queue not running - cannot add task", "catalog", task.CatalogName)
+		return ErrQueueNotRunning
+	}
+
+	select {
+	case q.tasks <- task:
+		q.logger.Info("Added reindex task", "catalog", task.CatalogName, "source", task.Source)
+		q.publish(EventQueued, task, nil)
 		return nil
+	default:
+		q.logger.Warn("Task queue is full - dropping task", "catalog", task.CatalogName)
+		return ErrQueueFull
 	}
 }
 
 // processTask processes a single reindex task
 func (q *TaskQueue) processTask(task *ReindexTask) {
-	// TODO add rate limiting here and error handling for failed tasks
-
 	// For now, just process the catalog directly
 	catalogPath := filepath.Join(q.archiveDir, task.CatalogName)
 
-	log.Printf("Processing reindex task for catalog %s (source: %s)", task.CatalogName, task.Source)
+	q.logger.Info("Processing reindex task", "catalog", task.CatalogName, "image", task.Image, "source", task.Source)
+
+	// Bound each task so a stuck catalog doesn't block the tasks queued after it
+	ctx, cancel := context.WithTimeout(q.ctx, q.taskTimeout)
+	defer cancel()
 
-	err := q.processor.ProcessImagesCatalog(q.ctx, catalogPath)
-	if err != nil {
-		// TODO retry or mark as failed
+	q.markStarted(task, cancel)
+	q.publish(EventStarted, task, nil)
+
+	err := q.runTask(ctx, task, catalogPath)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		q.logger.Error("Reindex task timed out", "catalog", task.CatalogName, "image", task.Image, "timeout", q.taskTimeout)
+		err = ctx.Err()
+	} else if errors.Is(ctx.Err(), context.Canceled) && q.ctx.Err() == nil {
+		// Cancelled through CancelTasks, neither retried nor recorded as failed
+		q.logger.Warn("Reindex task cancelled", "catalog", task.CatalogName, "image", task.Image)
+		q.markFinished(task, ctx.Err())
+		q.publish(EventCancelled, task, nil)
+		return
+	} else if err != nil {
 		// Log error but don't stop processing other tasks
-		log.Printf("Failed to reindex catalog %s: %v", task.CatalogName, err)
+		q.logger.Error("Failed to reindex catalog", "catalog", task.CatalogName, "image", task.Image, "error", err)
 	} else {
-		log.Printf("Successfully reindexed catalog %s", task.CatalogName)
+		q.logger.Info("Successfully reindexed catalog", "catalog", task.CatalogName, "image", task.Image)
+	}
+
+	q.markFinished(task, err)
+	metrics.QueueTasks.Inc()
+	if err == nil {
+		q.publish(EventCompleted, task, nil)
+		q.clearFailures(task.CatalogName)
+		return
+	}
+	metrics.QueueTaskFailures.Inc()
+	q.publish(EventFailed, task, err)
+
+	if q.ctx.Err() != nil {
+		return // Queue stopped, don't retry
+	}
+
+	task.Attempts++
+	if task.Attempts <= q.maxRetries {
+		q.logger.Warn("Retrying reindex task", "catalog", task.CatalogName, "delay", q.retryDelay,
+			"attempt", task.Attempts+1, "max_attempts", q.maxRetries+1)
+		q.scheduleRetry(task)
+		return
+	}
+
+	q.logger.Error("Giving up on reindex task", "catalog", task.CatalogName, "attempts", task.Attempts)
+	q.recordFailure(task, err)
+}
+
+// runTask reindexes the catalog of a task, or describes its single image again
+func (q *TaskQueue) runTask(ctx context.Context, task *ReindexTask, catalogPath string) error {
+	if task.Image == "" {
+		return q.processor.ProcessImagesCatalog(ctx, catalogPath)
+	}
+
+	reprocessor, ok := q.processor.(ImageReprocessor)
+	if !ok {
+		return errors.New("the processor can't reprocess single images")
+	}
+	return reprocessor.ReprocessImage(ctx, catalogPath, task.Image)
+}
+
+// scheduleRetry queues the task again after the retry delay. The delay keeps a failing
+// catalog from spinning, and waiting outside the worker lets other tasks run meanwhile.
+func (q *TaskQueue) scheduleRetry(task *ReindexTask) {
+	go func() {
+		select {
+		case <-time.After(q.retryDelay):
+			// A retry dropped from the full queue shows in the failure log instead of vanishing
+			if err := q.enqueue(task); errors.Is(err, ErrQueueFull) {
+				q.recordFailure(task, err)
+			}
+		case <-q.ctx.Done():
+		}
+	}()
+}
+
+// markStarted records the task the worker is currently processing and how to cancel it
+func (q *TaskQueue) markStarted(task *ReindexTask, cancel context.CancelFunc) {
+	q.statusMutex.Lock()
+	defer q.statusMutex.Unlock()
+
+	q.current = task
+	q.currentAt = time.Now()
+	q.currentCancel = cancel
+	q.progressDone = 0
+	q.progressTotal = 0
+}
+
+// markFinished moves the current task to the last processed one along with its outcome
+func (q *TaskQueue) markFinished(task *ReindexTask, err error) {
+	q.statusMutex.Lock()
+	defer q.statusMutex.Unlock()
+
+	q.last = task
+	q.lastStarted = q.currentAt
+	q.lastEnded = time.Now()
+	q.lastError = err
+	q.current = nil
+	q.currentCancel = nil
+}
+
+// CancelTasks cancels the running task and drops the pending tasks of a catalog, of every
+// catalog when catalogName is empty. It reports whether a running task was cancelled.
+func (q *TaskQueue) CancelTasks(catalogName string) bool {
+	q.statusMutex.Lock()
+	defer q.statusMutex.Unlock()
+
+	q.cancelledAt[catalogName] = time.Now()
+
+	if q.current == nil || q.currentCancel == nil {
+		return false
+	}
+	if catalogName != "" && q.current.CatalogName != catalogName {
+		return false
+	}
+
+	q.logger.Info("Cancelling reindex task", "catalog", q.current.CatalogName, "image", q.current.Image)
+	q.currentCancel()
+	return true
+}
+
+// isCancelled reports whether a task was queued before its catalog, or all catalogs, were cancelled
+func (q *TaskQueue) isCancelled(task *ReindexTask) bool {
+	q.statusMutex.Lock()
+	defer q.statusMutex.Unlock()
+
+	for _, name := range []string{"", task.CatalogName} {
+		if cancelledAt, ok := q.cancelledAt[name]; ok && !task.CreatedAt.After(cancelledAt) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetStatus returns a snapshot of the queue state
+func (q *TaskQueue) GetStatus() QueueStatus {
+	q.mutex.RLock()
+	status := QueueStatus{
+		Running: q.isRunning,
+		Pending: len(q.tasks),
 	}
+	q.mutex.RUnlock()
+
+	q.statusMutex.Lock()
+	defer q.statusMutex.Unlock()
+
+	if q.current != nil {
+		startedAt := q.currentAt
+		status.CurrentCatalog = q.current.CatalogName
+		status.CurrentStartedAt = &startedAt
+	}
+	if q.last != nil {
+		startedAt, finishedAt := q.lastStarted, q.lastEnded
+		status.LastCatalog = q.last.CatalogName
+		status.LastStartedAt = &startedAt
+		status.LastFinishedAt = &finishedAt
+		if q.lastError != nil {
+			status.LastError = q.lastError.Error()
+		}
+	}
+
+	return status
+}
+
+// recordFailure adds a task that exhausted its retries to the failure log
+func (q *TaskQueue) recordFailure(task *ReindexTask, err error) {
+	q.statusMutex.Lock()
+	defer q.statusMutex.Unlock()
+
+	q.failedTasks = append(q.failedTasks, FailedTask{
+		CatalogName: task.CatalogName,
+		Image:       task.Image,
+		Source:      task.Source,
+		Attempts:    task.Attempts,
+		Error:       err.Error(),
+		FailedAt:    time.Now(),
+	})
+	if len(q.failedTasks) > maxFailedTasks {
+		q.failedTasks = q.failedTasks[len(q.failedTasks)-maxFailedTasks:]
+	}
+}
+
+// clearFailures removes the failure log entries of a catalog that was reindexed successfully
+func (q *TaskQueue) clearFailures(catalogName string) {
+	q.statusMutex.Lock()
+	defer q.statusMutex.Unlock()
+
+	q.failedTasks = slices.DeleteFunc(q.failedTasks, func(failed FailedTask) bool {
+		return failed.CatalogName == catalogName
+	})
+}
+
+// GetFailedTasks returns a copy of the tasks that failed after exhausting their retries
+func (q *TaskQueue) GetFailedTasks() []FailedTask {
+	q.statusMutex.Lock()
+	defer q.statusMutex.Unlock()
+
+	return slices.Clone(q.failedTasks)
 }
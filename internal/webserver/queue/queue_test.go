@@ -1,9 +1,17 @@
 package queue
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"kbase-catalog/internal/config"
+	"kbase-catalog/internal/logging"
 	"kbase-catalog/internal/processor"
 
 	"github.com/stretchr/testify/assert"
@@ -29,6 +37,15 @@ func TestNewTaskQueue(t *testing.T) {
 	assert.NotNil(t, queue.cancel)
 }
 
+func TestNewTaskQueue_QueueSize(t *testing.T) {
+	queue := NewTaskQueue(&config.Config{QueueSize: 7}, &gatedIndexer{}, "/tmp/test-archive")
+	assert.Equal(t, 7, cap(queue.tasks))
+
+	// Without a size the queue holds the default number of tasks
+	queue = NewTaskQueue(&config.Config{}, &gatedIndexer{}, "/tmp/test-archive")
+	assert.Equal(t, config.DefaultQueueSize, cap(queue.tasks))
+}
+
 func TestTaskQueue_Start(t *testing.T) {
 	// Create a mock config
 	mockConfig := &config.Config{}
@@ -89,9 +106,9 @@ func TestTaskQueue_AddTask(t *testing.T) {
 
 	queue := NewTaskQueue(mockConfig, realProcessor, archivePath)
 
-	// Try to add task when queue is not running - should return nil (no error)
+	// Try to add task when queue is not running - the task is rejected
 	err := queue.AddTask("test-catalog", "manual")
-	assert.NoError(t, err)
+	assert.ErrorIs(t, err, ErrQueueNotRunning)
 
 	// Start the queue
 	err = queue.Start()
@@ -107,26 +124,574 @@ func TestTaskQueue_AddTask(t *testing.T) {
 }
 
 func TestTaskQueue_AddTask_WithFullChannel(t *testing.T) {
-	// Create a mock config
-	mockConfig := &config.Config{}
-	archivePath := "/tmp/test-archive"
-
-	// Create a real processor for testing
-	realProcessor := processor.NewCatalogProcessor(mockConfig, archivePath)
-
-	queue := NewTaskQueue(mockConfig, realProcessor, archivePath)
+	indexer := &gatedIndexer{gate: make(chan struct{}), calls: make(chan string, 200)}
+	queue := NewTaskQueue(&config.Config{}, indexer, "/tmp/test-archive")
 
 	// Start the queue
 	err := queue.Start()
 	assert.NoError(t, err)
-	assert.True(t, queue.isRunning)
+	defer queue.Stop()
+
+	// The worker holds the first task, the others fill the channel
+	assert.NoError(t, queue.AddTask("first", "manual"))
+	assert.Eventually(t, func() bool { return queue.GetStatus().CurrentCatalog == "first" }, 5*time.Second, 10*time.Millisecond)
+	for i := 0; i < cap(queue.tasks); i++ {
+		assert.NoError(t, queue.AddTask("test-catalog", "manual"))
+	}
+
+	// Tasks beyond the capacity are dropped and reported
+	assert.ErrorIs(t, queue.AddTask("test-catalog", "manual"), ErrQueueFull)
+	assert.ErrorIs(t, queue.AddImageTask("test-catalog", "a.jpg", "manual"), ErrQueueFull)
+
+	// Once the worker catches up tasks are accepted again
+	close(indexer.gate)
+	assert.Eventually(t, func() bool { return queue.AddTask("test-catalog", "manual") == nil }, 5*time.Second, 10*time.Millisecond)
+}
+
+// gatedIndexer is a stub processor that reports the catalogs it indexes once its gate is open
+type gatedIndexer struct {
+	gate  chan struct{}
+	calls chan string
+}
+
+func (g *gatedIndexer) ProcessImagesCatalog(ctx context.Context, catalogDir string) error {
+	select {
+	case <-g.gate:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	g.calls <- filepath.Base(catalogDir)
+	return nil
+}
+
+func TestTaskQueue_AddTasks(t *testing.T) {
+	catalogs := make([]string, 250)
+	for i := range catalogs {
+		catalogs[i] = fmt.Sprintf("catalog-%03d", i)
+	}
+
+	t.Run("Waits for room instead of dropping", func(t *testing.T) {
+		indexer := &gatedIndexer{gate: make(chan struct{}), calls: make(chan string, len(catalogs))}
+		queue := NewTaskQueue(&config.Config{}, indexer, "/tmp/test-archive")
+		assert.NoError(t, queue.Start())
+		defer queue.Stop()
+
+		type result struct {
+			queued int
+			err    error
+		}
+		done := make(chan result, 1)
+		go func() {
+			queued, err := queue.AddTasks(context.Background(), catalogs, "manual")
+			done <- result{queued, err}
+		}()
+
+		// The worker is stuck on the first task, the producer waits on the full buffer
+		assert.Eventually(t, func() bool { return queue.GetStatus().Pending == cap(queue.tasks) }, 5*time.Second, 10*time.Millisecond)
+		select {
+		case <-done:
+			t.Fatal("AddTasks returned while the queue was full")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		close(indexer.gate)
+		select {
+		case res := <-done:
+			assert.NoError(t, res.err)
+			assert.Equal(t, len(catalogs), res.queued)
+		case <-time.After(5 * time.Second):
+			t.Fatal("AddTasks did not return once the queue drained")
+		}
+
+		// Every catalog is indexed exactly once
+		var indexed []string
+		for range catalogs {
+			select {
+			case catalogName := <-indexer.calls:
+				indexed = append(indexed, catalogName)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("only %d of %d catalogs were indexed", len(indexed), len(catalogs))
+			}
+		}
+		assert.ElementsMatch(t, catalogs, indexed)
+	})
+
+	t.Run("Stops waiting when the context is done", func(t *testing.T) {
+		indexer := &gatedIndexer{gate: make(chan struct{}), calls: make(chan string, len(catalogs))}
+		queue := NewTaskQueue(&config.Config{}, indexer, "/tmp/test-archive")
+		assert.NoError(t, queue.Start())
+		defer queue.Stop()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		queued, err := queue.AddTasks(ctx, catalogs, "manual")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		// The buffer and the task held by the worker
+		assert.Equal(t, cap(queue.tasks)+1, queued)
+	})
+
+	t.Run("Stops waiting when the queue stops", func(t *testing.T) {
+		indexer := &gatedIndexer{gate: make(chan struct{}), calls: make(chan string, len(catalogs))}
+		queue := NewTaskQueue(&config.Config{}, indexer, "/tmp/test-archive")
+		assert.NoError(t, queue.Start())
+
+		done := make(chan error, 1)
+		go func() {
+			_, err := queue.AddTasks(context.Background(), catalogs, "manual")
+			done <- err
+		}()
+		assert.Eventually(t, func() bool { return queue.GetStatus().Pending == cap(queue.tasks) }, 5*time.Second, 10*time.Millisecond)
+
+		assert.NoError(t, queue.Stop())
+		select {
+		case err := <-done:
+			assert.ErrorIs(t, err, ErrQueueNotRunning)
+		case <-time.After(5 * time.Second):
+			t.Fatal("AddTasks kept waiting after Stop")
+		}
+	})
+
+	t.Run("Not running", func(t *testing.T) {
+		queue := NewTaskQueue(&config.Config{}, &gatedIndexer{}, "/tmp/test-archive")
+		queued, err := queue.AddTasks(context.Background(), catalogs, "manual")
+		assert.ErrorIs(t, err, ErrQueueNotRunning)
+		assert.Zero(t, queued)
+	})
+}
 
-	// Fill up the channel (capacity is 100) by adding more tasks than capacity
-	for i := 0; i < 105; i++ { // Add 105 tasks to exceed buffer
-		err = queue.AddTask("test-catalog", "manual")
+// slowIndexer is a stub processor taking delay to index a catalog, unless cancelled first
+type slowIndexer struct {
+	delay    time.Duration
+	started  chan string
+	finished atomic.Int32
+}
+
+func (s *slowIndexer) ProcessImagesCatalog(ctx context.Context, catalogDir string) error {
+	s.started <- filepath.Base(catalogDir)
+	select {
+	case <-time.After(s.delay):
+		s.finished.Add(1)
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestTaskQueue_Stop_Drain(t *testing.T) {
+	t.Run("The running task finishes", func(t *testing.T) {
+		indexer := &slowIndexer{delay: 300 * time.Millisecond, started: make(chan string, 10)}
+		queue := NewTaskQueue(&config.Config{QueueDrainTimeout: 5}, indexer, "/tmp/test-archive")
+		assert.NoError(t, queue.Start())
+
+		assert.NoError(t, queue.AddTask("running", "manual"))
+		assert.NoError(t, queue.AddTask("pending", "manual"))
+		assert.Equal(t, "running", <-indexer.started)
+
+		stopped := make(chan error, 1)
+		go func() { stopped <- queue.Stop() }()
+
+		// No task is accepted while draining
+		assert.Eventually(t, func() bool {
+			return errors.Is(queue.AddTask("late", "manual"), ErrQueueNotRunning)
+		}, time.Second, time.Millisecond)
+
+		select {
+		case err := <-stopped:
+			assert.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("Stop did not return")
+		}
+		assert.Equal(t, int32(1), indexer.finished.Load())
+		assert.Empty(t, queue.GetStatus().LastError)
+		assert.False(t, queue.IsRunning())
+
+		// The pending task was dropped
+		select {
+		case catalogName := <-indexer.started:
+			t.Fatalf("unexpected task for catalog %s", catalogName)
+		default:
+		}
+	})
+
+	t.Run("The running task is cancelled after the timeout", func(t *testing.T) {
+		indexer := &slowIndexer{delay: time.Minute, started: make(chan string, 10)}
+		queue := NewTaskQueue(&config.Config{}, indexer, "/tmp/test-archive")
+		queue.drainTimeout = 50 * time.Millisecond
+		assert.NoError(t, queue.Start())
+
+		assert.NoError(t, queue.AddTask("stuck", "manual"))
+		assert.Equal(t, "stuck", <-indexer.started)
+
+		start := time.Now()
+		assert.NoError(t, queue.Stop())
+		assert.GreaterOrEqual(t, time.Since(start), queue.drainTimeout)
+		assert.Less(t, time.Since(start), 5*time.Second)
+		assert.Zero(t, indexer.finished.Load())
+	})
+}
+
+// blockingIndexer is a stub processor that blocks until its context is cancelled
+type blockingIndexer struct {
+	calls chan string
+}
+
+func (b *blockingIndexer) ProcessImagesCatalog(ctx context.Context, catalogDir string) error {
+	b.calls <- filepath.Base(catalogDir)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestTaskQueue_ProcessTask_Timeout(t *testing.T) {
+	mockConfig := &config.Config{}
+	indexer := &blockingIndexer{calls: make(chan string, 10)}
+
+	queue := NewTaskQueue(mockConfig, indexer, "/tmp/test-archive")
+	queue.taskTimeout = 50 * time.Millisecond
+
+	t.Run("processTask returns after the timeout", func(t *testing.T) {
+		start := time.Now()
+		queue.processTask(&ReindexTask{CatalogName: "stuck", Source: "manual"})
+
+		assert.GreaterOrEqual(t, time.Since(start), queue.taskTimeout)
+		assert.Less(t, time.Since(start), 5*time.Second)
+		assert.Equal(t, "stuck", <-indexer.calls)
+	})
+
+	t.Run("The queue keeps running after a timed out task", func(t *testing.T) {
+		err := queue.Start()
 		assert.NoError(t, err)
+		defer queue.Stop()
+
+		assert.NoError(t, queue.AddTask("first", "manual"))
+		assert.NoError(t, queue.AddTask("second", "manual"))
+
+		for _, expected := range []string{"first", "second"} {
+			select {
+			case catalogName := <-indexer.calls:
+				assert.Equal(t, expected, catalogName)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("task for catalog %s was not processed", expected)
+			}
+		}
+		assert.True(t, queue.isRunning)
+	})
+}
+
+func TestTaskQueue_CancelTasks(t *testing.T) {
+	indexer := &blockingIndexer{calls: make(chan string, 10)}
+	queue := NewTaskQueue(&config.Config{QueueMaxRetries: 2}, indexer, "/tmp/test-archive")
+	queue.retryDelay = 10 * time.Millisecond
+
+	// nextCall waits for the stub to start indexing a catalog
+	nextCall := func() string {
+		select {
+		case catalogName := <-indexer.calls:
+			return catalogName
+		case <-time.After(5 * time.Second):
+			t.Fatal("no task was processed")
+			return ""
+		}
 	}
 
-	// Stop the queue for clean up
-	queue.Stop()
+	assert.NoError(t, queue.Start())
+	defer queue.Stop()
+
+	assert.False(t, queue.CancelTasks(""), "nothing is running")
+
+	assert.NoError(t, queue.AddTask("huge", "manual"))
+	assert.Equal(t, "huge", nextCall())
+	assert.NoError(t, queue.AddTask("huge", "watcher"))
+	assert.NoError(t, queue.AddTask("other", "manual"))
+
+	// Cancelling another catalog leaves the running task alone
+	assert.False(t, queue.CancelTasks("unrelated"))
+	assert.Equal(t, "huge", queue.GetStatus().CurrentCatalog)
+
+	// The running task stops and its pending duplicate is dropped, other catalogs still run
+	assert.True(t, queue.CancelTasks("huge"))
+	assert.Equal(t, "other", nextCall())
+
+	status := queue.GetStatus()
+	assert.Equal(t, "huge", status.LastCatalog)
+	assert.Equal(t, context.Canceled.Error(), status.LastError)
+	assert.Empty(t, queue.GetFailedTasks())
+
+	// Cancelling everything stops the current task, which is not retried
+	assert.True(t, queue.CancelTasks(""))
+	select {
+	case catalogName := <-indexer.calls:
+		t.Fatalf("unexpected task for catalog %s", catalogName)
+	case <-time.After(100 * time.Millisecond):
+	}
+	assert.Eventually(t, func() bool { return queue.GetStatus().CurrentCatalog == "" }, time.Second, 10*time.Millisecond)
+
+	// Tasks queued after the cancellation run again
+	assert.NoError(t, queue.AddTask("huge", "manual"))
+	assert.Equal(t, "huge", nextCall())
+}
+
+// flakyIndexer is a stub processor failing its first failures calls
+type flakyIndexer struct {
+	failures int
+	calls    chan int
+	count    int
+}
+
+func (f *flakyIndexer) ProcessImagesCatalog(ctx context.Context, catalogDir string) error {
+	f.count++
+	defer func() { f.calls <- f.count }()
+	if f.failures < 0 || f.count <= f.failures {
+		return errors.New("indexing failed")
+	}
+	return nil
+}
+
+// waitForCalls waits until the stub reports the expected number of calls
+func waitForCalls(t *testing.T, calls chan int, expected int) {
+	for {
+		select {
+		case count := <-calls:
+			if count == expected {
+				return
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected %d calls to the processor", expected)
+		}
+	}
+}
+
+func TestTaskQueue_Retry(t *testing.T) {
+	t.Run("Task succeeds after failing twice", func(t *testing.T) {
+		indexer := &flakyIndexer{failures: 2, calls: make(chan int, 10)}
+		queue := NewTaskQueue(&config.Config{QueueMaxRetries: 3}, indexer, "/tmp/test-archive")
+		queue.retryDelay = 10 * time.Millisecond
+
+		assert.NoError(t, queue.Start())
+		defer queue.Stop()
+		assert.NoError(t, queue.AddTask("flaky", "manual"))
+
+		waitForCalls(t, indexer.calls, 3)
+		assert.Empty(t, queue.GetFailedTasks())
+
+		// No further attempts after the success
+		select {
+		case count := <-indexer.calls:
+			t.Fatalf("unexpected attempt %d", count)
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+
+	t.Run("Task lands in failures after exhausting retries", func(t *testing.T) {
+		indexer := &flakyIndexer{failures: -1, calls: make(chan int, 10)}
+		queue := NewTaskQueue(&config.Config{QueueMaxRetries: 2}, indexer, "/tmp/test-archive")
+		queue.retryDelay = 10 * time.Millisecond
+
+		assert.NoError(t, queue.Start())
+		defer queue.Stop()
+		assert.NoError(t, queue.AddTask("broken", "watcher"))
+
+		waitForCalls(t, indexer.calls, 3)
+		assert.Eventually(t, func() bool { return len(queue.GetFailedTasks()) == 1 }, time.Second, 10*time.Millisecond)
+
+		failed := queue.GetFailedTasks()[0]
+		assert.Equal(t, "broken", failed.CatalogName)
+		assert.Equal(t, "watcher", failed.Source)
+		assert.Equal(t, 3, failed.Attempts)
+		assert.Equal(t, "indexing failed", failed.Error)
+
+		// The task is not retried once it is recorded as failed
+		select {
+		case count := <-indexer.calls:
+			t.Fatalf("unexpected attempt %d", count)
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+}
+
+// reprocessingIndexer is a stub processor recording reindexed catalogs and reprocessed images
+type reprocessingIndexer struct {
+	calls chan string
+}
+
+func (r *reprocessingIndexer) ProcessImagesCatalog(ctx context.Context, catalogDir string) error {
+	r.calls <- filepath.Base(catalogDir)
+	return nil
+}
+
+func (r *reprocessingIndexer) ReprocessImage(ctx context.Context, catalogDir, imgKey string) error {
+	r.calls <- filepath.Base(catalogDir) + ":" + imgKey
+	return nil
+}
+
+func TestTaskQueue_AddImageTask(t *testing.T) {
+	t.Run("Image tasks reprocess the image only", func(t *testing.T) {
+		indexer := &reprocessingIndexer{calls: make(chan string, 10)}
+		queue := NewTaskQueue(&config.Config{}, indexer, "/tmp/test-archive")
+
+		assert.NoError(t, queue.Start())
+		defer queue.Stop()
+		assert.NoError(t, queue.AddImageTask("holidays", "2024/beach.jpg", "manual"))
+		assert.NoError(t, queue.AddTask("holidays", "manual"))
+
+		for _, expected := range []string{"holidays:2024/beach.jpg", "holidays"} {
+			select {
+			case call := <-indexer.calls:
+				assert.Equal(t, expected, call)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("task %s was not processed", expected)
+			}
+		}
+	})
+
+	t.Run("Processors without single image support fail the task", func(t *testing.T) {
+		indexer := &flakyIndexer{calls: make(chan int, 10)}
+		queue := NewTaskQueue(&config.Config{}, indexer, "/tmp/test-archive")
+
+		queue.processTask(&ReindexTask{CatalogName: "holidays", Image: "beach.jpg", Source: "manual"})
+
+		assert.Zero(t, indexer.count)
+		failed := queue.GetFailedTasks()
+		if assert.Len(t, failed, 1) {
+			assert.Equal(t, "beach.jpg", failed[0].Image)
+		}
+	})
+}
+
+func TestTaskQueue_GetStatus(t *testing.T) {
+	indexer := &blockingIndexer{calls: make(chan string, 10)}
+	queue := NewTaskQueue(&config.Config{}, indexer, "/tmp/test-archive")
+
+	status := queue.GetStatus()
+	assert.False(t, status.Running)
+	assert.Equal(t, 0, status.Pending)
+	assert.Empty(t, status.CurrentCatalog)
+	assert.Empty(t, status.LastCatalog)
+
+	assert.NoError(t, queue.Start())
+	defer queue.Stop()
+
+	// The first task keeps the worker busy, the rest stay pending
+	for _, catalogName := range []string{"first", "second", "third", "fourth"} {
+		assert.NoError(t, queue.AddTask(catalogName, "manual"))
+	}
+	select {
+	case catalogName := <-indexer.calls:
+		assert.Equal(t, "first", catalogName)
+	case <-time.After(5 * time.Second):
+		t.Fatal("first task was not processed")
+	}
+
+	status = queue.GetStatus()
+	assert.True(t, status.Running)
+	assert.Equal(t, 3, status.Pending)
+	assert.Equal(t, "first", status.CurrentCatalog)
+	assert.NotNil(t, status.CurrentStartedAt)
+	assert.Empty(t, status.LastCatalog)
+}
+
+func TestTaskQueue_LogLevel(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := logging.New(&out, "error", logging.FormatText)
+	assert.NoError(t, err)
+
+	queue := NewTaskQueue(&config.Config{}, &flakyIndexer{calls: make(chan int, 10)}, "/tmp/test-archive")
+	queue.logger = logger
+
+	// Successful tasks only log at info level
+	queue.processTask(&ReindexTask{CatalogName: "quiet", Source: "manual"})
+	assert.Empty(t, out.String())
+
+	// Errors still get through
+	failing := NewTaskQueue(&config.Config{}, &flakyIndexer{failures: -1, calls: make(chan int, 10)}, "/tmp/test-archive")
+	failing.logger = logger
+	failing.processTask(&ReindexTask{CatalogName: "broken", Source: "manual"})
+	assert.Contains(t, out.String(), "level=ERROR")
+	assert.Contains(t, out.String(), "catalog=broken")
+	assert.NotContains(t, out.String(), "level=INFO")
+}
+
+// progressIndexer is a stub processor reporting two images per catalog, failing the catalog "broken"
+type progressIndexer struct {
+	progress processor.ProgressTracker
+}
+
+func (p *progressIndexer) SetProgress(progress processor.ProgressTracker) {
+	p.progress = progress
+}
+
+func (p *progressIndexer) ProcessImagesCatalog(ctx context.Context, catalogDir string) error {
+	if filepath.Base(catalogDir) == "broken" {
+		return errors.New("indexing failed")
+	}
+	p.progress.AddTotal(2)
+	p.progress.Complete()
+	p.progress.Complete()
+	return nil
+}
+
+// nextEvent waits for the next event of a subscription
+func nextEvent(t *testing.T, events <-chan Event) Event {
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event was published")
+		return Event{}
+	}
+}
+
+func TestTaskQueue_Subscribe(t *testing.T) {
+	indexer := &progressIndexer{}
+	queue := NewTaskQueue(&config.Config{}, indexer, "/tmp/test-archive")
+	assert.NotNil(t, indexer.progress, "the queue tracks the progress of the indexer")
+
+	events, unsubscribe := queue.Subscribe()
+	assert.NoError(t, queue.Start())
+	defer queue.Stop()
+
+	assert.NoError(t, queue.AddTask("shapes", "manual"))
+
+	queued := nextEvent(t, events)
+	assert.Equal(t, EventQueued, queued.Type)
+	assert.Equal(t, "shapes", queued.Catalog)
+	assert.Equal(t, "manual", queued.Source)
+	assert.False(t, queued.Time.IsZero())
+
+	assert.Equal(t, EventStarted, nextEvent(t, events).Type)
+	var progress []Event
+	for i := 0; i < 3; i++ {
+		progress = append(progress, nextEvent(t, events))
+	}
+	assert.Equal(t, []string{EventProgress, EventProgress, EventProgress},
+		[]string{progress[0].Type, progress[1].Type, progress[2].Type})
+	assert.Equal(t, 2, progress[2].Done)
+	assert.Equal(t, 2, progress[2].Total)
+	assert.Equal(t, EventCompleted, nextEvent(t, events).Type)
+
+	assert.NoError(t, queue.AddTask("broken", "watcher"))
+	assert.Equal(t, EventQueued, nextEvent(t, events).Type)
+	assert.Equal(t, EventStarted, nextEvent(t, events).Type)
+	failed := nextEvent(t, events)
+	assert.Equal(t, EventFailed, failed.Type)
+	assert.Equal(t, "broken", failed.Catalog)
+	assert.Equal(t, "indexing failed", failed.Error)
+
+	// Unsubscribing closes the channel, and can be repeated
+	unsubscribe()
+	unsubscribe()
+	_, open := <-events
+	assert.False(t, open)
+}
+
+func TestTaskQueue_Publish_SlowSubscriber(t *testing.T) {
+	queue := NewTaskQueue(&config.Config{}, &flakyIndexer{}, "/tmp/test-archive")
+	events, unsubscribe := queue.Subscribe()
+	defer unsubscribe()
+
+	// A subscriber that doesn't read never blocks the queue
+	task := &ReindexTask{CatalogName: "shapes"}
+	for i := 0; i < subscriberBuffer+10; i++ {
+		queue.publish(EventQueued, task, nil)
+	}
+	assert.Len(t, events, subscriberBuffer)
 }
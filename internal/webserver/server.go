@@ -2,19 +2,28 @@ package webserver
 
 import (
 	"context"
+	"fmt"
 	"kbase-catalog/internal/config"
+	"kbase-catalog/internal/metrics"
 	"kbase-catalog/internal/processor"
 	"kbase-catalog/internal/webserver/api"
-	"kbase-catalog/web"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
 	"strconv"
 )
 
+// DefaultHost is the address the web server listens on, all IPv4 interfaces
+const DefaultHost = "0.0.0.0"
+
 // Server represents the web server
 type Server struct {
-	config     *config.Config
-	port       int
+	config *config.Config
+	host   string
+	port   int
+	// unixSocket is the path of the unix domain socket listened on instead of host and port
+	unixSocket string
 	httpServer *http.Server
 	apiHandler *api.APIHandler
 }
@@ -23,59 +32,125 @@ type Server struct {
 func NewServer(cfg *config.Config, catalogProcessor *processor.CatalogProcessor, port int, archivePath string) *Server {
 	apiHandler, err := api.NewAPIHandler(cfg, catalogProcessor, archivePath)
 	if err != nil {
-		log.Printf("Failed to create API handler: %v", err)
+		slog.Error("Failed to create API handler", "error", err)
 	}
 
 	return &Server{
 		config:     cfg,
+		host:       DefaultHost,
 		port:       port,
 		apiHandler: apiHandler,
 	}
 }
 
+// SetHost sets the address the server listens on, DefaultHost when empty
+func (s *Server) SetHost(host string) {
+	if host == "" {
+		host = DefaultHost
+	}
+	s.host = host
+}
+
+// SetUnixSocket makes the server listen on the unix domain socket at path instead of the host
+// and port. An empty path listens on TCP.
+func (s *Server) SetUnixSocket(path string) {
+	s.unixSocket = path
+}
+
+// listen opens the listener of the server. A socket file left by a previous run is replaced.
+func (s *Server) listen() (net.Listener, string, error) {
+	if s.unixSocket == "" {
+		addr := net.JoinHostPort(s.host, strconv.Itoa(s.port))
+		listener, err := net.Listen("tcp", addr)
+		return listener, "http://" + addr, err
+	}
+
+	if info, err := os.Stat(s.unixSocket); err == nil && info.Mode().Type() == os.ModeSocket {
+		if err := os.Remove(s.unixSocket); err != nil {
+			return nil, "", err
+		}
+	}
+	listener, err := net.Listen("unix", s.unixSocket)
+	return listener, "unix:" + s.unixSocket, err
+}
+
 // Start starts the web server
 func (s *Server) Start() error {
+	listener, address, err := s.listen()
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+
+	s.httpServer = &http.Server{
+		Handler: s.routes(),
+	}
+
+	slog.Info("Starting web server", "url", address+s.config.GetBasePath()+"/", "auth", s.config.IsWebAuthEnabled())
+
+	if err := s.apiHandler.Start(); err != nil {
+		listener.Close()
+		return err
+	}
+
+	// Start the server in a goroutine so we can handle shutdown signals
+	go func() {
+		if err := s.httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+			slog.Error("Server error", "error", err)
+		}
+	}()
+
+	return nil
+}
+
+// routes returns the handler of every route wrapped in the middleware. The routes are
+// registered at the root, requests are dispatched to them once base_path is stripped.
+func (s *Server) routes() http.Handler {
 	mux := http.NewServeMux()
 
 	// Static files handler for images
 	mux.HandleFunc("/archive/", s.apiHandler.HandleArchiveFiles)
 
 	// Static files handler for static assets
-	mux.HandleFunc("/static/", web.HandleEmbeddedFile)
+	mux.HandleFunc("/static/", s.apiHandler.HandleStaticFiles)
+
+	// Health checks
+	mux.HandleFunc("/healthz", s.apiHandler.HandleHealthz)
+	mux.HandleFunc("/readyz", s.apiHandler.HandleReadyz)
+
+	// Metrics are opt-in
+	if s.config.MetricsEnabled {
+		mux.Handle("/metrics", metrics.Default.Handler())
+	}
 
 	// Web interface handlers
 	mux.HandleFunc("/", s.apiHandler.HandleIndex)
+	mux.HandleFunc("/sitemap.xml", s.apiHandler.HandleSitemap)
 	mux.HandleFunc("/api/catalog", s.apiHandler.HandleApiCatalog)
 	mux.HandleFunc("/api/search", s.apiHandler.HandleApiSearch)
+	mux.HandleFunc("/api/search-images", s.apiHandler.HandleApiGlobalSearch)
 	mux.HandleFunc("/api/reindex", s.apiHandler.HandleReindex)
+	mux.HandleFunc("/api/queue", s.apiHandler.HandleApiQueueStatus)
+	mux.HandleFunc("/api/queue/failures", s.apiHandler.HandleApiQueueFailures)
+	mux.HandleFunc("/api/queue/cancel", s.apiHandler.HandleApiCancelTask)
+	mux.HandleFunc("/api/events", s.apiHandler.HandleApiEvents)
 	mux.HandleFunc("/api/catalog-search", s.apiHandler.HandleApiCatalogSearch)
+	mux.HandleFunc("/api/catalog-meta", s.apiHandler.HandleApiUpdateCatalogMeta)
+	mux.HandleFunc("/api/catalog-children", s.apiHandler.HandleApiCatalogChildren)
+	mux.HandleFunc("POST /api/catalog/{catalog}/image/{filename}/reprocess", s.apiHandler.HandleApiReprocessImage)
+	mux.HandleFunc("GET /catalog/{catalog}/feed.xml", s.apiHandler.HandleCatalogFeed)
 	mux.HandleFunc("/catalog/", s.apiHandler.HandleCatalogDetail)
 
 	// Apply middleware
 	var handler http.Handler = mux
+	handler = s.apiHandler.CSRFMiddleware(handler)
+	handler = api.AuthMiddleware(s.config.WebAuthUser, s.config.WebAuthPassword, s.config.WebAPIToken, "/healthz", "/readyz")(handler)
+	// Images are already compressed, and the event stream must reach the client unbuffered
+	handler = api.CompressionMiddleware(api.DefaultCompressionMinSize, "/archive/", "/api/events")(handler)
 	handler = api.LoggingMiddleware(handler)
 	handler = api.RecoveryMiddleware(handler)
 	handler = api.CORSMiddleware(handler)
-
-	s.httpServer = &http.Server{
-		Addr:    ":" + strconv.Itoa(s.port),
-		Handler: handler,
-	}
-
-	log.Printf("Starting web server on http://localhost:%d\n", s.port)
-
-	if err := s.apiHandler.Start(); err != nil {
-		return err
-	}
-
-	// Start the server in a goroutine so we can handle shutdown signals
-	go func() {
-		if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Printf("Server error: %v", err)
-		}
-	}()
-
-	return nil
+	handler = api.RequestIDMiddleware(handler)
+	return api.BasePathMiddleware(s.config.GetBasePath())(handler)
 }
 
 // Stop stops the web server
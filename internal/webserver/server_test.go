@@ -0,0 +1,92 @@
+package webserver
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"kbase-catalog/internal/config"
+	"kbase-catalog/internal/processor"
+	"kbase-catalog/web"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_BasePath(t *testing.T) {
+	web.InitTemplateFS(false, "")
+
+	archivePath := t.TempDir()
+	catalogDir := filepath.Join(archivePath, "shapes")
+	assert.NoError(t, os.MkdirAll(catalogDir, 0755))
+	index := `{"red.png": {"short_name": "Red", "description": "A red square"}}`
+	assert.NoError(t, os.WriteFile(filepath.Join(catalogDir, "index.json"), []byte(index), 0644))
+
+	cfg := &config.Config{BasePath: "/kbase/"}
+	catalogProcessor := processor.NewCatalogProcessor(cfg, archivePath)
+	assert.NoError(t, catalogProcessor.RebuildRootIndex(context.Background()))
+	handler := NewServer(cfg, catalogProcessor, 0, archivePath).routes()
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	t.Run("Prefixed routes are dispatched", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, get("/kbase/healthz").Code)
+		assert.Equal(t, http.StatusOK, get("/kbase/static/styles.css").Code)
+
+		rec := get("/kbase/api/catalog")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"shapes"`)
+	})
+
+	t.Run("Routes outside the prefix are not found", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, get("/healthz").Code)
+		assert.Equal(t, http.StatusNotFound, get("/api/catalog").Code)
+	})
+
+	t.Run("Links carry the prefix", func(t *testing.T) {
+		rec := get("/kbase/")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		body := rec.Body.String()
+		assert.Contains(t, body, `href="/kbase/static/styles.css"`)
+		assert.Contains(t, body, `href="/kbase/catalog/shapes"`)
+		assert.Contains(t, body, `hx-post="/kbase/api/reindex"`)
+		assert.NotContains(t, body, `href="/catalog/`)
+
+		rec = get("/kbase/catalog/shapes")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `src="/kbase/archive/shapes/red.png"`)
+	})
+}
+
+func TestServer_UnixSocket(t *testing.T) {
+	web.InitTemplateFS(false, "")
+
+	archivePath := t.TempDir()
+	cfg := &config.Config{}
+	server := NewServer(cfg, processor.NewCatalogProcessor(cfg, archivePath), 0, archivePath)
+	socketPath := filepath.Join(t.TempDir(), "kbase.sock")
+	server.SetUnixSocket(socketPath)
+
+	assert.NoError(t, server.Start())
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socketPath)
+		},
+	}}
+
+	response, err := client.Get("http://kbase/healthz")
+	if assert.NoError(t, err) {
+		response.Body.Close()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+	}
+
+	assert.NoError(t, server.Stop(context.Background()))
+	assert.NoFileExists(t, socketPath)
+}
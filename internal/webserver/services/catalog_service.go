@@ -3,10 +3,14 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"kbase-catalog/internal/utils"
+	"log/slog"
+	"math"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"kbase-catalog/internal/config"
@@ -18,6 +22,31 @@ type CatalogService struct {
 	Config     *config.Config
 	Processor  *processor.CatalogProcessor
 	ArchiveDir string
+	// IndexDir holds the index files when they are kept apart from the images, see
+	// CatalogProcessor.SetOutputDir. Empty means the index files are in ArchiveDir.
+	IndexDir string
+}
+
+// ErrCatalogNotFound is returned for catalog names that don't name a catalog directory
+var ErrCatalogNotFound = errors.New("catalog not found")
+
+// archiveDir returns the directory holding the catalogs
+func (cs *CatalogService) archiveDir() string {
+	if cs.ArchiveDir == "" {
+		return "archive"
+	}
+	return cs.ArchiveDir
+}
+
+// indexDir returns the directory holding the index files
+func (cs *CatalogService) indexDir() string {
+	if cs.IndexDir != "" {
+		return cs.IndexDir
+	}
+	if cs.ArchiveDir == "" {
+		return "archive"
+	}
+	return cs.ArchiveDir
 }
 
 // GetCatalogs returns list of all catalogs with extra information
@@ -36,7 +65,7 @@ func (cs *CatalogService) GetCatalogs(ctx context.Context) ([]map[string]interfa
 	}
 
 	// First try to read the global index.json if it exists
-	globalIndexPath := filepath.Join(archiveDir, "index.json")
+	globalIndexPath := filepath.Join(cs.indexDir(), cs.Config.GetIndexJSONName())
 	if utils.IsFileExists(globalIndexPath) {
 		data, err := os.ReadFile(globalIndexPath)
 		if err == nil {
@@ -44,21 +73,86 @@ func (cs *CatalogService) GetCatalogs(ctx context.Context) ([]map[string]interfa
 			if err := json.Unmarshal(data, &globalIndexData); err == nil {
 				// Convert the global index data to the format expected by GetCatalogs
 				for catalogName, catalogInfo := range globalIndexData {
-					if catalogInfoMap, ok := catalogInfo.(map[string]interface{}); ok {
-						catalogs = append(catalogs, map[string]interface{}{
-							"name":       catalogName,
-							"imageCount": int(catalogInfoMap["image_count"].(float64)),
-							"lastUpdate": catalogInfoMap["last_update"],
-						})
+					// Catalogs without images are merged into the root index as null
+					if catalogInfo == nil {
+						continue
 					}
+					catalog, ok := catalogFromIndexEntry(catalogName, catalogInfo)
+					if !ok {
+						// The entry was written by an older version or damaged, scan the catalog instead
+						slog.WarnContext(ctx, "Malformed root index entry, reading the catalog", "catalog", catalogName)
+						if catalog, ok = cs.catalogFromDirectory(ctx, filepath.Join(archiveDir, catalogName)); !ok {
+							continue
+						}
+					}
+					catalogs = append(catalogs, catalog)
 				}
+				cs.addCatalogMeta(ctx, catalogs)
 				return catalogs, nil
 			}
 		}
 	}
 
 	// If global index doesn't exist or has issues, fall back to the old method
-	return cs.getCatalogsFallback(ctx)
+	catalogs, err := cs.getCatalogsFallback(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cs.addCatalogMeta(ctx, catalogs)
+	return catalogs, nil
+}
+
+// addCatalogMeta adds the "title" and "description" of the catalogs with a _catalog.json. The
+// file is read on every listing, so edits show up before the root index is rebuilt.
+func (cs *CatalogService) addCatalogMeta(ctx context.Context, catalogs []map[string]interface{}) {
+	for _, catalog := range catalogs {
+		name, _ := catalog["name"].(string)
+		meta, err := cs.GetCatalogMeta(name)
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to load catalog metadata", "catalog", name, "error", err)
+			continue
+		}
+		if meta.Title != "" {
+			catalog["title"] = meta.Title
+		}
+		if meta.Description != "" {
+			catalog["description"] = meta.Description
+		}
+	}
+}
+
+// GetCatalogMeta returns the title and description of a catalog, empty when it has none
+func (cs *CatalogService) GetCatalogMeta(catalogName string) (processor.CatalogMeta, error) {
+	if _, _, ok := SplitCatalogPath(catalogName); !ok {
+		return processor.CatalogMeta{}, ErrCatalogNotFound
+	}
+	return processor.LoadCatalogMeta(filepath.Join(cs.archiveDir(), filepath.FromSlash(catalogName)))
+}
+
+// UpdateCatalogMeta replaces the title and description of an existing catalog and rebuilds an
+// existing root index, so index.md shows the new title. Empty metadata removes the _catalog.json.
+func (cs *CatalogService) UpdateCatalogMeta(ctx context.Context, catalogName string, meta processor.CatalogMeta) error {
+	if _, _, ok := SplitCatalogPath(catalogName); !ok {
+		return ErrCatalogNotFound
+	}
+	catalogDir := filepath.Join(cs.archiveDir(), filepath.FromSlash(catalogName))
+	if !utils.IsDirectory(catalogDir) {
+		return ErrCatalogNotFound
+	}
+
+	if err := processor.SaveCatalogMeta(catalogDir, meta); err != nil {
+		return err
+	}
+
+	// Without a root index the catalogs are listed from their directories, a rebuild would
+	// only list the processed ones
+	if cs.Processor != nil && utils.IsFileExists(filepath.Join(cs.indexDir(), cs.Config.GetIndexJSONName())) {
+		if err := cs.Processor.RebuildRootIndex(ctx); err != nil {
+			slog.WarnContext(ctx, "Failed to rebuild root index after updating catalog metadata", "catalog", catalogName, "error", err)
+		}
+	}
+
+	return nil
 }
 
 // getCatalogsFallback is the original method for backward compatibility
@@ -83,39 +177,88 @@ func (cs *CatalogService) getCatalogsFallback(ctx context.Context) ([]map[string
 			continue
 		}
 
-		path := filepath.Join(archiveDir, entry.Name())
-
-		// Get image count and last update date
-		imageCount, lastUpdate, err := cs.getCatalogInfo(path)
-		if err != nil {
-			// Log error but continue processing other catalogs
-			fmt.Printf("Error getting catalog info for %s: %v\n", entry.Name(), err)
-			continue // Continue with other catalogs even if one fails
+		if catalog, ok := cs.catalogFromDirectory(ctx, filepath.Join(archiveDir, entry.Name())); ok {
+			catalogs = append(catalogs, catalog)
 		}
+	}
 
-		if imageCount == 0 {
-			continue // Skip empty catalogs or those with errors
-		}
+	return catalogs, nil
+}
 
-		catalogs = append(catalogs, map[string]interface{}{
-			"name":       entry.Name(),
-			"imageCount": imageCount,
-			"lastUpdate": lastUpdate,
-		})
+// catalogFromIndexEntry converts a root index entry to a catalog. It returns false when the
+// entry has no usable image count.
+func catalogFromIndexEntry(catalogName string, entry interface{}) (map[string]interface{}, bool) {
+	info, ok := entry.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	imageCount, ok := toInt(info["image_count"])
+	if !ok {
+		return nil, false
 	}
+	lastUpdate, _ := info["last_update"].(string)
 
-	return catalogs, nil
+	return map[string]interface{}{
+		"name":       catalogName,
+		"imageCount": imageCount,
+		"lastUpdate": lastUpdate,
+	}, true
 }
 
-// GetCatalogImages returns all images in a catalog with their metadata
-func (cs *CatalogService) GetCatalogImages(ctx context.Context, catalogName string) (map[string]interface{}, error) {
-	archiveDir := cs.ArchiveDir
+// catalogFromDirectory reads a catalog from its index.json, or its images when there is
+// none. It returns false for empty catalogs and catalogs that can't be read.
+func (cs *CatalogService) catalogFromDirectory(ctx context.Context, path string) (map[string]interface{}, bool) {
+	// Get image count and last update date
+	imageCount, lastUpdate, err := cs.getCatalogInfo(path)
+	if err != nil {
+		// Log error but continue processing other catalogs
+		slog.ErrorContext(ctx, "Error getting catalog info", "catalog", filepath.Base(path), "error", err)
+		return nil, false
+	}
 
-	if archiveDir == "" {
-		archiveDir = "archive"
+	if imageCount == 0 {
+		return nil, false
 	}
 
-	indexPath := filepath.Join(archiveDir, catalogName, "index.json")
+	return map[string]interface{}{
+		"name":       filepath.Base(path),
+		"imageCount": imageCount,
+		"lastUpdate": lastUpdate,
+	}, true
+}
+
+// toInt converts a non-negative whole number decoded from JSON, or written as an int by Go
+// code, to an int
+func toInt(value interface{}) (int, bool) {
+	switch number := value.(type) {
+	case float64:
+		if number < 0 || number != math.Trunc(number) {
+			return 0, false
+		}
+		return int(number), true
+	case int:
+		return number, number >= 0
+	case int64:
+		return int(number), number >= 0
+	case json.Number:
+		n, err := number.Int64()
+		if err != nil || n < 0 {
+			return 0, false
+		}
+		return int(n), true
+	default:
+		return 0, false
+	}
+}
+
+// GetCatalogImages returns all images in a catalog with their metadata. A nested catalog path
+// (a/b) returns the images of the folder and its subfolders, keyed as in the top level catalog.
+func (cs *CatalogService) GetCatalogImages(ctx context.Context, catalogName string) (map[string]interface{}, error) {
+	catalog, folder, ok := SplitCatalogPath(catalogName)
+	if !ok {
+		return nil, ErrCatalogNotFound
+	}
+	indexPath := filepath.Join(cs.indexDir(), catalog, cs.Config.GetIndexJSONName())
 
 	if _, err := os.Stat(indexPath); os.IsNotExist(err) {
 		return make(map[string]interface{}, 0), nil
@@ -132,11 +275,12 @@ func (cs *CatalogService) GetCatalogImages(ctx context.Context, catalogName stri
 		return nil, fmt.Errorf("failed to parse index file: %w", err)
 	}
 
-	return indexData, nil
+	return filterFolder(indexData, folder), nil
 }
 
-// SearchCatalogs returns filtered catalogs based on search query
-func (cs *CatalogService) SearchCatalogs(ctx context.Context, query string) ([]map[string]interface{}, error) {
+// SearchCatalogs returns filtered catalogs based on search query. In fuzzy mode every match
+// carries its "score" and the results are ordered from the best match down.
+func (cs *CatalogService) SearchCatalogs(ctx context.Context, query string, fuzzy bool) ([]map[string]interface{}, error) {
 	allCatalogs, err := cs.GetCatalogs(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("error getting catalogs for search: %w", err)
@@ -151,24 +295,153 @@ func (cs *CatalogService) SearchCatalogs(ctx context.Context, query string) ([]m
 		// Filter catalogs based on search query
 		for _, catalog := range allCatalogs {
 			name, _ := catalog["name"].(string)
-			if strings.Contains(strings.ToLower(name), strings.ToLower(query)) {
-				filtered = append(filtered, catalog)
+			score := matchScore(query, name, fuzzy)
+			if score == 0 {
+				continue
 			}
+			if fuzzy {
+				catalog["score"] = score
+			}
+			filtered = append(filtered, catalog)
 		}
 	}
 
+	if fuzzy {
+		sort.SliceStable(filtered, func(i, j int) bool {
+			score1, _ := filtered[i]["score"].(float64)
+			score2, _ := filtered[j]["score"].(float64)
+			return score1 > score2
+		})
+	}
+
 	return filtered, nil
 }
 
-// SearchCatalogImages returns filtered images in a catalog based on search query
-func (cs *CatalogService) SearchCatalogImages(ctx context.Context, catalogName string, query string) (map[string]interface{}, error) {
-	archiveDir := cs.ArchiveDir
+// ImageSearchOptions holds the optional filters applied when searching images
+type ImageSearchOptions struct {
+	// Tags limits results to images tagged with the given values (case-insensitive)
+	Tags []string
+	// MatchAllTags requires every tag to be present instead of any of them
+	MatchAllTags bool
+	// Fuzzy tolerates typos and word order instead of requiring an exact substring.
+	// Matches are annotated with their "score".
+	Fuzzy bool
+}
 
-	if archiveDir == "" {
-		archiveDir = "archive"
+// SearchCatalogImages returns filtered images in a catalog based on search query. When a query
+// is given, each result is a copy of the stored record annotated with the "highlights" of the
+// query within its searchable fields.
+func (cs *CatalogService) SearchCatalogImages(ctx context.Context, catalogName string, query string, opts ImageSearchOptions) (map[string]interface{}, error) {
+	indexData, err := cs.loadCatalogIndex(catalogName)
+	if err != nil {
+		return nil, err
 	}
 
-	indexPath := filepath.Join(archiveDir, catalogName, "index.json")
+	// If no query or tag filter provided, return all images
+	if query == "" && len(opts.Tags) == 0 {
+		return indexData, nil
+	}
+
+	// Filter images based on search query
+	filteredData := make(map[string]interface{})
+
+	for filename, value := range indexData {
+		if dataMap, ok := value.(map[string]interface{}); ok {
+			score := ScoreImage(dataMap, query, opts)
+			if score == 0 {
+				continue
+			}
+			filteredData[filename] = annotateResult(dataMap, query, score, opts)
+		}
+	}
+
+	return filteredData, nil
+}
+
+// SearchAllCatalogImages searches images across every catalog in the archive. Each result is a
+// copy of the image record annotated with "catalog" and "filename". Every match is returned,
+// callers sort and cap the results, and the search stops early when ctx is cancelled.
+func (cs *CatalogService) SearchAllCatalogImages(ctx context.Context, query string, opts ImageSearchOptions) ([]map[string]interface{}, error) {
+	catalogs, err := cs.GetCatalogs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting catalogs for search: %w", err)
+	}
+
+	// Visit catalogs in a stable order so results of equal rank keep a deterministic order
+	var catalogNames []string
+	for _, catalog := range catalogs {
+		if name, ok := catalog["name"].(string); ok && name != "" {
+			catalogNames = append(catalogNames, name)
+		}
+	}
+	sort.Strings(catalogNames)
+
+	results := []map[string]interface{}{}
+	for _, catalogName := range catalogNames {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		indexData, err := cs.loadCatalogIndex(catalogName)
+		if err != nil {
+			// Log error but continue searching other catalogs
+			slog.ErrorContext(ctx, "Error loading catalog for search", "catalog", catalogName, "error", err)
+			continue
+		}
+
+		var filenames []string
+		for filename := range indexData {
+			filenames = append(filenames, filename)
+		}
+		sort.Strings(filenames)
+
+		for _, filename := range filenames {
+			dataMap, ok := indexData[filename].(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			score := ScoreImage(dataMap, query, opts)
+			if score == 0 {
+				continue
+			}
+
+			result := annotateResult(dataMap, query, score, opts)
+			result["catalog"] = catalogName
+			result["filename"] = filename
+			results = append(results, result)
+		}
+	}
+
+	return results, nil
+}
+
+// annotateResult copies a matched image record and adds the search metadata to the copy,
+// leaving the stored record untouched
+func annotateResult(record map[string]interface{}, query string, score float64, opts ImageSearchOptions) map[string]interface{} {
+	result := make(map[string]interface{}, len(record)+4)
+	for k, v := range record {
+		result[k] = v
+	}
+
+	if query != "" {
+		result["highlights"] = FindHighlights(record, query)
+		if opts.Fuzzy {
+			result["score"] = score
+		}
+	}
+
+	return result
+}
+
+// loadCatalogIndex reads and parses the index.json of a catalog, limited to the folder of a
+// nested catalog path
+func (cs *CatalogService) loadCatalogIndex(catalogName string) (map[string]interface{}, error) {
+	catalog, folder, ok := SplitCatalogPath(catalogName)
+	if !ok {
+		return nil, ErrCatalogNotFound
+	}
+	indexPath := filepath.Join(cs.indexDir(), catalog, cs.Config.GetIndexJSONName())
 
 	if _, err := os.Stat(indexPath); os.IsNotExist(err) {
 		return nil, fmt.Errorf("index file not found for catalog %s", catalogName)
@@ -185,37 +458,68 @@ func (cs *CatalogService) SearchCatalogImages(ctx context.Context, catalogName s
 		return nil, fmt.Errorf("failed to parse index file: %w", err)
 	}
 
-	// If no query provided, return all images
+	return filterFolder(indexData, folder), nil
+}
+
+// MatchesImage checks whether an image record satisfies the search query and filters.
+// The query is matched against the short name, description and recognized text.
+func MatchesImage(record map[string]interface{}, query string, opts ImageSearchOptions) bool {
+	return ScoreImage(record, query, opts) > 0
+}
+
+// ScoreImage rates how well an image record matches the search query and filters, using the
+// best score among the searchable fields. Zero means the record doesn't match.
+func ScoreImage(record map[string]interface{}, query string, opts ImageSearchOptions) float64 {
+	if !MatchesTags(record, opts.Tags, opts.MatchAllTags) {
+		return 0
+	}
+
 	if query == "" {
-		return indexData, nil
+		return 1
 	}
 
-	// Filter images based on search query
-	filteredData := make(map[string]interface{})
+	best := 0.0
+	for _, field := range searchableFields {
+		if value, ok := record[field].(string); ok {
+			best = max(best, matchScore(query, value, opts.Fuzzy))
+		}
+	}
 
-	for filename, value := range indexData {
-		if dataMap, ok := value.(map[string]interface{}); ok {
-			// Check if the query matches either the short name or description
-			shortName := ""
-			description := ""
+	return best
+}
 
-			if sn, ok := dataMap["short_name"].(string); ok {
-				shortName = sn
-			}
+// MatchesTags checks whether an image record carries the requested tags. Records created
+// before tags were introduced have none and only match when no tags are requested.
+func MatchesTags(record map[string]interface{}, tags []string, matchAll bool) bool {
+	if len(tags) == 0 {
+		return true
+	}
 
-			if desc, ok := dataMap["description"].(string); ok {
-				description = desc
+	recordTags := make(map[string]bool)
+	switch values := record["tags"].(type) {
+	case []interface{}:
+		for _, value := range values {
+			if tag, ok := value.(string); ok {
+				recordTags[strings.ToLower(strings.TrimSpace(tag))] = true
 			}
+		}
+	case []string:
+		for _, tag := range values {
+			recordTags[strings.ToLower(strings.TrimSpace(tag))] = true
+		}
+	}
 
-			// If query matches either short name or description, include the image
-			if strings.Contains(strings.ToLower(shortName), strings.ToLower(query)) ||
-				strings.Contains(strings.ToLower(description), strings.ToLower(query)) {
-				filteredData[filename] = dataMap
-			}
+	for _, tag := range tags {
+		found := recordTags[strings.ToLower(strings.TrimSpace(tag))]
+		if matchAll && !found {
+			return false
+		}
+		if !matchAll && found {
+			return true
 		}
 	}
 
-	return filteredData, nil
+	return matchAll
 }
 
 // getCatalogInfo gets image count and last update date for a catalog directory
@@ -225,7 +529,10 @@ func (cs *CatalogService) getCatalogInfo(catalogPath string) (int, string, error
 	lastUpdate := ""
 
 	// Read index.json to get image information and update dates
-	indexJsonPath := filepath.Join(catalogPath, "index.json")
+	indexJsonPath := filepath.Join(catalogPath, cs.Config.GetIndexJSONName())
+	if cs.IndexDir != "" {
+		indexJsonPath = filepath.Join(cs.IndexDir, filepath.Base(catalogPath), cs.Config.GetIndexJSONName())
+	}
 	if _, err := os.Stat(indexJsonPath); !os.IsNotExist(err) {
 		data, err := os.ReadFile(indexJsonPath)
 		if err != nil {
@@ -263,11 +570,8 @@ func (cs *CatalogService) getCatalogInfo(catalogPath string) (int, string, error
 			if !entry.IsDir() {
 
 				// Skip files that match exclusion patterns
-				if len(cs.Config.ExcludeFilter) > 0 {
-					filePath := filepath.Join(catalogPath, entry.Name())
-					if cs.Processor.ShouldExclude(filePath) {
-						continue
-					}
+				if cs.Processor.ShouldExclude(filepath.Join(catalogPath, entry.Name())) {
+					continue
 				}
 
 				ext := strings.ToLower(filepath.Ext(entry.Name()))
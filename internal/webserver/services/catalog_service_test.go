@@ -2,6 +2,7 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"testing"
@@ -109,3 +110,301 @@ func TestCatalogService_GetCatalogs(t *testing.T) {
 	assert.True(t, ok)
 	assert.Equal(t, "test_catalog", name)
 }
+
+func TestCatalogService_GetCatalogs_MalformedRootIndex(t *testing.T) {
+	archiveDir := t.TempDir()
+	for _, catalog := range []string{"good", "int_count", "no_count", "not_a_map", "fraction"} {
+		catalogPath := filepath.Join(archiveDir, catalog)
+		assert.NoError(t, os.MkdirAll(catalogPath, 0755))
+		assert.NoError(t, os.WriteFile(filepath.Join(catalogPath, "index.json"),
+			[]byte(`{"a.jpg": {"short_name": "A", "update_date": "2024-01-02T00:00:00Z"}, "b.jpg": {"short_name": "B"}}`), 0644))
+	}
+
+	// Every shape the root index has been written in, plus broken ones
+	rootIndex := `{
+		"good": {"image_count": 2, "last_update": "2024-05-01T00:00:00Z"},
+		"int_count": {"image_count": "2"},
+		"no_count": {"last_update": "2024-05-01T00:00:00Z"},
+		"not_a_map": "broken",
+		"fraction": {"image_count": 1.5},
+		"empty": null
+	}`
+	assert.NoError(t, os.WriteFile(filepath.Join(archiveDir, "index.json"), []byte(rootIndex), 0644))
+
+	cfg := &config.Config{SupportedExtensions: []string{".jpg"}}
+	cs := &CatalogService{Config: cfg, Processor: processor.NewCatalogProcessor(cfg, archiveDir), ArchiveDir: archiveDir}
+
+	var catalogs []map[string]interface{}
+	assert.NotPanics(t, func() {
+		var err error
+		catalogs, err = cs.GetCatalogs(context.Background())
+		assert.NoError(t, err)
+	})
+
+	byName := make(map[string]map[string]interface{})
+	for _, catalog := range catalogs {
+		byName[catalog["name"].(string)] = catalog
+	}
+	assert.Len(t, byName, 5)
+	assert.Equal(t, map[string]interface{}{"name": "good", "imageCount": 2, "lastUpdate": "2024-05-01T00:00:00Z"}, byName["good"])
+	// Malformed entries are read from the catalog directory
+	for _, name := range []string{"int_count", "no_count", "not_a_map", "fraction"} {
+		assert.Equal(t, map[string]interface{}{"name": name, "imageCount": 2, "lastUpdate": "2024-01-02T00:00:00Z"}, byName[name], name)
+	}
+	assert.NotContains(t, byName, "empty")
+}
+
+func TestToInt(t *testing.T) {
+	for _, value := range []interface{}{float64(3), 3, int64(3), json.Number("3")} {
+		n, ok := toInt(value)
+		assert.True(t, ok, "%T", value)
+		assert.Equal(t, 3, n)
+	}
+	for _, value := range []interface{}{nil, "3", 2.5, float64(-1), -1, json.Number("3.5"), true} {
+		_, ok := toInt(value)
+		assert.False(t, ok, "%#v", value)
+	}
+}
+
+func TestCatalogService_CatalogMeta(t *testing.T) {
+	archiveDir := t.TempDir()
+	for _, catalog := range []string{"holidays", "plain"} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(archiveDir, catalog), 0755))
+		assert.NoError(t, os.WriteFile(filepath.Join(archiveDir, catalog, "a.jpg"), []byte("fake image content"), 0644))
+	}
+	assert.NoError(t, os.WriteFile(filepath.Join(archiveDir, "holidays", processor.CatalogMetaFile),
+		[]byte(`{"title": "Holidays 2024", "description": "Beach trip"}`), 0644))
+
+	cfg := &config.Config{SupportedExtensions: []string{".jpg"}}
+	cs := &CatalogService{Config: cfg, Processor: processor.NewCatalogProcessor(cfg, archiveDir), ArchiveDir: archiveDir}
+	ctx := context.Background()
+
+	byName := func() map[string]map[string]interface{} {
+		catalogs, err := cs.GetCatalogs(ctx)
+		assert.NoError(t, err)
+		byName := make(map[string]map[string]interface{})
+		for _, catalog := range catalogs {
+			byName[catalog["name"].(string)] = catalog
+		}
+		return byName
+	}
+
+	// Reading, catalogs without metadata keep their name only
+	catalogs := byName()
+	assert.Equal(t, "Holidays 2024", catalogs["holidays"]["title"])
+	assert.Equal(t, "Beach trip", catalogs["holidays"]["description"])
+	assert.NotContains(t, catalogs["plain"], "title")
+	assert.NotContains(t, catalogs["plain"], "description")
+
+	// Updating
+	assert.NoError(t, cs.UpdateCatalogMeta(ctx, "plain", processor.CatalogMeta{Title: "Plain shapes"}))
+	meta, err := cs.GetCatalogMeta("plain")
+	assert.NoError(t, err)
+	assert.Equal(t, processor.CatalogMeta{Title: "Plain shapes"}, meta)
+	assert.Equal(t, "Plain shapes", byName()["plain"]["title"])
+
+	// Only existing catalog directories can be updated
+	for _, name := range []string{"", "missing", ".", "..", "holidays/..", "../" + filepath.Base(archiveDir)} {
+		assert.ErrorIs(t, cs.UpdateCatalogMeta(ctx, name, processor.CatalogMeta{Title: "X"}), ErrCatalogNotFound, name)
+	}
+	assert.NoFileExists(t, filepath.Join(archiveDir, processor.CatalogMetaFile))
+}
+
+func TestCatalogService_IndexDir(t *testing.T) {
+	// The images stay in the archive while the index files live in a separate directory
+	archiveDir := t.TempDir()
+	indexDir := t.TempDir()
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(archiveDir, "shapes"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(archiveDir, "shapes", "red.jpg"), []byte("fake image content"), 0644))
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(indexDir, "shapes"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(indexDir, "index.json"),
+		[]byte(`{"shapes": {"name": "shapes", "image_count": 1, "last_update": "2024-01-01T00:00:00Z"}}`), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(indexDir, "shapes", "index.json"),
+		[]byte(`{"red.jpg": {"short_name": "Red square", "description": "A red square"}}`), 0644))
+
+	cfg := &config.Config{SupportedExtensions: []string{".jpg"}}
+	cs := &CatalogService{
+		Config:     cfg,
+		Processor:  processor.NewCatalogProcessor(cfg, archiveDir),
+		ArchiveDir: archiveDir,
+		IndexDir:   indexDir,
+	}
+
+	catalogs, err := cs.GetCatalogs(context.Background())
+	assert.NoError(t, err)
+	if assert.Len(t, catalogs, 1) {
+		assert.Equal(t, "shapes", catalogs[0]["name"])
+	}
+
+	images, err := cs.GetCatalogImages(context.Background(), "shapes")
+	assert.NoError(t, err)
+	assert.Contains(t, images, "red.jpg")
+
+	imageCount, _, err := cs.getCatalogInfo(filepath.Join(archiveDir, "shapes"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, imageCount)
+}
+
+func TestCatalogService_SearchCatalogImages_OCRText(t *testing.T) {
+	archiveDir := t.TempDir()
+
+	catalogPath := filepath.Join(archiveDir, "scans")
+	err := os.MkdirAll(catalogPath, 0755)
+	assert.NoError(t, err)
+
+	indexContent := `{
+  "invoice.png": {
+    "short_name": "Invoice",
+    "description": "",
+    "ocr_text": "Invoice No. 42\nTotal due: 100 EUR"
+  },
+  "receipt.png": {
+    "short_name": "Receipt",
+    "description": "",
+    "ocr_text": "Thank you for shopping"
+  }
+}`
+	err = os.WriteFile(filepath.Join(catalogPath, "index.json"), []byte(indexContent), 0644)
+	assert.NoError(t, err)
+
+	cs := &CatalogService{
+		Config:     &config.Config{},
+		ArchiveDir: archiveDir,
+	}
+
+	results, err := cs.SearchCatalogImages(context.Background(), "scans", "total", ImageSearchOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.Contains(t, results, "invoice.png")
+}
+
+func TestCatalogService_SearchCatalogImages_Languages(t *testing.T) {
+	archiveDir := t.TempDir()
+	catalogPath := filepath.Join(archiveDir, "trips")
+	assert.NoError(t, os.MkdirAll(catalogPath, 0755))
+
+	// Records described before and after output_language changed live side by side
+	indexContent := `{
+  "sunset.png": {"short_name": "Sunset", "description": "The sun sets over the sea"},
+  "sonne.png": {"short_name": "Sonnenuntergang", "description": "Die Sonne geht über dem Meer unter", "language": "German"}
+}`
+	assert.NoError(t, os.WriteFile(filepath.Join(catalogPath, "index.json"), []byte(indexContent), 0644))
+
+	cs := &CatalogService{Config: &config.Config{OutputLanguage: "German"}, ArchiveDir: archiveDir}
+
+	results, err := cs.SearchCatalogImages(context.Background(), "trips", "ÜBER DEM MEER", ImageSearchOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.Contains(t, results, "sonne.png")
+
+	results, err = cs.SearchCatalogImages(context.Background(), "trips", "sea", ImageSearchOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.Contains(t, results, "sunset.png")
+}
+
+func TestCatalogService_SearchCatalogImages_Tags(t *testing.T) {
+	archiveDir := t.TempDir()
+
+	catalogPath := filepath.Join(archiveDir, "photos")
+	err := os.MkdirAll(catalogPath, 0755)
+	assert.NoError(t, err)
+
+	// Mix of tagged records and an old record without tags
+	indexContent := `{
+  "beach.png": {
+    "short_name": "Beach",
+    "description": "Sunset over the beach",
+    "tags": ["Sunset", "beach", "sea"]
+  },
+  "mountain.png": {
+    "short_name": "Mountain",
+    "description": "Sunset in the mountains",
+    "tags": ["sunset", "mountains"]
+  },
+  "old.png": {
+    "short_name": "Old",
+    "description": "Sunset recorded before tags existed"
+  }
+}`
+	err = os.WriteFile(filepath.Join(catalogPath, "index.json"), []byte(indexContent), 0644)
+	assert.NoError(t, err)
+
+	cs := &CatalogService{
+		Config:     &config.Config{},
+		ArchiveDir: archiveDir,
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		opts     ImageSearchOptions
+		expected []string
+	}{
+		{"no filter", "", ImageSearchOptions{}, []string{"beach.png", "mountain.png", "old.png"}},
+		{"single tag is case-insensitive", "", ImageSearchOptions{Tags: []string{"SUNSET"}}, []string{"beach.png", "mountain.png"}},
+		{"any of tags", "", ImageSearchOptions{Tags: []string{"sea", "mountains"}}, []string{"beach.png", "mountain.png"}},
+		{"all of tags", "", ImageSearchOptions{Tags: []string{"sunset", "sea"}, MatchAllTags: true}, []string{"beach.png"}},
+		{"unknown tag", "", ImageSearchOptions{Tags: []string{"forest"}}, []string{}},
+		{"tag combined with query", "mountains", ImageSearchOptions{Tags: []string{"sunset"}}, []string{"mountain.png"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := cs.SearchCatalogImages(context.Background(), "photos", tt.query, tt.opts)
+			assert.NoError(t, err)
+
+			var names []string
+			for name := range results {
+				names = append(names, name)
+			}
+			assert.ElementsMatch(t, tt.expected, names)
+		})
+	}
+}
+
+func TestCatalogService_SearchAllCatalogImages(t *testing.T) {
+	archiveDir := t.TempDir()
+
+	indexes := map[string]string{
+		"holidays": `{
+  "beach.png": {"short_name": "Beach", "description": "Sunset over the sea"},
+  "city.png": {"short_name": "City", "description": "Streets at night"}
+}`,
+		"mountains": `{
+  "peak.png": {"short_name": "Peak", "description": "Sunset behind the peak"}
+}`,
+	}
+	for catalogName, content := range indexes {
+		catalogPath := filepath.Join(archiveDir, catalogName)
+		assert.NoError(t, os.MkdirAll(catalogPath, 0755))
+		assert.NoError(t, os.WriteFile(filepath.Join(catalogPath, "index.json"), []byte(content), 0644))
+	}
+
+	cs := &CatalogService{
+		Config:     &config.Config{},
+		ArchiveDir: archiveDir,
+	}
+
+	t.Run("Matches images in all catalogs", func(t *testing.T) {
+		results, err := cs.SearchAllCatalogImages(context.Background(), "sunset", ImageSearchOptions{})
+		assert.NoError(t, err)
+		assert.Len(t, results, 2)
+
+		found := map[string]string{}
+		for _, result := range results {
+			found[result["filename"].(string)] = result["catalog"].(string)
+		}
+		assert.Equal(t, map[string]string{"beach.png": "holidays", "peak.png": "mountains"}, found)
+	})
+
+	t.Run("Stops on cancelled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := cs.SearchAllCatalogImages(ctx, "sunset", ImageSearchOptions{})
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
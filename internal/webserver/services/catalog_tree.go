@@ -0,0 +1,156 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"kbase-catalog/internal/processor"
+)
+
+// Nested catalogs are the subfolders of a top level catalog indexed with --recursive. They have
+// no index of their own: their images are the records of the top level catalog index whose key
+// starts with the folder path, and those keys (a/x.jpg) are kept as they are everywhere.
+
+// SplitCatalogPath splits a slash separated catalog path into the top level catalog, which owns
+// the index, and the folder below it. It reports false for empty paths and paths with ".."
+// or empty segments, which could escape the archive.
+func SplitCatalogPath(catalogPath string) (catalog string, folder string, ok bool) {
+	catalogPath = strings.Trim(catalogPath, "/")
+	if catalogPath == "" {
+		return "", "", false
+	}
+
+	for _, segment := range strings.Split(catalogPath, "/") {
+		if segment == "" || segment == "." || segment == ".." || strings.Contains(segment, `\`) {
+			return "", "", false
+		}
+	}
+
+	catalog, folder, _ = strings.Cut(catalogPath, "/")
+	return catalog, folder, true
+}
+
+// CatalogRoot returns the top level catalog of a catalog path, the catalog reindexed and used
+// in the /archive/ URLs of its images
+func CatalogRoot(catalogPath string) string {
+	catalog, _, _ := SplitCatalogPath(catalogPath)
+	return catalog
+}
+
+// Breadcrumb is one level of a catalog path
+type Breadcrumb struct {
+	Name string
+	Path string
+}
+
+// CatalogBreadcrumbs returns the levels of a catalog path from the top level catalog down
+func CatalogBreadcrumbs(catalogPath string) []Breadcrumb {
+	var breadcrumbs []Breadcrumb
+	current := ""
+	for _, segment := range strings.Split(strings.Trim(catalogPath, "/"), "/") {
+		if segment == "" {
+			continue
+		}
+		current = path.Join(current, segment)
+		breadcrumbs = append(breadcrumbs, Breadcrumb{Name: segment, Path: current})
+	}
+	return breadcrumbs
+}
+
+// filterFolder returns the records of a catalog index that belong to folder, keeping their keys.
+// An empty folder is the whole catalog.
+func filterFolder(indexData map[string]interface{}, folder string) map[string]interface{} {
+	if folder == "" {
+		return indexData
+	}
+
+	prefix := folder + "/"
+	filtered := make(map[string]interface{})
+	for key, value := range indexData {
+		if strings.HasPrefix(key, prefix) {
+			filtered[key] = value
+		}
+	}
+	return filtered
+}
+
+// GetCatalogChildren lists the folders directly below a catalog path that hold indexed images,
+// named by their full catalog path. An empty path lists the top level catalogs.
+func (cs *CatalogService) GetCatalogChildren(ctx context.Context, catalogPath string) ([]map[string]interface{}, error) {
+	if strings.Trim(catalogPath, "/") == "" {
+		return cs.GetCatalogs(ctx)
+	}
+
+	catalog, folder, ok := SplitCatalogPath(catalogPath)
+	if !ok {
+		return nil, ErrCatalogNotFound
+	}
+
+	indexData, err := cs.GetCatalogImages(ctx, catalog)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load catalog %s: %w", catalog, err)
+	}
+
+	parent := catalog
+	prefix := ""
+	if folder != "" {
+		parent = catalog + "/" + folder
+		prefix = folder + "/"
+	}
+
+	children := make(map[string]map[string]interface{})
+	for key, value := range indexData {
+		rest, ok := strings.CutPrefix(key, prefix)
+		if !ok {
+			continue
+		}
+		// Images directly in the folder aren't part of a child
+		childName, _, nested := strings.Cut(rest, "/")
+		if !nested {
+			continue
+		}
+
+		child, exists := children[childName]
+		if !exists {
+			child = map[string]interface{}{
+				"name":       parent + "/" + childName,
+				"title":      childName,
+				"imageCount": 0,
+				"lastUpdate": "",
+			}
+			children[childName] = child
+		}
+		child["imageCount"] = child["imageCount"].(int) + 1
+		if record, ok := value.(map[string]interface{}); ok {
+			if updateDate, ok := record["update_date"].(string); ok && updateDate > child["lastUpdate"].(string) {
+				child["lastUpdate"] = updateDate
+			}
+		}
+	}
+
+	result := make([]map[string]interface{}, 0, len(children))
+	for _, child := range children {
+		name := child["name"].(string)
+		meta, err := processor.LoadCatalogMeta(filepath.Join(cs.archiveDir(), filepath.FromSlash(name)))
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to load catalog metadata", "catalog", name, "error", err)
+		}
+		if meta.Title != "" {
+			child["title"] = meta.Title
+		}
+		if meta.Description != "" {
+			child["description"] = meta.Description
+		}
+		result = append(result, child)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i]["name"].(string) < result[j]["name"].(string)
+	})
+
+	return result, nil
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"kbase-catalog/internal/config"
+	"kbase-catalog/internal/processor"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTreeCatalogService creates an archive with the two level catalog "holidays", indexed
+// recursively, next to the flat catalog "shapes"
+func newTreeCatalogService(t *testing.T) *CatalogService {
+	archiveDir := t.TempDir()
+	indexes := map[string]string{
+		"holidays": `{
+			"beach.jpg": {"short_name": "Beach"},
+			"2023/d.jpg": {"short_name": "D", "update_date": "2023-08-01T00:00:00Z"},
+			"2024/a.jpg": {"short_name": "A", "update_date": "2024-05-01T00:00:00Z"},
+			"2024/summer/b.jpg": {"short_name": "B", "update_date": "2024-07-01T00:00:00Z"},
+			"2024/summer/c.jpg": {"short_name": "C"}
+		}`,
+		"shapes": `{"red.jpg": {"short_name": "Red"}}`,
+	}
+	for catalog, index := range indexes {
+		assert.NoError(t, os.MkdirAll(filepath.Join(archiveDir, catalog), 0755))
+		assert.NoError(t, os.WriteFile(filepath.Join(archiveDir, catalog, "index.json"), []byte(index), 0644))
+	}
+	assert.NoError(t, os.MkdirAll(filepath.Join(archiveDir, "holidays", "2024", "summer"), 0755))
+	assert.NoError(t, processor.SaveCatalogMeta(filepath.Join(archiveDir, "holidays", "2024"), processor.CatalogMeta{Title: "Year 2024"}))
+
+	cfg := &config.Config{SupportedExtensions: []string{".jpg"}}
+	return &CatalogService{Config: cfg, Processor: processor.NewCatalogProcessor(cfg, archiveDir), ArchiveDir: archiveDir}
+}
+
+// childNames returns the names of the listed catalogs
+func childNames(children []map[string]interface{}) []string {
+	names := []string{}
+	for _, child := range children {
+		names = append(names, child["name"].(string))
+	}
+	return names
+}
+
+func TestCatalogService_GetCatalogChildren(t *testing.T) {
+	cs := newTreeCatalogService(t)
+	ctx := context.Background()
+
+	t.Run("Top level", func(t *testing.T) {
+		children, err := cs.GetCatalogChildren(ctx, "")
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []string{"holidays", "shapes"}, childNames(children))
+	})
+
+	t.Run("First level", func(t *testing.T) {
+		children, err := cs.GetCatalogChildren(ctx, "holidays")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"holidays/2023", "holidays/2024"}, childNames(children))
+
+		assert.Equal(t, map[string]interface{}{
+			"name": "holidays/2023", "title": "2023", "imageCount": 1, "lastUpdate": "2023-08-01T00:00:00Z",
+		}, children[0])
+		// Every image below the folder counts, and the folder's _catalog.json names it
+		assert.Equal(t, 3, children[1]["imageCount"])
+		assert.Equal(t, "2024-07-01T00:00:00Z", children[1]["lastUpdate"])
+		assert.Equal(t, "Year 2024", children[1]["title"])
+	})
+
+	t.Run("Second level", func(t *testing.T) {
+		children, err := cs.GetCatalogChildren(ctx, "holidays/2024")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"holidays/2024/summer"}, childNames(children))
+		assert.Equal(t, 2, children[0]["imageCount"])
+
+		children, err = cs.GetCatalogChildren(ctx, "holidays/2024/summer/")
+		assert.NoError(t, err)
+		assert.Empty(t, children)
+	})
+
+	t.Run("Flat catalog", func(t *testing.T) {
+		children, err := cs.GetCatalogChildren(ctx, "shapes")
+		assert.NoError(t, err)
+		assert.Empty(t, children)
+	})
+
+	t.Run("Invalid path", func(t *testing.T) {
+		for _, catalogPath := range []string{"..", "holidays/../shapes", "holidays//2024"} {
+			_, err := cs.GetCatalogChildren(ctx, catalogPath)
+			assert.ErrorIs(t, err, ErrCatalogNotFound, catalogPath)
+		}
+	})
+}
+
+func TestCatalogService_GetCatalogImages_Nested(t *testing.T) {
+	cs := newTreeCatalogService(t)
+	ctx := context.Background()
+
+	// The keys stay relative to the top level catalog
+	images, err := cs.GetCatalogImages(ctx, "holidays/2024")
+	assert.NoError(t, err)
+	assert.Len(t, images, 3)
+	assert.Contains(t, images, "2024/a.jpg")
+	assert.Contains(t, images, "2024/summer/b.jpg")
+
+	images, err = cs.SearchCatalogImages(ctx, "holidays/2024/summer", "B", ImageSearchOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, images, 1)
+	assert.Contains(t, images, "2024/summer/b.jpg")
+
+	images, err = cs.GetCatalogImages(ctx, "holidays")
+	assert.NoError(t, err)
+	assert.Len(t, images, 5)
+
+	_, err = cs.GetCatalogImages(ctx, "../holidays")
+	assert.ErrorIs(t, err, ErrCatalogNotFound)
+}
+
+func TestSplitCatalogPath(t *testing.T) {
+	tests := []struct {
+		path    string
+		catalog string
+		folder  string
+		ok      bool
+	}{
+		{"holidays", "holidays", "", true},
+		{"holidays/2024/summer", "holidays", "2024/summer", true},
+		{"/holidays/2024/", "holidays", "2024", true},
+		{"", "", "", false},
+		{"/", "", "", false},
+		{"..", "", "", false},
+		{"holidays/../..", "", "", false},
+		{"holidays/./2024", "", "", false},
+		{"holidays//2024", "", "", false},
+		{`holidays\..`, "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			catalog, folder, ok := SplitCatalogPath(tt.path)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.catalog, catalog)
+			assert.Equal(t, tt.folder, folder)
+		})
+	}
+
+	assert.Equal(t, "holidays", CatalogRoot("holidays/2024"))
+}
+
+func TestCatalogBreadcrumbs(t *testing.T) {
+	assert.Equal(t, []Breadcrumb{
+		{Name: "holidays", Path: "holidays"},
+		{Name: "2024", Path: "holidays/2024"},
+		{Name: "summer", Path: "holidays/2024/summer"},
+	}, CatalogBreadcrumbs("holidays/2024/summer/"))
+	assert.Empty(t, CatalogBreadcrumbs(""))
+}
@@ -0,0 +1,146 @@
+package services
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// FuzzyThreshold is the minimal FuzzyScore for a candidate to be considered a match
+const FuzzyThreshold = 0.7
+
+// FuzzyScore rates how well text matches query in the range [0, 1]. Both strings are split
+// into words and every query word is paired with its most similar word in the text, so the
+// score tolerates typos and a different word order. The result is the average of those
+// similarities.
+func FuzzyScore(query, text string) float64 {
+	queryTokens := tokenize(query)
+	textTokens := tokenize(text)
+	if len(queryTokens) == 0 || len(textTokens) == 0 {
+		return 0
+	}
+
+	total := 0.0
+	for _, queryToken := range queryTokens {
+		best := 0.0
+		for _, textToken := range textTokens {
+			if similarity := tokenSimilarity(queryToken, textToken); similarity > best {
+				best = similarity
+			}
+		}
+		total += best
+	}
+
+	return total / float64(len(queryTokens))
+}
+
+// tokenize splits text into lowercase words
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// tokenSimilarity compares two words. A word containing the query word scores at least 0.8,
+// growing towards 1 the more of the word it covers. Otherwise the similarity is derived from
+// the Levenshtein distance relative to the longer word.
+func tokenSimilarity(query, token string) float64 {
+	if query == token {
+		return 1
+	}
+
+	queryRunes := []rune(query)
+	tokenRunes := []rune(token)
+
+	if strings.Contains(token, query) {
+		return 0.8 + 0.2*float64(len(queryRunes))/float64(len(tokenRunes))
+	}
+
+	maxLen := max(len(queryRunes), len(tokenRunes))
+	return 1 - float64(levenshtein(queryRunes, tokenRunes))/float64(maxLen)
+}
+
+// levenshtein returns the edit distance between two strings
+func levenshtein(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
+}
+
+// matchScore rates a value against the query. Exact mode is a case-insensitive substring
+// check scoring 1 or 0, fuzzy mode returns the FuzzyScore if it reaches FuzzyThreshold.
+func matchScore(query, value string, fuzzy bool) float64 {
+	if !fuzzy {
+		if strings.Contains(strings.ToLower(value), strings.ToLower(query)) {
+			return 1
+		}
+		return 0
+	}
+
+	if score := FuzzyScore(query, value); score >= FuzzyThreshold {
+		return score
+	}
+	return 0
+}
+
+// Highlight marks a matched substring within a field of an image record. Start and End are
+// character (rune) offsets, End being exclusive.
+type Highlight struct {
+	Field string `json:"field"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+// searchableFields lists the image record fields matched against a search query
+var searchableFields = []string{"short_name", "description", "long_description", "ocr_text"}
+
+// FindHighlights returns every case-insensitive occurrence of query within the searchable
+// fields of an image record. Overlapping occurrences are all reported.
+func FindHighlights(record map[string]interface{}, query string) []Highlight {
+	highlights := []Highlight{}
+
+	queryRunes := []rune(query)
+	for i, r := range queryRunes {
+		queryRunes[i] = unicode.ToLower(r)
+	}
+	if len(queryRunes) == 0 {
+		return highlights
+	}
+
+	for _, field := range searchableFields {
+		value, ok := record[field].(string)
+		if !ok {
+			continue
+		}
+
+		// Lowercase rune by rune so offsets stay aligned with the original value
+		valueRunes := []rune(value)
+		for i, r := range valueRunes {
+			valueRunes[i] = unicode.ToLower(r)
+		}
+
+		for start := 0; start+len(queryRunes) <= len(valueRunes); start++ {
+			if slices.Equal(valueRunes[start:start+len(queryRunes)], queryRunes) {
+				highlights = append(highlights, Highlight{Field: field, Start: start, End: start + len(queryRunes)})
+			}
+		}
+	}
+
+	return highlights
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"kbase-catalog/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFuzzyScore(t *testing.T) {
+	t.Run("Typo matches in fuzzy mode only", func(t *testing.T) {
+		assert.GreaterOrEqual(t, FuzzyScore("sunst", "Sunset over the sea"), FuzzyThreshold)
+		assert.Equal(t, 0.0, matchScore("sunst", "Sunset over the sea", false))
+		assert.Greater(t, matchScore("sunst", "Sunset over the sea", true), 0.0)
+	})
+
+	t.Run("Word order does not matter", func(t *testing.T) {
+		assert.Equal(t, 1.0, FuzzyScore("sea sunset", "Sunset over the sea"))
+	})
+
+	t.Run("Unrelated text does not match", func(t *testing.T) {
+		assert.Less(t, FuzzyScore("sunst", "Streets at night"), FuzzyThreshold)
+	})
+
+	t.Run("Closer matches score higher", func(t *testing.T) {
+		exact := FuzzyScore("sunset", "sunset")
+		longer := FuzzyScore("sunset", "sunsets")
+		typo := FuzzyScore("sunset", "sunst")
+
+		assert.Greater(t, exact, longer)
+		assert.Greater(t, longer, typo)
+	})
+
+	t.Run("Empty input", func(t *testing.T) {
+		assert.Equal(t, 0.0, FuzzyScore("", "sunset"))
+		assert.Equal(t, 0.0, FuzzyScore("sunset", ""))
+	})
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"", "", 0},
+		{"sunset", "sunset", 0},
+		{"sunst", "sunset", 1},
+		{"kitten", "sitting", 3},
+		{"", "abc", 3},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, levenshtein([]rune(tt.a), []rune(tt.b)), "%s -> %s", tt.a, tt.b)
+	}
+}
+
+func TestCatalogService_SearchCatalogImages_Fuzzy(t *testing.T) {
+	archiveDir := t.TempDir()
+
+	catalogPath := filepath.Join(archiveDir, "photos")
+	assert.NoError(t, os.MkdirAll(catalogPath, 0755))
+
+	indexContent := `{
+  "beach.png": {"short_name": "Beach", "description": "Sunset over the sea"},
+  "city.png": {"short_name": "City", "description": "Streets at night"}
+}`
+	assert.NoError(t, os.WriteFile(filepath.Join(catalogPath, "index.json"), []byte(indexContent), 0644))
+
+	cs := &CatalogService{
+		Config:     &config.Config{},
+		ArchiveDir: archiveDir,
+	}
+
+	t.Run("Exact mode does not tolerate typos", func(t *testing.T) {
+		results, err := cs.SearchCatalogImages(context.Background(), "photos", "sunst", ImageSearchOptions{})
+		assert.NoError(t, err)
+		assert.Empty(t, results)
+	})
+
+	t.Run("Fuzzy mode tolerates typos", func(t *testing.T) {
+		results, err := cs.SearchCatalogImages(context.Background(), "photos", "sunst", ImageSearchOptions{Fuzzy: true})
+		assert.NoError(t, err)
+		assert.Len(t, results, 1)
+		assert.Contains(t, results, "beach.png")
+
+		record := results["beach.png"].(map[string]interface{})
+		assert.GreaterOrEqual(t, record["score"], FuzzyThreshold)
+	})
+}
+
+func TestCatalogService_SearchCatalogs_Fuzzy(t *testing.T) {
+	archiveDir := t.TempDir()
+
+	for _, catalogName := range []string{"Sunsets", "Sunset", "Cities"} {
+		catalogPath := filepath.Join(archiveDir, catalogName)
+		assert.NoError(t, os.MkdirAll(catalogPath, 0755))
+		assert.NoError(t, os.WriteFile(filepath.Join(catalogPath, "index.json"), []byte(`{"a.png": {"short_name": "A"}}`), 0644))
+	}
+
+	cs := &CatalogService{
+		Config:     &config.Config{},
+		ArchiveDir: archiveDir,
+	}
+
+	results, err := cs.SearchCatalogs(context.Background(), "sunset", true)
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+
+	// Closer matches are ranked first
+	assert.Equal(t, "Sunset", results[0]["name"])
+	assert.Equal(t, "Sunsets", results[1]["name"])
+}
+
+func TestFindHighlights(t *testing.T) {
+	t.Run("Query appearing twice in description", func(t *testing.T) {
+		record := map[string]interface{}{
+			"short_name":  "Beach",
+			"description": "Sunset over the sea, another sunset later",
+		}
+
+		highlights := FindHighlights(record, "sunset")
+		assert.Equal(t, []Highlight{
+			{Field: "description", Start: 0, End: 6},
+			{Field: "description", Start: 29, End: 35},
+		}, highlights)
+	})
+
+	t.Run("Matches in several fields", func(t *testing.T) {
+		record := map[string]interface{}{
+			"short_name":  "Sunset",
+			"description": "The sunset",
+		}
+
+		highlights := FindHighlights(record, "SUNSET")
+		assert.Equal(t, []Highlight{
+			{Field: "short_name", Start: 0, End: 6},
+			{Field: "description", Start: 4, End: 10},
+		}, highlights)
+	})
+
+	t.Run("Overlapping matches are all returned", func(t *testing.T) {
+		record := map[string]interface{}{"description": "aaaa"}
+
+		highlights := FindHighlights(record, "aa")
+		assert.Equal(t, []Highlight{
+			{Field: "description", Start: 0, End: 2},
+			{Field: "description", Start: 1, End: 3},
+			{Field: "description", Start: 2, End: 4},
+		}, highlights)
+	})
+
+	t.Run("Offsets count characters, not bytes", func(t *testing.T) {
+		record := map[string]interface{}{"description": "Закат и закат"}
+
+		highlights := FindHighlights(record, "закат")
+		assert.Equal(t, []Highlight{
+			{Field: "description", Start: 0, End: 5},
+			{Field: "description", Start: 8, End: 13},
+		}, highlights)
+	})
+}
+
+func TestCatalogService_SearchCatalogImages_Highlights(t *testing.T) {
+	archiveDir := t.TempDir()
+
+	catalogPath := filepath.Join(archiveDir, "photos")
+	assert.NoError(t, os.MkdirAll(catalogPath, 0755))
+
+	indexContent := `{"beach.png": {"short_name": "Beach", "description": "Sunset over the sea, another sunset later"}}`
+	indexPath := filepath.Join(catalogPath, "index.json")
+	assert.NoError(t, os.WriteFile(indexPath, []byte(indexContent), 0644))
+
+	cs := &CatalogService{
+		Config:     &config.Config{},
+		ArchiveDir: archiveDir,
+	}
+
+	results, err := cs.SearchCatalogImages(context.Background(), "photos", "sunset", ImageSearchOptions{})
+	assert.NoError(t, err)
+
+	record := results["beach.png"].(map[string]interface{})
+	assert.Equal(t, []Highlight{
+		{Field: "description", Start: 0, End: 6},
+		{Field: "description", Start: 29, End: 35},
+	}, record["highlights"])
+
+	// Stored data is not altered
+	content, err := os.ReadFile(indexPath)
+	assert.NoError(t, err)
+	assert.Equal(t, indexContent, string(content))
+}
+
+func TestHighlightText(t *testing.T) {
+	highlights := []Highlight{
+		{Field: "description", Start: 0, End: 2},
+		{Field: "description", Start: 1, End: 3},
+		{Field: "short_name", Start: 4, End: 5},
+	}
+
+	assert.Equal(t, "<mark>&lt;ab</mark>c d", string(highlightText("<abc d", "description", highlights)))
+	assert.Equal(t, "&lt;abc d", string(highlightText("<abc d", "short_name", nil)))
+}
@@ -1,17 +1,29 @@
 package services
 
 import (
+	"encoding/base64"
 	"html/template"
+	"io/fs"
+	"kbase-catalog/internal/config"
 	"kbase-catalog/web"
-	"log"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"path"
 	"strings"
+	"sync"
 	"time"
 )
 
 // TemplateRenderer handles template rendering operations
 type TemplateRenderer struct {
 	catalogService *CatalogService
+	// fsys is read instead of web.FS when set
+	fsys fs.FS
+
+	mu sync.Mutex
+	// templates caches the parsed templates by path
+	templates map[string]*template.Template
 }
 
 // NewTemplateRenderer creates a new template renderer instance
@@ -21,37 +33,80 @@ func NewTemplateRenderer(catalogService *CatalogService) *TemplateRenderer {
 	}
 }
 
+// loadTemplate returns the parsed template at path. Templates are parsed on first use only,
+// except when --use-fs serves them from the web directory so edits show up right away.
+func (tr *TemplateRenderer) loadTemplate(path string) (*template.Template, error) {
+	fsys := tr.fsys
+	if fsys == nil {
+		fsys = web.FS
+	}
+	if web.IsLocal() {
+		return tr.parseTemplate(fsys, path)
+	}
+
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	if tmpl, ok := tr.templates[path]; ok {
+		return tmpl, nil
+	}
+	tmpl, err := tr.parseTemplate(fsys, path)
+	if err != nil {
+		return nil, err
+	}
+	if tr.templates == nil {
+		tr.templates = make(map[string]*template.Template)
+	}
+	tr.templates[path] = tmpl
+	return tmpl, nil
+}
+
+// parseTemplate parses the template file name with the functions available to every template:
+// basePath returns the path prefix of the internal links.
+func (tr *TemplateRenderer) parseTemplate(fsys fs.FS, name string) (*template.Template, error) {
+	funcs := template.FuncMap{"basePath": tr.basePath}
+	return template.New(path.Base(name)).Funcs(funcs).ParseFS(fsys, name)
+}
+
+// basePath returns the path prefix the web interface is served under
+func (tr *TemplateRenderer) basePath() string {
+	if tr.catalogService == nil {
+		return ""
+	}
+	return tr.catalogService.Config.GetBasePath()
+}
+
 // RenderTemplate handles rendering of templates with HTMX support
 func (tr *TemplateRenderer) RenderTemplate(w http.ResponseWriter, r *http.Request, fullTemplatePath, fragmentTemplatePath string, data map[string]interface{}) error {
 	isHTMX := r.Header.Get("HX-Request") == "true"
 
 	if isHTMX && fragmentTemplatePath != "" {
 		// For HTMX requests, only render the fragment
-		tmpl, err := template.ParseFS(web.FS, fragmentTemplatePath)
+		tmpl, err := tr.loadTemplate(fragmentTemplatePath)
 		if err != nil {
-			log.Printf("Failed to load fragment template %s: %v", fragmentTemplatePath, err)
+			slog.ErrorContext(r.Context(), "Failed to load fragment template", "template", fragmentTemplatePath, "error", err)
 			http.Error(w, "Failed to load template", http.StatusInternalServerError)
 			return err
 		}
 
 		err = tmpl.Execute(w, data)
 		if err != nil {
-			log.Printf("Error executing fragment template %s: %v", fragmentTemplatePath, err)
+			slog.ErrorContext(r.Context(), "Error executing fragment template", "template", fragmentTemplatePath, "error", err)
 			http.Error(w, "Failed to execute template", http.StatusInternalServerError)
 			return err
 		}
 	} else {
 		// For regular requests, render the full template
-		tmpl, err := template.ParseFS(web.FS, fullTemplatePath)
+		tmpl, err := tr.loadTemplate(fullTemplatePath)
 		if err != nil {
-			log.Printf("Failed to load template %s: %v", fullTemplatePath, err)
+			slog.ErrorContext(r.Context(), "Failed to load template", "template", fullTemplatePath, "error", err)
 			http.Error(w, "Failed to load template", http.StatusInternalServerError)
 			return err
 		}
 
 		err = tmpl.Execute(w, data)
 		if err != nil {
-			log.Printf("Error executing template %s: %v", fullTemplatePath, err)
+			slog.ErrorContext(r.Context(), "Error executing template", "template", fullTemplatePath, "error", err)
 			http.Error(w, "Failed to execute template", http.StatusInternalServerError)
 			return err
 		}
@@ -88,16 +143,16 @@ func (tr *TemplateRenderer) RenderCatalogList(catalogs []map[string]interface{})
 		"CatalogList": formattedCatalogs,
 	}
 
-	tmpl, err := template.ParseFS(web.FS, "templates/catalog-list-template.html")
+	tmpl, err := tr.loadTemplate("templates/catalog-list-template.html")
 	if err != nil {
-		log.Printf("Failed to load catalog list template: %v", err)
+		slog.Error("Failed to load catalog list template", "error", err)
 		return ""
 	}
 
 	var html strings.Builder
 	err = tmpl.Execute(&html, data)
 	if err != nil {
-		log.Printf("Error executing catalog list template: %v", err)
+		slog.Error("Error executing catalog list template", "error", err)
 		return ""
 	}
 
@@ -111,28 +166,101 @@ func (tr *TemplateRenderer) RenderCatalogNavigation(catalogs []map[string]interf
 		"CurrentCatalog":    current,
 	}
 
-	tmpl, err := template.ParseFS(web.FS, "templates/catalog-navigation-template.html")
+	tmpl, err := tr.loadTemplate("templates/catalog-navigation-template.html")
 	if err != nil {
-		log.Printf("Failed to load catalog navigation template: %v", err)
+		slog.Error("Failed to load catalog navigation template", "error", err)
 		return ""
 	}
 
 	var html strings.Builder
 	err = tmpl.Execute(&html, data)
 	if err != nil {
-		log.Printf("Error executing catalog navigation template: %v", err)
+		slog.Error("Error executing catalog navigation template", "error", err)
 		return ""
 	}
 
 	return template.HTML(html.String())
 }
 
-// RenderCatalogImages renders HTML for catalog images using a template
+// RenderCatalogImages renders HTML for catalog images using a template. When catalogName is
+// empty the images come from several catalogs and each one must carry its own "catalog" field.
 func (tr *TemplateRenderer) RenderCatalogImages(catalogImages []map[string]interface{}, catalogName string) template.HTML {
-	// Format the data as needed by templates
-	formattedImages := make([]map[string]interface{}, len(catalogImages))
-	for i, imageData := range catalogImages {
-		data := map[string]interface{}{}
+	data := map[string]interface{}{
+		"catalog":     catalogName,
+		"showCatalog": catalogName == "",
+		"images":      formatImages(catalogImages, catalogName, tr.archiveBaseURL()),
+	}
+
+	tmpl, err := tr.loadTemplate("templates/catalog-images-template.html")
+	if err != nil {
+		slog.Error("Failed to load catalog images template", "error", err)
+		return ""
+	}
+
+	var html strings.Builder
+	err = tmpl.Execute(&html, data)
+	if err != nil {
+		slog.Error("Error executing catalog images template", "error", err)
+		return ""
+	}
+
+	return template.HTML(html.String())
+}
+
+// RenderSearchImages renders HTML for image search results, marking the matched parts of
+// the title and description using the "highlights" computed by the search
+func (tr *TemplateRenderer) RenderSearchImages(searchImages []map[string]interface{}, catalogName string) template.HTML {
+	formattedImages := formatImages(searchImages, catalogName, tr.archiveBaseURL())
+	for i, imageData := range searchImages {
+		highlights, _ := imageData["highlights"].([]Highlight)
+		formattedImages[i]["alt"] = formattedImages[i]["title"]
+		formattedImages[i]["title"] = highlightText(formattedImages[i]["title"].(string), "short_name", highlights)
+		formattedImages[i]["description"] = highlightText(formattedImages[i]["description"].(string), "description", highlights)
+	}
+
+	data := map[string]interface{}{
+		"catalog":     catalogName,
+		"showCatalog": catalogName == "",
+		"images":      formattedImages,
+	}
+
+	tmpl, err := tr.loadTemplate("templates/search-images-template.html")
+	if err != nil {
+		slog.Error("Failed to load search images template", "error", err)
+		return ""
+	}
+
+	var html strings.Builder
+	err = tmpl.Execute(&html, data)
+	if err != nil {
+		slog.Error("Error executing search images template", "error", err)
+		return ""
+	}
+
+	return template.HTML(html.String())
+}
+
+// archiveBaseURL returns the prefix of the image URLs, paths being under the base path
+func (tr *TemplateRenderer) archiveBaseURL() string {
+	if tr.catalogService == nil {
+		return config.DefaultArchiveBaseURL
+	}
+	baseURL := tr.catalogService.Config.GetArchiveBaseURL()
+	if strings.HasPrefix(baseURL, "/") {
+		baseURL = tr.basePath() + baseURL
+	}
+	return baseURL
+}
+
+// formatImages prepares image records for the image templates, the image URLs starting
+// with baseURL
+func formatImages(images []map[string]interface{}, catalogName, baseURL string) []map[string]interface{} {
+	formattedImages := make([]map[string]interface{}, len(images))
+	for i, imageData := range images {
+		data := map[string]interface{}{
+			"title":       "",
+			"description": "",
+		}
 
 		if filename, ok := imageData["filename"].(string); ok {
 			shortName := filename
@@ -149,26 +277,77 @@ func (tr *TemplateRenderer) RenderCatalogImages(catalogImages []map[string]inter
 			data["filename"] = filename
 			data["title"] = shortName
 			data["description"] = description
+			data["tags"] = imageData["tags"]
+			if longDescription, ok := imageData["long_description"].(string); ok && longDescription != "" {
+				data["long_description"] = longDescription
+			}
+			catalog := catalogName
+			if catalog == "" {
+				catalog, _ = imageData["catalog"].(string)
+			}
+			data["catalog"] = catalog
+			data["src"] = ArchiveImageURL(baseURL, catalog, filename)
+			if thumbnail, ok := imageData["thumbnail"].(string); ok && isThumbnailURL(thumbnail) {
+				data["thumbnail"] = template.URL(thumbnail)
+			}
 		}
 		formattedImages[i] = data
 	}
+	return formattedImages
+}
 
-	data := map[string]interface{}{
-		"catalog": catalogName,
-		"images":  formattedImages,
+// isThumbnailURL reports whether value is a base64 image data URL like the thumbnails of
+// embed_thumbnails, which html/template would otherwise refuse as an unsafe URL
+func isThumbnailURL(value string) bool {
+	for _, prefix := range []string{"data:image/jpeg;base64,", "data:image/png;base64,", "data:image/webp;base64,"} {
+		if encoded, ok := strings.CutPrefix(value, prefix); ok {
+			_, err := base64.StdEncoding.DecodeString(encoded)
+			return err == nil
+		}
 	}
+	return false
+}
 
-	tmpl, err := template.ParseFS(web.FS, "templates/catalog-images-template.html")
-	if err != nil {
-		log.Printf("Failed to load catalog images template: %v", err)
-		return ""
+// ArchiveImageURL builds the URL of an image under baseURL, see Config.GetArchiveBaseURL, from
+// its catalog and index key. Every path segment is escaped on its own, so keys of images in
+// subfolders (a/x.jpg) keep their slashes while names with spaces, '#' or '?' still resolve
+// to the file.
+func ArchiveImageURL(baseURL, catalog, key string) string {
+	segments := append([]string{catalog}, strings.Split(key, "/")...)
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return baseURL + "/" + strings.Join(segments, "/")
+}
+
+// highlightText escapes text and wraps the ranges highlighted in field with <mark> tags.
+// Overlapping and adjacent ranges are merged into a single mark.
+func highlightText(text string, field string, highlights []Highlight) template.HTML {
+	runes := []rune(text)
+	marked := make([]bool, len(runes))
+	for _, highlight := range highlights {
+		if highlight.Field != field {
+			continue
+		}
+		for i := max(highlight.Start, 0); i < min(highlight.End, len(runes)); i++ {
+			marked[i] = true
+		}
 	}
 
 	var html strings.Builder
-	err = tmpl.Execute(&html, data)
-	if err != nil {
-		log.Printf("Error executing catalog images template: %v", err)
-		return ""
+	for i := 0; i < len(runes); {
+		j := i
+		for j < len(runes) && marked[j] == marked[i] {
+			j++
+		}
+
+		segment := template.HTMLEscapeString(string(runes[i:j]))
+		if marked[i] {
+			html.WriteString("<mark>" + segment + "</mark>")
+		} else {
+			html.WriteString(segment)
+		}
+		i = j
 	}
 
 	return template.HTML(html.String())
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"html/template"
+	"io/fs"
+	"sync/atomic"
+	"testing"
+
+	"kbase-catalog/internal/config"
+	"kbase-catalog/web"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countingFS counts the files opened in the wrapped filesystem
+type countingFS struct {
+	fs.FS
+	opens atomic.Int64
+}
+
+func (c *countingFS) Open(name string) (fs.File, error) {
+	c.opens.Add(1)
+	return c.FS.Open(name)
+}
+
+func TestTemplateRenderer_CachesTemplates(t *testing.T) {
+	web.InitTemplateFS(false, "")
+	counting := &countingFS{FS: web.FS}
+	tr := &TemplateRenderer{fsys: counting}
+	catalogs := []map[string]interface{}{{"name": "shapes"}}
+
+	first := tr.RenderCatalogList(catalogs)
+	assert.Contains(t, string(first), "shapes")
+	opens := counting.opens.Load()
+	assert.Positive(t, opens)
+
+	for range 50 {
+		assert.Equal(t, first, tr.RenderCatalogList(catalogs))
+	}
+	assert.Equal(t, opens, counting.opens.Load(), "the template is parsed once")
+
+	tr.RenderCatalogNavigation(catalogs, "shapes")
+	assert.Greater(t, counting.opens.Load(), opens, "other templates are parsed on first use")
+}
+
+func TestTemplateRenderer_LocalTemplatesNotCached(t *testing.T) {
+	web.InitTemplateFS(false, "")
+	counting := &countingFS{FS: web.FS}
+	web.InitTemplateFS(true, "")
+	defer web.InitTemplateFS(false, "")
+	tr := &TemplateRenderer{fsys: counting}
+
+	tr.RenderCatalogList(nil)
+	opens := counting.opens.Load()
+	tr.RenderCatalogList(nil)
+	assert.Equal(t, 2*opens, counting.opens.Load(), "--use-fs parses the template on every render")
+}
+
+func BenchmarkTemplateRenderer_RenderCatalogList(b *testing.B) {
+	web.InitTemplateFS(false, "")
+	tr := &TemplateRenderer{}
+	catalogs := []map[string]interface{}{{"name": "shapes"}, {"name": "photos"}}
+
+	for b.Loop() {
+		tr.RenderCatalogList(catalogs)
+	}
+}
+
+func TestFormatImages_Src(t *testing.T) {
+	images := []map[string]interface{}{
+		{"filename": "a/x.jpg", "short_name": "First"},
+		{"filename": "b/x.jpg", "short_name": "Second"},
+		{"filename": "photo #1.jpg"},
+	}
+
+	formatted := formatImages(images, "Holidays 2024", config.DefaultArchiveBaseURL)
+
+	// Equally named images of different subfolders point to different files
+	assert.Equal(t, "/archive/Holidays%202024/a/x.jpg", formatted[0]["src"])
+	assert.Equal(t, "/archive/Holidays%202024/b/x.jpg", formatted[1]["src"])
+	assert.Equal(t, "/archive/Holidays%202024/photo%20%231.jpg", formatted[2]["src"])
+	assert.Equal(t, "photo #1.jpg", formatted[2]["title"])
+}
+
+func TestTemplateRenderer_ArchiveBaseURL(t *testing.T) {
+	web.InitTemplateFS(false, "")
+	images := []map[string]interface{}{{"filename": "a/x 1.jpg", "short_name": "First"}}
+
+	for _, tt := range []struct {
+		baseURL  string
+		expected string
+	}{
+		{"", `src="/archive/shapes/a/x%201.jpg"`},
+		{"https://cdn.example.com/images/", `src="https://cdn.example.com/images/shapes/a/x%201.jpg"`},
+		{"/kbase/archive", `src="/kbase/archive/shapes/a/x%201.jpg"`},
+	} {
+		tr := NewTemplateRenderer(&CatalogService{Config: &config.Config{ArchiveBaseURL: tt.baseURL}})
+		assert.Contains(t, string(tr.RenderCatalogImages(images, "shapes")), tt.expected, tt.baseURL)
+	}
+}
+
+func TestFormatImages_SearchResults(t *testing.T) {
+	// Search results carry their own catalog
+	images := []map[string]interface{}{
+		{"filename": "a/x.jpg", "catalog": "shapes"},
+	}
+
+	formatted := formatImages(images, "", config.DefaultArchiveBaseURL)
+
+	assert.Equal(t, "shapes", formatted[0]["catalog"])
+	assert.Equal(t, "/archive/shapes/a/x.jpg", formatted[0]["src"])
+}
+
+func TestFormatImages_LongDescription(t *testing.T) {
+	images := []map[string]interface{}{
+		{"filename": "a.jpg", "short_name": "A", "description": "Short.", "long_description": "A much longer paragraph."},
+		{"filename": "b.jpg", "short_name": "B", "description": "Short."},
+	}
+
+	formatted := formatImages(images, "shapes", config.DefaultArchiveBaseURL)
+
+	assert.Equal(t, "A", formatted[0]["title"])
+	assert.Equal(t, "Short.", formatted[0]["description"])
+	assert.Equal(t, "A much longer paragraph.", formatted[0]["long_description"])
+	assert.NotContains(t, formatted[1], "long_description")
+}
+
+func TestTemplateRenderer_EscapesRecords(t *testing.T) {
+	web.InitTemplateFS(false, "")
+	tr := NewTemplateRenderer(nil)
+	malicious := `"><script>alert(1)</script>`
+	images := []map[string]interface{}{
+		{"filename": malicious + ".png", "short_name": malicious, "description": malicious, "long_description": malicious, "tags": []interface{}{malicious}},
+	}
+
+	rendered := map[string]template.HTML{
+		"catalog images": tr.RenderCatalogImages(images, malicious),
+		"search images":  tr.RenderSearchImages(images, ""),
+	}
+	for name, html := range rendered {
+		assert.NotEmpty(t, html, name)
+		assert.NotContains(t, string(html), "<script>", name)
+		assert.NotContains(t, string(html), `">`+"<script", name)
+		assert.Contains(t, string(html), "&lt;script&gt;", name)
+	}
+}
+
+func TestFormatImages_Thumbnail(t *testing.T) {
+	images := []map[string]interface{}{
+		{"filename": "a.jpg", "thumbnail": "data:image/jpeg;base64,/9j/4AAQ"},
+		{"filename": "b.jpg", "thumbnail": "javascript:alert(1)"},
+		{"filename": "c.jpg", "thumbnail": "data:image/jpeg;base64,not base64!"},
+		{"filename": "d.jpg"},
+	}
+
+	formatted := formatImages(images, "shapes", config.DefaultArchiveBaseURL)
+
+	assert.Equal(t, template.URL("data:image/jpeg;base64,/9j/4AAQ"), formatted[0]["thumbnail"])
+	for _, image := range formatted[1:] {
+		assert.NotContains(t, image, "thumbnail")
+	}
+}
@@ -3,25 +3,35 @@ package watch
 import (
 	"context"
 	"kbase-catalog/internal/utils"
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"kbase-catalog/internal/processor"
 	"kbase-catalog/internal/webserver/queue"
 
 	"github.com/fsnotify/fsnotify"
 )
 
+// IgnoreReloader drops the cached ignore files of the archive, implemented by
+// processor.CatalogProcessor
+type IgnoreReloader interface {
+	ReloadIgnoreFiles()
+}
+
 // CatalogWatcher monitors file system changes in the archive directory
 type CatalogWatcher struct {
 	watcher    *fsnotify.Watcher
 	queue      *queue.TaskQueue
+	ignores    IgnoreReloader
 	ctx        context.Context
 	cancel     context.CancelFunc
-	isRunning  bool
+	isRunning  atomic.Bool
 	archiveDir string
+	logger     *slog.Logger
 }
 
 // NewCatalogWatcher creates a new catalog watcher
@@ -39,19 +49,24 @@ func NewCatalogWatcher(queue *queue.TaskQueue, archivePath string) (*CatalogWatc
 		queue:      queue,
 		ctx:        ctx,
 		cancel:     cancel,
-		isRunning:  false,
 		archiveDir: archivePath,
+		logger:     slog.Default(),
 	}, nil
 }
 
+// SetIgnoreReloader installs the reloader told about changed ignore files
+func (cw *CatalogWatcher) SetIgnoreReloader(ignores IgnoreReloader) {
+	cw.ignores = ignores
+}
+
 // Start starts the catalog watcher
 func (cw *CatalogWatcher) Start() error {
-	cw.isRunning = true
+	cw.isRunning.Store(true)
 
 	// Add the archive directory and all subdirectories to watch
 	err := cw.addDirectoriesToWatch(cw.archiveDir)
 	if err != nil {
-		log.Printf("Failed to add directories for watching: %v", err)
+		cw.logger.Error("Failed to add directories for watching", "error", err)
 		return err
 	}
 
@@ -72,7 +87,7 @@ func (cw *CatalogWatcher) Start() error {
 				if !ok {
 					return
 				}
-				log.Printf("Watcher error: %v", err)
+				cw.logger.Error("Watcher error", "error", err)
 
 			case <-cw.ctx.Done():
 				cw.watcher.Close()
@@ -87,16 +102,21 @@ func (cw *CatalogWatcher) Start() error {
 // Stop stops the catalog watcher
 func (cw *CatalogWatcher) Stop() error {
 	cw.cancel()
-	cw.isRunning = false
+	cw.isRunning.Store(false)
 	return cw.watcher.Close()
 }
 
+// IsRunning reports whether the watcher was started
+func (cw *CatalogWatcher) IsRunning() bool {
+	return cw.isRunning.Load()
+}
+
 // addDirectoriesToWatch recursively adds all directories to watch for changes
 func (cw *CatalogWatcher) addDirectoriesToWatch(rootDir string) error {
 	// First, add the root directory itself
 	err := cw.watcher.Add(rootDir)
 	if err != nil {
-		log.Printf("Failed to add root directory %s to watcher: %v", rootDir, err)
+		cw.logger.Error("Failed to add root directory to watcher", "path", rootDir, "error", err)
 		return err
 	}
 
@@ -110,7 +130,7 @@ func (cw *CatalogWatcher) addDirectoriesToWatch(rootDir string) error {
 		if info.IsDir() && path != rootDir {
 			err := cw.watcher.Add(path)
 			if err != nil {
-				log.Printf("Failed to add directory %s to watcher: %v", path, err)
+				cw.logger.Warn("Failed to add directory to watcher", "path", path, "error", err)
 				// Don't return error here - continue with other directories
 			}
 		}
@@ -126,18 +146,27 @@ func (cw *CatalogWatcher) handleFileChange(filePath string) {
 	isDir := utils.IsDirectory(filePath)
 	filePath, err := filepath.Rel(cw.archiveDir, filePath)
 	if err != nil {
-		log.Printf("Error getting relative path: %s", filePath)
+		cw.logger.Error("Error getting relative path", "path", filePath, "error", err)
 		return
 	}
 
-	catalogName := filepath.Base(filePath)
+	// The catalog is the top level directory, images and folders may be nested below it
+	catalogName := strings.Split(filepath.ToSlash(filePath), "/")[0]
 
-	if !isDir {
+	if filepath.Base(filePath) == processor.IgnoreFileName {
+		if cw.ignores != nil {
+			cw.ignores.ReloadIgnoreFiles()
+		}
+		// The ignore file of the archive root applies from the next reindex of each catalog
+		if catalogName == filepath.ToSlash(filePath) {
+			return
+		}
+	} else if !isDir {
 		// Check if the file is an image file
 		ext := strings.ToLower(filepath.Ext(filePath))
 		if ext != "" {
 			// Only process supported image extensions
-			supportedExtensions := []string{".png", ".jpg", ".jpeg", ".webp", ".gif", ".bmp"}
+			supportedExtensions := []string{".png", ".jpg", ".jpeg", ".webp", ".gif", ".bmp", ".tif", ".tiff"}
 
 			// Check if this is a file with a supported extension
 			isImageFile := false
@@ -158,7 +187,7 @@ func (cw *CatalogWatcher) handleFileChange(filePath string) {
 
 			// Make sure we have enough parts to extract the catalog name
 			if len(parts) < 2 {
-				log.Printf("Invalid file path structure: %s", filePath)
+				cw.logger.Warn("Invalid file path structure", "path", filePath)
 				return
 			}
 
@@ -171,7 +200,7 @@ func (cw *CatalogWatcher) handleFileChange(filePath string) {
 		// Small delay to ensure file write is complete
 		time.Sleep(200 * time.Millisecond)
 		if err := cw.queue.AddTask(catalogName, "watcher"); err != nil {
-			log.Printf("Failed to add reindex task for catalog %s: %v", catalogName, err)
+			cw.logger.Error("Failed to add reindex task", "catalog", catalogName, "error", err)
 		}
 	}()
 }
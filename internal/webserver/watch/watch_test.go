@@ -3,6 +3,7 @@ package watch
 import (
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -19,7 +20,7 @@ func TestNewCatalogWatcher(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotNil(t, watcher)
 	assert.Equal(t, tempDir, watcher.archiveDir)
-	assert.False(t, watcher.isRunning)
+	assert.False(t, watcher.isRunning.Load())
 
 	// Test with empty archive path
 	watcher2, err := NewCatalogWatcher(nil, "")
@@ -41,12 +42,12 @@ func TestCatalogWatcher_Start(t *testing.T) {
 	// Test starting the watcher
 	err = watcher.Start()
 	assert.NoError(t, err)
-	assert.True(t, watcher.isRunning)
+	assert.True(t, watcher.isRunning.Load())
 
 	// Try to start again - should not error but do nothing
 	err = watcher.Start()
 	assert.NoError(t, err)
-	assert.True(t, watcher.isRunning)
+	assert.True(t, watcher.isRunning.Load())
 
 	// Clean up
 	watcher.Stop()
@@ -65,17 +66,38 @@ func TestCatalogWatcher_Stop(t *testing.T) {
 	// Start the watcher first
 	err = watcher.Start()
 	assert.NoError(t, err)
-	assert.True(t, watcher.isRunning)
+	assert.True(t, watcher.isRunning.Load())
 
 	// Test stopping the watcher
 	err = watcher.Stop()
 	assert.NoError(t, err)
-	assert.False(t, watcher.isRunning)
+	assert.False(t, watcher.isRunning.Load())
 
 	// Try to stop again - should not error but do nothing
 	err = watcher.Stop()
 	assert.NoError(t, err)
-	assert.False(t, watcher.isRunning)
+	assert.False(t, watcher.isRunning.Load())
+}
+
+// TestCatalogWatcher_IsRunning tests that the readiness probe can read the state while the
+// watcher starts and stops, run with -race
+func TestCatalogWatcher_IsRunning(t *testing.T) {
+	watcher, err := NewCatalogWatcher(nil, t.TempDir())
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range 100 {
+			watcher.IsRunning()
+		}
+	}()
+
+	assert.NoError(t, watcher.Start())
+	assert.NoError(t, watcher.Stop())
+	wg.Wait()
+	assert.False(t, watcher.IsRunning())
 }
 
 func TestCatalogWatcher_addDirectoriesToWatch(t *testing.T) {
@@ -141,3 +163,31 @@ func TestCatalogWatcher_handleFileChange(t *testing.T) {
 	invalidPath := filepath.Join(tempDir, "nonexistent", "test.png")
 	watcher.handleFileChange(invalidPath)
 }
+
+// countingReloader counts the ignore file reloads
+type countingReloader struct {
+	reloads int
+}
+
+func (r *countingReloader) ReloadIgnoreFiles() {
+	r.reloads++
+}
+
+func TestCatalogWatcher_handleFileChange_IgnoreFile(t *testing.T) {
+	tempDir := t.TempDir()
+	ignoreFile := filepath.Join(tempDir, ".kbaseignore")
+	assert.NoError(t, os.WriteFile(ignoreFile, []byte("*.raw\n"), 0644))
+
+	watcher, err := NewCatalogWatcher(nil, tempDir)
+	assert.NoError(t, err)
+	reloader := &countingReloader{}
+	watcher.SetIgnoreReloader(reloader)
+
+	// The root ignore file is reloaded without queueing a task, the nil queue would panic
+	watcher.handleFileChange(ignoreFile)
+	assert.Equal(t, 1, reloader.reloads)
+
+	// Other files don't reload it
+	watcher.handleFileChange(filepath.Join(tempDir, "notes.txt"))
+	assert.Equal(t, 1, reloader.reloads)
+}
@@ -0,0 +1,39 @@
+package web
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// templatesPrefix is the directory of the templates in FS
+const templatesPrefix = "templates/"
+
+// templateOverlay serves the templates of an override directory over the ones of a base
+// filesystem. Templates missing from the override directory and other files come from base.
+type templateOverlay struct {
+	override fs.FS
+	base     fs.FS
+}
+
+// NewTemplateOverlay returns base with templates/<name> taken from dir when dir has a file
+// of that name
+func NewTemplateOverlay(dir string, base fs.FS) fs.FS {
+	return &templateOverlay{override: os.DirFS(dir), base: base}
+}
+
+// Open opens name from the override directory when it's an overridden template, from the
+// base filesystem otherwise
+func (o *templateOverlay) Open(name string) (fs.File, error) {
+	if template, ok := strings.CutPrefix(name, templatesPrefix); ok {
+		file, err := o.override.Open(template)
+		if err == nil {
+			return file, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return o.base.Open(name)
+}
@@ -0,0 +1,59 @@
+package web
+
+import (
+	"bytes"
+	"html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTemplateOverlay(t *testing.T) {
+	dir := t.TempDir()
+	custom := `<h1>Custom {{.title}}</h1>`
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), []byte(custom), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	overlay := NewTemplateOverlay(dir, embedFS)
+
+	tmpl, err := template.ParseFS(overlay, "templates/index.html")
+	if err != nil {
+		t.Fatalf("ParseFS() error = %v", err)
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, map[string]string{"title": "catalog"}); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if out.String() != "<h1>Custom catalog</h1>" {
+		t.Errorf("overridden template rendered %q", out.String())
+	}
+
+	// Templates and files that aren't overridden stay embedded
+	for _, name := range []string{"templates/catalog-list-template.html", "static/styles.css"} {
+		got, err := fs.ReadFile(overlay, name)
+		if err != nil {
+			t.Fatalf("ReadFile(%q) error = %v", name, err)
+		}
+		want, _ := embedFS.ReadFile(name)
+		if !bytes.Equal(got, want) {
+			t.Errorf("ReadFile(%q) didn't return the embedded file", name)
+		}
+	}
+}
+
+func TestInitTemplateFS_TemplateDir(t *testing.T) {
+	defer InitTemplateFS(false, "")
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), []byte("custom"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	InitTemplateFS(false, dir)
+	content, err := fs.ReadFile(FS, "templates/index.html")
+	if err != nil || string(content) != "custom" {
+		t.Errorf("ReadFile() = %q, %v, want the overridden template", content, err)
+	}
+}
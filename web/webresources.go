@@ -19,8 +19,9 @@ var localFS fs.FS
 
 var useLocal bool
 
-// InitTemplateFS initializes the template filesystem based on environment variable
-func InitTemplateFS(useLocalFileSystem bool) {
+// InitTemplateFS initializes the template filesystem based on environment variable. The
+// templates of templateDir, when set, override the ones of the web directory.
+func InitTemplateFS(useLocalFileSystem bool, templateDir string) {
 	useLocal = useLocalFileSystem
 	if useLocal {
 		localFS = os.DirFS("web")
@@ -28,6 +29,14 @@ func InitTemplateFS(useLocalFileSystem bool) {
 	} else {
 		FS = embedFS
 	}
+	if templateDir != "" {
+		FS = NewTemplateOverlay(templateDir, FS)
+	}
+}
+
+// IsLocal reports whether FS reads the web directory, as set by InitTemplateFS
+func IsLocal() bool {
+	return useLocal
 }
 
 // getContentType returns the appropriate content type for a file path
@@ -58,7 +67,7 @@ func getContentType(path string) string {
 // HandleEmbeddedFile serves a file from the embedded filesystem
 func HandleEmbeddedFile(w http.ResponseWriter, r *http.Request) {
 	realPath := strings.TrimPrefix(r.URL.Path, "/")
-	if realPath == "" {
+	if realPath == "" || !fs.ValidPath(realPath) {
 		http.NotFound(w, r)
 		return
 	}